@@ -0,0 +1,302 @@
+package document
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"DOC/domain"
+)
+
+const (
+	recentDefaultLimit = 20
+	recentMaxLimit     = 100
+	recentCandidates   = 3 // 候选文档数为返回数的倍数，为权限过滤掉的文档留出余量
+)
+
+// documentAccessService 文档访问业务逻辑实现
+// 实现 domain.DocumentAccessUsecase 接口；访问先写入 Redis 的有序集合，由工作者定期合并写入数据库，
+// 最近文档列表合并 Redis 中尚未持久化的访问、数据库中的访问记录以及共享给用户的文档
+type documentAccessService struct {
+	cache          domain.DocumentAccessCacheRepository
+	accessRepo     domain.DocumentAccessRepository
+	documentRepo   domain.DocumentRepository
+	permissionRepo domain.DocumentPermissionRepository
+	shareRepo      domain.DocumentShareRepository
+	spaceRepo      domain.SpaceRepository
+}
+
+// RecordAccess 记录用户查看或编辑文档，失败只记录日志
+func (d *documentAccessService) RecordAccess(ctx context.Context, userID, documentID int64, action domain.DocumentAccessAction) {
+	if userID <= 0 || documentID <= 0 || !action.IsValid() {
+		return
+	}
+
+	event := &domain.DocumentAccessEvent{
+		UserID:     userID,
+		DocumentID: documentID,
+		Action:     action,
+		AccessedAt: time.Now(),
+	}
+	if err := d.cache.Record(ctx, event); err != nil {
+		log.Printf("记录文档访问失败: userID=%d, documentID=%d, action=%s, err=%v", userID, documentID, action, err)
+	}
+}
+
+// GetRecentDocuments 获取用户最近打开、编辑以及被共享的文档
+// 共享的时间取授予权限或加入私有分享的时间，同一文档取最近一次活动排序
+func (d *documentAccessService) GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*domain.RecentDocument, error) {
+	if userID <= 0 {
+		return nil, domain.ErrInvalidUser
+	}
+	if limit <= 0 {
+		limit = recentDefaultLimit
+	}
+	if limit > recentMaxLimit {
+		limit = recentMaxLimit
+	}
+	candidateCount := limit * recentCandidates
+
+	// 1. 收集打开、编辑和共享的文档
+	recent := make(map[int64]*domain.RecentDocument)
+	entry := func(documentID int64) *domain.RecentDocument {
+		item, ok := recent[documentID]
+		if !ok {
+			item = &domain.RecentDocument{Document: &domain.Document{ID: documentID}} // 过滤后替换为完整的文档
+			recent[documentID] = item
+		}
+		return item
+	}
+
+	if err := d.collectAccesses(ctx, userID, candidateCount, entry); err != nil {
+		return nil, err
+	}
+	if err := d.collectShared(ctx, userID, entry); err != nil {
+		return nil, err
+	}
+	if len(recent) == 0 {
+		return []*domain.RecentDocument{}, nil
+	}
+
+	// 2. 按最近一次活动排序并截取候选文档
+	candidates := make([]*domain.RecentDocument, 0, len(recent))
+	for _, item := range recent {
+		setRecentActivity(item)
+		candidates = append(candidates, item)
+	}
+	sortRecentDocuments(candidates)
+	if len(candidates) > candidateCount {
+		candidates = candidates[:candidateCount]
+	}
+
+	// 3. 按权限、分享和空间成员关系过滤，权限被撤销、分享过期或已删除的文档被排除
+	ids := make([]int64, len(candidates))
+	for i, item := range candidates {
+		ids[i] = item.Document.ID
+	}
+	visible, err := d.visibleDocuments(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*domain.RecentDocument, 0, limit)
+	for _, item := range candidates {
+		document, ok := visible[item.Document.ID]
+		if !ok {
+			continue
+		}
+		item.Document = document
+		results = append(results, item)
+		if len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// RecentDocumentIDs 按访问时间倒序获取用户最近打开或编辑的文档ID
+// 只读取 Redis，供每次按键都会查询的快速切换加权使用
+func (d *documentAccessService) RecentDocumentIDs(ctx context.Context, userID int64, limit int) ([]int64, error) {
+	latest := make(map[int64]time.Time)
+	for _, action := range []domain.DocumentAccessAction{domain.DocumentAccessView, domain.DocumentAccessEdit} {
+		events, err := d.cache.GetRecent(ctx, userID, action, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			if event.AccessedAt.After(latest[event.DocumentID]) {
+				latest[event.DocumentID] = event.AccessedAt
+			}
+		}
+	}
+
+	ids := make([]int64, 0, len(latest))
+	for id := range latest {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if !latest[ids[i]].Equal(latest[ids[j]]) {
+			return latest[ids[i]].After(latest[ids[j]])
+		}
+		return ids[i] > ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// Flush 将 Redis 中等待持久化的访问写入数据库
+// 写入失败时访问留在 Redis 中，下次重试
+func (d *documentAccessService) Flush(ctx context.Context) (int, error) {
+	events, err := d.cache.TakePending(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(events) > 0 {
+		if err := d.accessRepo.Upsert(ctx, events); err != nil {
+			return 0, err
+		}
+	}
+	if err := d.cache.AckPending(ctx); err != nil {
+		return len(events), err
+	}
+	return len(events), nil
+}
+
+// collectAccesses 合并 Redis 中的最近访问和数据库中的访问记录
+// Redis 不可用时只使用数据库中的记录，最近一个持久化间隔内的访问缺失
+func (d *documentAccessService) collectAccesses(ctx context.Context, userID int64, limit int, entry func(int64) *domain.RecentDocument) error {
+	for _, action := range []domain.DocumentAccessAction{domain.DocumentAccessView, domain.DocumentAccessEdit} {
+		events, err := d.cache.GetRecent(ctx, userID, action, limit)
+		if err != nil {
+			log.Printf("读取最近访问失败: userID=%d, action=%s, err=%v", userID, action, err)
+			continue
+		}
+		for _, event := range events {
+			item := entry(event.DocumentID)
+			accessedAt := event.AccessedAt
+			if action == domain.DocumentAccessView {
+				item.LastViewedAt = latestTime(item.LastViewedAt, &accessedAt)
+			} else {
+				item.LastEditedAt = latestTime(item.LastEditedAt, &accessedAt)
+			}
+		}
+	}
+
+	accesses, err := d.accessRepo.GetRecentByUser(ctx, userID, limit)
+	if err != nil {
+		return err
+	}
+	for _, access := range accesses {
+		item := entry(access.DocumentID)
+		item.LastViewedAt = latestTime(item.LastViewedAt, access.LastViewedAt)
+		item.LastEditedAt = latestTime(item.LastEditedAt, access.LastEditedAt)
+	}
+	return nil
+}
+
+// collectShared 收集他人通过权限或未过期的私有分享共享给用户的文档
+func (d *documentAccessService) collectShared(ctx context.Context, userID int64, entry func(int64) *domain.RecentDocument) error {
+	shared := func(documentID int64, sharedAt time.Time, sharedBy int64) {
+		item := entry(documentID)
+		if item.SharedAt == nil || sharedAt.After(*item.SharedAt) {
+			item.SharedAt = &sharedAt
+			item.SharedBy = &sharedBy
+		}
+	}
+
+	permissions, err := d.permissionRepo.GetByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if permission.GrantedBy != userID && permission.CanView() {
+			shared(permission.DocumentID, permission.GrantedAt, permission.GrantedBy)
+		}
+	}
+
+	shareUsers, err := d.shareRepo.GetShareUsersByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, shareUser := range shareUsers {
+		share := shareUser.Share
+		if share == nil || share.CreatedBy == userID || share.IsExpired() {
+			continue
+		}
+		shared(share.DocumentID, shareUser.AddedAt, share.CreatedBy)
+	}
+	return nil
+}
+
+// visibleDocuments 返回候选文档中用户可以查看的未删除文档，包括已归档的文档
+func (d *documentAccessService) visibleDocuments(ctx context.Context, userID int64, ids []int64) (map[int64]*domain.Document, error) {
+	documents, err := readableDocuments(ctx, d.documentRepo, d.permissionRepo, d.shareRepo, d.spaceRepo, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make(map[int64]*domain.Document, len(documents))
+	for _, document := range documents {
+		visible[document.ID] = document
+	}
+	return visible, nil
+}
+
+// setRecentActivity 以查看、编辑和共享中最晚的一个作为最近一次活动
+func setRecentActivity(item *domain.RecentDocument) {
+	for _, activity := range []struct {
+		kind domain.RecentActivity
+		at   *time.Time
+	}{
+		{domain.RecentActivityShared, item.SharedAt},
+		{domain.RecentActivityViewed, item.LastViewedAt},
+		{domain.RecentActivityEdited, item.LastEditedAt}, // 同一时刻的查看和编辑视为编辑
+	} {
+		if activity.at != nil && !activity.at.Before(item.ActivityAt) {
+			item.Activity = activity.kind
+			item.ActivityAt = *activity.at
+		}
+	}
+}
+
+// sortRecentDocuments 按最近一次活动倒序排序，时间相同时按文档ID倒序
+func sortRecentDocuments(items []*domain.RecentDocument) {
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].ActivityAt.Equal(items[j].ActivityAt) {
+			return items[i].ActivityAt.After(items[j].ActivityAt)
+		}
+		return items[i].Document.ID > items[j].Document.ID
+	})
+}
+
+// latestTime 返回两个可为空的时间中较晚的一个
+func latestTime(a, b *time.Time) *time.Time {
+	if a == nil {
+		return b
+	}
+	if b == nil || a.After(*b) {
+		return a
+	}
+	return b
+}
+
+// NewDocumentAccessService 创建新的文档访问服务实例
+func NewDocumentAccessService(
+	cache domain.DocumentAccessCacheRepository,
+	accessRepo domain.DocumentAccessRepository,
+	documentRepo domain.DocumentRepository,
+	permissionRepo domain.DocumentPermissionRepository,
+	shareRepo domain.DocumentShareRepository,
+	spaceRepo domain.SpaceRepository) domain.DocumentAccessUsecase {
+	return &documentAccessService{
+		cache:          cache,
+		accessRepo:     accessRepo,
+		documentRepo:   documentRepo,
+		permissionRepo: permissionRepo,
+		shareRepo:      shareRepo,
+		spaceRepo:      spaceRepo,
+	}
+}
@@ -2,20 +2,34 @@ package document
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"DOC/domain"
+	"DOC/pkg/search"
+)
+
+const (
+	searchSnippetWidth = 120 // 搜索结果摘要的字符数
+	searchMaxSnippets  = 3   // 每个搜索结果最多的摘要段数
 )
 
 // documentAggregateService 文档聚合服务实现
 // 作为文档聚合根的协调服务，整合文档核心操作、分享、权限、收藏等功能
 // 实现 domain.DocumentAggregateService 接口
 type documentAggregateService struct {
-	documentUsecase domain.DocumentUsecase           // 文档核心业务
-	shareUsecase    domain.DocumentShareUsecase      // 分享子域
-	permUsecase     domain.DocumentPermissionUsecase // 权限子域
-	favoriteUsecase domain.DocumentFavoriteUsecase   // 收藏子域
-	userRepo        domain.UserRepository            // 用户仓储
+	documentUsecase  domain.DocumentUsecase            // 文档核心业务
+	shareUsecase     domain.DocumentShareUsecase       // 分享子域
+	permUsecase      domain.DocumentPermissionUsecase  // 权限子域
+	favoriteUsecase  domain.DocumentFavoriteUsecase    // 收藏子域
+	versionUsecase   domain.DocumentVersionUsecase     // 版本子域
+	trashUsecase     domain.DocumentTrashUsecase       // 回收站子域
+	searchUsecase    domain.DocumentSearchUsecase      // 搜索子域
+	quickSwitch      domain.DocumentQuickSwitchUsecase // 快速切换
+	accessUsecase    domain.DocumentAccessUsecase      // 访问记录
+	duplicateUsecase domain.DocumentDuplicateUsecase   // 复制
+	userRepo         domain.UserRepository             // 用户仓储
+	spaceRepo        domain.SpaceRepository            // 空间仓储
 }
 
 // NewDocumentAggregateService 创建新的文档聚合服务实例
@@ -25,14 +39,28 @@ func NewDocumentAggregateService(
 	shareUsecase domain.DocumentShareUsecase,
 	permUsecase domain.DocumentPermissionUsecase,
 	favoriteUsecase domain.DocumentFavoriteUsecase,
+	versionUsecase domain.DocumentVersionUsecase,
+	trashUsecase domain.DocumentTrashUsecase,
+	searchUsecase domain.DocumentSearchUsecase,
+	quickSwitch domain.DocumentQuickSwitchUsecase,
+	accessUsecase domain.DocumentAccessUsecase,
+	duplicateUsecase domain.DocumentDuplicateUsecase,
 	userRepo domain.UserRepository,
+	spaceRepo domain.SpaceRepository,
 ) domain.DocumentAggregateUsecase {
 	return &documentAggregateService{
-		documentUsecase: documentUsecase,
-		shareUsecase:    shareUsecase,
-		permUsecase:     permUsecase,
-		favoriteUsecase: favoriteUsecase,
-		userRepo:        userRepo,
+		documentUsecase:  documentUsecase,
+		shareUsecase:     shareUsecase,
+		permUsecase:      permUsecase,
+		favoriteUsecase:  favoriteUsecase,
+		versionUsecase:   versionUsecase,
+		trashUsecase:     trashUsecase,
+		searchUsecase:    searchUsecase,
+		quickSwitch:      quickSwitch,
+		accessUsecase:    accessUsecase,
+		duplicateUsecase: duplicateUsecase,
+		userRepo:         userRepo,
+		spaceRepo:        spaceRepo,
 	}
 }
 
@@ -50,8 +78,8 @@ func (s *documentAggregateService) GetDocument(ctx context.Context, userID, docu
 }
 
 // UpdateDocument 更新文档信息
-func (s *documentAggregateService) UpdateDocument(ctx context.Context, userID, documentID int64, title string, docType *domain.DocumentType, parentID *int64, sortOrder *int, isStarred *bool) (*domain.Document, error) {
-	return s.documentUsecase.UpdateDocument(ctx, userID, documentID, title, docType, parentID, sortOrder, isStarred)
+func (s *documentAggregateService) UpdateDocument(ctx context.Context, userID, documentID int64, title string, docType *domain.DocumentType, parentID *int64, sortOrder *int, isStarred *bool, expectedVersion int64) (*domain.Document, error) {
+	return s.documentUsecase.UpdateDocument(ctx, userID, documentID, title, docType, parentID, sortOrder, isStarred, expectedVersion)
 }
 
 // DeleteDocument 删除文档
@@ -60,41 +88,51 @@ func (s *documentAggregateService) DeleteDocument(ctx context.Context, userID, d
 }
 
 // RestoreDocument 恢复文档
+// 委托给回收站子域，连同与文档一起删除的子孙文档一起恢复
 func (s *documentAggregateService) RestoreDocument(ctx context.Context, userID, documentID int64) error {
-	return s.documentUsecase.RestoreDocument(ctx, userID, documentID)
+	_, err := s.trashUsecase.RestoreFromTrash(ctx, userID, documentID)
+	return err
 }
 
 // UpdateDocumentContent 更新文档内容
-func (s *documentAggregateService) UpdateDocumentContent(ctx context.Context, userID, documentID int64, content string) error {
-	return s.documentUsecase.UpdateDocumentContent(ctx, userID, documentID, content)
+func (s *documentAggregateService) UpdateDocumentContent(ctx context.Context, userID, documentID int64, content string, expectedVersion int64) (int64, error) {
+	return s.documentUsecase.UpdateDocumentContent(ctx, userID, documentID, content, expectedVersion)
+}
+
+// SetCollaborationMode 设置文档的实时协作模式
+func (s *documentAggregateService) SetCollaborationMode(ctx context.Context, userID, documentID int64, mode domain.CollaborationMode) error {
+	return s.documentUsecase.SetCollaborationMode(ctx, userID, documentID, mode)
 }
 
 // GetDocumentContent 获取文档内容
-func (s *documentAggregateService) GetDocumentContent(ctx context.Context, userID, documentID int64) (string, error) {
+func (s *documentAggregateService) GetDocumentContent(ctx context.Context, userID, documentID int64) (string, int64, error) {
 	return s.documentUsecase.GetDocumentContent(ctx, userID, documentID)
 }
 
 // GetMyDocuments 获取我的文档列表
-func (s *documentAggregateService) GetMyDocuments(ctx context.Context, userID int64, parentID *int64, includeDeleted bool) ([]*domain.Document, error) {
-	return s.documentUsecase.GetMyDocuments(ctx, userID, parentID, includeDeleted)
+func (s *documentAggregateService) GetMyDocuments(ctx context.Context, userID int64, parentID *int64, includeDeleted, includeArchived bool) ([]*domain.Document, error) {
+	return s.documentUsecase.GetMyDocuments(ctx, userID, parentID, includeDeleted, includeArchived)
 }
 
 // GetDocumentTree 获取文档树结构
-func (s *documentAggregateService) GetDocumentTree(ctx context.Context, userID int64, rootID *int64) ([]*domain.Document, error) {
-	return s.documentUsecase.GetDocumentTree(ctx, userID, rootID)
+func (s *documentAggregateService) GetDocumentTree(ctx context.Context, userID int64, rootID *int64, includeArchived bool) ([]*domain.Document, error) {
+	return s.documentUsecase.GetDocumentTree(ctx, userID, rootID, includeArchived)
 }
 
 // SearchDocuments 搜索文档
-func (s *documentAggregateService) SearchDocuments(ctx context.Context, userID int64, keyword string, docType *domain.DocumentType, limit, offset int) ([]*domain.DocumentSearchResult, error) {
-	// 1. 获取基础搜索结果
-	documents, err := s.documentUsecase.SearchDocuments(ctx, userID, keyword, docType, limit, offset)
+func (s *documentAggregateService) SearchDocuments(ctx context.Context, userID int64, filter *domain.DocumentSearchFilter) (*domain.DocumentSearchPage, error) {
+	// 1. 获取按相关度排序的命中文档
+	page, err := s.searchUsecase.SearchDocuments(ctx, userID, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	// 2. 转换为搜索结果对象，并附加额外信息
-	results := make([]*domain.DocumentSearchResult, 0, len(documents))
-	for _, doc := range documents {
+	// 2. 转换为搜索结果对象，并附加额外信息和高亮
+	terms := search.Terms(filter.Keyword)
+	results := make([]*domain.DocumentSearchResult, 0, len(page.Hits))
+	for _, hit := range page.Hits {
+		doc := hit.Document
+
 		// 检查是否收藏
 		isFavorite, _ := s.favoriteUsecase.IsFavorite(ctx, doc.ID, userID)
 
@@ -118,20 +156,74 @@ func (s *documentAggregateService) SearchDocuments(ctx context.Context, userID i
 			Type:       doc.Type,
 			IsStarred:  doc.IsStarred,
 			IsFavorite: isFavorite,
+			IsArchived: doc.IsArchived(),
 			UpdatedAt:  doc.UpdatedAt,
 			Owner:      owner,
 			Permission: permission,
-			MatchScore: 1.0, // 简单实现，实际应该基于搜索算法计算
+			ParentPath: hit.ParentPath,
+			MatchScore: hit.Score,
+
+			TitleHighlights: search.Highlights(doc.Title, terms),
+			Snippets:        search.Snippets(hit.PlainText, terms, searchSnippetWidth, searchMaxSnippets),
 		}
 		results = append(results, result)
 	}
 
-	return results, nil
+	return &domain.DocumentSearchPage{
+		Results: results,
+		Total:   page.Total,
+		Facets:  s.searchFacets(ctx, page.Facets),
+	}, nil
 }
 
-// GetRecentDocuments 获取最近访问的文档
-func (s *documentAggregateService) GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*domain.Document, error) {
-	return s.documentUsecase.GetRecentDocuments(ctx, userID, limit)
+// QuickSwitch 按标题快速查找文档
+// 委托给快速切换服务处理
+func (s *documentAggregateService) QuickSwitch(ctx context.Context, userID int64, query string, limit int) ([]*domain.QuickSwitchResult, error) {
+	return s.quickSwitch.QuickSwitch(ctx, userID, query, limit)
+}
+
+// searchFacets 为分面统计附加空间名称和所有者显示名称，并按文档数排序
+func (s *documentAggregateService) searchFacets(ctx context.Context, facets *domain.SearchFacets) *domain.DocumentSearchFacets {
+	result := &domain.DocumentSearchFacets{
+		Spaces: make([]*domain.SearchFacetCount, 0, len(facets.Spaces)),
+		Owners: make([]*domain.SearchFacetCount, 0, len(facets.Owners)),
+		Types:  facets.Types,
+	}
+
+	for spaceID, count := range facets.Spaces {
+		facet := &domain.SearchFacetCount{ID: spaceID, Count: count}
+		if space, err := s.spaceRepo.GetByID(ctx, spaceID); err == nil && space != nil {
+			facet.Name = space.Name
+		}
+		result.Spaces = append(result.Spaces, facet)
+	}
+	for ownerID, count := range facets.Owners {
+		facet := &domain.SearchFacetCount{ID: ownerID, Count: count}
+		if owner, err := s.userRepo.GetByID(ctx, ownerID); err == nil && owner != nil {
+			facet.Name = owner.Name
+		}
+		result.Owners = append(result.Owners, facet)
+	}
+
+	sortFacetCounts(result.Spaces)
+	sortFacetCounts(result.Owners)
+	return result
+}
+
+// sortFacetCounts 按文档数从多到少排序，文档数相同时按ID排序
+func sortFacetCounts(counts []*domain.SearchFacetCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].ID < counts[j].ID
+	})
+}
+
+// GetRecentDocuments 获取最近打开、编辑以及被共享的文档
+// 委托给文档访问服务处理
+func (s *documentAggregateService) GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*domain.RecentDocument, error) {
+	return s.accessUsecase.GetRecentDocuments(ctx, userID, limit)
 }
 
 // MoveDocument 移动文档
@@ -140,8 +232,25 @@ func (s *documentAggregateService) MoveDocument(ctx context.Context, userID, doc
 }
 
 // DuplicateDocument 复制文档
-func (s *documentAggregateService) DuplicateDocument(ctx context.Context, userID, documentID int64, newTitle string) (*domain.Document, error) {
-	return s.documentUsecase.DuplicateDocument(ctx, userID, documentID, newTitle)
+// 委托给文档复制服务处理，文件夹连同子孙文档一起复制，文档较多时返回异步任务
+func (s *documentAggregateService) DuplicateDocument(ctx context.Context, userID, documentID int64, options *domain.DocumentDuplicateOptions) (*domain.DocumentDuplicateResult, error) {
+	return s.duplicateUsecase.DuplicateDocument(ctx, userID, documentID, options)
+}
+
+// GetDuplicateJob 获取异步复制任务的进度
+func (s *documentAggregateService) GetDuplicateJob(ctx context.Context, userID int64, jobID string) (*domain.DocumentDuplicateJob, error) {
+	return s.duplicateUsecase.GetDuplicateJob(ctx, userID, jobID)
+}
+
+// ArchiveDocument 归档文档
+// 文件夹连同其子孙文档一起归档，归档的文档只读
+func (s *documentAggregateService) ArchiveDocument(ctx context.Context, userID, documentID int64) (*domain.Document, error) {
+	return s.documentUsecase.ArchiveDocument(ctx, userID, documentID)
+}
+
+// UnarchiveDocument 取消归档文档
+func (s *documentAggregateService) UnarchiveDocument(ctx context.Context, userID, documentID int64) (*domain.Document, error) {
+	return s.documentUsecase.UnarchiveDocument(ctx, userID, documentID)
 }
 
 // BatchDeleteDocuments 批量删除文档
@@ -182,9 +291,17 @@ func (s *documentAggregateService) GetMySharedDocuments(ctx context.Context, use
 }
 
 // GetSharedDocument 通过分享链接获取文档
-func (s *documentAggregateService) GetSharedDocument(ctx context.Context, linkID, password string, accessIP string) (*domain.DocumentAccessInfo, error) {
-	//return s.shareUsecase.GetSharedDocument(ctx, linkID, password, accessIP)
-	return nil, nil
+// 访问权限以分享设置为准，归档的文档即使分享为编辑权限也只读
+func (s *documentAggregateService) GetSharedDocument(ctx context.Context, userID int64, linkID, password string, accessIP string) (*domain.DocumentAccessInfo, error) {
+	share, err := s.shareUsecase.ValidateShareAccess(ctx, linkID, password)
+	if err != nil {
+		return nil, err
+	}
+	document, err := s.shareUsecase.GetSharedDocument(ctx, userID, linkID, password, accessIP)
+	if err != nil {
+		return nil, err
+	}
+	return domain.BuildShareAccessInfo(share, document), nil
 }
 
 // todo梳理整个文档模块
@@ -258,6 +375,65 @@ func (s *documentAggregateService) IsFavoriteDocument(ctx context.Context, userI
 	return s.favoriteUsecase.IsFavorite(ctx, userID, documentID)
 }
 
+// === 文档版本操作（委托给DocumentVersionUsecase） ===
+
+// ListDocumentVersions 分页获取文档的历史版本
+func (s *documentAggregateService) ListDocumentVersions(ctx context.Context, userID, documentID int64, namedOnly bool, page, pageSize int) ([]*domain.DocumentVersion, int64, error) {
+	return s.versionUsecase.ListVersions(ctx, userID, documentID, namedOnly, page, pageSize)
+}
+
+// GetDocumentVersion 获取文档的指定版本
+func (s *documentAggregateService) GetDocumentVersion(ctx context.Context, userID, documentID, versionID int64) (*domain.DocumentVersion, error) {
+	return s.versionUsecase.GetVersion(ctx, userID, documentID, versionID)
+}
+
+// CreateNamedVersion 以文档当前内容创建命名版本
+func (s *documentAggregateService) CreateNamedVersion(ctx context.Context, userID, documentID int64, name string) (*domain.DocumentVersion, error) {
+	return s.versionUsecase.CreateNamedVersion(ctx, userID, documentID, name)
+}
+
+// NameDocumentVersion 为已有版本设置名称
+func (s *documentAggregateService) NameDocumentVersion(ctx context.Context, userID, documentID, versionID int64, name string) (*domain.DocumentVersion, error) {
+	return s.versionUsecase.NameVersion(ctx, userID, documentID, versionID, name)
+}
+
+// RestoreDocumentVersion 将文档恢复为历史版本
+func (s *documentAggregateService) RestoreDocumentVersion(ctx context.Context, userID, documentID, versionID int64) (*domain.DocumentVersion, error) {
+	return s.versionUsecase.RestoreVersion(ctx, userID, documentID, versionID)
+}
+
+// DiffDocumentVersions 对比文档的两个版本
+func (s *documentAggregateService) DiffDocumentVersions(ctx context.Context, userID, documentID, fromID, toID int64, granularity domain.DiffGranularity) (*domain.DocumentDiff, error) {
+	return s.versionUsecase.DiffVersions(ctx, userID, documentID, fromID, toID, granularity)
+}
+
+// MarkDocumentVersionViewed 标记用户最后查看的版本
+func (s *documentAggregateService) MarkDocumentVersionViewed(ctx context.Context, userID, documentID, versionID int64) (*domain.DocumentVersionView, error) {
+	return s.versionUsecase.MarkVersionViewed(ctx, userID, documentID, versionID)
+}
+
+// GetLastViewedDocumentVersion 获取用户最后查看的版本
+func (s *documentAggregateService) GetLastViewedDocumentVersion(ctx context.Context, userID, documentID int64) (*domain.DocumentVersionView, error) {
+	return s.versionUsecase.GetLastViewedVersion(ctx, userID, documentID)
+}
+
+// === 回收站操作（委托给DocumentTrashUsecase） ===
+
+// ListTrash 分页获取回收站条目
+func (s *documentAggregateService) ListTrash(ctx context.Context, userID int64, spaceID *int64, page, pageSize int) ([]*domain.DocumentTrashItem, int64, error) {
+	return s.trashUsecase.ListTrash(ctx, userID, spaceID, page, pageSize)
+}
+
+// RestoreFromTrash 从回收站恢复文档及其子树
+func (s *documentAggregateService) RestoreFromTrash(ctx context.Context, userID, documentID int64) (*domain.Document, error) {
+	return s.trashUsecase.RestoreFromTrash(ctx, userID, documentID)
+}
+
+// DeleteDocumentPermanently 永久删除回收站中的文档及其子树
+func (s *documentAggregateService) DeleteDocumentPermanently(ctx context.Context, userID, documentID int64) error {
+	return s.trashUsecase.DeletePermanently(ctx, userID, documentID)
+}
+
 // === 聚合根级别的复合操作 ===
 
 // GetDocumentWithAccessInfo 获取文档及其访问信息
@@ -330,12 +506,11 @@ func (s *documentAggregateService) GetDocumentFullInfo(ctx context.Context, user
 	// 6. 获取子文档（如果是文件夹）
 	var children []*domain.Document
 	if accessInfo.Document.IsFolder() {
-		children, _ = s.documentUsecase.GetMyDocuments(ctx, userID, &documentID, false)
+		children, _ = s.documentUsecase.GetMyDocuments(ctx, userID, &documentID, false, accessInfo.Document.IsArchived())
 	}
 
 	// 7. 获取面包屑导航
-	allDocs, _ := s.documentUsecase.GetMyDocuments(ctx, userID, nil, false)
-	breadcrumb := domain.GetDocumentBreadcrumb(accessInfo.Document, allDocs)
+	breadcrumb, _ := s.documentUsecase.GetDocumentBreadcrumb(ctx, userID, documentID)
 
 	return &domain.DocumentFullInfo{
 		Document:     accessInfo.Document,
@@ -2,7 +2,9 @@ package document
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -17,6 +19,11 @@ type documentService struct {
 	permUsecase     domain.DocumentPermissionUsecase // 权限子域
 	favoriteUsecase domain.DocumentFavoriteUsecase   // 收藏子域
 	userRepo        domain.UserRepository            // 用户仓储（用于验证用户存在性）
+	events          domain.DocumentEventPublisher    // 文档事件发布（可为空）
+	versions        domain.DocumentVersionRecorder   // 文档版本记录（可为空）
+	search          domain.DocumentSearchSyncer      // 搜索索引同步（可为空）
+	access          domain.DocumentAccessRecorder    // 文档访问记录（可为空）
+	collaboration   domain.CollaborationContentGuard // 协作会话协调（可为空）
 }
 
 // NewDocumentService 创建新的文档业务服务实例
@@ -27,6 +34,11 @@ func NewDocumentService(
 	permUsecase domain.DocumentPermissionUsecase,
 	favoriteUsecase domain.DocumentFavoriteUsecase,
 	userRepo domain.UserRepository,
+	events domain.DocumentEventPublisher,
+	versions domain.DocumentVersionRecorder,
+	search domain.DocumentSearchSyncer,
+	access domain.DocumentAccessRecorder,
+	collaboration domain.CollaborationContentGuard,
 ) domain.DocumentUsecase {
 	return &documentService{
 		documentRepo:    documentRepo,
@@ -34,6 +46,11 @@ func NewDocumentService(
 		permUsecase:     permUsecase,
 		favoriteUsecase: favoriteUsecase,
 		userRepo:        userRepo,
+		events:          events,
+		versions:        versions,
+		search:          search,
+		access:          access,
+		collaboration:   collaboration,
 	}
 }
 
@@ -59,6 +76,11 @@ func (d *documentService) CreateDocument(ctx context.Context, userID int64, titl
 			return nil, domain.ErrDocumentNotFound
 		}
 
+		// 归档的文件夹只读，不能在其中创建文档
+		if parentDoc.IsArchived() {
+			return nil, domain.ErrParentDocumentArchived
+		}
+
 		// 检查父文档是否为文件夹
 		if !parentDoc.CanBeParent() {
 			return nil, domain.ErrInvalidDocumentType
@@ -98,6 +120,8 @@ func (d *documentService) CreateDocument(ctx context.Context, userID int64, titl
 	if err := d.documentRepo.Store(ctx, document); err != nil {
 		return nil, fmt.Errorf("failed to create document: %w", err)
 	}
+	d.syncSearch(ctx, document.ID)
+	d.recordAccess(ctx, userID, document.ID, domain.DocumentAccessEdit)
 
 	return document, nil
 }
@@ -126,13 +150,15 @@ func (d *documentService) GetDocument(ctx context.Context, userID, documentID in
 	if !hasAccess {
 		return nil, domain.ErrPermissionDenied
 	}
+	d.recordAccess(ctx, userID, documentID, domain.DocumentAccessView)
 
 	return document, nil
 }
 
 // UpdateDocument 更新文档信息
 // 支持更新标题、类型、父目录、排序和星标状态
-func (d *documentService) UpdateDocument(ctx context.Context, userID, documentID int64, title string, docType *domain.DocumentType, parentID *int64, sortOrder *int, isStarred *bool) (*domain.Document, error) {
+// expectedVersion 大于 0 时要求文档当前版本号与之一致，否则返回 DocumentModifiedError
+func (d *documentService) UpdateDocument(ctx context.Context, userID, documentID int64, title string, docType *domain.DocumentType, parentID *int64, sortOrder *int, isStarred *bool, expectedVersion int64) (*domain.Document, error) {
 	// 1. 获取现有文档
 	document, err := d.documentRepo.GetByID(ctx, documentID)
 	if err != nil {
@@ -152,13 +178,18 @@ func (d *documentService) UpdateDocument(ctx context.Context, userID, documentID
 	if err := domain.ValidateDocumentOperation(userID, documentID, "edit", document, domain.PermissionEdit); err != nil {
 		return nil, err
 	}
+	if err := document.CheckVersion(expectedVersion); err != nil {
+		return nil, err
+	}
 
 	// 4. 更新字段
 	needsUpdate := false
+	titleChanged := false
 
 	if strings.TrimSpace(title) != "" && title != document.Title {
 		document.Title = strings.TrimSpace(title)
 		needsUpdate = true
+		titleChanged = true
 	}
 
 	if docType != nil && *docType != document.Type {
@@ -168,11 +199,7 @@ func (d *documentService) UpdateDocument(ctx context.Context, userID, documentID
 
 	if parentID != nil && (document.ParentID == nil || *parentID != *document.ParentID) {
 		// 验证层级关系（防止循环引用）
-		allDocs, err := d.documentRepo.GetByOwner(ctx, userID, false)
-		if err != nil {
-			return nil, err
-		}
-		if err := domain.ValidateDocumentHierarchy(document, parentID, allDocs); err != nil {
+		if err := d.validateMove(ctx, userID, document, parentID); err != nil {
 			return nil, err
 		}
 		document.ParentID = parentID
@@ -194,13 +221,22 @@ func (d *documentService) UpdateDocument(ctx context.Context, userID, documentID
 		if err := d.documentRepo.Update(ctx, document); err != nil {
 			return nil, fmt.Errorf("failed to update document: %w", err)
 		}
+		d.syncSearch(ctx, documentID)
+		d.recordAccess(ctx, userID, documentID, domain.DocumentAccessEdit)
+	}
+	if titleChanged {
+		d.recordVersion(ctx, documentID, userID)
+		d.publishEvent(ctx, domain.DocumentEventTitleChanged, documentID, map[string]interface{}{
+			"title":   document.Title,
+			"user_id": userID,
+		})
 	}
 
 	return document, nil
 }
 
 // DeleteDocument 删除文档（软删除）
-// 只有文档所有者或具有管理权限的用户才能删除文档
+// 只有文档所有者或具有管理权限的用户才能删除文档，文件夹连同其子孙文档一起移入回收站
 func (d *documentService) DeleteDocument(ctx context.Context, userID, documentID int64) error {
 	// 1. 获取文档
 	document, err := d.documentRepo.GetByID(ctx, documentID)
@@ -214,7 +250,16 @@ func (d *documentService) DeleteDocument(ctx context.Context, userID, documentID
 	}
 
 	// 3. 执行软删除
-	return d.documentRepo.SoftDelete(ctx, documentID)
+	if err := d.documentRepo.SoftDelete(ctx, documentID); err != nil {
+		return err
+	}
+	d.syncSearchSubtree(ctx, documentID)
+
+	// 4. 通知正在查看文档的用户
+	d.publishEvent(ctx, domain.DocumentEventDeleted, documentID, map[string]interface{}{
+		"user_id": userID,
+	})
+	return nil
 }
 
 // RestoreDocument 恢复已删除的文档
@@ -233,15 +278,63 @@ func (d *documentService) RestoreDocument(ctx context.Context, userID, documentI
 
 	// 3. 恢复文档
 	document.Restore()
-	return d.documentRepo.Update(ctx, document)
+	if err := d.documentRepo.Update(ctx, document); err != nil {
+		return err
+	}
+	d.syncSearch(ctx, documentID)
+	return nil
 }
 
 // === 文档内容管理方法 ===
 
-// UpdateDocumentContent 更新文档内容
-func (d *documentService) UpdateDocumentContent(ctx context.Context, userID, documentID int64, content string) error {
+// UpdateDocumentContent 更新文档内容，返回新的版本号
+// expectedVersion 大于 0 时要求文档当前版本号与之一致，否则返回 DocumentModifiedError
+// 文档已归档时由仓储在写入时拒绝，返回 ErrDocumentArchived；
+// 协作会话中有尚未写入文档的修改时返回 ErrDocumentCollaborating，写入后以新内容作为协作会话的检查点
+func (d *documentService) UpdateDocumentContent(ctx context.Context, userID, documentID int64, content string, expectedVersion int64) (int64, error) {
 	// 1. 检查文档访问权限
 	hasAccess, err := d.CheckDocumentAccess(ctx, userID, documentID, domain.PermissionEdit)
+	if err != nil {
+		return 0, err
+	}
+	if !hasAccess {
+		return 0, domain.ErrPermissionDenied
+	}
+
+	// 2. 更新内容，有协作会话时在同一事务中检查会话并更新其检查点
+	var version int64
+	if d.collaboration != nil {
+		version, err = d.collaboration.WriteContent(ctx, documentID, content, expectedVersion)
+	} else {
+		version, err = d.documentRepo.UpdateContent(ctx, documentID, content, expectedVersion)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// 3. 记录历史版本和访问并同步搜索索引
+	d.recordVersion(ctx, documentID, userID)
+	d.syncSearch(ctx, documentID)
+	d.recordAccess(ctx, userID, documentID, domain.DocumentAccessEdit)
+
+	// 4. 通知正在查看文档的用户，协作连接收到通知后被关闭并重新同步
+	d.publishEvent(ctx, domain.DocumentEventContentReset, documentID, map[string]interface{}{
+		"user_id": userID,
+		"version": version,
+	})
+	return version, nil
+}
+
+// SetCollaborationMode 设置文档的实时协作模式，需要管理权限
+// 新模式对之后加入房间的连接生效，已在房间内的连接提交时会收到模式不匹配错误
+func (d *documentService) SetCollaborationMode(ctx context.Context, userID, documentID int64, mode domain.CollaborationMode) error {
+	// 1. 验证协作模式
+	if !mode.IsValid() {
+		return domain.ErrInvalidCollaborationMode
+	}
+
+	// 2. 检查管理权限
+	hasAccess, err := d.CheckDocumentAccess(ctx, userID, documentID, domain.PermissionManage)
 	if err != nil {
 		return err
 	}
@@ -249,44 +342,55 @@ func (d *documentService) UpdateDocumentContent(ctx context.Context, userID, doc
 		return domain.ErrPermissionDenied
 	}
 
-	// 2. 更新内容
-	return d.documentRepo.UpdateContent(ctx, documentID, content)
+	// 3. 更新协作模式
+	document, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	if document.IsArchived() {
+		return domain.ErrDocumentArchived
+	}
+	if document.GetCollaborationMode() == mode {
+		return nil
+	}
+
+	document.CollaborationMode = mode
+	if err := d.documentRepo.Update(ctx, document); err != nil {
+		return fmt.Errorf("failed to update collaboration mode: %w", err)
+	}
+	return nil
 }
 
-// GetDocumentContent 获取文档内容
-func (d *documentService) GetDocumentContent(ctx context.Context, userID, documentID int64) (string, error) {
+// GetDocumentContent 获取文档内容及其对应的版本号
+func (d *documentService) GetDocumentContent(ctx context.Context, userID, documentID int64) (string, int64, error) {
 	// 1. 检查文档访问权限
 	hasAccess, err := d.CheckDocumentAccess(ctx, userID, documentID, domain.PermissionView)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	if !hasAccess {
-		return "", domain.ErrPermissionDenied
+		return "", 0, domain.ErrPermissionDenied
 	}
 
-	// 2. 获取内容
-	return d.documentRepo.GetContent(ctx, documentID)
+	// 2. 获取内容，内容和版本号需来自同一次读取
+	document, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return "", 0, err
+	}
+	d.recordAccess(ctx, userID, documentID, domain.DocumentAccessView)
+	return document.Content, document.Version, nil
 }
 
 // === 文档查询方法 ===
 
-// GetMyDocuments 获取用户的文档列表
-func (d *documentService) GetMyDocuments(ctx context.Context, userID int64, parentID *int64, includeDeleted bool) ([]*domain.Document, error) {
-	return d.documentRepo.GetByParent(ctx, parentID, userID)
+// GetMyDocuments 获取用户的文档列表，默认不包含已归档的文档
+func (d *documentService) GetMyDocuments(ctx context.Context, userID int64, parentID *int64, includeDeleted, includeArchived bool) ([]*domain.Document, error) {
+	return d.documentRepo.GetByParent(ctx, parentID, userID, includeArchived)
 }
 
-// GetDocumentTree 获取文档树结构
-func (d *documentService) GetDocumentTree(ctx context.Context, userID int64, rootID *int64) ([]*domain.Document, error) {
-	return d.documentRepo.GetDocumentTree(ctx, rootID, userID)
-}
-
-// SearchDocuments 搜索文档
-func (d *documentService) SearchDocuments(ctx context.Context, userID int64, keyword string, docType *domain.DocumentType, limit, offset int) ([]*domain.Document, error) {
-	if strings.TrimSpace(keyword) == "" {
-		return []*domain.Document{}, nil
-	}
-
-	return d.documentRepo.SearchDocuments(ctx, userID, keyword, docType, limit, offset)
+// GetDocumentTree 获取文档树结构，默认不包含已归档的文档
+func (d *documentService) GetDocumentTree(ctx context.Context, userID int64, rootID *int64, includeArchived bool) ([]*domain.Document, error) {
+	return d.documentRepo.GetDocumentTree(ctx, rootID, userID, includeArchived)
 }
 
 // GetStarredDocuments 获取星标文档
@@ -294,9 +398,49 @@ func (d *documentService) GetStarredDocuments(ctx context.Context, userID int64)
 	return d.documentRepo.GetStarredDocuments(ctx, userID)
 }
 
-// GetRecentDocuments 获取最近访问的文档
-func (d *documentService) GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*domain.Document, error) {
-	return d.documentRepo.GetRecentDocuments(ctx, userID, limit)
+// GetDocumentBreadcrumb 获取文档的面包屑导航，由根目录到父文件夹
+// 祖先ID取自文档的物化路径，一次读取；由父文件夹向上遇到已删除或用户无权查看的祖先时停止
+func (d *documentService) GetDocumentBreadcrumb(ctx context.Context, userID, documentID int64) ([]*domain.Document, error) {
+	// 1. 获取文档并检查查看权限
+	document, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	hasAccess, err := d.CheckDocumentAccess(ctx, userID, documentID, domain.PermissionView)
+	if err != nil {
+		return nil, err
+	}
+	if !hasAccess {
+		return nil, domain.ErrPermissionDenied
+	}
+
+	// 2. 按物化路径读取未删除的祖先
+	ancestors, err := d.documentRepo.GetByIDs(ctx, document.AncestorIDs(), false)
+	if err != nil {
+		return nil, err
+	}
+	visible := make([]*domain.Document, 0, len(ancestors))
+	for _, ancestor := range ancestors {
+		if !ancestor.IsDeleted() {
+			visible = append(visible, ancestor)
+		}
+	}
+	breadcrumb := domain.GetDocumentBreadcrumb(document, visible)
+
+	// 3. 由父文件夹向上检查查看权限
+	for i := len(breadcrumb) - 1; i >= 0; i-- {
+		if breadcrumb[i].OwnerID == userID {
+			continue
+		}
+		hasAccess, err := d.permUsecase.CheckPermission(ctx, breadcrumb[i].ID, userID, domain.PermissionView)
+		if err != nil {
+			return nil, err
+		}
+		if !hasAccess {
+			return breadcrumb[i+1:], nil
+		}
+	}
+	return breadcrumb, nil
 }
 
 // === 文档操作方法 ===
@@ -315,16 +459,16 @@ func (d *documentService) MoveDocument(ctx context.Context, userID, documentID i
 	}
 
 	// 3. 验证层级关系
-	allDocs, err := d.documentRepo.GetByOwner(ctx, userID, false)
-	if err != nil {
-		return err
-	}
-	if err := domain.ValidateDocumentHierarchy(document, newParentID, allDocs); err != nil {
+	if err := d.validateMove(ctx, userID, document, newParentID); err != nil {
 		return err
 	}
 
 	// 4. 执行移动
-	return d.documentRepo.MoveDocument(ctx, documentID, newParentID)
+	if err := d.documentRepo.MoveDocument(ctx, documentID, newParentID); err != nil {
+		return err
+	}
+	d.syncSearch(ctx, documentID)
+	return nil
 }
 
 // ToggleStarDocument 切换文档星标状态
@@ -349,39 +493,72 @@ func (d *documentService) ToggleStarDocument(ctx context.Context, userID, docume
 	if err := d.documentRepo.ToggleStar(ctx, documentID, userID, newStarred); err != nil {
 		return false, err
 	}
+	d.syncSearch(ctx, documentID)
 
 	return newStarred, nil
 }
 
-// DuplicateDocument 复制文档
-func (d *documentService) DuplicateDocument(ctx context.Context, userID, documentID int64, newTitle string) (*domain.Document, error) {
-	// 1. 获取原文档
-	originalDoc, err := d.documentRepo.GetByID(ctx, documentID)
+// === 文档归档方法 ===
+
+// ArchiveDocument 归档文档，文件夹连同其子孙文档一起归档
+// 只有文档所有者或具有管理权限的用户才能归档，归档的文档只读
+func (d *documentService) ArchiveDocument(ctx context.Context, userID, documentID int64) (*domain.Document, error) {
+	// 1. 获取文档并检查管理权限
+	document, err := d.getManagedDocument(ctx, userID, documentID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 2. 检查访问权限
-	hasAccess, err := d.CheckDocumentAccess(ctx, userID, documentID, domain.PermissionView)
+	// 2. 归档文档及其子孙文档
+	archivedIDs, err := d.documentRepo.Archive(ctx, document.ID)
 	if err != nil {
 		return nil, err
 	}
-	if !hasAccess {
-		return nil, domain.ErrPermissionDenied
+	d.syncSearch(ctx, archivedIDs...)
+
+	// 3. 通知正在查看文档的用户，协作客户端据此切换为只读
+	for _, id := range archivedIDs {
+		d.publishEvent(ctx, domain.DocumentEventArchived, id, map[string]interface{}{
+			"user_id": userID,
+		})
 	}
+	return d.documentRepo.GetByID(ctx, documentID)
+}
 
-	// 3. 获取原文档内容
-	content, err := d.documentRepo.GetContent(ctx, documentID)
+// UnarchiveDocument 取消归档，文件夹连同其已归档的子孙文档一起取消归档
+// 父文件夹仍处于归档状态时不能单独取消归档，需先取消归档父文件夹
+func (d *documentService) UnarchiveDocument(ctx context.Context, userID, documentID int64) (*domain.Document, error) {
+	// 1. 获取文档并检查管理权限
+	document, err := d.getManagedDocument(ctx, userID, documentID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 4. 创建新文档
-	if strings.TrimSpace(newTitle) == "" {
-		newTitle = originalDoc.Title + " - 副本"
+	// 2. 检查父文件夹是否已归档
+	if document.ParentID != nil {
+		parent, err := d.documentRepo.GetByID(ctx, *document.ParentID)
+		if err != nil && !errors.Is(err, domain.ErrDocumentNotFound) {
+			return nil, err
+		}
+		if parent != nil && parent.IsArchived() {
+			return nil, domain.ErrParentDocumentArchived
+		}
+	}
+
+	// 3. 取消归档文档及其子孙文档
+	unarchivedIDs, err := d.documentRepo.Unarchive(ctx, document.ID)
+	if err != nil {
+		return nil, err
 	}
+	d.syncSearch(ctx, unarchivedIDs...)
 
-	return d.CreateDocument(ctx, userID, newTitle, content, originalDoc.Type, originalDoc.ParentID, originalDoc.SpaceID, 0, false)
+	// 4. 通知正在查看文档的用户
+	for _, id := range unarchivedIDs {
+		d.publishEvent(ctx, domain.DocumentEventUnarchived, id, map[string]interface{}{
+			"user_id": userID,
+		})
+	}
+	return d.documentRepo.GetByID(ctx, documentID)
 }
 
 // === 批量操作方法 ===
@@ -439,3 +616,83 @@ func (d *documentService) CheckDocumentAccess(ctx context.Context, userID, docum
 	// 3. 委托给权限子域检查
 	return d.permUsecase.CheckPermission(ctx, documentID, userID, permission)
 }
+
+// publishEvent 发布文档事件，未配置事件发布时忽略
+func (d *documentService) publishEvent(ctx context.Context, eventType domain.DocumentEventType, documentID int64, data map[string]interface{}) {
+	if d.events == nil {
+		return
+	}
+	d.events.PublishDocumentEvent(ctx, domain.NewDocumentEvent(eventType, documentID, data))
+}
+
+// recordVersion 记录文档历史版本，未配置版本记录时忽略，记录失败只记录日志
+func (d *documentService) recordVersion(ctx context.Context, documentID, userID int64) {
+	if d.versions == nil {
+		return
+	}
+	if err := d.versions.RecordVersion(ctx, documentID, userID, domain.DocumentVersionSourceSave); err != nil {
+		log.Printf("记录文档历史版本失败: documentID=%d, err=%v", documentID, err)
+	}
+}
+
+// recordAccess 记录用户查看或编辑文档，未配置访问记录时忽略
+func (d *documentService) recordAccess(ctx context.Context, userID, documentID int64, action domain.DocumentAccessAction) {
+	if d.access == nil {
+		return
+	}
+	d.access.RecordAccess(ctx, userID, documentID, action)
+}
+
+// syncSearch 同步文档的搜索索引，未配置搜索索引时忽略
+func (d *documentService) syncSearch(ctx context.Context, documentIDs ...int64) {
+	if d.search == nil {
+		return
+	}
+	d.search.SyncDocuments(ctx, documentIDs...)
+}
+
+// syncSearchSubtree 同步文档自身及所有子孙文档的搜索索引，未配置搜索索引时忽略
+func (d *documentService) syncSearchSubtree(ctx context.Context, rootID int64) {
+	if d.search == nil {
+		return
+	}
+	d.search.SyncSubtree(ctx, rootID)
+}
+
+// validateMove 验证文档可以移动到新的父文件夹，新的父文件夹需要是用户自己未删除的文件夹
+// 循环引用依据物化路径判断，不需要读取用户的全部文档
+func (d *documentService) validateMove(ctx context.Context, userID int64, document *domain.Document, newParentID *int64) error {
+	if newParentID == nil {
+		return domain.ValidateDocumentHierarchy(document, nil)
+	}
+
+	newParent, err := d.documentRepo.GetByID(ctx, *newParentID)
+	if err != nil {
+		return err
+	}
+	if newParent.IsDeleted() || newParent.OwnerID != userID {
+		return domain.ErrDocumentNotFound
+	}
+	return domain.ValidateDocumentHierarchy(document, newParent)
+}
+
+// getManagedDocument 获取文档，并检查用户是否为所有者或具有管理权限
+func (d *documentService) getManagedDocument(ctx context.Context, userID, documentID int64) (*domain.Document, error) {
+	document, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	hasAccess, err := d.CheckDocumentAccess(ctx, userID, documentID, domain.PermissionManage)
+	if err != nil {
+		return nil, err
+	}
+	if !hasAccess {
+		return nil, domain.ErrPermissionDenied
+	}
+
+	if err := domain.ValidateDocumentOperation(userID, documentID, "archive", document, domain.PermissionManage); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
-	"refatorSiwu/domain"
+	"DOC/domain"
 )
 
 // MockDocumentRepository Mock 文档仓储
@@ -23,6 +23,9 @@ func (m *MockDocumentRepository) Store(ctx context.Context, document *domain.Doc
 
 func (m *MockDocumentRepository) GetByID(ctx context.Context, id int64) (*domain.Document, error) {
 	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
 	return args.Get(0).(*domain.Document), args.Error(1)
 }
 
@@ -46,8 +49,8 @@ func (m *MockDocumentRepository) GetByOwner(ctx context.Context, ownerID int64,
 	return args.Get(0).([]*domain.Document), args.Error(1)
 }
 
-func (m *MockDocumentRepository) GetByParent(ctx context.Context, parentID *int64, ownerID int64) ([]*domain.Document, error) {
-	args := m.Called(ctx, parentID, ownerID)
+func (m *MockDocumentRepository) GetByParent(ctx context.Context, parentID *int64, ownerID int64, includeArchived bool) ([]*domain.Document, error) {
+	args := m.Called(ctx, parentID, ownerID, includeArchived)
 	return args.Get(0).([]*domain.Document), args.Error(1)
 }
 
@@ -56,29 +59,39 @@ func (m *MockDocumentRepository) GetBySpace(ctx context.Context, spaceID int64,
 	return args.Get(0).([]*domain.Document), args.Error(1)
 }
 
-func (m *MockDocumentRepository) GetDocumentTree(ctx context.Context, rootID *int64, ownerID int64) ([]*domain.Document, error) {
-	args := m.Called(ctx, rootID, ownerID)
+func (m *MockDocumentRepository) GetDocumentTree(ctx context.Context, rootID *int64, ownerID int64, includeArchived bool) ([]*domain.Document, error) {
+	args := m.Called(ctx, rootID, ownerID, includeArchived)
 	return args.Get(0).([]*domain.Document), args.Error(1)
 }
 
-func (m *MockDocumentRepository) SearchDocuments(ctx context.Context, userID int64, keyword string, docType *domain.DocumentType, limit, offset int) ([]*domain.Document, error) {
-	args := m.Called(ctx, userID, keyword, docType, limit, offset)
+func (m *MockDocumentRepository) GetWithAncestors(ctx context.Context, ids []int64) ([]*domain.Document, error) {
+	args := m.Called(ctx, ids)
 	return args.Get(0).([]*domain.Document), args.Error(1)
 }
 
-func (m *MockDocumentRepository) GetStarredDocuments(ctx context.Context, userID int64) ([]*domain.Document, error) {
-	args := m.Called(ctx, userID)
+func (m *MockDocumentRepository) GetByIDs(ctx context.Context, ids []int64, withContent bool) ([]*domain.Document, error) {
+	args := m.Called(ctx, ids, withContent)
 	return args.Get(0).([]*domain.Document), args.Error(1)
 }
 
-func (m *MockDocumentRepository) GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*domain.Document, error) {
-	args := m.Called(ctx, userID, limit)
+func (m *MockDocumentRepository) GetSubtreeIDs(ctx context.Context, rootID int64) ([]int64, error) {
+	args := m.Called(ctx, rootID)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockDocumentRepository) ListUndeleted(ctx context.Context, afterID int64, limit int) ([]*domain.Document, error) {
+	args := m.Called(ctx, afterID, limit)
 	return args.Get(0).([]*domain.Document), args.Error(1)
 }
 
-func (m *MockDocumentRepository) UpdateContent(ctx context.Context, id int64, content string) error {
-	args := m.Called(ctx, id, content)
-	return args.Error(0)
+func (m *MockDocumentRepository) GetStarredDocuments(ctx context.Context, userID int64) ([]*domain.Document, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*domain.Document), args.Error(1)
+}
+
+func (m *MockDocumentRepository) UpdateContent(ctx context.Context, id int64, content string, expectedVersion int64) (int64, error) {
+	args := m.Called(ctx, id, content, expectedVersion)
+	return args.Get(0).(int64), args.Error(1)
 }
 
 func (m *MockDocumentRepository) GetContent(ctx context.Context, id int64) (string, error) {
@@ -101,6 +114,16 @@ func (m *MockDocumentRepository) MoveDocument(ctx context.Context, id int64, new
 	return args.Error(0)
 }
 
+func (m *MockDocumentRepository) Archive(ctx context.Context, id int64) ([]int64, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockDocumentRepository) Unarchive(ctx context.Context, id int64) ([]int64, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
 func (m *MockDocumentRepository) BatchDelete(ctx context.Context, ids []int64, userID int64) error {
 	args := m.Called(ctx, ids, userID)
 	return args.Error(0)
@@ -134,6 +157,11 @@ func (m *MockUserRepository) GetByUsername(ctx context.Context, username string)
 	return args.Get(0).(*domain.User), args.Error(1)
 }
 
+func (m *MockUserRepository) GetByGithubId(ctx context.Context, id string) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
 func (m *MockUserRepository) Store(ctx context.Context, user *domain.User) error {
 	args := m.Called(ctx, user)
 	return args.Error(0)
@@ -149,14 +177,29 @@ func (m *MockUserRepository) Delete(ctx context.Context, id int64) error {
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) SearchUsers(ctx context.Context, query string, limit, offset int) ([]*domain.User, error) {
-	args := m.Called(ctx, query, limit, offset)
+func (m *MockUserRepository) List(ctx context.Context, offset, limit int) ([]*domain.User, error) {
+	args := m.Called(ctx, offset, limit)
 	return args.Get(0).([]*domain.User), args.Error(1)
 }
 
-func (m *MockUserRepository) GetByGitHubID(ctx context.Context, githubID int64) (*domain.User, error) {
-	args := m.Called(ctx, githubID)
-	return args.Get(0).(*domain.User), args.Error(1)
+func (m *MockUserRepository) Search(ctx context.Context, query string, offset, limit int) ([]*domain.User, error) {
+	args := m.Called(ctx, query, offset, limit)
+	return args.Get(0).([]*domain.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Count(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) CountByStatus(ctx context.Context, status domain.UserStatus) (int64, error) {
+	args := m.Called(ctx, status)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) BatchUpdateStatus(ctx context.Context, userIDs []int64, status domain.UserStatus) error {
+	args := m.Called(ctx, userIDs, status)
+	return args.Error(0)
 }
 
 // Mock 子域服务
@@ -164,7 +207,7 @@ type MockDocumentShareUsecase struct {
 	mock.Mock
 }
 
-func (m *MockDocumentShareUsecase) ShareDocument(ctx context.Context, userID, documentID int64, permission domain.Permission, password string, expiresAt *time.Time, shareWithUserIDs []int64) (*domain.DocumentShare, error) {
+func (m *MockDocumentShareUsecase) CreateShareLink(ctx context.Context, userID, documentID int64, permission domain.Permission, password string, expiresAt *time.Time, shareWithUserIDs []int64) (*domain.DocumentShare, error) {
 	args := m.Called(ctx, userID, documentID, permission, password, expiresAt, shareWithUserIDs)
 	return args.Get(0).(*domain.DocumentShare), args.Error(1)
 }
@@ -179,6 +222,21 @@ func (m *MockDocumentShareUsecase) DeleteShareLink(ctx context.Context, userID,
 	return args.Error(0)
 }
 
+func (m *MockDocumentShareUsecase) GetSharedDocument(ctx context.Context, userID int64, linkID, password string, accessIP string) (*domain.Document, error) {
+	args := m.Called(ctx, userID, linkID, password, accessIP)
+	return args.Get(0).(*domain.Document), args.Error(1)
+}
+
+func (m *MockDocumentShareUsecase) ValidateShareAccess(ctx context.Context, linkID, password string) (*domain.DocumentShare, error) {
+	args := m.Called(ctx, linkID, password)
+	return args.Get(0).(*domain.DocumentShare), args.Error(1)
+}
+
+func (m *MockDocumentShareUsecase) RecordShareAccess(ctx context.Context, shareID int64, accessIP string) error {
+	args := m.Called(ctx, shareID, accessIP)
+	return args.Error(0)
+}
+
 func (m *MockDocumentShareUsecase) GetDocumentShares(ctx context.Context, userID, documentID int64) ([]*domain.DocumentShare, error) {
 	args := m.Called(ctx, userID, documentID)
 	return args.Get(0).([]*domain.DocumentShare), args.Error(1)
@@ -189,13 +247,28 @@ func (m *MockDocumentShareUsecase) GetMySharedDocuments(ctx context.Context, use
 	return args.Get(0).([]*domain.DocumentShare), args.Error(1)
 }
 
-func (m *MockDocumentShareUsecase) GetSharedDocument(ctx context.Context, linkID, password string, accessIP string) (*domain.DocumentAccessInfo, error) {
-	args := m.Called(ctx, linkID, password, accessIP)
-	return args.Get(0).(*domain.DocumentAccessInfo), args.Error(1)
+func (m *MockDocumentShareUsecase) GetSharedWithMeDocuments(ctx context.Context, userID int64) ([]*domain.DocumentShare, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*domain.DocumentShare), args.Error(1)
 }
 
-func (m *MockDocumentShareUsecase) ValidateShareAccess(ctx context.Context, linkID, password string) (*domain.DocumentShare, error) {
-	args := m.Called(ctx, linkID, password)
+func (m *MockDocumentShareUsecase) AddShareUsers(ctx context.Context, userID, shareID int64, targetUserIDs []int64) error {
+	args := m.Called(ctx, userID, shareID, targetUserIDs)
+	return args.Error(0)
+}
+
+func (m *MockDocumentShareUsecase) RemoveShareUsers(ctx context.Context, userID, shareID int64, targetUserIDs []int64) error {
+	args := m.Called(ctx, userID, shareID, targetUserIDs)
+	return args.Error(0)
+}
+
+func (m *MockDocumentShareUsecase) GetShareUsers(ctx context.Context, userID, shareID int64) ([]*domain.DocumentShareUser, error) {
+	args := m.Called(ctx, userID, shareID)
+	return args.Get(0).([]*domain.DocumentShareUser), args.Error(1)
+}
+
+func (m *MockDocumentShareUsecase) GetShareStats(ctx context.Context, userID, shareID int64) (*domain.DocumentShare, error) {
+	args := m.Called(ctx, userID, shareID)
 	return args.Get(0).(*domain.DocumentShare), args.Error(1)
 }
 
@@ -203,31 +276,46 @@ type MockDocumentPermissionUsecase struct {
 	mock.Mock
 }
 
-func (m *MockDocumentPermissionUsecase) GrantDocumentPermission(ctx context.Context, userID, documentID, targetUserID int64, permission domain.Permission) error {
+func (m *MockDocumentPermissionUsecase) GrantPermission(ctx context.Context, userID, documentID, targetUserID int64, permission domain.Permission) error {
 	args := m.Called(ctx, userID, documentID, targetUserID, permission)
 	return args.Error(0)
 }
 
-func (m *MockDocumentPermissionUsecase) RevokeDocumentPermission(ctx context.Context, userID, documentID, targetUserID int64) error {
+func (m *MockDocumentPermissionUsecase) RevokePermission(ctx context.Context, userID, documentID, targetUserID int64) error {
 	args := m.Called(ctx, userID, documentID, targetUserID)
 	return args.Error(0)
 }
 
-func (m *MockDocumentPermissionUsecase) UpdateDocumentPermission(ctx context.Context, userID, documentID, targetUserID int64, permission domain.Permission) error {
+func (m *MockDocumentPermissionUsecase) UpdatePermission(ctx context.Context, userID, documentID, targetUserID int64, permission domain.Permission) error {
 	args := m.Called(ctx, userID, documentID, targetUserID, permission)
 	return args.Error(0)
 }
 
-func (m *MockDocumentPermissionUsecase) CheckDocumentPermission(ctx context.Context, userID, documentID int64, permission domain.Permission) (bool, error) {
-	args := m.Called(ctx, userID, documentID, permission)
-	return args.Bool(0), args.Error(1)
-}
-
 func (m *MockDocumentPermissionUsecase) GetDocumentPermissions(ctx context.Context, userID, documentID int64) ([]*domain.DocumentPermission, error) {
 	args := m.Called(ctx, userID, documentID)
 	return args.Get(0).([]*domain.DocumentPermission), args.Error(1)
 }
 
+func (m *MockDocumentPermissionUsecase) GetUserPermission(ctx context.Context, documentID, userID int64) (*domain.DocumentPermission, error) {
+	args := m.Called(ctx, documentID, userID)
+	return args.Get(0).(*domain.DocumentPermission), args.Error(1)
+}
+
+func (m *MockDocumentPermissionUsecase) GetUserDocumentsWithPermission(ctx context.Context, userID int64, permission domain.Permission) ([]*domain.Document, error) {
+	args := m.Called(ctx, userID, permission)
+	return args.Get(0).([]*domain.Document), args.Error(1)
+}
+
+func (m *MockDocumentPermissionUsecase) CheckPermission(ctx context.Context, documentID, userID int64, permission domain.Permission) (bool, error) {
+	args := m.Called(ctx, documentID, userID, permission)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDocumentPermissionUsecase) CanAccessDocument(ctx context.Context, documentID, userID int64) (bool, domain.Permission, error) {
+	args := m.Called(ctx, documentID, userID)
+	return args.Bool(0), args.Get(1).(domain.Permission), args.Error(2)
+}
+
 func (m *MockDocumentPermissionUsecase) BatchGrantPermission(ctx context.Context, userID, documentID int64, targetUserIDs []int64, permission domain.Permission) error {
 	args := m.Called(ctx, userID, documentID, targetUserIDs, permission)
 	return args.Error(0)
@@ -242,31 +330,56 @@ type MockDocumentFavoriteUsecase struct {
 	mock.Mock
 }
 
-func (m *MockDocumentFavoriteUsecase) ToggleDocumentFavorite(ctx context.Context, userID, documentID int64) (bool, error) {
+func (m *MockDocumentFavoriteUsecase) ToggleFavorite(ctx context.Context, userID, documentID int64) (bool, error) {
 	args := m.Called(ctx, userID, documentID)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockDocumentFavoriteUsecase) SetFavoriteCustomTitle(ctx context.Context, userID, documentID int64, customTitle string) error {
+func (m *MockDocumentFavoriteUsecase) SetCustomTitle(ctx context.Context, userID, documentID int64, customTitle string) error {
 	args := m.Called(ctx, userID, documentID, customTitle)
 	return args.Error(0)
 }
 
-func (m *MockDocumentFavoriteUsecase) RemoveDocumentFavorite(ctx context.Context, userID, documentID int64) error {
+func (m *MockDocumentFavoriteUsecase) RemoveFavorite(ctx context.Context, userID, documentID int64) error {
 	args := m.Called(ctx, userID, documentID)
 	return args.Error(0)
 }
 
-func (m *MockDocumentFavoriteUsecase) GetFavoriteDocuments(ctx context.Context, userID int64) ([]*domain.DocumentFavorite, error) {
+func (m *MockDocumentFavoriteUsecase) GetMyFavorites(ctx context.Context, userID int64) ([]*domain.DocumentFavorite, error) {
 	args := m.Called(ctx, userID)
 	return args.Get(0).([]*domain.DocumentFavorite), args.Error(1)
 }
 
-func (m *MockDocumentFavoriteUsecase) IsFavoriteDocument(ctx context.Context, userID, documentID int64) (bool, error) {
+func (m *MockDocumentFavoriteUsecase) IsFavorite(ctx context.Context, userID, documentID int64) (bool, error) {
 	args := m.Called(ctx, userID, documentID)
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockDocumentFavoriteUsecase) HandleFavoriteAction(ctx context.Context, userID, documentID int64, action string) error {
+	args := m.Called(ctx, userID, documentID, action)
+	return args.Error(0)
+}
+
+// MockCollaborationContentGuard Mock 协作内容协调
+type MockCollaborationContentGuard struct {
+	mock.Mock
+}
+
+func (m *MockCollaborationContentGuard) CheckContentWritable(ctx context.Context, documentID int64) error {
+	args := m.Called(ctx, documentID)
+	return args.Error(0)
+}
+
+func (m *MockCollaborationContentGuard) RebaseSession(ctx context.Context, documentID int64, content string) error {
+	args := m.Called(ctx, documentID, content)
+	return args.Error(0)
+}
+
+func (m *MockCollaborationContentGuard) WriteContent(ctx context.Context, documentID int64, content string, expectedVersion int64) (int64, error) {
+	args := m.Called(ctx, documentID, content, expectedVersion)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 // 测试用例
 
 func TestCreateDocument_Success(t *testing.T) {
@@ -284,6 +397,11 @@ func TestCreateDocument_Success(t *testing.T) {
 		mockPermUsecase,
 		mockFavoriteUsecase,
 		mockUserRepo,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	// 准备测试数据
@@ -335,6 +453,11 @@ func TestCreateDocument_UserNotFound(t *testing.T) {
 		mockPermUsecase,
 		mockFavoriteUsecase,
 		mockUserRepo,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	// 准备测试数据
@@ -381,6 +504,11 @@ func TestCreateDocument_InvalidTitle(t *testing.T) {
 		mockPermUsecase,
 		mockFavoriteUsecase,
 		mockUserRepo,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	// 准备测试数据
@@ -428,6 +556,11 @@ func TestGetDocument_Success(t *testing.T) {
 		mockPermUsecase,
 		mockFavoriteUsecase,
 		mockUserRepo,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	// 准备测试数据
@@ -437,13 +570,13 @@ func TestGetDocument_Success(t *testing.T) {
 	document := &domain.Document{
 		ID:      documentID,
 		Title:   "测试文档",
-		OwnerID: userID,
+		OwnerID: int64(2),
 		Status:  domain.DocumentStatusActive,
 	}
 
-	// 设置 Mock 期望
+	// 设置 Mock 期望（非所有者需要查看权限）
 	mockDocRepo.On("GetByID", ctx, documentID).Return(document, nil)
-	mockPermUsecase.On("CheckDocumentPermission", ctx, userID, documentID, domain.PermissionView).Return(true, nil)
+	mockPermUsecase.On("CheckPermission", ctx, documentID, userID, domain.PermissionView).Return(true, nil)
 
 	// 执行测试
 	result, err := service.GetDocument(ctx, userID, documentID)
@@ -474,6 +607,11 @@ func TestGetDocument_NotFound(t *testing.T) {
 		mockPermUsecase,
 		mockFavoriteUsecase,
 		mockUserRepo,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
 	)
 
 	// 准备测试数据
@@ -496,4 +634,32 @@ func TestGetDocument_NotFound(t *testing.T) {
 	mockDocRepo.AssertExpectations(t)
 }
 
+func TestUpdateDocumentContent_Collaborating(t *testing.T) {
+	// 准备 Mock
+	mockDocRepo := new(MockDocumentRepository)
+	mockGuard := new(MockCollaborationContentGuard)
+
+	// 创建服务实例
+	service := NewDocumentService(mockDocRepo, nil, nil, nil, nil, nil, nil, nil, nil, mockGuard)
+
+	// 准备测试数据
+	ctx := context.Background()
+	userID := int64(1)
+	documentID := int64(100)
+	document := &domain.Document{ID: documentID, OwnerID: userID, Status: domain.DocumentStatusActive}
+
+	// 设置 Mock 期望：写入与协作会话的检查在同一事务中进行，有未写入的协作操作时拒绝
+	mockDocRepo.On("GetByID", ctx, documentID).Return(document, nil)
+	mockGuard.On("WriteContent", ctx, documentID, "新内容", int64(3)).Return(int64(0), domain.ErrDocumentCollaborating)
+
+	// 执行测试
+	version, err := service.UpdateDocumentContent(ctx, userID, documentID, "新内容", 3)
+
+	// 验证结果
+	assert.ErrorIs(t, err, domain.ErrDocumentCollaborating)
+	assert.Equal(t, int64(0), version)
+	mockDocRepo.AssertNotCalled(t, "UpdateContent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockGuard.AssertExpectations(t)
+}
+
 // 运行测试：go test ./document -v
@@ -0,0 +1,327 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"DOC/domain"
+	"github.com/google/uuid"
+)
+
+// 未配置时同步复制的最大文档数
+const defaultDuplicateAsyncThreshold = 100
+
+// documentDuplicateService 文档复制业务逻辑实现
+// 实现 domain.DocumentDuplicateUsecase 接口；文件夹连同用户可查看的子孙文档在一个事务中复制，
+// 文档数超过阈值时在后台执行，进度写入任务供客户端轮询
+type documentDuplicateService struct {
+	duplicateRepo  domain.DocumentDuplicateRepository
+	jobRepo        domain.DocumentDuplicateJobRepository
+	documentRepo   domain.DocumentRepository
+	permUsecase    domain.DocumentPermissionUsecase
+	permissionRepo domain.DocumentPermissionRepository // 按权限、分享和空间过滤子孙文档
+	shareRepo      domain.DocumentShareRepository
+	spaceRepo      domain.SpaceRepository
+	search         domain.DocumentSearchSyncer   // 搜索索引同步（可为空）
+	access         domain.DocumentAccessRecorder // 文档访问记录（可为空）
+	asyncThreshold int                           // 超过该文档数时异步复制
+}
+
+// DuplicateDocument 复制文档，文件夹连同用户可查看的子孙文档一起复制，保留层级结构和排序
+// 文档数不超过阈值时同步复制并返回副本根文档，否则创建异步任务并立即返回
+func (d *documentDuplicateService) DuplicateDocument(ctx context.Context, userID, documentID int64, options *domain.DocumentDuplicateOptions) (*domain.DocumentDuplicateResult, error) {
+	if options == nil {
+		options = &domain.DocumentDuplicateOptions{}
+	}
+
+	// 1. 获取原文档并检查查看权限
+	source, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if source.IsDeleted() {
+		return nil, domain.ErrDocumentNotFound
+	}
+	if err := d.checkAccess(ctx, userID, source, domain.PermissionView); err != nil {
+		return nil, err
+	}
+
+	// 2. 确定副本的父文件夹和空间
+	parentID, spaceID, err := d.resolveTarget(ctx, userID, source, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 收集需要复制的文档，不能复制到自身或子孙文件夹中
+	subtreeIDs, err := d.documentRepo.GetSubtreeIDs(ctx, source.ID)
+	if err != nil {
+		return nil, err
+	}
+	if parentID != nil && containsID(subtreeIDs, *parentID) {
+		return nil, domain.ErrDuplicateIntoSelf
+	}
+	documentIDs, err := d.duplicableIDs(ctx, userID, source.ID, subtreeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(options.NewTitle)
+	if title == "" {
+		title = source.Title + " - 副本"
+	}
+	plan := &domain.DocumentDuplicatePlan{
+		SourceID:        source.ID,
+		DocumentIDs:     documentIDs,
+		OwnerID:         userID,
+		Title:           title,
+		ParentID:        parentID,
+		SpaceID:         spaceID,
+		CopyPermissions: options.CopyPermissions,
+		CopyFavorites:   options.CopyFavorites,
+	}
+
+	// 4. 文档数较少时同步复制
+	if len(documentIDs) <= d.asyncThreshold {
+		rootID, err := d.duplicateRepo.Duplicate(ctx, plan, nil)
+		if err != nil {
+			return nil, err
+		}
+		d.afterDuplicate(ctx, userID, rootID)
+
+		document, err := d.documentRepo.GetByID(ctx, rootID)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.DocumentDuplicateResult{Document: document}, nil
+	}
+
+	// 5. 文档数较多时创建任务并在后台复制
+	now := time.Now()
+	job := &domain.DocumentDuplicateJob{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		SourceID:  source.ID,
+		Status:    domain.DocumentDuplicateJobPending,
+		Total:     len(documentIDs),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := d.jobRepo.SaveJob(ctx, job); err != nil {
+		return nil, err
+	}
+	accepted := *job
+	go d.runJob(job, plan)
+
+	return &domain.DocumentDuplicateResult{Job: &accepted}, nil
+}
+
+// GetDuplicateJob 获取用户自己的异步复制任务，其他用户的任务视为不存在
+func (d *documentDuplicateService) GetDuplicateJob(ctx context.Context, userID int64, jobID string) (*domain.DocumentDuplicateJob, error) {
+	job, err := d.jobRepo.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.UserID != userID {
+		return nil, domain.ErrDuplicateJobNotFound
+	}
+	return job, nil
+}
+
+// runJob 在后台执行复制任务，每写入一批副本更新一次进度
+// 进程在复制过程中退出时事务回滚，任务停留在执行中直到过期
+func (d *documentDuplicateService) runJob(job *domain.DocumentDuplicateJob, plan *domain.DocumentDuplicatePlan) {
+	ctx := context.Background()
+
+	job.Status = domain.DocumentDuplicateJobRunning
+	d.saveJob(ctx, job)
+
+	rootID, err := d.duplicateRepo.Duplicate(ctx, plan, func(copied int) {
+		job.Copied = copied
+		d.saveJob(ctx, job)
+	})
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	if err != nil {
+		log.Printf("复制文档失败: jobID=%s, documentID=%d, err=%v", job.ID, job.SourceID, err)
+		job.Status = domain.DocumentDuplicateJobFailed
+		job.Copied = 0
+		job.Error = err.Error()
+		d.saveJob(ctx, job)
+		return
+	}
+
+	d.afterDuplicate(ctx, job.UserID, rootID)
+	job.Status = domain.DocumentDuplicateJobCompleted
+	job.ResultID = &rootID
+	d.saveJob(ctx, job)
+}
+
+// saveJob 更新任务，失败只记录日志，不影响复制本身
+func (d *documentDuplicateService) saveJob(ctx context.Context, job *domain.DocumentDuplicateJob) {
+	job.UpdatedAt = time.Now()
+	if err := d.jobRepo.SaveJob(ctx, job); err != nil {
+		log.Printf("更新复制任务失败: jobID=%s, err=%v", job.ID, err)
+	}
+}
+
+// resolveTarget 确定副本根文档的父文件夹和所有副本所属的空间
+// 指定目标父文件夹时需要对其有编辑权限，副本归属其所在空间；只指定目标空间时需要空间的编辑权限，副本放在空间根目录；
+// 都未指定时放在原文档所在的父文件夹和空间中，与在该文件夹中新建文档的要求相同
+func (d *documentDuplicateService) resolveTarget(ctx context.Context, userID int64, source *domain.Document, options *domain.DocumentDuplicateOptions) (*int64, *int64, error) {
+	switch {
+	case options.TargetParentID != nil:
+		parent, err := d.getTargetParent(ctx, userID, *options.TargetParentID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if options.TargetSpaceID != nil && (parent.SpaceID == nil || *parent.SpaceID != *options.TargetSpaceID) {
+			return nil, nil, domain.ErrBadParamInput
+		}
+		return &parent.ID, parent.SpaceID, nil
+
+	case options.TargetSpaceID != nil:
+		if err := d.checkSpaceEdit(ctx, userID, *options.TargetSpaceID); err != nil {
+			return nil, nil, err
+		}
+		return nil, options.TargetSpaceID, nil
+
+	case source.ParentID != nil:
+		if _, err := d.getTargetParent(ctx, userID, *source.ParentID); err != nil {
+			return nil, nil, err
+		}
+		return source.ParentID, source.SpaceID, nil
+
+	default:
+		return nil, source.SpaceID, nil
+	}
+}
+
+// getTargetParent 获取目标父文件夹，需要是未归档的文件夹且用户具有编辑权限
+func (d *documentDuplicateService) getTargetParent(ctx context.Context, userID, parentID int64) (*domain.Document, error) {
+	parent, err := d.documentRepo.GetByID(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.IsDeleted() {
+		return nil, domain.ErrDocumentNotFound
+	}
+	if parent.IsArchived() {
+		return nil, domain.ErrParentDocumentArchived
+	}
+	if !parent.CanBeParent() {
+		return nil, domain.ErrInvalidDocumentType
+	}
+	if err := d.checkAccess(ctx, userID, parent, domain.PermissionEdit); err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// checkSpaceEdit 检查空间存在且用户是可以编辑文档的成员
+func (d *documentDuplicateService) checkSpaceEdit(ctx context.Context, userID, spaceID int64) error {
+	space, err := d.spaceRepo.GetByID(ctx, spaceID)
+	if err != nil {
+		return err
+	}
+	if !space.IsActive() {
+		return domain.ErrSpaceNotFound
+	}
+
+	member, err := d.spaceRepo.GetMember(ctx, spaceID, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrNotSpaceMember
+		}
+		return err
+	}
+	if !member.CanEditDocuments() {
+		return domain.ErrSpacePermissionDenied
+	}
+	return nil
+}
+
+// checkAccess 检查用户是否为文档所有者或具有指定权限
+func (d *documentDuplicateService) checkAccess(ctx context.Context, userID int64, document *domain.Document, permission domain.Permission) error {
+	if document.OwnerID == userID {
+		return nil
+	}
+	hasAccess, err := d.permUsecase.CheckPermission(ctx, document.ID, userID, permission)
+	if err != nil {
+		return err
+	}
+	if !hasAccess {
+		return domain.ErrPermissionDenied
+	}
+	return nil
+}
+
+// duplicableIDs 子树中用户可以查看的未删除文档，原文档已检查过权限，总是包含在内
+func (d *documentDuplicateService) duplicableIDs(ctx context.Context, userID, sourceID int64, subtreeIDs []int64) ([]int64, error) {
+	documents, err := readableDocuments(ctx, d.documentRepo, d.permissionRepo, d.shareRepo, d.spaceRepo, userID, subtreeIDs)
+	if err != nil {
+		return nil, err
+	}
+	visibleIDs := make([]int64, len(documents))
+	for i, document := range documents {
+		visibleIDs[i] = document.ID
+	}
+	if !containsID(visibleIDs, sourceID) {
+		visibleIDs = append(visibleIDs, sourceID)
+	}
+	return visibleIDs, nil
+}
+
+// afterDuplicate 同步副本的搜索索引并记录用户编辑了副本根文档
+func (d *documentDuplicateService) afterDuplicate(ctx context.Context, userID, rootID int64) {
+	if d.search != nil {
+		d.search.SyncSubtree(ctx, rootID)
+	}
+	if d.access != nil {
+		d.access.RecordAccess(ctx, userID, rootID, domain.DocumentAccessEdit)
+	}
+}
+
+// containsID 检查ID列表中是否包含指定ID
+func containsID(ids []int64, id int64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDocumentDuplicateService 创建新的文档复制服务实例
+// asyncThreshold 为同步复制的最大文档数，不大于 0 时使用默认值
+func NewDocumentDuplicateService(
+	duplicateRepo domain.DocumentDuplicateRepository,
+	jobRepo domain.DocumentDuplicateJobRepository,
+	documentRepo domain.DocumentRepository,
+	permUsecase domain.DocumentPermissionUsecase,
+	permissionRepo domain.DocumentPermissionRepository,
+	shareRepo domain.DocumentShareRepository,
+	spaceRepo domain.SpaceRepository,
+	search domain.DocumentSearchSyncer,
+	access domain.DocumentAccessRecorder,
+	asyncThreshold int,
+) domain.DocumentDuplicateUsecase {
+	if asyncThreshold <= 0 {
+		asyncThreshold = defaultDuplicateAsyncThreshold
+	}
+	return &documentDuplicateService{
+		duplicateRepo:  duplicateRepo,
+		jobRepo:        jobRepo,
+		documentRepo:   documentRepo,
+		permUsecase:    permUsecase,
+		permissionRepo: permissionRepo,
+		shareRepo:      shareRepo,
+		spaceRepo:      spaceRepo,
+		search:         search,
+		access:         access,
+		asyncThreshold: asyncThreshold,
+	}
+}
@@ -0,0 +1,144 @@
+package document
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"DOC/domain"
+)
+
+// MockDocumentDuplicateRepository Mock 文档复制仓储
+type MockDocumentDuplicateRepository struct {
+	mock.Mock
+}
+
+func (m *MockDocumentDuplicateRepository) Duplicate(ctx context.Context, plan *domain.DocumentDuplicatePlan, progress func(copied int)) (int64, error) {
+	args := m.Called(ctx, plan, progress)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockDocumentPermissionRepository Mock 文档权限仓储，只实现复制用到的方法
+type MockDocumentPermissionRepository struct {
+	domain.DocumentPermissionRepository
+	mock.Mock
+}
+
+func (m *MockDocumentPermissionRepository) GetByDocuments(ctx context.Context, documentIDs []int64) ([]*domain.DocumentPermission, error) {
+	args := m.Called(ctx, documentIDs)
+	return args.Get(0).([]*domain.DocumentPermission), args.Error(1)
+}
+
+// MockDocumentShareRepository Mock 文档分享仓储，只实现测试用到的方法
+type MockDocumentShareRepository struct {
+	domain.DocumentShareRepository
+	mock.Mock
+}
+
+func (m *MockDocumentShareRepository) GetShareUsersByDocuments(ctx context.Context, documentIDs []int64) ([]*domain.DocumentShareUser, error) {
+	args := m.Called(ctx, documentIDs)
+	return args.Get(0).([]*domain.DocumentShareUser), args.Error(1)
+}
+
+func (m *MockDocumentShareRepository) GetShareUsersByUser(ctx context.Context, userID int64) ([]*domain.DocumentShareUser, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*domain.DocumentShareUser), args.Error(1)
+}
+
+// MockSpaceRepository Mock 空间仓储，只实现复制用到的方法
+type MockSpaceRepository struct {
+	domain.SpaceRepository
+	mock.Mock
+}
+
+func (m *MockSpaceRepository) GetUserSpaces(ctx context.Context, userID int64) ([]*domain.Space, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*domain.Space), args.Error(1)
+}
+
+func (m *MockSpaceRepository) GetDocumentSpaces(ctx context.Context, documentIDs []int64) ([]*domain.SpaceDocument, error) {
+	args := m.Called(ctx, documentIDs)
+	return args.Get(0).([]*domain.SpaceDocument), args.Error(1)
+}
+
+func TestDuplicateDocument_CopiesReadableDescendants(t *testing.T) {
+	// 准备 Mock
+	mockDuplicateRepo := new(MockDocumentDuplicateRepository)
+	mockDocRepo := new(MockDocumentRepository)
+	mockPermissionRepo := new(MockDocumentPermissionRepository)
+	mockShareRepo := new(MockDocumentShareRepository)
+	mockSpaceRepo := new(MockSpaceRepository)
+	service := NewDocumentDuplicateService(mockDuplicateRepo, nil, mockDocRepo, nil, mockPermissionRepo, mockShareRepo, mockSpaceRepo, nil, nil, 0)
+
+	ctx := context.Background()
+	userID := int64(1)
+	otherID := int64(2)
+	spaceID := int64(7)
+	source := &domain.Document{ID: 1, Title: "文件夹", Type: domain.DocumentTypeFolder, OwnerID: userID}
+
+	// 2 自己的文档，3 有查看权限，4 有私有分享，5 在所在空间中，6 无权查看，8 在回收站中
+	subtreeIDs := []int64{1, 2, 3, 4, 5, 6, 8}
+	documents := []*domain.Document{
+		source,
+		{ID: 2, OwnerID: userID, Status: domain.DocumentStatusArchived},
+		{ID: 3, OwnerID: otherID},
+		{ID: 4, OwnerID: otherID},
+		{ID: 5, OwnerID: otherID, SpaceID: &spaceID},
+		{ID: 6, OwnerID: otherID},
+		{ID: 8, OwnerID: userID, Status: domain.DocumentStatusDeleted},
+	}
+	candidates := []int64{1, 2, 3, 4, 5, 6}
+	duplicated := &domain.Document{ID: 100, Title: "文件夹 - 副本", OwnerID: userID}
+
+	mockDocRepo.On("GetByID", ctx, int64(1)).Return(source, nil)
+	mockDocRepo.On("GetSubtreeIDs", ctx, int64(1)).Return(subtreeIDs, nil)
+	mockDocRepo.On("GetByIDs", ctx, subtreeIDs, false).Return(documents, nil)
+	mockPermissionRepo.On("GetByDocuments", ctx, candidates).Return([]*domain.DocumentPermission{
+		{DocumentID: 3, UserID: userID, Permission: domain.PermissionView},
+		{DocumentID: 6, UserID: 9, Permission: domain.PermissionView},
+	}, nil)
+	mockShareRepo.On("GetShareUsersByDocuments", ctx, candidates).Return([]*domain.DocumentShareUser{
+		{UserID: userID, Share: &domain.DocumentShare{DocumentID: 4}},
+	}, nil)
+	mockSpaceRepo.On("GetUserSpaces", ctx, userID).Return([]*domain.Space{
+		{ID: spaceID, Status: domain.SpaceStatusActive},
+	}, nil)
+	mockSpaceRepo.On("GetDocumentSpaces", ctx, candidates).Return([]*domain.SpaceDocument{}, nil)
+	mockDuplicateRepo.On("Duplicate", ctx, mock.AnythingOfType("*domain.DocumentDuplicatePlan"), mock.Anything).Return(int64(100), nil)
+	mockDocRepo.On("GetByID", ctx, int64(100)).Return(duplicated, nil)
+
+	// 执行测试
+	result, err := service.DuplicateDocument(ctx, userID, 1, nil)
+
+	// 验证结果
+	assert.NoError(t, err)
+	assert.Equal(t, duplicated, result.Document)
+	plan := mockDuplicateRepo.Calls[0].Arguments.Get(1).(*domain.DocumentDuplicatePlan)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, plan.DocumentIDs)
+	assert.Equal(t, "文件夹 - 副本", plan.Title)
+	assert.Nil(t, plan.ParentID)
+}
+
+func TestDuplicateDocument_IntoOwnSubtree(t *testing.T) {
+	// 准备 Mock
+	mockDocRepo := new(MockDocumentRepository)
+	service := NewDocumentDuplicateService(nil, nil, mockDocRepo, nil, nil, nil, nil, nil, nil, 0)
+
+	ctx := context.Background()
+	userID := int64(1)
+	source := &domain.Document{ID: 1, Type: domain.DocumentTypeFolder, OwnerID: userID}
+	target := &domain.Document{ID: 3, Type: domain.DocumentTypeFolder, OwnerID: userID}
+
+	mockDocRepo.On("GetByID", ctx, int64(1)).Return(source, nil)
+	mockDocRepo.On("GetByID", ctx, int64(3)).Return(target, nil)
+	mockDocRepo.On("GetSubtreeIDs", ctx, int64(1)).Return([]int64{1, 2, 3}, nil)
+
+	// 执行测试
+	result, err := service.DuplicateDocument(ctx, userID, 1, &domain.DocumentDuplicateOptions{TargetParentID: &target.ID})
+
+	// 验证结果
+	assert.ErrorIs(t, err, domain.ErrDuplicateIntoSelf)
+	assert.Nil(t, result)
+}
@@ -11,6 +11,7 @@ import (
 type documentFavoriteService struct {
 	favoriteRepo domain.DocumentFavoriteRepository
 	documentRepo domain.DocumentRepository
+	titles       domain.DocumentTitleSyncer // 快速切换标题索引失效（可为空）
 }
 
 // ToggleFavorite 切换文档收藏状态
@@ -32,9 +33,9 @@ func (d *documentFavoriteService) ToggleFavorite(ctx context.Context, userID, do
 		return false, domain.ErrDocumentNotFound
 	}
 
-	// 检查文档是否处于活跃状态
-	if !document.IsActive() {
-		return false, domain.ErrDocumentNotFound // 对于非活跃文档，表现为不存在
+	// 检查文档是否已删除，归档的文档仍可收藏
+	if document.IsDeleted() {
+		return false, domain.ErrDocumentNotFound // 已删除的文档表现为不存在
 	}
 
 	// 执行切换操作
@@ -42,6 +43,7 @@ func (d *documentFavoriteService) ToggleFavorite(ctx context.Context, userID, do
 	if err != nil {
 		return false, err
 	}
+	d.invalidateTitles(ctx, userID)
 
 	return isFavorited, nil
 }
@@ -95,7 +97,11 @@ func (d *documentFavoriteService) RemoveFavorite(ctx context.Context, userID, do
 	}
 
 	// 删除收藏记录
-	return d.favoriteRepo.DeleteByDocumentAndUser(ctx, documentID, userID)
+	if err := d.favoriteRepo.DeleteByDocumentAndUser(ctx, documentID, userID); err != nil {
+		return err
+	}
+	d.invalidateTitles(ctx, userID)
+	return nil
 }
 
 // GetMyFavorites 获取用户的收藏文档列表
@@ -114,7 +120,7 @@ func (d *documentFavoriteService) GetMyFavorites(ctx context.Context, userID int
 	// 过滤掉已删除或不可访问的文档
 	validFavorites := make([]*domain.DocumentFavorite, 0, len(favorites))
 	for _, favorite := range favorites {
-		if favorite.Document != nil && favorite.Document.IsActive() {
+		if favorite.Document != nil && !favorite.Document.IsDeleted() {
 			validFavorites = append(validFavorites, favorite)
 		}
 	}
@@ -164,7 +170,7 @@ func (d *documentFavoriteService) HandleFavoriteAction(ctx context.Context, user
 		if err != nil {
 			return err
 		}
-		if document == nil || !document.IsActive() {
+		if document == nil || document.IsDeleted() {
 			return domain.ErrDocumentNotFound
 		}
 
@@ -179,7 +185,11 @@ func (d *documentFavoriteService) HandleFavoriteAction(ctx context.Context, user
 			return err
 		}
 
-		return d.favoriteRepo.Store(ctx, favorite)
+		if err := d.favoriteRepo.Store(ctx, favorite); err != nil {
+			return err
+		}
+		d.invalidateTitles(ctx, userID)
+		return nil
 
 	case "unfavorite", "remove":
 		// 取消收藏
@@ -190,12 +200,22 @@ func (d *documentFavoriteService) HandleFavoriteAction(ctx context.Context, user
 	}
 }
 
+// invalidateTitles 收藏变化后使用户的快速切换标题索引失效，收藏文档在其中加权
+func (d *documentFavoriteService) invalidateTitles(ctx context.Context, userID int64) {
+	if d.titles == nil {
+		return
+	}
+	d.titles.InvalidateUsers(ctx, userID)
+}
+
 // NewDocumentFavoriteService 创建新的文档收藏服务实例
 func NewDocumentFavoriteService(
 	favoriteRepo domain.DocumentFavoriteRepository,
-	documentRepo domain.DocumentRepository) domain.DocumentFavoriteUsecase {
+	documentRepo domain.DocumentRepository,
+	titles domain.DocumentTitleSyncer) domain.DocumentFavoriteUsecase {
 	return &documentFavoriteService{
 		favoriteRepo: favoriteRepo,
 		documentRepo: documentRepo,
+		titles:       titles,
 	}
 }
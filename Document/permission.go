@@ -10,6 +10,7 @@ import (
 type documentPermissionService struct {
 	permissionRepo domain.DocumentPermissionRepository
 	documentRepo   domain.DocumentRepository
+	search         domain.DocumentSearchSyncer // 搜索索引同步（可为空）
 }
 
 // GrantPermission 授予用户文档权限
@@ -30,7 +31,7 @@ func (d *documentPermissionService) GrantPermission(ctx context.Context, userID,
 	if err != nil {
 		return err
 	}
-	if document == nil || !document.IsActive() {
+	if document == nil || document.IsDeleted() {
 		return domain.ErrDocumentNotFound
 	}
 
@@ -78,7 +79,13 @@ func (d *documentPermissionService) GrantPermission(ctx context.Context, userID,
 		return err
 	}
 
-	return d.permissionRepo.Store(ctx, newPermission)
+	if err := d.permissionRepo.Store(ctx, newPermission); err != nil {
+		return err
+	}
+
+	// 可查看文档的用户变化，同步搜索索引
+	d.syncSearch(ctx, documentID)
+	return nil
 }
 
 // RevokePermission 撤销用户文档权限
@@ -126,7 +133,13 @@ func (d *documentPermissionService) RevokePermission(ctx context.Context, userID
 	}
 
 	// 删除权限记录
-	return d.permissionRepo.Delete(ctx, existingPermission.ID)
+	if err := d.permissionRepo.Delete(ctx, existingPermission.ID); err != nil {
+		return err
+	}
+
+	// 可查看文档的用户变化，同步搜索索引
+	d.syncSearch(ctx, documentID)
+	return nil
 }
 
 // UpdatePermission 更新用户文档权限
@@ -147,7 +160,7 @@ func (d *documentPermissionService) UpdatePermission(ctx context.Context, userID
 	if err != nil {
 		return err
 	}
-	if document == nil || !document.IsActive() {
+	if document == nil || document.IsDeleted() {
 		return domain.ErrDocumentNotFound
 	}
 
@@ -300,7 +313,7 @@ func (d *documentPermissionService) CanAccessDocument(ctx context.Context, docum
 	if err != nil {
 		return false, "", err
 	}
-	if document == nil || !document.IsActive() {
+	if document == nil || document.IsDeleted() {
 		return false, "", domain.ErrDocumentNotFound
 	}
 
@@ -345,7 +358,7 @@ func (d *documentPermissionService) BatchGrantPermission(ctx context.Context, us
 	if err != nil {
 		return err
 	}
-	if document == nil || !document.IsActive() {
+	if document == nil || document.IsDeleted() {
 		return domain.ErrDocumentNotFound
 	}
 
@@ -375,7 +388,13 @@ func (d *documentPermissionService) BatchGrantPermission(ctx context.Context, us
 	}
 
 	// 执行批量授权
-	return d.permissionRepo.BatchGrantPermission(ctx, documentID, validUserIDs, permission, userID)
+	if err := d.permissionRepo.BatchGrantPermission(ctx, documentID, validUserIDs, permission, userID); err != nil {
+		return err
+	}
+
+	// 可查看文档的用户变化，同步搜索索引
+	d.syncSearch(ctx, documentID)
+	return nil
 }
 
 // BatchRevokePermission 批量撤销权限
@@ -429,7 +448,21 @@ func (d *documentPermissionService) BatchRevokePermission(ctx context.Context, u
 	}
 
 	// 执行批量撤销
-	return d.permissionRepo.BatchRevokePermission(ctx, documentID, validUserIDs)
+	if err := d.permissionRepo.BatchRevokePermission(ctx, documentID, validUserIDs); err != nil {
+		return err
+	}
+
+	// 可查看文档的用户变化，同步搜索索引
+	d.syncSearch(ctx, documentID)
+	return nil
+}
+
+// syncSearch 同步文档的搜索索引，未配置搜索索引时忽略
+func (d *documentPermissionService) syncSearch(ctx context.Context, documentID int64) {
+	if d.search == nil {
+		return
+	}
+	d.search.SyncDocuments(ctx, documentID)
 }
 
 // isValidPermission 验证权限是否有效
@@ -452,9 +485,11 @@ func isValidPermission(permission domain.Permission) bool {
 // NewDocumentPermissionService 创建新的文档权限服务实例
 func NewDocumentPermissionService(
 	permissionRepo domain.DocumentPermissionRepository,
-	documentRepo domain.DocumentRepository) domain.DocumentPermissionUsecase {
+	documentRepo domain.DocumentRepository,
+	search domain.DocumentSearchSyncer) domain.DocumentPermissionUsecase {
 	return &documentPermissionService{
 		permissionRepo: permissionRepo,
 		documentRepo:   documentRepo,
+		search:         search,
 	}
 }
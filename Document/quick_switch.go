@@ -0,0 +1,424 @@
+package document
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"DOC/domain"
+	"DOC/pkg/pinyin"
+	"DOC/pkg/search"
+)
+
+const (
+	quickSwitchDefaultLimit = 10
+	quickSwitchMaxLimit     = 50
+	quickSwitchRecentCount  = 50 // 参与加权的最近访问文档数
+
+	quickSwitchRecentBoost   = 30.0 // 最近访问的最高加分，按访问先后递减
+	quickSwitchFavoriteBoost = 20.0 // 收藏的加分
+)
+
+// documentQuickSwitchService 快速切换业务逻辑实现
+// 实现 domain.DocumentQuickSwitchUsecase 接口；用户的标题索引由搜索索引按可见范围构建后缓存在 Redis 中，
+// 查询时只读取一次缓存并在内存中匹配；文档的标题、状态或可见范围变化时使新旧可见用户的缓存失效，
+// 收藏和空间成员变化由对应服务使相关用户失效，缓存过期时间不晚于用户最早过期的私有分享，分享过期后重建
+type documentQuickSwitchService struct {
+	index        domain.SearchIndex
+	documentRepo domain.DocumentRepository
+	shareRepo    domain.DocumentShareRepository // 私有分享的过期时间，用于缩短缓存时间
+	spaceRepo    domain.SpaceRepository
+	favoriteRepo domain.DocumentFavoriteRepository
+	access       domain.DocumentAccessUsecase // 最近访问，用于加权
+	cache        domain.QuickSwitchCacheRepository
+	cacheTTL     time.Duration
+	maxEntries   int // 每个用户标题索引的最大文档数，超出时保留最近更新的文档
+}
+
+// QuickSwitch 按前缀、包含、拼音首字母和模糊匹配查找用户可以查看的文档标题
+func (d *documentQuickSwitchService) QuickSwitch(ctx context.Context, userID int64, query string, limit int) ([]*domain.QuickSwitchResult, error) {
+	if userID <= 0 {
+		return nil, domain.ErrInvalidUser
+	}
+	if limit <= 0 {
+		limit = quickSwitchDefaultLimit
+	}
+	if limit > quickSwitchMaxLimit {
+		limit = quickSwitchMaxLimit
+	}
+
+	// 1. 读取标题索引，未缓存时构建
+	titles, err := d.getTitles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 最近访问读取失败时不加权
+	recentIDs, err := d.access.RecentDocumentIDs(ctx, userID, quickSwitchRecentCount)
+	if err != nil {
+		log.Printf("读取最近访问失败: userID=%d, err=%v", userID, err)
+	}
+	recentRanks := make(map[int64]int, len(recentIDs))
+	for i, id := range recentIDs {
+		if _, ok := recentRanks[id]; !ok {
+			recentRanks[id] = i
+		}
+	}
+	favorites := make(map[int64]bool, len(titles.FavoriteIDs))
+	for _, id := range titles.FavoriteIDs {
+		favorites[id] = true
+	}
+
+	// 2. 匹配标题并按最近访问和收藏加权，查询为空时只返回最近访问和收藏的文档
+	query = strings.TrimSpace(query)
+	results := make([]*domain.QuickSwitchResult, 0, limit)
+	for _, entry := range titles.Entries {
+		rank, isRecent := recentRanks[entry.ID]
+		result := &domain.QuickSwitchResult{
+			ID:         entry.ID,
+			Title:      entry.Title,
+			Type:       entry.Type,
+			ParentPath: entry.ParentPath,
+			UpdatedAt:  entry.UpdatedAt,
+			IsRecent:   isRecent,
+			IsFavorite: favorites[entry.ID],
+		}
+
+		if query != "" {
+			match, ok := search.MatchTitle(entry.Title, entry.Initials, query)
+			if !ok {
+				continue
+			}
+			result.MatchType = match.Kind
+			result.Highlights = match.Highlights
+			result.Score = match.Score
+		} else if !result.IsRecent && !result.IsFavorite {
+			continue
+		}
+
+		if isRecent {
+			result.Score += quickSwitchRecentBoost * float64(len(recentIDs)-rank) / float64(len(recentIDs))
+		}
+		if result.IsFavorite {
+			result.Score += quickSwitchFavoriteBoost
+		}
+		results = append(results, result)
+	}
+
+	// 3. 按得分排序，得分相同时最近更新的在前
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if !results[i].UpdatedAt.Equal(results[j].UpdatedAt) {
+			return results[i].UpdatedAt.After(results[j].UpdatedAt)
+		}
+		return results[i].ID > results[j].ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// SyncTitles 使标题或可见范围有变化的文档的新旧可见用户的标题索引失效
+// 文档的摘要与上次同步时相同时跳过，因此只修改内容不会使缓存失效
+func (d *documentQuickSwitchService) SyncTitles(ctx context.Context, documents []*domain.SearchDocument, removedIDs []int64) {
+	if err := d.syncTitles(ctx, documents, removedIDs); err != nil {
+		log.Printf("同步快速切换标题索引失败: removedIDs=%v, err=%v", removedIDs, err)
+	}
+}
+
+// InvalidateUsers 使用户的标题索引失效
+func (d *documentQuickSwitchService) InvalidateUsers(ctx context.Context, userIDs ...int64) {
+	if err := d.cache.DeleteTitles(ctx, userIDs...); err != nil {
+		log.Printf("清除快速切换标题索引失败: userIDs=%v, err=%v", userIDs, err)
+	}
+}
+
+// getTitles 读取用户的标题索引，未缓存或缓存不可用时从搜索索引构建
+func (d *documentQuickSwitchService) getTitles(ctx context.Context, userID int64) (*domain.QuickSwitchTitles, error) {
+	titles, err := d.cache.GetTitles(ctx, userID)
+	if err != nil {
+		log.Printf("读取快速切换标题索引失败: userID=%d, err=%v", userID, err)
+	}
+	if titles != nil {
+		return titles, nil
+	}
+
+	titles, err = d.buildTitles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 用户的私有分享过期后文档不再可见，缓存不能超过最早的过期时间
+	expiration := d.cacheTTL
+	expiresAt, err := d.earliestShareExpiry(ctx, userID, titles.BuiltAt)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt != nil {
+		if until := expiresAt.Sub(titles.BuiltAt); until < expiration {
+			expiration = until
+		}
+	}
+	if err := d.cache.SetTitles(ctx, userID, titles, expiration); err != nil {
+		log.Printf("缓存快速切换标题索引失败: userID=%d, err=%v", userID, err)
+	}
+	return titles, nil
+}
+
+// earliestShareExpiry 用户尚未过期的私有分享中最早的过期时间，都不过期时返回 nil
+func (d *documentQuickSwitchService) earliestShareExpiry(ctx context.Context, userID int64, now time.Time) (*time.Time, error) {
+	shareUsers, err := d.shareRepo.GetShareUsersByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var earliest *time.Time
+	for _, shareUser := range shareUsers {
+		if shareUser.Share == nil || shareUser.Share.ExpiresAt == nil || !shareUser.Share.ExpiresAt.After(now) {
+			continue
+		}
+		if earliest == nil || shareUser.Share.ExpiresAt.Before(*earliest) {
+			earliest = shareUser.Share.ExpiresAt
+		}
+	}
+	return earliest, nil
+}
+
+// buildTitles 从搜索索引查出用户可以查看的所有未归档文档，并加载收藏
+func (d *documentQuickSwitchService) buildTitles(ctx context.Context, userID int64) (*domain.QuickSwitchTitles, error) {
+	spaceIDs, err := activeSpaceIDs(ctx, d.spaceRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 搜索索引按可见范围过滤，关键词为空时按更新时间返回所有文档，只需要文档ID，不统计分面
+	result, err := d.index.Search(ctx, &domain.SearchQuery{
+		UserID:     userID,
+		SpaceIDs:   spaceIDs,
+		Limit:      d.maxEntries,
+		Now:        time.Now(),
+		SkipFacets: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	documents, err := d.documentRepo.GetByIDs(ctx, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	documentMap := make(map[int64]*domain.Document, len(documents))
+	for _, document := range documents {
+		if !document.IsDeleted() {
+			documentMap[document.ID] = document
+		}
+	}
+
+	// 2. 按索引顺序生成条目，父路径只由用户可见的祖先组成
+	titles := &domain.QuickSwitchTitles{
+		Entries: make([]*domain.QuickSwitchEntry, 0, len(documentMap)),
+		BuiltAt: time.Now(),
+	}
+	for _, id := range ids {
+		document, ok := documentMap[id]
+		if !ok {
+			continue
+		}
+		entry := &domain.QuickSwitchEntry{
+			ID:         document.ID,
+			Title:      document.Title,
+			Type:       document.Type,
+			ParentPath: domain.GetDocumentParentPath(document, documentMap),
+			UpdatedAt:  document.UpdatedAt,
+		}
+		if pinyin.HasHan(document.Title) {
+			entry.Initials = pinyin.Initials(document.Title)
+		}
+		titles.Entries = append(titles.Entries, entry)
+	}
+
+	// 3. 收藏用于加权
+	favorites, err := d.favoriteRepo.GetByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, favorite := range favorites {
+		titles.FavoriteIDs = append(titles.FavoriteIDs, favorite.DocumentID)
+	}
+	return titles, nil
+}
+
+// syncTitles 比较文档的摘要与上次同步时的记录，收集需要失效的用户
+func (d *documentQuickSwitchService) syncTitles(ctx context.Context, documents []*domain.SearchDocument, removedIDs []int64) error {
+	ids := make([]int64, 0, len(documents)+len(removedIDs))
+	for _, document := range documents {
+		ids = append(ids, document.ID)
+	}
+	ids = append(ids, removedIDs...)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	previous, err := d.cache.GetAudiences(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	invalidated := make(map[int64]bool)
+	spaceMembers := make(map[int64][]int64) // 本次同步内复用空间成员
+	for _, document := range documents {
+		signature := titleSignature(document)
+		old, ok := previous[document.ID]
+		if ok && old.Signature == signature {
+			continue
+		}
+
+		audience, err := d.audience(ctx, document, spaceMembers)
+		if err != nil {
+			return err
+		}
+		for _, userID := range audience {
+			invalidated[userID] = true
+		}
+		if ok {
+			for _, userID := range old.UserIDs {
+				invalidated[userID] = true
+			}
+		}
+		if err := d.cache.SetAudience(ctx, document.ID, &domain.QuickSwitchAudience{Signature: signature, UserIDs: audience}); err != nil {
+			return err
+		}
+	}
+
+	// 移除的文档使上次同步时的可见用户失效
+	for _, id := range removedIDs {
+		if old, ok := previous[id]; ok {
+			for _, userID := range old.UserIDs {
+				invalidated[userID] = true
+			}
+		}
+	}
+	if err := d.cache.DeleteAudiences(ctx, removedIDs...); err != nil {
+		return err
+	}
+
+	userIDs := make([]int64, 0, len(invalidated))
+	for userID := range invalidated {
+		userIDs = append(userIDs, userID)
+	}
+	return d.cache.DeleteTitles(ctx, userIDs...)
+}
+
+// audience 可以看到文档的用户：所有者、被授予权限或私有分享的用户以及文档所在空间的创建者和成员
+func (d *documentQuickSwitchService) audience(ctx context.Context, document *domain.SearchDocument, spaceMembers map[int64][]int64) ([]int64, error) {
+	seen := map[int64]bool{document.OwnerID: true}
+	audience := []int64{document.OwnerID}
+	add := func(userID int64) {
+		if !seen[userID] {
+			seen[userID] = true
+			audience = append(audience, userID)
+		}
+	}
+
+	for _, readerID := range document.ReaderIDs {
+		add(readerID)
+	}
+	for _, reader := range document.ShareReaders {
+		add(reader.UserID)
+	}
+	for _, spaceID := range document.SpaceIDs {
+		members, ok := spaceMembers[spaceID]
+		if !ok {
+			space, err := d.spaceRepo.GetByID(ctx, spaceID)
+			if err != nil && !errors.Is(err, domain.ErrSpaceNotFound) {
+				return nil, err
+			}
+			if space != nil {
+				members = append(members, space.CreatedBy)
+			}
+			spaceMemberList, err := d.spaceRepo.GetMembers(ctx, spaceID)
+			if err != nil {
+				return nil, err
+			}
+			for _, member := range spaceMemberList {
+				members = append(members, member.UserID)
+			}
+			spaceMembers[spaceID] = members
+		}
+		for _, userID := range members {
+			add(userID)
+		}
+	}
+	return audience, nil
+}
+
+// titleSignature 文档中影响标题索引的字段的摘要，用户和空间按ID排序后参与计算
+func titleSignature(document *domain.SearchDocument) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s|%d|%d", document.Title, document.Type, document.Status, document.OwnerID)
+	if document.ParentID != nil {
+		fmt.Fprintf(&b, "|p%d", *document.ParentID)
+	}
+	for _, id := range sortedIDs(document.ReaderIDs) {
+		fmt.Fprintf(&b, "|r%d", id)
+	}
+	for _, id := range sortedIDs(document.SpaceIDs) {
+		fmt.Fprintf(&b, "|s%d", id)
+	}
+
+	shares := make([]string, len(document.ShareReaders))
+	for i, reader := range document.ShareReaders {
+		shares[i] = fmt.Sprintf("|u%d", reader.UserID)
+		if reader.ExpiresAt != nil {
+			shares[i] += fmt.Sprintf("@%d", reader.ExpiresAt.Unix())
+		}
+	}
+	sort.Strings(shares)
+	b.WriteString(strings.Join(shares, ""))
+
+	sum := sha1.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// sortedIDs 返回排序后的ID副本
+func sortedIDs(ids []int64) []int64 {
+	sorted := append([]int64(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// NewDocumentQuickSwitchService 创建新的快速切换服务实例
+func NewDocumentQuickSwitchService(
+	index domain.SearchIndex,
+	documentRepo domain.DocumentRepository,
+	shareRepo domain.DocumentShareRepository,
+	spaceRepo domain.SpaceRepository,
+	favoriteRepo domain.DocumentFavoriteRepository,
+	access domain.DocumentAccessUsecase,
+	cache domain.QuickSwitchCacheRepository,
+	cacheTTL time.Duration,
+	maxEntries int) domain.DocumentQuickSwitchUsecase {
+	return &documentQuickSwitchService{
+		index:        index,
+		documentRepo: documentRepo,
+		shareRepo:    shareRepo,
+		spaceRepo:    spaceRepo,
+		favoriteRepo: favoriteRepo,
+		access:       access,
+		cache:        cache,
+		cacheTTL:     cacheTTL,
+		maxEntries:   maxEntries,
+	}
+}
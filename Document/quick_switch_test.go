@@ -0,0 +1,37 @@
+package document
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"DOC/domain"
+)
+
+func TestEarliestShareExpiry_IgnoresExpiredAndPermanentShares(t *testing.T) {
+	// 准备 Mock
+	mockShareRepo := new(MockDocumentShareRepository)
+	service := NewDocumentQuickSwitchService(nil, nil, mockShareRepo, nil, nil, nil, nil, time.Minute, 0).(*documentQuickSwitchService)
+
+	ctx := context.Background()
+	userID := int64(1)
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	soon := now.Add(2 * time.Minute)
+	later := now.Add(time.Hour)
+	mockShareRepo.On("GetShareUsersByUser", ctx, userID).Return([]*domain.DocumentShareUser{
+		{UserID: userID, Share: &domain.DocumentShare{DocumentID: 2, ExpiresAt: &expired}},
+		{UserID: userID, Share: &domain.DocumentShare{DocumentID: 3}},
+		{UserID: userID, Share: &domain.DocumentShare{DocumentID: 4, ExpiresAt: &later}},
+		{UserID: userID, Share: &domain.DocumentShare{DocumentID: 5, ExpiresAt: &soon}},
+	}, nil)
+
+	// 执行测试
+	expiresAt, err := service.earliestShareExpiry(ctx, userID, now)
+
+	// 验证结果
+	assert.NoError(t, err)
+	assert.Equal(t, &soon, expiresAt)
+}
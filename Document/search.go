@@ -0,0 +1,435 @@
+package document
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"DOC/domain"
+	"DOC/pkg/diff"
+)
+
+// 重建索引时每批读取的文档数
+const reindexBatchSize = 200
+
+// documentSearchService 文档搜索业务逻辑实现
+// 实现 domain.DocumentSearchUsecase 接口，负责搜索索引的查询、同步和重建
+type documentSearchService struct {
+	index          domain.SearchIndex
+	documentRepo   domain.DocumentRepository
+	permissionRepo domain.DocumentPermissionRepository
+	shareRepo      domain.DocumentShareRepository
+	spaceRepo      domain.SpaceRepository
+	favoriteRepo   domain.DocumentFavoriteRepository
+	titles         domain.DocumentTitleSyncer // 快速切换标题索引同步（可为空）
+}
+
+// SearchDocuments 搜索用户可以查看的文档，默认不包含已归档的文档
+// 用户可以查看自己拥有、被授予权限、被私有分享以及所在空间中的文档；
+// 索引只返回文档ID和相关度，文档按ID从仓储读取，索引尚未同步的已删除文档被忽略
+func (d *documentSearchService) SearchDocuments(ctx context.Context, userID int64, filter *domain.DocumentSearchFilter) (*domain.DocumentSearchHits, error) {
+	empty := &domain.DocumentSearchHits{Hits: []*domain.DocumentSearchHit{}, Facets: domain.NewSearchFacets()}
+	if filter == nil || (strings.TrimSpace(filter.Keyword) == "" && !filter.HasFilters()) {
+		return empty, nil
+	}
+
+	// 1. 解析用户所在的空间和收藏过滤
+	query, err := d.buildQuery(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 查询索引
+	result, err := d.index.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if result.Facets == nil {
+		result.Facets = domain.NewSearchFacets()
+	}
+	if len(result.Hits) == 0 {
+		return &domain.DocumentSearchHits{Hits: []*domain.DocumentSearchHit{}, Total: result.Total, Facets: result.Facets}, nil
+	}
+
+	// 3. 按索引顺序加载文档
+	ids := make([]int64, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	documents, err := d.documentRepo.GetByIDs(ctx, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	documentMap := make(map[int64]*domain.Document, len(documents))
+	for _, document := range documents {
+		documentMap[document.ID] = document
+	}
+
+	hits := make([]*domain.DocumentSearchHit, 0, len(result.Hits))
+	var parentIDs []int64
+	for _, hit := range result.Hits {
+		document, ok := documentMap[hit.ID]
+		if !ok || document.IsDeleted() {
+			continue
+		}
+		hits = append(hits, &domain.DocumentSearchHit{
+			Document:  document,
+			PlainText: hit.PlainText,
+			Score:     hit.Score,
+		})
+		if document.ParentID != nil {
+			parentIDs = append(parentIDs, *document.ParentID)
+		}
+	}
+	page := &domain.DocumentSearchHits{Hits: hits, Total: result.Total, Facets: result.Facets}
+	if len(parentIDs) == 0 {
+		return page, nil
+	}
+
+	// 4. 一次性加载所有命中文档的祖先，用于拼接父路径
+	ancestors, err := d.documentRepo.GetWithAncestors(ctx, parentIDs)
+	if err != nil {
+		return nil, err
+	}
+	ancestorMap := make(map[int64]*domain.Document, len(ancestors))
+	for _, ancestor := range ancestors {
+		ancestorMap[ancestor.ID] = ancestor
+	}
+	for _, hit := range hits {
+		hit.ParentPath = domain.GetDocumentParentPath(hit.Document, ancestorMap)
+	}
+	return page, nil
+}
+
+// buildQuery 将搜索条件转换为索引查询，空间成员关系和收藏在查询时解析，不写入索引
+func (d *documentSearchService) buildQuery(ctx context.Context, userID int64, filter *domain.DocumentSearchFilter) (*domain.SearchQuery, error) {
+	query := &domain.SearchQuery{
+		UserID:          userID,
+		Keyword:         filter.Keyword,
+		Type:            filter.Type,
+		IncludeArchived: filter.IncludeArchived,
+		OwnerID:         filter.OwnerID,
+		SpaceID:         filter.SpaceID,
+		AncestorID:      filter.ParentID,
+		UpdatedAfter:    filter.UpdatedAfter,
+		UpdatedBefore:   filter.UpdatedBefore,
+		CreatedAfter:    filter.CreatedAfter,
+		CreatedBefore:   filter.CreatedBefore,
+		StarredOnly:     filter.StarredOnly,
+		SharedWithMe:    filter.SharedWithMe,
+		Limit:           filter.Limit,
+		Offset:          filter.Offset,
+		Now:             time.Now(),
+	}
+
+	spaceIDs, err := activeSpaceIDs(ctx, d.spaceRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+	query.SpaceIDs = spaceIDs
+
+	if filter.FavoritesOnly {
+		favorites, err := d.favoriteRepo.GetByUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		query.DocumentIDs = make([]int64, 0, len(favorites))
+		for _, favorite := range favorites {
+			query.DocumentIDs = append(query.DocumentIDs, favorite.DocumentID)
+		}
+	}
+	return query, nil
+}
+
+// SyncDocuments 按文档当前状态更新索引，已删除或不存在的文档从索引中移除
+func (d *documentSearchService) SyncDocuments(ctx context.Context, documentIDs ...int64) {
+	if len(documentIDs) == 0 {
+		return
+	}
+	if err := d.syncDocuments(ctx, documentIDs); err != nil {
+		log.Printf("同步搜索索引失败: documentIDs=%v, err=%v", documentIDs, err)
+	}
+}
+
+// SyncSubtree 同步文档自身及所有子孙文档
+// 文档已被永久删除时子树无法再查出，调用方需在删除前取得子树并使用 SyncDocuments
+func (d *documentSearchService) SyncSubtree(ctx context.Context, rootID int64) {
+	ids, err := d.documentRepo.GetSubtreeIDs(ctx, rootID)
+	if err != nil {
+		log.Printf("同步搜索索引失败: rootID=%d, err=%v", rootID, err)
+		return
+	}
+	d.SyncDocuments(ctx, ids...)
+}
+
+// Reindex 清空索引并从所有未删除的文档重建
+func (d *documentSearchService) Reindex(ctx context.Context) (int, error) {
+	if err := d.index.Reset(ctx); err != nil {
+		return 0, fmt.Errorf("failed to reset search index: %w", err)
+	}
+
+	indexed := 0
+	var afterID int64
+	for {
+		documents, err := d.documentRepo.ListUndeleted(ctx, afterID, reindexBatchSize)
+		if err != nil {
+			return indexed, err
+		}
+		if len(documents) == 0 {
+			return indexed, nil
+		}
+
+		if _, err := d.indexDocuments(ctx, documents); err != nil {
+			return indexed, err
+		}
+		indexed += len(documents)
+		afterID = documents[len(documents)-1].ID
+	}
+}
+
+// syncDocuments 重新读取文档及其权限并写入索引
+func (d *documentSearchService) syncDocuments(ctx context.Context, documentIDs []int64) error {
+	documents, err := d.documentRepo.GetByIDs(ctx, documentIDs, true)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[int64]bool, len(documents))
+	var active []*domain.Document
+	for _, document := range documents {
+		if document.IsDeleted() {
+			continue
+		}
+		found[document.ID] = true
+		active = append(active, document)
+	}
+
+	var removed []int64
+	for _, id := range documentIDs {
+		if !found[id] {
+			removed = append(removed, id)
+		}
+	}
+	if len(removed) > 0 {
+		if err := d.index.Delete(ctx, removed...); err != nil {
+			return err
+		}
+	}
+
+	indexed, err := d.indexDocuments(ctx, active)
+	if err != nil {
+		return err
+	}
+	if d.titles != nil {
+		d.titles.SyncTitles(ctx, indexed, removed)
+	}
+	return nil
+}
+
+// indexDocuments 加载文档的权限、私有分享用户和空间关联并写入索引，返回写入的索引文档
+func (d *documentSearchService) indexDocuments(ctx context.Context, documents []*domain.Document) ([]*domain.SearchDocument, error) {
+	if len(documents) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, len(documents))
+	for i, document := range documents {
+		ids[i] = document.ID
+	}
+
+	permissions, err := d.permissionRepo.GetByDocuments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	readers := make(map[int64][]int64)
+	for _, permission := range permissions {
+		if permission.CanView() {
+			readers[permission.DocumentID] = append(readers[permission.DocumentID], permission.UserID)
+		}
+	}
+
+	shareUsers, err := d.shareRepo.GetShareUsersByDocuments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	shareReaders := make(map[int64][]domain.SearchReader)
+	for _, shareUser := range shareUsers {
+		if shareUser.Share == nil {
+			continue
+		}
+		documentID := shareUser.Share.DocumentID
+		shareReaders[documentID] = append(shareReaders[documentID], domain.SearchReader{
+			UserID:    shareUser.UserID,
+			ExpiresAt: shareUser.Share.ExpiresAt,
+		})
+	}
+
+	spaceDocuments, err := d.spaceRepo.GetDocumentSpaces(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	spaces := make(map[int64][]int64)
+	for _, spaceDocument := range spaceDocuments {
+		spaces[spaceDocument.DocumentID] = append(spaces[spaceDocument.DocumentID], spaceDocument.SpaceID)
+	}
+	for _, document := range documents {
+		if document.SpaceID != nil && !isSpaceLinked(spaceDocuments, document.ID, *document.SpaceID) {
+			spaces[document.ID] = append(spaces[document.ID], *document.SpaceID)
+		}
+	}
+
+	indexed := make([]*domain.SearchDocument, 0, len(documents))
+	for _, document := range documents {
+		searchDocument := &domain.SearchDocument{
+			ID:           document.ID,
+			Title:        document.Title,
+			PlainText:    diff.PlainText(document.Content),
+			Type:         document.Type,
+			Status:       document.Status,
+			IsStarred:    document.IsStarred,
+			OwnerID:      document.OwnerID,
+			SpaceIDs:     spaces[document.ID],
+			ParentID:     document.ParentID,
+			ReaderIDs:    readers[document.ID],
+			ShareReaders: shareReaders[document.ID],
+			CreatedAt:    document.CreatedAt,
+			UpdatedAt:    document.UpdatedAt,
+		}
+		if err := d.index.Index(ctx, searchDocument); err != nil {
+			return indexed, err
+		}
+		indexed = append(indexed, searchDocument)
+	}
+	return indexed, nil
+}
+
+// activeSpaceIDs 用户所在的未删除空间，空间内的文档对用户可见
+func activeSpaceIDs(ctx context.Context, spaceRepo domain.SpaceRepository, userID int64) ([]int64, error) {
+	spaces, err := spaceRepo.GetUserSpaces(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var spaceIDs []int64
+	for _, space := range spaces {
+		if space.IsActive() {
+			spaceIDs = append(spaceIDs, space.ID)
+		}
+	}
+	return spaceIDs, nil
+}
+
+// readableDocuments 返回候选文档中用户可以查看的未删除文档（不含内容），包括已归档的文档
+// 与 CheckPermission 一样直接读取权限、私有分享和空间成员关系，不依赖可能滞后的搜索索引：
+// 所有者、有查看权限或未过期私有分享的用户以及文档所在空间的成员可以查看
+func readableDocuments(
+	ctx context.Context,
+	documentRepo domain.DocumentRepository,
+	permissionRepo domain.DocumentPermissionRepository,
+	shareRepo domain.DocumentShareRepository,
+	spaceRepo domain.SpaceRepository,
+	userID int64,
+	ids []int64) ([]*domain.Document, error) {
+	if len(ids) == 0 {
+		return []*domain.Document{}, nil
+	}
+
+	documents, err := documentRepo.GetByIDs(ctx, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]int64, 0, len(documents))
+	for _, document := range documents {
+		if !document.IsDeleted() {
+			candidates = append(candidates, document.ID)
+		}
+	}
+	if len(candidates) == 0 {
+		return []*domain.Document{}, nil
+	}
+
+	readable := make(map[int64]bool)
+	permissions, err := permissionRepo.GetByDocuments(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, permission := range permissions {
+		if permission.UserID == userID && permission.CanView() {
+			readable[permission.DocumentID] = true
+		}
+	}
+
+	shareUsers, err := shareRepo.GetShareUsersByDocuments(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, shareUser := range shareUsers {
+		if shareUser.UserID == userID && shareUser.Share != nil && !shareUser.Share.IsExpired() {
+			readable[shareUser.Share.DocumentID] = true
+		}
+	}
+
+	spaceIDs, err := activeSpaceIDs(ctx, spaceRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(spaceIDs) > 0 {
+		memberOf := make(map[int64]bool, len(spaceIDs))
+		for _, spaceID := range spaceIDs {
+			memberOf[spaceID] = true
+		}
+		spaceDocuments, err := spaceRepo.GetDocumentSpaces(ctx, candidates)
+		if err != nil {
+			return nil, err
+		}
+		for _, spaceDocument := range spaceDocuments {
+			if memberOf[spaceDocument.SpaceID] {
+				readable[spaceDocument.DocumentID] = true
+			}
+		}
+		for _, document := range documents {
+			if document.SpaceID != nil && memberOf[*document.SpaceID] {
+				readable[document.ID] = true
+			}
+		}
+	}
+
+	visible := make([]*domain.Document, 0, len(candidates))
+	for _, document := range documents {
+		if !document.IsDeleted() && (document.OwnerID == userID || readable[document.ID]) {
+			visible = append(visible, document)
+		}
+	}
+	return visible, nil
+}
+
+// isSpaceLinked 文档是否已通过关联加入空间
+func isSpaceLinked(spaceDocuments []*domain.SpaceDocument, documentID, spaceID int64) bool {
+	for _, spaceDocument := range spaceDocuments {
+		if spaceDocument.DocumentID == documentID && spaceDocument.SpaceID == spaceID {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDocumentSearchService 创建新的文档搜索服务实例
+func NewDocumentSearchService(
+	index domain.SearchIndex,
+	documentRepo domain.DocumentRepository,
+	permissionRepo domain.DocumentPermissionRepository,
+	shareRepo domain.DocumentShareRepository,
+	spaceRepo domain.SpaceRepository,
+	favoriteRepo domain.DocumentFavoriteRepository,
+	titles domain.DocumentTitleSyncer) domain.DocumentSearchUsecase {
+	return &documentSearchService{
+		index:          index,
+		documentRepo:   documentRepo,
+		permissionRepo: permissionRepo,
+		shareRepo:      shareRepo,
+		spaceRepo:      spaceRepo,
+		favoriteRepo:   favoriteRepo,
+		titles:         titles,
+	}
+}
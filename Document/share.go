@@ -13,6 +13,8 @@ type documentShareService struct {
 	shareRepo      domain.DocumentShareRepository
 	documentRepo   domain.DocumentRepository
 	permissionRepo domain.DocumentPermissionRepository
+	search         domain.DocumentSearchSyncer   // 搜索索引同步（可为空）
+	access         domain.DocumentAccessRecorder // 文档访问记录（可为空）
 }
 
 // CreateShareLink 创建文档分享链接
@@ -28,12 +30,12 @@ func (d *documentShareService) CreateShareLink(ctx context.Context, userID, docu
 		return nil, domain.ErrInvalidPermission
 	}
 
-	// 验证文档存在性和活跃状态
+	// 验证文档存在且未删除，归档的文档可以只读分享
 	document, err := d.documentRepo.GetByID(ctx, documentID)
 	if err != nil {
 		return nil, err
 	}
-	if document == nil || !document.IsActive() {
+	if document == nil || document.IsDeleted() {
 		return nil, domain.ErrDocumentNotFound
 	}
 
@@ -111,6 +113,7 @@ func (d *documentShareService) CreateShareLink(ctx context.Context, userID, docu
 				_ = d.shareRepo.AddShareUser(ctx, shareUser)
 			}
 		}
+		d.syncSearch(ctx, documentID)
 	}
 
 	return share, nil
@@ -176,6 +179,9 @@ func (d *documentShareService) UpdateShareLink(ctx context.Context, userID, shar
 	if err := d.shareRepo.Update(ctx, share); err != nil {
 		return nil, err
 	}
+	if expiresAt != nil && share.IsPrivate() {
+		d.syncSearch(ctx, share.DocumentID)
+	}
 
 	return share, nil
 }
@@ -210,11 +216,18 @@ func (d *documentShareService) DeleteShareLink(ctx context.Context, userID, shar
 	}
 
 	// 删除分享
-	return d.shareRepo.Delete(ctx, shareID)
+	if err := d.shareRepo.Delete(ctx, shareID); err != nil {
+		return err
+	}
+	if share.IsPrivate() {
+		d.syncSearch(ctx, share.DocumentID)
+	}
+	return nil
 }
 
 // GetSharedDocument 通过分享链接获取文档
-func (d *documentShareService) GetSharedDocument(ctx context.Context, linkID, password string, accessIP string) (*domain.Document, error) {
+// userID 为已登录的访问者，用于记录最近访问，匿名访问时为 0
+func (d *documentShareService) GetSharedDocument(ctx context.Context, userID int64, linkID, password string, accessIP string) (*domain.Document, error) {
 	// 验证分享访问
 	share, err := d.ValidateShareAccess(ctx, linkID, password)
 	if err != nil {
@@ -226,7 +239,7 @@ func (d *documentShareService) GetSharedDocument(ctx context.Context, linkID, pa
 	if err != nil {
 		return nil, err
 	}
-	if document == nil || !document.IsActive() {
+	if document == nil || document.IsDeleted() {
 		return nil, domain.ErrDocumentNotFound
 	}
 
@@ -234,6 +247,9 @@ func (d *documentShareService) GetSharedDocument(ctx context.Context, linkID, pa
 	if accessIP != "" {
 		_ = d.RecordShareAccess(ctx, share.ID, accessIP)
 	}
+	if d.access != nil && userID > 0 {
+		d.access.RecordAccess(ctx, userID, document.ID, domain.DocumentAccessView)
+	}
 
 	return document, nil
 }
@@ -268,7 +284,7 @@ func (d *documentShareService) ValidateShareAccess(ctx context.Context, linkID,
 	}
 
 	// 检查文档状态
-	if share.Document != nil && !share.Document.IsActive() {
+	if share.Document != nil && share.Document.IsDeleted() {
 		return nil, domain.ErrDocumentNotFound
 	}
 
@@ -349,7 +365,7 @@ func (d *documentShareService) GetMySharedDocuments(ctx context.Context, userID
 	// 过滤过期的分享和已删除的文档
 	validShares := make([]*domain.DocumentShare, 0, len(shares))
 	for _, share := range shares {
-		if !share.IsExpired() && share.Document != nil && share.Document.IsActive() {
+		if !share.IsExpired() && share.Document != nil && !share.Document.IsDeleted() {
 			validShares = append(validShares, share)
 		}
 	}
@@ -375,7 +391,7 @@ func (d *documentShareService) GetSharedWithMeDocuments(ctx context.Context, use
 	for _, share := range shares {
 		if !share.IsExpired() &&
 			share.Document != nil &&
-			share.Document.IsActive() &&
+			!share.Document.IsDeleted() &&
 			share.CreatedBy != userID { // 排除自己创建的分享
 			validShares = append(validShares, share)
 		}
@@ -447,6 +463,7 @@ func (d *documentShareService) AddShareUsers(ctx context.Context, userID, shareI
 			_ = d.shareRepo.AddShareUser(ctx, shareUser)
 		}
 	}
+	d.syncSearch(ctx, share.DocumentID)
 
 	return nil
 }
@@ -494,6 +511,7 @@ func (d *documentShareService) RemoveShareUsers(ctx context.Context, userID, sha
 			_ = d.shareRepo.RemoveShareUser(ctx, shareID, targetUserID)
 		}
 	}
+	d.syncSearch(ctx, share.DocumentID)
 
 	return nil
 }
@@ -586,14 +604,26 @@ func isValidSharePermission(permission domain.Permission) bool {
 	return false
 }
 
+// syncSearch 私有分享的用户变化后同步文档的搜索索引，未配置搜索索引时忽略
+func (d *documentShareService) syncSearch(ctx context.Context, documentID int64) {
+	if d.search == nil {
+		return
+	}
+	d.search.SyncDocuments(ctx, documentID)
+}
+
 // NewDocumentShareService 创建新的文档分享服务实例
 func NewDocumentShareService(
 	shareRepo domain.DocumentShareRepository,
 	documentRepo domain.DocumentRepository,
-	permissionRepo domain.DocumentPermissionRepository) domain.DocumentShareUsecase {
+	permissionRepo domain.DocumentPermissionRepository,
+	search domain.DocumentSearchSyncer,
+	access domain.DocumentAccessRecorder) domain.DocumentShareUsecase {
 	return &documentShareService{
 		shareRepo:      shareRepo,
 		documentRepo:   documentRepo,
 		permissionRepo: permissionRepo,
+		search:         search,
+		access:         access,
 	}
 }
@@ -0,0 +1,210 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"DOC/domain"
+)
+
+const (
+	// 回收站列表默认和最大分页大小
+	defaultTrashPageSize = 20
+	maxTrashPageSize     = 100
+
+	// 每批清理的过期回收站条目数
+	trashPurgeBatchSize = 100
+)
+
+// documentTrashService 回收站业务逻辑实现
+// 实现 domain.DocumentTrashUsecase 接口，负责回收站的查询、恢复、永久删除和过期清理
+type documentTrashService struct {
+	trashRepo    domain.DocumentTrashRepository
+	documentRepo domain.DocumentRepository
+	spaceRepo    domain.SpaceRepository
+	search       domain.DocumentSearchSyncer // 搜索索引同步（可为空）
+
+	retention time.Duration // 回收站条目保留时长，不大于 0 时不自动清理
+}
+
+// ListTrash 分页获取回收站条目
+func (d *documentTrashService) ListTrash(ctx context.Context, userID int64, spaceID *int64, page, pageSize int) ([]*domain.DocumentTrashItem, int64, error) {
+	filter := domain.DocumentTrashFilter{OwnerID: userID}
+	if spaceID != nil {
+		if err := d.requireSpaceMember(ctx, userID, *spaceID); err != nil {
+			return nil, 0, err
+		}
+		filter.SpaceID = spaceID
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = defaultTrashPageSize
+	}
+	if pageSize > maxTrashPageSize {
+		pageSize = maxTrashPageSize
+	}
+
+	items, total, err := d.trashRepo.List(ctx, filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if d.retention > 0 {
+		for _, item := range items {
+			if item.Document.DeletedAt != nil {
+				purgeAt := item.Document.DeletedAt.Add(d.retention)
+				item.PurgeAt = &purgeAt
+			}
+		}
+	}
+	return items, total, nil
+}
+
+// RestoreFromTrash 恢复文档及其子树
+func (d *documentTrashService) RestoreFromTrash(ctx context.Context, userID, documentID int64) (*domain.Document, error) {
+	// 1. 获取文档并检查权限
+	document, err := d.getTrashedDocument(ctx, userID, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 原父文件夹已被永久删除或仍在回收站中时恢复到根目录
+	relocate := false
+	if document.ParentID != nil {
+		parent, err := d.documentRepo.GetByID(ctx, *document.ParentID)
+		switch {
+		case errors.Is(err, domain.ErrDocumentNotFound):
+			relocate = true
+		case err != nil:
+			return nil, err
+		case !parent.IsActive():
+			relocate = true
+		}
+	}
+
+	// 3. 恢复文档及与其一起删除的子孙文档
+	if _, err := d.trashRepo.Restore(ctx, documentID, relocate); err != nil {
+		return nil, err
+	}
+
+	// 4. 删除时已从搜索索引中移除，恢复后重新写入
+	if d.search != nil {
+		d.search.SyncSubtree(ctx, documentID)
+	}
+	return d.documentRepo.GetByID(ctx, documentID)
+}
+
+// DeletePermanently 永久删除回收站中的文档及其子树
+func (d *documentTrashService) DeletePermanently(ctx context.Context, userID, documentID int64) error {
+	if _, err := d.getTrashedDocument(ctx, userID, documentID); err != nil {
+		return err
+	}
+
+	_, err := d.trashRepo.Purge(ctx, documentID)
+	return err
+}
+
+// PurgeExpired 永久删除超过保留期的回收站条目
+func (d *documentTrashService) PurgeExpired(ctx context.Context) (int, error) {
+	if d.retention <= 0 {
+		return 0, nil
+	}
+
+	before := time.Now().Add(-d.retention)
+	purged := 0
+	for {
+		ids, err := d.trashRepo.ListExpired(ctx, before, trashPurgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, id := range ids {
+			count, err := d.trashRepo.Purge(ctx, id)
+			if err != nil {
+				return purged, err
+			}
+			log.Printf("回收站条目已过期并永久删除: documentID=%d, documents=%d", id, count)
+			purged++
+		}
+
+		if len(ids) < trashPurgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// getTrashedDocument 获取回收站中的文档，并检查用户是否可以恢复或永久删除
+// 文档所有者，或文档所属空间的所有者、管理员可以操作
+func (d *documentTrashService) getTrashedDocument(ctx context.Context, userID, documentID int64) (*domain.Document, error) {
+	document, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if !document.IsDeleted() {
+		return nil, domain.ErrDocumentNotInTrash
+	}
+	if document.OwnerID == userID {
+		return document, nil
+	}
+	if document.SpaceID == nil {
+		return nil, domain.ErrPermissionDenied
+	}
+
+	space, err := d.spaceRepo.GetByID(ctx, *document.SpaceID)
+	if err != nil {
+		return nil, err
+	}
+	if space.CreatedBy == userID {
+		return document, nil
+	}
+	member, err := d.spaceRepo.GetMember(ctx, space.ID, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrPermissionDenied
+		}
+		return nil, err
+	}
+	if member.Role != domain.SpaceRoleOwner && member.Role != domain.SpaceRoleAdmin {
+		return nil, domain.ErrPermissionDenied
+	}
+	return document, nil
+}
+
+// requireSpaceMember 检查用户是否为空间的创建者或成员
+func (d *documentTrashService) requireSpaceMember(ctx context.Context, userID, spaceID int64) error {
+	space, err := d.spaceRepo.GetByID(ctx, spaceID)
+	if err != nil {
+		return err
+	}
+	if space.CreatedBy == userID {
+		return nil
+	}
+	if _, err := d.spaceRepo.GetMember(ctx, spaceID, userID); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrNotSpaceMember
+		}
+		return err
+	}
+	return nil
+}
+
+// NewDocumentTrashService 创建新的回收站服务实例
+// retention 为回收站条目的保留时长，不大于 0 时不自动清理
+func NewDocumentTrashService(
+	trashRepo domain.DocumentTrashRepository,
+	documentRepo domain.DocumentRepository,
+	spaceRepo domain.SpaceRepository,
+	search domain.DocumentSearchSyncer,
+	retention time.Duration) domain.DocumentTrashUsecase {
+	return &documentTrashService{
+		trashRepo:    trashRepo,
+		documentRepo: documentRepo,
+		spaceRepo:    spaceRepo,
+		search:       search,
+		retention:    retention,
+	}
+}
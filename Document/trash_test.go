@@ -0,0 +1,208 @@
+package document
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"DOC/domain"
+)
+
+// MockDocumentTrashRepository Mock 回收站仓储
+type MockDocumentTrashRepository struct {
+	mock.Mock
+}
+
+func (m *MockDocumentTrashRepository) List(ctx context.Context, filter domain.DocumentTrashFilter, offset, limit int) ([]*domain.DocumentTrashItem, int64, error) {
+	args := m.Called(ctx, filter, offset, limit)
+	return args.Get(0).([]*domain.DocumentTrashItem), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockDocumentTrashRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]int64, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockDocumentTrashRepository) Restore(ctx context.Context, documentID int64, relocate bool) (int64, error) {
+	args := m.Called(ctx, documentID, relocate)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDocumentTrashRepository) Purge(ctx context.Context, documentID int64) (int64, error) {
+	args := m.Called(ctx, documentID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// MockDocumentSearchSyncer Mock 搜索索引同步
+type MockDocumentSearchSyncer struct {
+	mock.Mock
+}
+
+func (m *MockDocumentSearchSyncer) SyncDocuments(ctx context.Context, documentIDs ...int64) {
+	m.Called(ctx, documentIDs)
+}
+
+func (m *MockDocumentSearchSyncer) SyncSubtree(ctx context.Context, rootID int64) {
+	m.Called(ctx, rootID)
+}
+
+func trashedDocument(id, ownerID int64, parentID *int64) *domain.Document {
+	deletedAt := time.Now()
+	return &domain.Document{
+		ID:        id,
+		Title:     "回收站文档",
+		Type:      domain.DocumentTypeFolder,
+		Status:    domain.DocumentStatusDeleted,
+		ParentID:  parentID,
+		OwnerID:   ownerID,
+		DeletedAt: &deletedAt,
+	}
+}
+
+func TestRestoreFromTrash_RelocateWhenParentUnavailable(t *testing.T) {
+	parentID := int64(10)
+	cases := []struct {
+		name      string
+		parent    *domain.Document
+		parentErr error
+		relocate  bool
+	}{
+		{"父文件夹正常", &domain.Document{ID: parentID, Status: domain.DocumentStatusActive}, nil, false},
+		{"父文件夹在回收站中", &domain.Document{ID: parentID, Status: domain.DocumentStatusDeleted}, nil, true},
+		{"父文件夹已归档", &domain.Document{ID: parentID, Status: domain.DocumentStatusArchived}, nil, true},
+		{"父文件夹已永久删除", (*domain.Document)(nil), domain.ErrDocumentNotFound, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 准备 Mock
+			mockTrashRepo := new(MockDocumentTrashRepository)
+			mockDocRepo := new(MockDocumentRepository)
+			mockSearch := new(MockDocumentSearchSyncer)
+			service := NewDocumentTrashService(mockTrashRepo, mockDocRepo, nil, mockSearch, 0)
+
+			ctx := context.Background()
+			userID := int64(1)
+			document := trashedDocument(20, userID, &parentID)
+			restored := &domain.Document{ID: 20, OwnerID: userID, Status: domain.DocumentStatusActive}
+
+			mockDocRepo.On("GetByID", ctx, int64(20)).Return(document, nil).Once()
+			mockDocRepo.On("GetByID", ctx, parentID).Return(tc.parent, tc.parentErr)
+			mockTrashRepo.On("Restore", ctx, int64(20), tc.relocate).Return(int64(3), nil)
+			mockSearch.On("SyncSubtree", ctx, int64(20)).Return()
+			mockDocRepo.On("GetByID", ctx, int64(20)).Return(restored, nil).Once()
+
+			// 执行测试
+			result, err := service.RestoreFromTrash(ctx, userID, 20)
+
+			// 验证结果
+			assert.NoError(t, err)
+			assert.Equal(t, restored, result)
+			mockTrashRepo.AssertExpectations(t)
+			mockSearch.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRestoreFromTrash_NotInTrash(t *testing.T) {
+	// 准备 Mock
+	mockTrashRepo := new(MockDocumentTrashRepository)
+	mockDocRepo := new(MockDocumentRepository)
+	service := NewDocumentTrashService(mockTrashRepo, mockDocRepo, nil, nil, 0)
+
+	ctx := context.Background()
+	document := &domain.Document{ID: 20, OwnerID: 1, Status: domain.DocumentStatusActive}
+	mockDocRepo.On("GetByID", ctx, int64(20)).Return(document, nil)
+
+	// 执行测试
+	result, err := service.RestoreFromTrash(ctx, 1, 20)
+
+	// 验证结果
+	assert.ErrorIs(t, err, domain.ErrDocumentNotInTrash)
+	assert.Nil(t, result)
+	mockTrashRepo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeletePermanently_Success(t *testing.T) {
+	// 准备 Mock
+	mockTrashRepo := new(MockDocumentTrashRepository)
+	mockDocRepo := new(MockDocumentRepository)
+	service := NewDocumentTrashService(mockTrashRepo, mockDocRepo, nil, nil, 0)
+
+	ctx := context.Background()
+	userID := int64(1)
+	mockDocRepo.On("GetByID", ctx, int64(20)).Return(trashedDocument(20, userID, nil), nil)
+	mockTrashRepo.On("Purge", ctx, int64(20)).Return(int64(4), nil)
+
+	// 执行测试
+	err := service.DeletePermanently(ctx, userID, 20)
+
+	// 验证结果
+	assert.NoError(t, err)
+	mockTrashRepo.AssertExpectations(t)
+}
+
+func TestDeletePermanently_PermissionDenied(t *testing.T) {
+	// 准备 Mock
+	mockTrashRepo := new(MockDocumentTrashRepository)
+	mockDocRepo := new(MockDocumentRepository)
+	service := NewDocumentTrashService(mockTrashRepo, mockDocRepo, nil, nil, 0)
+
+	ctx := context.Background()
+	mockDocRepo.On("GetByID", ctx, int64(20)).Return(trashedDocument(20, 1, nil), nil)
+
+	// 执行测试
+	err := service.DeletePermanently(ctx, 2, 20)
+
+	// 验证结果
+	assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	mockTrashRepo.AssertNotCalled(t, "Purge", mock.Anything, mock.Anything)
+}
+
+func TestPurgeExpired_PurgesEveryBatch(t *testing.T) {
+	// 准备 Mock
+	mockTrashRepo := new(MockDocumentTrashRepository)
+	service := NewDocumentTrashService(mockTrashRepo, nil, nil, nil, 24*time.Hour)
+
+	ctx := context.Background()
+	firstBatch := make([]int64, trashPurgeBatchSize)
+	for i := range firstBatch {
+		firstBatch[i] = int64(i + 1)
+	}
+	mockTrashRepo.On("ListExpired", ctx, mock.AnythingOfType("time.Time"), trashPurgeBatchSize).Return(firstBatch, nil).Once()
+	mockTrashRepo.On("ListExpired", ctx, mock.AnythingOfType("time.Time"), trashPurgeBatchSize).Return([]int64{500}, nil).Once()
+	mockTrashRepo.On("Purge", ctx, mock.AnythingOfType("int64")).Return(int64(1), nil)
+
+	// 执行测试
+	purged, err := service.PurgeExpired(ctx)
+
+	// 验证结果
+	assert.NoError(t, err)
+	assert.Equal(t, trashPurgeBatchSize+1, purged)
+	mockTrashRepo.AssertNumberOfCalls(t, "Purge", trashPurgeBatchSize+1)
+}
+
+func TestDeleteDocument_SyncsSubtree(t *testing.T) {
+	// 准备 Mock
+	mockDocRepo := new(MockDocumentRepository)
+	mockSearch := new(MockDocumentSearchSyncer)
+	service := NewDocumentService(mockDocRepo, nil, nil, nil, nil, nil, nil, mockSearch, nil, nil)
+
+	ctx := context.Background()
+	userID := int64(1)
+	document := &domain.Document{ID: 20, Type: domain.DocumentTypeFolder, Status: domain.DocumentStatusActive, OwnerID: userID}
+	mockDocRepo.On("GetByID", ctx, int64(20)).Return(document, nil)
+	mockDocRepo.On("SoftDelete", ctx, int64(20)).Return(nil)
+	mockSearch.On("SyncSubtree", ctx, int64(20)).Return()
+
+	// 执行测试
+	err := service.DeleteDocument(ctx, userID, 20)
+
+	// 验证结果
+	assert.NoError(t, err)
+	mockDocRepo.AssertExpectations(t)
+	mockSearch.AssertExpectations(t)
+}
@@ -0,0 +1,439 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"DOC/domain"
+	"DOC/pkg/diff"
+)
+
+const (
+	// 版本列表默认和最大分页大小
+	defaultVersionPageSize = 20
+	maxVersionPageSize     = 100
+)
+
+// documentVersionService 文档版本业务逻辑实现
+// 实现 domain.DocumentVersionUsecase 接口，负责版本记录、查询、命名、恢复和对比
+type documentVersionService struct {
+	versionRepo   domain.DocumentVersionRepository
+	documentRepo  domain.DocumentRepository
+	spaceRepo     domain.SpaceRepository
+	permUsecase   domain.DocumentPermissionUsecase
+	events        domain.DocumentEventPublisher    // 文档事件发布（可为空）
+	search        domain.DocumentSearchSyncer      // 搜索索引同步（可为空）
+	collaboration domain.CollaborationContentGuard // 协作会话协调（可为空）
+
+	interval  time.Duration                   // 同一用户连续保存合并为一个版本的间隔
+	retention domain.DocumentVersionRetention // 空间未设置时使用的保留规则
+}
+
+// RecordVersion 为文档当前的标题和内容记录自动版本
+func (d *documentVersionService) RecordVersion(ctx context.Context, documentID, authorID int64, source domain.DocumentVersionSource) error {
+	document, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	if document.IsFolder() {
+		return nil
+	}
+
+	// 1. 内容和标题都未变化时不记录，同一用户节流期内的自动版本直接更新
+	latest, err := d.versionRepo.GetLatest(ctx, documentID)
+	if err != nil && !errors.Is(err, domain.ErrDocumentVersionNotFound) {
+		return err
+	}
+	if latest != nil {
+		if latest.Title == document.Title && latest.Content == document.Content {
+			return nil
+		}
+		if latest.IsAutomatic() && latest.AuthorID == authorID && time.Since(latest.CreatedAt) < d.interval {
+			// 已被标记为最后查看的版本不能再修改，否则对比时会漏掉之后的变化
+			viewed, err := d.versionRepo.IsViewed(ctx, latest.ID)
+			if err != nil {
+				return err
+			}
+			if !viewed {
+				latest.SetSnapshot(document.Title, document.Content)
+				latest.Source = source
+				return d.versionRepo.Update(ctx, latest)
+			}
+		}
+	}
+
+	// 2. 记录新版本
+	version := &domain.DocumentVersion{
+		DocumentID: documentID,
+		Source:     source,
+		AuthorID:   authorID,
+	}
+	version.SetSnapshot(document.Title, document.Content)
+	if err := d.store(ctx, version); err != nil {
+		return err
+	}
+
+	// 3. 按空间的保留规则清理旧版本
+	d.prune(ctx, document)
+	return nil
+}
+
+// ListVersions 分页获取文档的版本列表
+func (d *documentVersionService) ListVersions(ctx context.Context, userID, documentID int64, namedOnly bool, page, pageSize int) ([]*domain.DocumentVersion, int64, error) {
+	if err := d.requireAccess(ctx, userID, documentID, domain.PermissionView); err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = defaultVersionPageSize
+	}
+	if pageSize > maxVersionPageSize {
+		pageSize = maxVersionPageSize
+	}
+
+	return d.versionRepo.ListByDocument(ctx, documentID, namedOnly, (page-1)*pageSize, pageSize)
+}
+
+// GetVersion 获取文档的指定版本，包含内容
+func (d *documentVersionService) GetVersion(ctx context.Context, userID, documentID, versionID int64) (*domain.DocumentVersion, error) {
+	if err := d.requireAccess(ctx, userID, documentID, domain.PermissionView); err != nil {
+		return nil, err
+	}
+	return d.getDocumentVersion(ctx, documentID, versionID)
+}
+
+// CreateNamedVersion 以文档当前的标题和内容创建命名版本
+func (d *documentVersionService) CreateNamedVersion(ctx context.Context, userID, documentID int64, name string) (*domain.DocumentVersion, error) {
+	if name == "" {
+		return nil, domain.ErrInvalidDocumentVersionName
+	}
+	if err := d.requireAccess(ctx, userID, documentID, domain.PermissionEdit); err != nil {
+		return nil, err
+	}
+
+	document, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if document.IsFolder() {
+		return nil, domain.ErrInvalidDocumentType
+	}
+
+	version := &domain.DocumentVersion{
+		DocumentID: documentID,
+		Source:     domain.DocumentVersionSourceManual,
+		Name:       name,
+		AuthorID:   userID,
+	}
+	version.SetSnapshot(document.Title, document.Content)
+	if err := d.store(ctx, version); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// NameVersion 为已有版本设置或取消名称
+func (d *documentVersionService) NameVersion(ctx context.Context, userID, documentID, versionID int64, name string) (*domain.DocumentVersion, error) {
+	if err := domain.ValidateDocumentVersionName(name); err != nil {
+		return nil, err
+	}
+	if err := d.requireAccess(ctx, userID, documentID, domain.PermissionEdit); err != nil {
+		return nil, err
+	}
+
+	version, err := d.getDocumentVersion(ctx, documentID, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if version.Name == name {
+		return version, nil
+	}
+
+	version.Name = name
+	if err := d.versionRepo.Update(ctx, version); err != nil {
+		return nil, fmt.Errorf("failed to update document version: %w", err)
+	}
+	return version, nil
+}
+
+// RestoreVersion 将文档恢复为历史版本的标题和内容
+// 恢复前先记录文档当前内容，恢复后记录一个指向来源版本的恢复版本，恢复本身也可以被撤销
+func (d *documentVersionService) RestoreVersion(ctx context.Context, userID, documentID, versionID int64) (*domain.DocumentVersion, error) {
+	// 1. 检查编辑权限
+	if err := d.requireAccess(ctx, userID, documentID, domain.PermissionEdit); err != nil {
+		return nil, err
+	}
+
+	// 2. 获取要恢复的版本和文档
+	source, err := d.getDocumentVersion(ctx, documentID, versionID)
+	if err != nil {
+		return nil, err
+	}
+	document, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.ValidateDocumentOperation(userID, documentID, "edit", document, domain.PermissionEdit); err != nil {
+		return nil, err
+	}
+	contentChanged := document.Content != source.Content
+	if contentChanged && d.collaboration != nil {
+		// 协作会话中有尚未写入文档的修改时拒绝恢复，避免覆盖
+		if err := d.collaboration.CheckContentWritable(ctx, documentID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. 记录当前内容，避免恢复覆盖尚未记录的修改
+	if err := d.RecordVersion(ctx, documentID, userID, domain.DocumentVersionSourceSave); err != nil {
+		log.Printf("记录文档恢复前版本失败: documentID=%d, err=%v", documentID, err)
+	}
+
+	// 4. 写回标题和内容
+	titleChanged := document.Title != source.Title
+	document.Title = source.Title
+	document.Content = source.Content
+	if err := d.documentRepo.Update(ctx, document); err != nil {
+		return nil, fmt.Errorf("failed to restore document version: %w", err)
+	}
+	if contentChanged && d.collaboration != nil {
+		// 以恢复的内容作为协作会话的检查点，基于旧内容的操作不再被接受
+		if err := d.collaboration.RebaseSession(ctx, documentID, document.Content); err != nil {
+			log.Printf("更新协作会话检查点失败: documentID=%d, err=%v", documentID, err)
+		}
+	}
+	if d.search != nil {
+		d.search.SyncDocuments(ctx, documentID)
+	}
+
+	// 5. 记录恢复版本
+	restored := &domain.DocumentVersion{
+		DocumentID:     documentID,
+		Source:         domain.DocumentVersionSourceRestore,
+		AuthorID:       userID,
+		RestoredFromID: &source.ID,
+	}
+	restored.SetSnapshot(source.Title, source.Content)
+	if err := d.store(ctx, restored); err != nil {
+		return nil, err
+	}
+	d.prune(ctx, document)
+
+	// 6. 通知正在查看文档的用户，协作连接收到通知后被关闭并重新同步
+	if contentChanged {
+		d.publishEvent(ctx, domain.DocumentEventContentReset, documentID, map[string]interface{}{
+			"user_id":    userID,
+			"version_id": restored.ID,
+		})
+	}
+	if titleChanged {
+		d.publishEvent(ctx, domain.DocumentEventTitleChanged, documentID, map[string]interface{}{
+			"title":   document.Title,
+			"user_id": userID,
+		})
+	}
+
+	return restored, nil
+}
+
+// DiffVersions 对比文档的两个版本
+func (d *documentVersionService) DiffVersions(ctx context.Context, userID, documentID, fromID, toID int64, granularity domain.DiffGranularity) (*domain.DocumentDiff, error) {
+	if granularity == "" {
+		granularity = domain.DiffGranularityWord
+	}
+	if !granularity.IsValid() {
+		return nil, domain.ErrInvalidDiffGranularity
+	}
+	if err := d.requireAccess(ctx, userID, documentID, domain.PermissionView); err != nil {
+		return nil, err
+	}
+
+	// 1. 确定起始版本，未指定时使用用户最后查看的版本
+	if fromID == 0 {
+		view, err := d.versionRepo.GetView(ctx, documentID, userID)
+		if err != nil {
+			return nil, err
+		}
+		fromID = view.VersionID
+	}
+	from, err := d.getDocumentVersion(ctx, documentID, fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 确定目标版本，未指定时使用文档当前内容
+	var to *domain.DocumentVersion
+	if toID == 0 {
+		document, err := d.documentRepo.GetByID(ctx, documentID)
+		if err != nil {
+			return nil, err
+		}
+		to = &domain.DocumentVersion{DocumentID: documentID, CreatedAt: document.UpdatedAt, UpdatedAt: document.UpdatedAt}
+		to.SetSnapshot(document.Title, document.Content)
+	} else if to, err = d.getDocumentVersion(ctx, documentID, toID); err != nil {
+		return nil, err
+	}
+
+	// 3. 计算标题、文本和内容节点的差异
+	result := &domain.DocumentDiff{
+		DocumentID:  documentID,
+		Granularity: granularity,
+		Title:       diff.Words(from.Title, to.Title),
+		Text:        diff.Text(diff.PlainText(from.Content), diff.PlainText(to.Content), granularity),
+	}
+	if nodes, ok := diff.Nodes(from.Content, to.Content); ok {
+		result.Nodes = nodes
+	}
+	result.HTML = diff.HTML(result.Title, result.Text)
+
+	// 响应中只保留版本信息，内容已体现在差异中
+	result.From, result.To = withoutContent(from), withoutContent(to)
+	return result, nil
+}
+
+// MarkVersionViewed 将版本标记为用户最后查看的版本
+// versionID 为 0 时先为文档当前内容记录版本，再标记最新版本
+func (d *documentVersionService) MarkVersionViewed(ctx context.Context, userID, documentID, versionID int64) (*domain.DocumentVersionView, error) {
+	if err := d.requireAccess(ctx, userID, documentID, domain.PermissionView); err != nil {
+		return nil, err
+	}
+
+	if versionID == 0 {
+		if err := d.RecordVersion(ctx, documentID, userID, domain.DocumentVersionSourceSave); err != nil {
+			return nil, err
+		}
+		latest, err := d.versionRepo.GetLatest(ctx, documentID)
+		if err != nil {
+			return nil, err
+		}
+		versionID = latest.ID
+	} else if _, err := d.getDocumentVersion(ctx, documentID, versionID); err != nil {
+		return nil, err
+	}
+
+	view := &domain.DocumentVersionView{
+		DocumentID: documentID,
+		UserID:     userID,
+		VersionID:  versionID,
+		ViewedAt:   time.Now(),
+	}
+	if err := d.versionRepo.SaveView(ctx, view); err != nil {
+		return nil, fmt.Errorf("failed to save document version view: %w", err)
+	}
+	return view, nil
+}
+
+// GetLastViewedVersion 获取用户最后查看的版本记录
+func (d *documentVersionService) GetLastViewedVersion(ctx context.Context, userID, documentID int64) (*domain.DocumentVersionView, error) {
+	if err := d.requireAccess(ctx, userID, documentID, domain.PermissionView); err != nil {
+		return nil, err
+	}
+	return d.versionRepo.GetView(ctx, documentID, userID)
+}
+
+// store 验证并保存版本
+func (d *documentVersionService) store(ctx context.Context, version *domain.DocumentVersion) error {
+	if err := version.Validate(); err != nil {
+		return err
+	}
+	if err := d.versionRepo.Store(ctx, version); err != nil {
+		return fmt.Errorf("failed to store document version: %w", err)
+	}
+	return nil
+}
+
+// prune 按文档所在空间的保留规则清理自动版本，清理失败只记录日志
+func (d *documentVersionService) prune(ctx context.Context, document *domain.Document) {
+	retention := d.retention
+	if document.SpaceID != nil && d.spaceRepo != nil {
+		space, err := d.spaceRepo.GetByID(ctx, *document.SpaceID)
+		if err == nil {
+			retention = space.VersionRetention(d.retention)
+		}
+	}
+
+	if err := d.versionRepo.Prune(ctx, document.ID, retention); err != nil {
+		log.Printf("清理文档历史版本失败: documentID=%d, err=%v", document.ID, err)
+	}
+}
+
+// getDocumentVersion 获取属于指定文档的版本
+func (d *documentVersionService) getDocumentVersion(ctx context.Context, documentID, versionID int64) (*domain.DocumentVersion, error) {
+	version, err := d.versionRepo.GetByID(ctx, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if version.DocumentID != documentID {
+		return nil, domain.ErrDocumentVersionNotFound
+	}
+	return version, nil
+}
+
+// withoutContent 复制版本信息，不含内容
+func withoutContent(version *domain.DocumentVersion) *domain.DocumentVersion {
+	copied := *version
+	copied.Content = ""
+	return &copied
+}
+
+// requireAccess 检查用户对文档是否拥有所需权限，文档所有者拥有全部权限
+func (d *documentVersionService) requireAccess(ctx context.Context, userID, documentID int64, permission domain.Permission) error {
+	document, err := d.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	if document.IsDeleted() {
+		return domain.ErrDocumentNotFound
+	}
+	if userID == document.OwnerID {
+		return nil
+	}
+
+	hasAccess, err := d.permUsecase.CheckPermission(ctx, documentID, userID, permission)
+	if err != nil {
+		return err
+	}
+	if !hasAccess {
+		return domain.ErrPermissionDenied
+	}
+	return nil
+}
+
+// publishEvent 发布文档事件，未配置事件发布时忽略
+func (d *documentVersionService) publishEvent(ctx context.Context, eventType domain.DocumentEventType, documentID int64, data map[string]interface{}) {
+	if d.events == nil {
+		return
+	}
+	d.events.PublishDocumentEvent(ctx, domain.NewDocumentEvent(eventType, documentID, data))
+}
+
+// NewDocumentVersionService 创建新的文档版本服务实例
+// interval 为同一用户连续保存合并为一个版本的间隔，retention 为空间未设置保留规则时的默认值
+func NewDocumentVersionService(
+	versionRepo domain.DocumentVersionRepository,
+	documentRepo domain.DocumentRepository,
+	spaceRepo domain.SpaceRepository,
+	permUsecase domain.DocumentPermissionUsecase,
+	events domain.DocumentEventPublisher,
+	search domain.DocumentSearchSyncer,
+	interval time.Duration,
+	retention domain.DocumentVersionRetention,
+	collaboration domain.CollaborationContentGuard) domain.DocumentVersionUsecase {
+	return &documentVersionService{
+		versionRepo:   versionRepo,
+		documentRepo:  documentRepo,
+		spaceRepo:     spaceRepo,
+		permUsecase:   permUsecase,
+		events:        events,
+		search:        search,
+		interval:      interval,
+		retention:     retention,
+		collaboration: collaboration,
+	}
+}
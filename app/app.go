@@ -3,6 +3,7 @@ package app
 import (
 	document "DOC/Document"
 	"DOC/auth"
+	"DOC/collaboration"
 	"DOC/organization"
 	"DOC/space"
 	"context"
@@ -16,7 +17,10 @@ import (
 	email2 "DOC/email"
 	redis2 "DOC/internal/repository/redis"
 	"DOC/internal/websocket"
+	"DOC/internal/workers/access"
 	"DOC/internal/workers/email"
+	"DOC/internal/workers/snapshot"
+	"DOC/internal/workers/trash"
 
 	"syscall"
 	"time"
@@ -34,6 +38,7 @@ import (
 	"DOC/internal/rest"
 
 	"DOC/pkg/jwt"
+	"DOC/pkg/search"
 	"DOC/user"
 )
 
@@ -59,6 +64,17 @@ type App struct {
 	documentPermissionRepo domain.DocumentPermissionRepository
 	documentFavoriteRepo   domain.DocumentFavoriteRepository
 	documentShareRepo      domain.DocumentShareRepository
+	documentVersionRepo    domain.DocumentVersionRepository
+	documentTrashRepo      domain.DocumentTrashRepository
+	documentAccessRepo     domain.DocumentAccessRepository
+	documentAccessCache    domain.DocumentAccessCacheRepository
+	documentDuplicateRepo  domain.DocumentDuplicateRepository
+	documentDuplicateJobs  domain.DocumentDuplicateJobRepository
+	// 搜索索引
+	searchIndex      domain.SearchIndex
+	quickSwitchCache domain.QuickSwitchCacheRepository
+	// 协作仓储层
+	collaborationRepo domain.CollaborationRepository
 
 	emailRep domain.EmailRepository
 
@@ -72,18 +88,28 @@ type App struct {
 	// 空间业务层
 	spaceUsecase domain.SpaceUsecase
 	// 文档业务层
-	documentUsecase           domain.DocumentUsecase
-	documentFavoriteUsecase   domain.DocumentFavoriteUsecase
-	documentShareUsecase      domain.DocumentShareUsecase
-	documentPermissionUsecase domain.DocumentPermissionUsecase
-	DocumentAggregateUsecase  domain.DocumentAggregateUsecase
-	emailUseCase              domain.EmailUsecase
+	documentUsecase            domain.DocumentUsecase
+	documentFavoriteUsecase    domain.DocumentFavoriteUsecase
+	documentShareUsecase       domain.DocumentShareUsecase
+	documentPermissionUsecase  domain.DocumentPermissionUsecase
+	documentVersionUsecase     domain.DocumentVersionUsecase
+	documentTrashUsecase       domain.DocumentTrashUsecase
+	documentSearchUsecase      domain.DocumentSearchUsecase
+	documentQuickSwitchUsecase domain.DocumentQuickSwitchUsecase
+	documentAccessUsecase      domain.DocumentAccessUsecase
+	documentDuplicateUsecase   domain.DocumentDuplicateUsecase
+	DocumentAggregateUsecase   domain.DocumentAggregateUsecase
+	collaborationUsecase       domain.CollaborationUsecase
+	emailUseCase               domain.EmailUsecase
 
 	// 邮件发送服务
 	emailSender domain.EmailSender
 
 	// 工作者
-	emailWorker *email.EmailWorker
+	emailWorker    *email.EmailWorker
+	snapshotWorker *snapshot.SnapshotWorker
+	trashWorker    *trash.TrashWorker
+	accessWorker   *access.AccessWorker
 
 	// WebSocket 服务
 	wsHub    *websocket.Hub
@@ -112,6 +138,14 @@ func NewApp(configPath string) (*App, error) {
 	// 初始化仓储层
 	app.initRepositories()
 
+	// 初始化搜索索引
+	if err := app.initSearchIndex(); err != nil {
+		return nil, fmt.Errorf("failed to init search index: %v", err)
+	}
+
+	// 初始化 WebSocket Hub，文档服务通过它发布文档事件
+	app.initHub()
+
 	// 初始化业务层
 	app.initUsecases()
 
@@ -191,6 +225,16 @@ func (a *App) initRepositories() {
 	a.documentShareRepo = mysql.NewDocumentShareRepository(a.db)
 	a.documentFavoriteRepo = mysql.NewDocumentFavoriteRepository(a.db)
 	a.documentPermissionRepo = mysql.NewDocumentPermissionRepository(a.db)
+	a.documentVersionRepo = mysql.NewDocumentVersionRepository(a.db)
+	a.documentTrashRepo = mysql.NewDocumentTrashRepository(a.db)
+	a.documentAccessRepo = mysql.NewDocumentAccessRepository(a.db)
+	a.documentAccessCache = redis2.NewDocumentAccessCacheRepository(a.redis)
+	a.documentDuplicateRepo = mysql.NewDocumentDuplicateRepository(a.db)
+	a.documentDuplicateJobs = redis2.NewDocumentDuplicateJobRepository(a.redis)
+	a.quickSwitchCache = redis2.NewQuickSwitchCacheRepository(a.redis)
+
+	// 初始化协作仓储
+	a.collaborationRepo = mysql.NewCollaborationRepository(a.db)
 
 	// 初始化邮件仓储
 	a.emailRep = mysql.NewEmailRepository(a.db)
@@ -198,6 +242,29 @@ func (a *App) initRepositories() {
 	log.Println("Repositories initialized")
 }
 
+// initSearchIndex 初始化搜索索引
+func (a *App) initSearchIndex() error {
+	switch a.config.Search.Engine {
+	case "mysql":
+		a.searchIndex = mysql.NewDocumentSearchIndex(a.db)
+	case "embedded":
+		// 嵌入式索引保存在本机，多实例部署时各实例的索引互不同步
+		if a.config.WebSocket.Backplane == "redis" {
+			return fmt.Errorf("search engine embedded only supports a single instance, use mysql when websocket backplane is redis")
+		}
+		index, err := search.NewIndex(a.config.Search.IndexDir)
+		if err != nil {
+			return err
+		}
+		a.searchIndex = index
+	default:
+		return fmt.Errorf("unknown search engine: %s", a.config.Search.Engine)
+	}
+
+	log.Printf("Search index initialized, engine: %s", a.config.Search.Engine)
+	return nil
+}
+
 // initUsecases 初始化业务层
 func (a *App) initUsecases() {
 	timeout := time.Duration(a.config.App.ContextTimeout) * time.Second
@@ -252,29 +319,93 @@ func (a *App) initUsecases() {
 		a.emailUseCase,
 		timeout,
 	)
+	// 初始化各个文档服务
+	// 访问记录，其他文档服务和协作服务在用户查看或编辑文档后通过它记录访问
+	a.documentAccessUsecase = document.NewDocumentAccessService(
+		a.documentAccessCache,
+		a.documentAccessRepo,
+		a.documentRepo,
+		a.documentPermissionRepo,
+		a.documentShareRepo,
+		a.spaceRepo,
+	)
+	// 快速切换，搜索索引同步时使受影响用户的标题索引失效
+	searchConfig := a.config.Search
+	a.documentQuickSwitchUsecase = document.NewDocumentQuickSwitchService(
+		a.searchIndex,
+		a.documentRepo,
+		a.documentShareRepo,
+		a.spaceRepo,
+		a.documentFavoriteRepo,
+		a.documentAccessUsecase,
+		a.quickSwitchCache,
+		time.Duration(searchConfig.QuickSwitchTTL)*time.Second,
+		searchConfig.QuickSwitchMaxEntries,
+	)
+	// 搜索，其他文档服务和空间服务写入后通过它同步搜索索引
+	a.documentSearchUsecase = document.NewDocumentSearchService(
+		a.searchIndex,
+		a.documentRepo,
+		a.documentPermissionRepo,
+		a.documentShareRepo,
+		a.spaceRepo,
+		a.documentFavoriteRepo,
+		a.documentQuickSwitchUsecase,
+	)
 	// 初始化空间业务服务
 	a.spaceUsecase = space.NewSpaceService(
 		a.spaceRepo,
 		a.userRepo,
 		a.organizationRepo,
-		nil,
+		a.documentRepo,
+		a.documentSearchUsecase,
+		a.documentQuickSwitchUsecase,
 		timeout,
 	)
-	// 初始化各个文档服务
 	a.documentShareUsecase = document.NewDocumentShareService(
 		a.documentShareRepo,
 		a.documentRepo,
 		a.documentPermissionRepo,
+		a.documentSearchUsecase,
+		a.documentAccessUsecase,
 	)
 	// 权限
 	a.documentPermissionUsecase = document.NewDocumentPermissionService(
 		a.documentPermissionRepo,
 		a.documentRepo,
+		a.documentSearchUsecase,
 	)
 	// 分享
 	a.documentFavoriteUsecase = document.NewDocumentFavoriteService(
 		a.documentFavoriteRepo,
 		a.documentRepo,
+		a.documentQuickSwitchUsecase,
+	)
+	// 协作会话协调，REST 写入和版本恢复改写内容时使用
+	contentGuard := collaboration.NewContentGuard(a.collaborationRepo, a.documentRepo)
+	// 版本
+	documentConfig := a.config.Document
+	a.documentVersionUsecase = document.NewDocumentVersionService(
+		a.documentVersionRepo,
+		a.documentRepo,
+		a.spaceRepo,
+		a.documentPermissionUsecase,
+		a.wsHub,
+		a.documentSearchUsecase,
+		time.Duration(documentConfig.VersionInterval)*time.Minute,
+		domain.DocumentVersionRetention{
+			Days:     documentConfig.VersionRetentionDays,
+			MaxCount: documentConfig.VersionRetentionCount,
+		},
+		contentGuard,
+	)
+	// 回收站
+	a.documentTrashUsecase = document.NewDocumentTrashService(
+		a.documentTrashRepo,
+		a.documentRepo,
+		a.spaceRepo,
+		a.documentSearchUsecase,
+		time.Duration(documentConfig.TrashRetentionDays)*24*time.Hour,
 	)
 	// 聚合
 	a.documentUsecase = document.NewDocumentService(
@@ -283,6 +414,24 @@ func (a *App) initUsecases() {
 		a.documentPermissionUsecase,
 		a.documentFavoriteUsecase,
 		a.userRepo,
+		a.wsHub,
+		a.documentVersionUsecase,
+		a.documentSearchUsecase,
+		a.documentAccessUsecase,
+		contentGuard,
+	)
+	// 复制
+	a.documentDuplicateUsecase = document.NewDocumentDuplicateService(
+		a.documentDuplicateRepo,
+		a.documentDuplicateJobs,
+		a.documentRepo,
+		a.documentPermissionUsecase,
+		a.documentPermissionRepo,
+		a.documentShareRepo,
+		a.spaceRepo,
+		a.documentSearchUsecase,
+		a.documentAccessUsecase,
+		documentConfig.DuplicateAsyncThreshold,
 	)
 
 	// 初始化文档聚合服务
@@ -291,12 +440,75 @@ func (a *App) initUsecases() {
 		a.documentShareUsecase,
 		a.documentPermissionUsecase,
 		a.documentFavoriteUsecase,
+		a.documentVersionUsecase,
+		a.documentTrashUsecase,
+		a.documentSearchUsecase,
+		a.documentQuickSwitchUsecase,
+		a.documentAccessUsecase,
+		a.documentDuplicateUsecase,
 		a.userRepo,
+		a.spaceRepo,
+	)
+
+	// 初始化协作业务服务
+	a.collaborationUsecase = collaboration.NewCollaborationService(
+		a.collaborationRepo,
+		a.documentRepo,
+		a.documentPermissionUsecase,
+		a.documentVersionUsecase,
+		a.documentSearchUsecase,
+		a.documentAccessUsecase,
+		timeout,
 	)
 
+	// 初始化协作快照工作者
+	collaborationConfig := a.config.Collaboration
+	a.snapshotWorker = snapshot.NewSnapshotWorker(a.collaborationUsecase, snapshot.WorkerConfig{
+		PollInterval:  time.Duration(collaborationConfig.SnapshotInterval) * time.Second,
+		MinOperations: int64(collaborationConfig.SnapshotOperations),
+		IdleTimeout:   time.Duration(collaborationConfig.SnapshotIdle) * time.Second,
+		Retention:     time.Duration(collaborationConfig.OperationRetentionHours) * time.Hour,
+	})
+	a.snapshotWorker.Start()
+
+	// 初始化回收站清理工作者
+	a.trashWorker = trash.NewTrashWorker(a.documentTrashUsecase, trash.WorkerConfig{
+		PurgeInterval: time.Duration(documentConfig.TrashPurgeInterval) * time.Minute,
+	})
+	a.trashWorker.Start()
+
+	// 初始化文档访问持久化工作者
+	a.accessWorker = access.NewAccessWorker(a.documentAccessUsecase, access.WorkerConfig{
+		FlushInterval: time.Duration(documentConfig.AccessFlushInterval) * time.Second,
+	})
+	a.accessWorker.Start()
+
 	log.Println("Usecases initialized")
 }
 
+// initHub 初始化 WebSocket Hub
+func (a *App) initHub() {
+	// 多实例部署时通过 Redis 消息总线互通
+	var backplane domain.HubBackplane
+	if a.config.WebSocket.Backplane == "redis" {
+		backplane = redis2.NewHubBackplane(a.redis)
+	}
+
+	wsConfig := a.config.WebSocket
+	a.wsHub = websocket.NewHub(a.collaborationRepo, backplane, websocket.HubConfig{
+		ReadBufferSize:        wsConfig.ReadBufferSize,
+		WriteBufferSize:       wsConfig.WriteBufferSize,
+		MaxMessageSize:        wsConfig.MaxMessageSize,
+		PingPeriod:            time.Duration(wsConfig.PingPeriod) * time.Second,
+		PongWait:              time.Duration(wsConfig.PongWait) * time.Second,
+		WriteWait:             time.Duration(wsConfig.WriteWait) * time.Second,
+		MaxConnections:        wsConfig.MaxConnections,
+		MaxConnectionsPerUser: wsConfig.MaxConnectionsPerUser,
+		MaxConnectionsPerRoom: wsConfig.MaxConnectionsPerRoom,
+		DrainReconnectDelay:   time.Duration(wsConfig.DrainReconnectDelay) * time.Second,
+	})
+}
+
 // initWebSocket 初始化 WebSocket 服务
 func (a *App) initWebSocket() {
 	// 创建 JWT 管理器
@@ -305,11 +517,8 @@ func (a *App) initWebSocket() {
 		time.Duration(a.config.App.JWTExpireHours)*time.Hour,
 	)
 
-	// 创建 WebSocket Hub
-	a.wsHub = websocket.NewHub(nil) // 暂时传入 nil，后续可以添加协作仓储
-
 	// 创建 WebSocket 服务器
-	a.wsServer = websocket.NewServer(a.wsHub, jwtManager, nil) // 暂时传入 nil，后续可以添加协作用例
+	a.wsServer = websocket.NewServer(a.wsHub, jwtManager, a.collaborationUsecase, a.userRepo)
 
 	// 启动 WebSocket 服务
 	a.wsServer.Start()
@@ -325,6 +534,8 @@ func (a *App) initRouter() {
 		OrganizationUsecase:      a.organizationUsecase,
 		SpaceUsecase:             a.spaceUsecase,
 		DocumentAggregateUsecase: a.DocumentAggregateUsecase,
+		DocumentEventFeed:        a.wsHub,
+		CollaborationUsecase:     a.collaborationUsecase,
 		Config:                   a.config,
 	}
 	a.router = rest.NewRouter(routerConfig)
@@ -380,12 +591,35 @@ func (a *App) gracefulShutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// 关闭 WebSocket 服务
+	// 排空并关闭 WebSocket 服务，客户端收到通知后重连到其他实例，未确认的操作在重连后重新提交
 	if a.wsServer != nil {
+		drainCtx, drainCancel := context.WithTimeout(ctx, time.Duration(a.config.WebSocket.DrainTimeout)*time.Second)
+		if err := a.wsServer.Drain(drainCtx); err != nil {
+			log.Printf("WebSocket drain incomplete: %v", err)
+		}
+		drainCancel()
 		a.wsServer.Stop()
 		log.Println("WebSocket server stopped")
 	}
 
+	// 关闭协作快照工作者，WebSocket 关闭后不再有新操作
+	if a.snapshotWorker != nil {
+		a.snapshotWorker.Stop()
+		log.Println("Snapshot worker stopped")
+	}
+
+	// 关闭回收站清理工作者
+	if a.trashWorker != nil {
+		a.trashWorker.Stop()
+		log.Println("Trash worker stopped")
+	}
+
+	// 关闭文档访问持久化工作者，停止前持久化剩余的访问
+	if a.accessWorker != nil {
+		a.accessWorker.Stop()
+		log.Println("Access worker stopped")
+	}
+
 	// 关闭邮件工作者
 	if a.emailWorker != nil {
 		a.emailWorker.Stop()
@@ -398,6 +632,14 @@ func (a *App) gracefulShutdown() error {
 		return err
 	}
 
+	// 关闭搜索索引，HTTP 服务器关闭后不再有写入
+	if a.searchIndex != nil {
+		if err := a.searchIndex.Close(); err != nil {
+			log.Printf("Failed to close search index: %v", err)
+		}
+		log.Println("Search index closed")
+	}
+
 	// 关闭数据库连接
 	if a.db != nil {
 		sqlDB, err := a.db.DB()
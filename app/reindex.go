@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	document "DOC/Document"
+	"DOC/internal/repository/mysql"
+)
+
+// Reindex 清空搜索索引并从数据库中所有未删除的文档重建
+// 嵌入式索引同一时间只能由一个进程打开，需在服务停止时运行
+func Reindex(configPath string) error {
+	a := &App{}
+
+	if err := a.loadConfig(configPath); err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+	if err := a.initDatabase(); err != nil {
+		return fmt.Errorf("failed to init database: %v", err)
+	}
+	defer func() {
+		if sqlDB, err := a.db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}()
+
+	a.documentRepo = mysql.NewDocumentRepository(a.db)
+	a.documentPermissionRepo = mysql.NewDocumentPermissionRepository(a.db)
+	a.documentShareRepo = mysql.NewDocumentShareRepository(a.db)
+	a.spaceRepo = mysql.NewSpaceRepository(a.db)
+	a.documentFavoriteRepo = mysql.NewDocumentFavoriteRepository(a.db)
+	if err := a.initSearchIndex(); err != nil {
+		return fmt.Errorf("failed to init search index: %v", err)
+	}
+
+	searchUsecase := document.NewDocumentSearchService(
+		a.searchIndex,
+		a.documentRepo,
+		a.documentPermissionRepo,
+		a.documentShareRepo,
+		a.spaceRepo,
+		a.documentFavoriteRepo,
+		nil, // 离线重建不维护快速切换标题索引，由其过期时间刷新
+	)
+
+	start := time.Now()
+	count, err := searchUsecase.Reindex(context.Background())
+	if closeErr := a.searchIndex.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("reindexed %d documents before failure: %v", count, err)
+	}
+
+	log.Printf("Reindexed %d documents in %v", count, time.Since(start))
+	return nil
+}
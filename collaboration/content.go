@@ -0,0 +1,82 @@
+package collaboration
+
+import (
+	"context"
+	"errors"
+
+	"DOC/domain"
+)
+
+// contentGuard 协作会话之外写入文档内容时的会话协调
+// 实现 domain.CollaborationContentGuard 接口，只依赖仓储，文档服务创建时即可注入
+type contentGuard struct {
+	collaborationRepo domain.CollaborationRepository
+	documentRepo      domain.DocumentRepository
+}
+
+// NewContentGuard 创建新的协作内容协调实例
+func NewContentGuard(collaborationRepo domain.CollaborationRepository, documentRepo domain.DocumentRepository) domain.CollaborationContentGuard {
+	return &contentGuard{
+		collaborationRepo: collaborationRepo,
+		documentRepo:      documentRepo,
+	}
+}
+
+// CheckContentWritable 检查文档内容是否可以在协作会话之外写入
+// OT 会话中尚未写入检查点的操作基于旧内容，无法合并到新内容上；Yjs 的合并状态保存在在线实例的内存中，
+// 有连接在线时同样无法替换
+func (g *contentGuard) CheckContentWritable(ctx context.Context, documentID int64) error {
+	session, err := g.getSession(ctx, documentID)
+	if err != nil || session == nil {
+		return err
+	}
+	if session.PendingOperations() > 0 {
+		return domain.ErrDocumentCollaborating
+	}
+
+	document, err := g.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	if document.GetCollaborationMode() != domain.CollaborationModeYjs {
+		return nil
+	}
+
+	participants, err := g.collaborationRepo.GetActiveUsersBySessionID(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+	if len(participants) > 0 {
+		return domain.ErrDocumentCollaborating
+	}
+	return nil
+}
+
+// RebaseSession 以文档新写入的内容作为协作会话的检查点
+// 在线的 OT 客户端下次提交或续传时收到重新同步的要求，不会把旧内容上的操作应用到新内容上
+func (g *contentGuard) RebaseSession(ctx context.Context, documentID int64, content string) error {
+	session, err := g.getSession(ctx, documentID)
+	if err != nil || session == nil {
+		return err
+	}
+	return g.collaborationRepo.RebaseSession(ctx, session.ID, content)
+}
+
+// WriteContent 在会话之外写入文档内容，并以其作为协作会话的检查点
+// 检查、写入和更新检查点在仓储的同一事务中完成，之间不会插入新的协作提交
+func (g *contentGuard) WriteContent(ctx context.Context, documentID int64, content string, expectedVersion int64) (int64, error) {
+	return g.collaborationRepo.ReplaceDocumentContent(ctx, documentID, content, expectedVersion)
+}
+
+// getSession 获取文档的协作会话，没有会话时返回 nil
+// 已关闭的会话在再次加入时会重新激活并从检查点继续，同样需要协调
+func (g *contentGuard) getSession(ctx context.Context, documentID int64) (*domain.CollaborationSession, error) {
+	session, err := g.collaborationRepo.GetSessionByRoomID(ctx, domain.DocumentRoomID(documentID))
+	if errors.Is(err, domain.ErrCollaborationSessionNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
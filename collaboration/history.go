@@ -0,0 +1,143 @@
+package collaboration
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"DOC/domain"
+	"DOC/pkg/ot"
+)
+
+const (
+	// defaultHistoryPageSize 操作历史默认每页操作数
+	defaultHistoryPageSize = 100
+
+	// maxHistoryPageSize 操作历史每页最大操作数
+	maxHistoryPageSize = 500
+)
+
+// GetOperationHistory 分页获取文档在 [from, to) 内的协作操作，按序号排列
+// 文档尚未开启过协作会话时返回空列表
+func (c *collaborationService) GetOperationHistory(ctx context.Context, documentID int64, userID int64, from, to time.Time, afterSeq int64, limit int) (*domain.CollaborationHistory, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	if err := c.requirePermission(ctx, documentID, userID, domain.PermissionManage); err != nil {
+		return nil, err
+	}
+
+	if afterSeq < 0 {
+		afterSeq = 0
+	}
+	if limit <= 0 {
+		limit = defaultHistoryPageSize
+	}
+	if limit > maxHistoryPageSize {
+		limit = maxHistoryPageSize
+	}
+
+	history := &domain.CollaborationHistory{
+		Operations: []*domain.CollaborationOperation{},
+		NextSeq:    afterSeq,
+	}
+
+	session, err := c.collaborationRepo.GetSessionByDocumentID(ctx, documentID)
+	if errors.Is(err, domain.ErrCollaborationSessionNotFound) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 多取一条判断是否还有下一页
+	operations, err := c.collaborationRepo.GetOperationsInRange(ctx, session.ID, from, to, afterSeq, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(operations) > limit {
+		operations = operations[:limit]
+		history.HasMore = true
+	}
+	if len(operations) > 0 {
+		history.Operations = operations
+		history.NextSeq = operations[len(operations)-1].Seq
+	}
+
+	return history, nil
+}
+
+// GetContentAtSeq 重建文档在序号 seq 的操作应用后的内容
+// 从不晚于 seq 的最近检查点开始依次应用操作，所需的检查点或操作已按保留期清理时返回 ErrCollaborationOpsCompacted
+func (c *collaborationService) GetContentAtSeq(ctx context.Context, documentID int64, userID int64, seq int64) (*domain.CollaborationSnapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	if err := c.requirePermission(ctx, documentID, userID, domain.PermissionManage); err != nil {
+		return nil, err
+	}
+
+	session, err := c.collaborationRepo.GetSessionByDocumentID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+	if seq < 0 || seq > session.LastSeq {
+		return nil, domain.ErrInvalidCollaborationSeq
+	}
+
+	base, err := c.collaborationRepo.GetCheckpointAtOrBefore(ctx, session.ID, seq)
+	if errors.Is(err, domain.ErrCollaborationCheckpointNotFound) {
+		base, err = c.currentCheckpoint(ctx, session, seq)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if base.Seq == seq {
+		return &domain.CollaborationSnapshot{
+			Content:  base.Content,
+			Revision: base.Revision,
+			Seq:      seq,
+		}, nil
+	}
+
+	count := seq - base.Seq
+	operations, err := c.collaborationRepo.GetOperationsAfterSeq(ctx, session.ID, base.Seq, int(count))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(operations)) != count || operations[0].Seq != base.Seq+1 {
+		return nil, domain.ErrCollaborationOpsCompacted
+	}
+
+	content, err := ot.Apply(base.Content, operations...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.CollaborationSnapshot{
+		Content:  content,
+		Revision: operations[len(operations)-1].Revision,
+		Seq:      seq,
+	}, nil
+}
+
+// currentCheckpoint 以文档当前内容作为会话当前检查点
+// 用于没有检查点历史的会话，只能重建当前检查点及之后的内容
+func (c *collaborationService) currentCheckpoint(ctx context.Context, session *domain.CollaborationSession, seq int64) (*domain.CollaborationCheckpoint, error) {
+	if seq < session.CheckpointSeq {
+		return nil, domain.ErrCollaborationOpsCompacted
+	}
+
+	document, err := c.documentRepo.GetByID(ctx, session.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.CollaborationCheckpoint{
+		SessionID: session.ID,
+		Revision:  session.CheckpointRevision,
+		Seq:       session.CheckpointSeq,
+		Content:   document.Content,
+	}, nil
+}
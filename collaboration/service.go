@@ -0,0 +1,617 @@
+package collaboration
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"DOC/domain"
+	"DOC/pkg/ot"
+)
+
+const (
+	// sessionInactiveThreshold 会话无活动超过该时长后会被清理关闭
+	sessionInactiveThreshold = 30 * time.Minute
+
+	// maxOperationsPerSync 单次同步返回的最大操作数
+	maxOperationsPerSync = 1000
+
+	// maxCommitRetries 修订号冲突时的最大重试次数
+	maxCommitRetries = 3
+
+	// maxClientOpIDLength 客户端操作ID的最大长度
+	maxClientOpIDLength = 64
+)
+
+// collaborationService 协作业务逻辑服务
+type collaborationService struct {
+	collaborationRepo domain.CollaborationRepository
+	documentRepo      domain.DocumentRepository
+	permUsecase       domain.DocumentPermissionUsecase
+	versions          domain.DocumentVersionRecorder // 文档版本记录（可为空）
+	search            domain.DocumentSearchSyncer    // 搜索索引同步（可为空）
+	access            domain.DocumentAccessRecorder  // 文档访问记录（可为空）
+	contextTimeout    time.Duration
+
+	// yjsStates Yjs 模式下各会话的合并状态，会话ID -> *yjsState
+	yjsStates sync.Map
+}
+
+// NewCollaborationService 创建新的协作服务实例
+func NewCollaborationService(
+	collaborationRepo domain.CollaborationRepository,
+	documentRepo domain.DocumentRepository,
+	permUsecase domain.DocumentPermissionUsecase,
+	versions domain.DocumentVersionRecorder,
+	search domain.DocumentSearchSyncer,
+	access domain.DocumentAccessRecorder,
+	timeout time.Duration,
+) domain.CollaborationUsecase {
+	return &collaborationService{
+		collaborationRepo: collaborationRepo,
+		documentRepo:      documentRepo,
+		permUsecase:       permUsecase,
+		versions:          versions,
+		search:            search,
+		access:            access,
+		contextTimeout:    timeout,
+	}
+}
+
+// === 会话管理 ===
+
+// CreateSession 为文档创建协作会话，会话已存在时直接返回（必要时重新激活）
+func (c *collaborationService) CreateSession(ctx context.Context, documentID int64, userID int64) (*domain.CollaborationSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	if err := c.requirePermission(ctx, documentID, userID, domain.PermissionView); err != nil {
+		return nil, err
+	}
+
+	return c.ensureSession(ctx, documentID)
+}
+
+// GetSession 根据房间ID获取协作会话
+func (c *collaborationService) GetSession(ctx context.Context, roomID string) (*domain.CollaborationSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	return c.collaborationRepo.GetSessionByRoomID(ctx, roomID)
+}
+
+// CloseSession 关闭协作会话，需要文档管理权限
+func (c *collaborationService) CloseSession(ctx context.Context, roomID string, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	session, err := c.collaborationRepo.GetSessionByRoomID(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if session.IsClosed() {
+		return nil
+	}
+
+	if err := c.requirePermission(ctx, session.DocumentID, userID, domain.PermissionManage); err != nil {
+		return err
+	}
+
+	// 所有参与者标记为离开
+	participants, err := c.collaborationRepo.GetActiveUsersBySessionID(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+	for _, participant := range participants {
+		if err := c.collaborationRepo.RemoveUser(ctx, session.ID, participant.SocketID); err != nil {
+			return err
+		}
+	}
+
+	if err := c.releaseYjsState(ctx, session.ID); err != nil {
+		return err
+	}
+
+	session.Close()
+	return c.collaborationRepo.UpdateSession(ctx, session)
+}
+
+// === 用户管理 ===
+
+// JoinSession 加入协作会话
+// 房间ID必须对应一个文档，且用户至少拥有查看权限
+// 非旁观者受会话人数上限 MaxUsers 限制，旁观者只读且不占用名额
+// 参与者按连接登记，同一用户的多个连接各有一条记录，互不影响
+func (c *collaborationService) JoinSession(ctx context.Context, roomID string, userID int64, socketID string, spectator bool) (*domain.CollaborationUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	if socketID == "" {
+		return nil, domain.ErrInvalidCollaborationOperation
+	}
+
+	documentID, err := domain.ParseDocumentRoomID(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.requirePermission(ctx, documentID, userID, domain.PermissionView); err != nil {
+		return nil, err
+	}
+
+	session, err := c.ensureSession(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一连接重新加入时复用其在线的参与者记录，已离开的记录不再读取，按保留期清理
+	participants, err := c.collaborationRepo.GetActiveUsersBySessionID(ctx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !spectator && sessionFull(session, participants, userID) {
+		return nil, domain.ErrCollaborationSessionFull
+	}
+	for _, participant := range participants {
+		if participant.SocketID != socketID || participant.UserID != userID {
+			continue
+		}
+		// 旁观标记只属于这个连接，用户其他连接的编辑身份不受影响
+		participant.Spectator = spectator
+		if err := c.collaborationRepo.UpdateUser(ctx, participant); err != nil {
+			return nil, err
+		}
+		c.recordAccess(ctx, userID, documentID, domain.DocumentAccessView)
+		return participant, nil
+	}
+
+	participant := &domain.CollaborationUser{
+		SessionID: session.ID,
+		UserID:    userID,
+		SocketID:  socketID,
+		IsActive:  true,
+		Spectator: spectator,
+		JoinedAt:  time.Now(),
+	}
+	if err := participant.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.collaborationRepo.StoreUser(ctx, participant); err != nil {
+		return nil, err
+	}
+	c.recordAccess(ctx, userID, documentID, domain.DocumentAccessView)
+
+	return participant, nil
+}
+
+// LeaveSession 连接离开协作会话，同一用户的其他连接不受影响
+// 最后一个连接离开时会话置为非活跃，并压缩 Yjs 更新
+func (c *collaborationService) LeaveSession(ctx context.Context, roomID string, socketID string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	session, err := c.collaborationRepo.GetSessionByRoomID(ctx, roomID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.collaborationRepo.RemoveUser(ctx, session.ID, socketID); err != nil {
+		return err
+	}
+
+	participants, err := c.collaborationRepo.GetActiveUsersBySessionID(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+	if len(participants) > 0 {
+		return nil
+	}
+
+	if err := c.releaseYjsState(ctx, session.ID); err != nil {
+		return err
+	}
+	if session.IsActive() {
+		session.Deactivate()
+		return c.collaborationRepo.UpdateSession(ctx, session)
+	}
+
+	return nil
+}
+
+// GetSessionParticipants 获取会话中当前在线的参与者
+func (c *collaborationService) GetSessionParticipants(ctx context.Context, roomID string) ([]*domain.CollaborationUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	session, err := c.collaborationRepo.GetSessionByRoomID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.collaborationRepo.GetActiveUsersBySessionID(ctx, session.ID)
+}
+
+// UpdateUserCursor 更新参与者光标位置
+func (c *collaborationService) UpdateUserCursor(ctx context.Context, roomID string, userID int64, socketID string, position int) error {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	if position < 0 {
+		return domain.ErrInvalidCollaborationOperation
+	}
+
+	participant, _, err := c.getActiveParticipant(ctx, roomID, userID, socketID)
+	if err != nil {
+		return err
+	}
+
+	participant.UpdateCursor(position)
+	return c.collaborationRepo.UpdateUser(ctx, participant)
+}
+
+// === 操作管理 ===
+
+// ApplyOperation 应用协作操作，需要文档编辑权限
+// 操作基于客户端的 baseRevision 生成，提交前先转换到最新修订之后并校验能应用到会话当前内容上，再分配新的修订号
+// 客户端未收到确认而重发的操作按 clientOpID 识别，直接返回首次提交的结果
+func (c *collaborationService) ApplyOperation(ctx context.Context, roomID string, userID int64, socketID string, baseRevision int64, clientOpID string, operations []*domain.CollaborationOperation) (*domain.CollaborationCommit, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	if len(operations) == 0 || len(clientOpID) > maxClientOpIDLength {
+		return nil, domain.ErrInvalidCollaborationOperation
+	}
+	for _, operation := range operations {
+		if err := ot.Validate(operation); err != nil {
+			return nil, err
+		}
+	}
+
+	session, err := c.getActiveEditor(ctx, roomID, userID, socketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.requireMode(ctx, session.DocumentID, domain.CollaborationModeOT); err != nil {
+		return nil, err
+	}
+	if err := c.requirePermission(ctx, session.DocumentID, userID, domain.PermissionEdit); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < maxCommitRetries; attempt++ {
+		if attempt > 0 {
+			// 其他实例已提交新的修订，重新读取会话
+			if session, err = c.collaborationRepo.GetSessionByID(ctx, session.ID); err != nil {
+				return nil, err
+			}
+		}
+
+		// 重发的操作可能在冲突重试期间由其他连接提交，每次尝试都需检查
+		if clientOpID != "" {
+			committed, err := c.collaborationRepo.GetOperationsByClientOpID(ctx, session.ID, userID, clientOpID)
+			if err != nil {
+				return nil, err
+			}
+			if len(committed) > 0 {
+				return &domain.CollaborationCommit{
+					Revision:   committed[0].Revision,
+					Seq:        committed[len(committed)-1].Seq,
+					Operations: committed,
+					Duplicate:  true,
+				}, nil
+			}
+		}
+
+		if baseRevision < 0 || baseRevision > session.Revision {
+			return nil, domain.ErrInvalidCollaborationRevision
+		}
+
+		history, err := c.collaborationRepo.GetOperationsAfterRevision(ctx, session.ID, baseRevision)
+		if err != nil {
+			return nil, err
+		}
+		// 基准修订之后的操作已被清理，无法转换，客户端需重新同步
+		if baseRevision < session.Revision && (len(history) == 0 || history[0].Revision != baseRevision+1) {
+			return nil, domain.ErrCollaborationOpsCompacted
+		}
+
+		// 只转换到本次读取的会话修订为止，之后的提交交给冲突重试处理
+		concurrent := make([]*domain.CollaborationOperation, 0, len(history))
+		for _, operation := range history {
+			if operation.Revision <= session.Revision {
+				concurrent = append(concurrent, operation)
+			}
+		}
+
+		transformed, err := ot.Transform(operations, concurrent)
+		if err != nil {
+			return nil, err
+		}
+
+		// 操作被并发删除完全抵消，无需分配新修订
+		if len(transformed) == 0 {
+			return &domain.CollaborationCommit{Revision: session.Revision, Seq: session.LastSeq}, nil
+		}
+
+		// 转换后的操作必须能应用到本次读取的会话内容上，越界的操作写入后会让之后的回放全部失败
+		current, _, err := c.replay(ctx, session)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ot.Apply(current, transformed...); err != nil {
+			return nil, domain.ErrInvalidCollaborationOperation
+		}
+
+		now := time.Now()
+		for _, operation := range transformed {
+			operation.ID = 0
+			operation.UserID = userID
+			operation.ClientOpID = clientOpID
+			operation.Timestamp = now
+		}
+
+		revision := session.Revision + 1
+		err = c.collaborationRepo.CommitOperations(ctx, session.ID, revision, transformed)
+		if errors.Is(err, domain.ErrCollaborationRevisionConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.recordAccess(ctx, userID, session.DocumentID, domain.DocumentAccessEdit)
+
+		return &domain.CollaborationCommit{
+			Revision:   revision,
+			Seq:        transformed[len(transformed)-1].Seq,
+			Operations: transformed,
+		}, nil
+	}
+
+	return nil, domain.ErrCollaborationRevisionConflict
+}
+
+// GetOperations 获取会话中序号 afterSeq 之后的操作记录
+func (c *collaborationService) GetOperations(ctx context.Context, roomID string, afterSeq int64) ([]*domain.CollaborationOperation, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	session, err := c.collaborationRepo.GetSessionByRoomID(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.collaborationRepo.GetOperationsAfterSeq(ctx, session.ID, afterSeq, maxOperationsPerSync)
+}
+
+// ResumeSession 获取客户端断线期间缺失的操作
+// 缺失的操作过多、已被清理，或客户端序号超出会话范围时返回快照，由客户端替换本地内容
+func (c *collaborationService) ResumeSession(ctx context.Context, roomID string, userID int64, socketID string, lastSeq int64) (*domain.CollaborationCatchUp, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	if lastSeq < 0 {
+		return nil, domain.ErrInvalidCollaborationRevision
+	}
+
+	_, session, err := c.getActiveParticipant(ctx, roomID, userID, socketID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.requireMode(ctx, session.DocumentID, domain.CollaborationModeOT); err != nil {
+		return nil, err
+	}
+
+	catchUp := &domain.CollaborationCatchUp{
+		Revision: session.Revision,
+		Seq:      session.LastSeq,
+	}
+	if lastSeq == session.LastSeq {
+		return catchUp, nil
+	}
+
+	missing := session.LastSeq - lastSeq
+	if missing > 0 && missing <= maxOperationsPerSync {
+		operations, err := c.collaborationRepo.GetOperationsAfterSeq(ctx, session.ID, lastSeq, int(missing))
+		if err != nil {
+			return nil, err
+		}
+		// 序号连续递增，首个序号和数量都对得上说明中间没有被清理的操作
+		if int64(len(operations)) == missing && operations[0].Seq == lastSeq+1 {
+			catchUp.Operations = operations
+			return catchUp, nil
+		}
+	}
+
+	snapshot, err := c.snapshot(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	catchUp.Snapshot = snapshot
+	return catchUp, nil
+}
+
+// SyncDocument 将协作结果同步写回文档内容，需要文档编辑权限
+// 仅用于 Yjs 模式，OT 模式的文档内容由检查点生成
+func (c *collaborationService) SyncDocument(ctx context.Context, roomID string, userID int64, socketID string, content string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	session, err := c.getActiveEditor(ctx, roomID, userID, socketID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.requireMode(ctx, session.DocumentID, domain.CollaborationModeYjs); err != nil {
+		return err
+	}
+	if err := c.requirePermission(ctx, session.DocumentID, userID, domain.PermissionEdit); err != nil {
+		return err
+	}
+
+	// 协作会话内的同步以房间状态为准，不校验版本号
+	if _, err := c.documentRepo.UpdateContent(ctx, session.DocumentID, content, 0); err != nil {
+		return err
+	}
+	c.recordVersion(ctx, session.DocumentID, userID)
+	c.syncSearch(ctx, session.DocumentID)
+	c.recordAccess(ctx, userID, session.DocumentID, domain.DocumentAccessEdit)
+	return nil
+}
+
+// === 权限检查 ===
+
+// CheckCollaborationPermission 检查用户是否可以参与协作编辑
+// 返回 false 表示用户最多只能以只读方式加入，归档的文档对所有用户只读
+func (c *collaborationService) CheckCollaborationPermission(ctx context.Context, userID int64, documentID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	err := c.requirePermission(ctx, documentID, userID, domain.PermissionEdit)
+	if errors.Is(err, domain.ErrCollaborationPermissionDenied) || errors.Is(err, domain.ErrDocumentArchived) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// === 清理操作 ===
+
+// CleanupInactiveSessions 关闭长时间无活动的协作会话
+func (c *collaborationService) CleanupInactiveSessions(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	return c.collaborationRepo.CleanupInactiveSessions(ctx, sessionInactiveThreshold)
+}
+
+// === 辅助方法 ===
+
+// recordAccess 记录用户通过协作会话查看或编辑文档，未配置访问记录时忽略
+func (c *collaborationService) recordAccess(ctx context.Context, userID, documentID int64, action domain.DocumentAccessAction) {
+	if c.access == nil {
+		return
+	}
+	c.access.RecordAccess(ctx, userID, documentID, action)
+}
+
+// requirePermission 检查用户对文档是否拥有所需权限，需要编辑权限时文档不能已归档
+func (c *collaborationService) requirePermission(ctx context.Context, documentID, userID int64, required domain.Permission) error {
+	canAccess, permission, err := c.permUsecase.CanAccessDocument(ctx, documentID, userID)
+	if err != nil {
+		return err
+	}
+	if !canAccess || !domain.IsPermissionSufficient(permission, required) {
+		return domain.ErrCollaborationPermissionDenied
+	}
+
+	if domain.IsPermissionSufficient(required, domain.PermissionEdit) {
+		document, err := c.documentRepo.GetByID(ctx, documentID)
+		if err != nil {
+			return err
+		}
+		if document.IsArchived() {
+			return domain.ErrDocumentArchived
+		}
+	}
+	return nil
+}
+
+// ensureSession 获取文档对应的协作会话，不存在时创建，已关闭或非活跃时重新激活
+func (c *collaborationService) ensureSession(ctx context.Context, documentID int64) (*domain.CollaborationSession, error) {
+	roomID := domain.DocumentRoomID(documentID)
+
+	session, err := c.collaborationRepo.GetSessionByRoomID(ctx, roomID)
+	if err == nil {
+		if !session.IsActive() {
+			session.Activate()
+			session.ClosedAt = nil
+			if err := c.collaborationRepo.UpdateSession(ctx, session); err != nil {
+				return nil, err
+			}
+		}
+		return session, nil
+	}
+	if !errors.Is(err, domain.ErrCollaborationSessionNotFound) {
+		return nil, err
+	}
+
+	session = &domain.CollaborationSession{
+		DocumentID: documentID,
+		RoomID:     roomID,
+		Status:     domain.CollaborationSessionStatusActive,
+	}
+	if err := session.Validate(); err != nil {
+		return nil, err
+	}
+	if err := c.collaborationRepo.StoreSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	// 记录会话创建时的文档内容作为回放起点，失败只影响历史回放
+	if document, err := c.documentRepo.GetByID(ctx, documentID); err == nil {
+		if err := c.collaborationRepo.StoreCheckpoint(ctx, &domain.CollaborationCheckpoint{
+			SessionID: session.ID,
+			Content:   document.Content,
+		}); err != nil {
+			log.Printf("保存协作会话初始内容失败: session=%d, err=%v", session.ID, err)
+		}
+	}
+
+	return session, nil
+}
+
+// sessionFull 检查除 userID 外以非旁观者身份在线的用户是否已达到会话人数上限
+// 名额按用户计算，同一用户的多个编辑连接只占一个名额；旁观标记属于各自的连接
+func sessionFull(session *domain.CollaborationSession, participants []*domain.CollaborationUser, userID int64) bool {
+	if session.MaxUsers <= 0 {
+		return false
+	}
+
+	editors := make(map[int64]bool)
+	for _, participant := range participants {
+		if participant.IsActive && !participant.Spectator && participant.UserID != userID {
+			editors[participant.UserID] = true
+		}
+	}
+	return len(editors) >= session.MaxUsers
+}
+
+// getActiveParticipant 获取房间中用户在指定连接上在线的参与者记录及其会话
+func (c *collaborationService) getActiveParticipant(ctx context.Context, roomID string, userID int64, socketID string) (*domain.CollaborationUser, *domain.CollaborationSession, error) {
+	session, err := c.collaborationRepo.GetSessionByRoomID(ctx, roomID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.IsClosed() {
+		return nil, nil, domain.ErrCollaborationSessionNotFound
+	}
+
+	participants, err := c.collaborationRepo.GetActiveUsersBySessionID(ctx, session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, participant := range participants {
+		if participant.SocketID == socketID && participant.UserID == userID {
+			return participant, session, nil
+		}
+	}
+
+	return nil, nil, domain.ErrCollaborationPermissionDenied
+}
+
+// getActiveEditor 获取连接以非旁观者身份在线的会话，旁观者不允许提交编辑
+func (c *collaborationService) getActiveEditor(ctx context.Context, roomID string, userID int64, socketID string) (*domain.CollaborationSession, error) {
+	participant, session, err := c.getActiveParticipant(ctx, roomID, userID, socketID)
+	if err != nil {
+		return nil, err
+	}
+	if participant.Spectator {
+		return nil, domain.ErrCollaborationPermissionDenied
+	}
+	return session, nil
+}
@@ -0,0 +1,162 @@
+package collaboration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"DOC/domain"
+)
+
+// MockCollaborationRepository Mock 协作仓储，只实现测试用到的方法
+type MockCollaborationRepository struct {
+	domain.CollaborationRepository
+	mock.Mock
+}
+
+func (m *MockCollaborationRepository) GetSessionByRoomID(ctx context.Context, roomID string) (*domain.CollaborationSession, error) {
+	args := m.Called(ctx, roomID)
+	return args.Get(0).(*domain.CollaborationSession), args.Error(1)
+}
+
+func (m *MockCollaborationRepository) GetActiveUsersBySessionID(ctx context.Context, sessionID int64) ([]*domain.CollaborationUser, error) {
+	args := m.Called(ctx, sessionID)
+	return args.Get(0).([]*domain.CollaborationUser), args.Error(1)
+}
+
+func (m *MockCollaborationRepository) GetOperationsAfterRevision(ctx context.Context, sessionID int64, revision int64) ([]*domain.CollaborationOperation, error) {
+	args := m.Called(ctx, sessionID, revision)
+	return args.Get(0).([]*domain.CollaborationOperation), args.Error(1)
+}
+
+func (m *MockCollaborationRepository) GetOperationsAfterSeq(ctx context.Context, sessionID int64, seq int64, limit int) ([]*domain.CollaborationOperation, error) {
+	args := m.Called(ctx, sessionID, seq, limit)
+	return args.Get(0).([]*domain.CollaborationOperation), args.Error(1)
+}
+
+func (m *MockCollaborationRepository) CommitOperations(ctx context.Context, sessionID int64, revision int64, operations []*domain.CollaborationOperation) error {
+	args := m.Called(ctx, sessionID, revision, operations)
+	return args.Error(0)
+}
+
+func (m *MockCollaborationRepository) GetCheckpointAtOrBefore(ctx context.Context, sessionID int64, seq int64) (*domain.CollaborationCheckpoint, error) {
+	args := m.Called(ctx, sessionID, seq)
+	return args.Get(0).(*domain.CollaborationCheckpoint), args.Error(1)
+}
+
+// MockDocumentRepository Mock 文档仓储，只实现测试用到的方法
+type MockDocumentRepository struct {
+	domain.DocumentRepository
+	mock.Mock
+}
+
+func (m *MockDocumentRepository) GetByID(ctx context.Context, id int64) (*domain.Document, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.Document), args.Error(1)
+}
+
+// MockDocumentPermissionUsecase Mock 文档权限服务，只实现测试用到的方法
+type MockDocumentPermissionUsecase struct {
+	domain.DocumentPermissionUsecase
+	mock.Mock
+}
+
+func (m *MockDocumentPermissionUsecase) CanAccessDocument(ctx context.Context, documentID, userID int64) (bool, domain.Permission, error) {
+	args := m.Called(ctx, documentID, userID)
+	return args.Bool(0), args.Get(1).(domain.Permission), args.Error(2)
+}
+
+// newEditingSession 准备一个用户以编辑者身份在线的 OT 会话，检查点内容为 checkpoint，之后有 pending 个操作
+func newEditingSession(collaborationRepo *MockCollaborationRepository, documentRepo *MockDocumentRepository, permUsecase *MockDocumentPermissionUsecase, checkpoint string, pending []*domain.CollaborationOperation) *domain.CollaborationSession {
+	session := &domain.CollaborationSession{
+		ID:         1,
+		DocumentID: 10,
+		RoomID:     domain.DocumentRoomID(10),
+		Status:     domain.CollaborationSessionStatusActive,
+		Revision:   int64(len(pending)),
+		LastSeq:    int64(len(pending)),
+	}
+	collaborationRepo.On("GetSessionByRoomID", mock.Anything, session.RoomID).Return(session, nil)
+	collaborationRepo.On("GetActiveUsersBySessionID", mock.Anything, session.ID).Return([]*domain.CollaborationUser{
+		{SessionID: session.ID, UserID: 1, SocketID: "socket-1", IsActive: true},
+	}, nil)
+	collaborationRepo.On("GetCheckpointAtOrBefore", mock.Anything, session.ID, int64(0)).Return(&domain.CollaborationCheckpoint{
+		SessionID: session.ID,
+		Content:   checkpoint,
+	}, nil)
+	collaborationRepo.On("GetOperationsAfterSeq", mock.Anything, session.ID, int64(0), len(pending)).Return(pending, nil)
+	documentRepo.On("GetByID", mock.Anything, session.DocumentID).Return(&domain.Document{
+		ID:                session.DocumentID,
+		Status:            domain.DocumentStatusActive,
+		CollaborationMode: domain.CollaborationModeOT,
+	}, nil)
+	permUsecase.On("CanAccessDocument", mock.Anything, session.DocumentID, int64(1)).Return(true, domain.PermissionEdit, nil)
+	return session
+}
+
+func TestApplyOperation_RejectsOutOfRangeOperation(t *testing.T) {
+	// 准备 Mock
+	collaborationRepo := new(MockCollaborationRepository)
+	documentRepo := new(MockDocumentRepository)
+	permUsecase := new(MockDocumentPermissionUsecase)
+	service := NewCollaborationService(collaborationRepo, documentRepo, permUsecase, nil, nil, nil, time.Second)
+
+	ctx := context.Background()
+	pending := []*domain.CollaborationOperation{
+		{Revision: 1, Seq: 1, Type: domain.CollaborationOperationTypeInsert, Position: 2, Content: "c"},
+	}
+	session := newEditingSession(collaborationRepo, documentRepo, permUsecase, "ab", pending)
+	collaborationRepo.On("GetOperationsAfterRevision", mock.Anything, session.ID, int64(1)).Return([]*domain.CollaborationOperation{}, nil)
+
+	// 执行测试：会话内容为 "abc"，删除超出末尾
+	commit, err := service.ApplyOperation(ctx, session.RoomID, 1, "socket-1", 1, "", []*domain.CollaborationOperation{
+		{Type: domain.CollaborationOperationTypeDelete, Position: 2, Length: 5},
+	})
+
+	// 验证结果
+	assert.ErrorIs(t, err, domain.ErrInvalidCollaborationOperation)
+	assert.Nil(t, commit)
+	collaborationRepo.AssertNotCalled(t, "CommitOperations", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApplyOperation_CommitsOperationWithinContent(t *testing.T) {
+	// 准备 Mock
+	collaborationRepo := new(MockCollaborationRepository)
+	documentRepo := new(MockDocumentRepository)
+	permUsecase := new(MockDocumentPermissionUsecase)
+	service := NewCollaborationService(collaborationRepo, documentRepo, permUsecase, nil, nil, nil, time.Second)
+
+	ctx := context.Background()
+	pending := []*domain.CollaborationOperation{
+		{Revision: 1, Seq: 1, Type: domain.CollaborationOperationTypeInsert, Position: 2, Content: "c"},
+	}
+	session := newEditingSession(collaborationRepo, documentRepo, permUsecase, "ab", pending)
+	collaborationRepo.On("GetOperationsAfterRevision", mock.Anything, session.ID, int64(1)).Return([]*domain.CollaborationOperation{}, nil)
+	collaborationRepo.On("CommitOperations", mock.Anything, session.ID, int64(2), mock.Anything).Return(nil)
+
+	// 执行测试：在末尾插入
+	commit, err := service.ApplyOperation(ctx, session.RoomID, 1, "socket-1", 1, "", []*domain.CollaborationOperation{
+		{Type: domain.CollaborationOperationTypeInsert, Position: 3, Content: "d"},
+	})
+
+	// 验证结果
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), commit.Revision)
+	collaborationRepo.AssertExpectations(t)
+}
+
+func TestApplyOperations_SkipsOperationsThatDoNotApply(t *testing.T) {
+	operations := []*domain.CollaborationOperation{
+		{Seq: 1, Type: domain.CollaborationOperationTypeInsert, Position: 2, Content: "c"},
+		{Seq: 2, Type: domain.CollaborationOperationTypeDelete, Position: 2, Length: 5},
+		{Seq: 3, Type: domain.CollaborationOperationTypeInsert, Position: 3, Content: "d"},
+	}
+
+	content, skipped := applyOperations("ab", operations)
+
+	assert.Equal(t, "abcd", content)
+	assert.Equal(t, []int64{2}, skipped)
+}
@@ -0,0 +1,174 @@
+package collaboration
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"DOC/domain"
+	"DOC/pkg/ot"
+)
+
+// checkpointBatchSize 单次检查的最大会话数
+const checkpointBatchSize = 50
+
+// CheckpointSessions 为待处理的会话生成检查点
+// 未写入的操作达到 minOperations，或会话空闲超过 idle 时，将操作应用到文档内容
+func (c *collaborationService) CheckpointSessions(ctx context.Context, minOperations int64, idle time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	sessions, err := c.collaborationRepo.GetSessionsPendingCheckpoint(ctx, minOperations, time.Now().Add(-idle), checkpointBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, session := range sessions {
+		err := c.checkpoint(ctx, session)
+		if errors.Is(err, domain.ErrCollaborationCheckpointStale) {
+			// 其他实例已生成检查点
+			continue
+		}
+		if errors.Is(err, domain.ErrDocumentArchived) {
+			// 归档的文档只读，操作保留到取消归档后再写入
+			continue
+		}
+		if err != nil {
+			log.Printf("生成协作检查点失败: session=%d, err=%v", session.ID, err)
+			continue
+		}
+		created++
+	}
+
+	return created, nil
+}
+
+// CleanupOldOperations 删除超过保留期且已写入检查点的操作
+func (c *collaborationService) CleanupOldOperations(ctx context.Context, retention time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	return c.collaborationRepo.CleanupOldOperations(ctx, time.Now().Add(-retention))
+}
+
+// checkpoint 将检查点之后的操作应用到文档内容并推进检查点
+func (c *collaborationService) checkpoint(ctx context.Context, session *domain.CollaborationSession) error {
+	if session.PendingOperations() <= 0 {
+		return nil
+	}
+
+	content, operations, err := c.replay(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	last := operations[len(operations)-1]
+	prevSeq := session.CheckpointSeq
+	session.CheckpointRevision = last.Revision
+	session.CheckpointSeq = last.Seq
+	if err := c.collaborationRepo.SaveCheckpoint(ctx, session, prevSeq, content); err != nil {
+		return err
+	}
+
+	// 检查点写入了文档内容，记录为最后一个操作者的版本
+	c.recordVersion(ctx, session.DocumentID, last.UserID)
+	c.syncSearch(ctx, session.DocumentID)
+	return nil
+}
+
+// recordVersion 记录协作写入后的文档版本，未配置版本记录时忽略，记录失败只记录日志
+func (c *collaborationService) recordVersion(ctx context.Context, documentID, userID int64) {
+	if c.versions == nil {
+		return
+	}
+	if err := c.versions.RecordVersion(ctx, documentID, userID, domain.DocumentVersionSourceCollaboration); err != nil {
+		log.Printf("记录协作文档版本失败: documentID=%d, err=%v", documentID, err)
+	}
+}
+
+// syncSearch 同步协作写入后的文档搜索索引，未配置搜索索引时忽略
+func (c *collaborationService) syncSearch(ctx context.Context, documentID int64) {
+	if c.search == nil {
+		return
+	}
+	c.search.SyncDocuments(ctx, documentID)
+}
+
+// snapshot 获取会话当前的文档快照：检查点内容加上之后的操作
+func (c *collaborationService) snapshot(ctx context.Context, session *domain.CollaborationSession) (*domain.CollaborationSnapshot, error) {
+	content, _, err := c.replay(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.CollaborationSnapshot{
+		Content:  content,
+		Revision: session.Revision,
+		Seq:      session.LastSeq,
+	}, nil
+}
+
+// replay 将检查点之后直到会话最新序号的操作依次应用到检查点内容
+// 操作只能应用在生成它们时的内容上，文档表中的内容可能已在会话之外被改写，因此以检查点历史为基准
+func (c *collaborationService) replay(ctx context.Context, session *domain.CollaborationSession) (string, []*domain.CollaborationOperation, error) {
+	base, baseSeq, err := c.checkpointContent(ctx, session)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pending := session.LastSeq - baseSeq
+	if pending <= 0 {
+		return base, nil, nil
+	}
+
+	operations, err := c.collaborationRepo.GetOperationsAfterSeq(ctx, session.ID, baseSeq, int(pending))
+	if err != nil {
+		return "", nil, err
+	}
+	// 清理只删除检查点之前的操作，缺失说明数据不一致
+	if int64(len(operations)) != pending || operations[0].Seq != baseSeq+1 {
+		return "", nil, domain.ErrCollaborationOpsCompacted
+	}
+
+	content, skipped := applyOperations(base, operations)
+	if len(skipped) > 0 {
+		log.Printf("跳过无法应用的协作操作: session=%d, seqs=%v", session.ID, skipped)
+	}
+	return content, operations, nil
+}
+
+// applyOperations 将操作依次应用到内容上，无法应用的操作视为空操作跳过，返回跳过的操作序号
+// 提交时已校验操作能应用到会话内容上，跳过的只可能是校验之前写入的操作；
+// 跳过后检查点照常推进，这些操作随之按保留期清理，会话不会因为一个操作永远无法生成检查点
+func applyOperations(content string, operations []*domain.CollaborationOperation) (string, []int64) {
+	var skipped []int64
+	for _, operation := range operations {
+		next, err := ot.Apply(content, operation)
+		if err != nil {
+			skipped = append(skipped, operation.Seq)
+			continue
+		}
+		content = next
+	}
+	return content, skipped
+}
+
+// checkpointContent 获取会话最近一个检查点的内容及其序号
+// 升级前创建的会话没有检查点历史，此时文档内容就是检查点内容
+func (c *collaborationService) checkpointContent(ctx context.Context, session *domain.CollaborationSession) (string, int64, error) {
+	checkpoint, err := c.collaborationRepo.GetCheckpointAtOrBefore(ctx, session.ID, session.CheckpointSeq)
+	if err == nil {
+		return checkpoint.Content, checkpoint.Seq, nil
+	}
+	if !errors.Is(err, domain.ErrCollaborationCheckpointNotFound) {
+		return "", 0, err
+	}
+
+	document, err := c.documentRepo.GetByID(ctx, session.DocumentID)
+	if err != nil {
+		return "", 0, err
+	}
+	return document.Content, session.CheckpointSeq, nil
+}
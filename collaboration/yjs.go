@@ -0,0 +1,220 @@
+package collaboration
+
+import (
+	"context"
+	"sync"
+
+	"DOC/domain"
+	"DOC/pkg/yjs"
+)
+
+// yjsCompactThreshold 合并状态累计的更新条数达到该值后压缩持久化的更新
+const yjsCompactThreshold = 100
+
+// yjsState 会话的 Yjs 合并状态
+// 状态是数据库中更新记录的缓存，每次使用前都会合并其他实例新写入的更新
+type yjsState struct {
+	mu      sync.Mutex
+	update  []byte // 合并后的完整更新
+	lastID  int64  // 已合并的最大更新ID
+	pending int    // 上次压缩后合并的更新条数
+}
+
+// GetCollaborationMode 获取房间对应文档的协作模式
+func (c *collaborationService) GetCollaborationMode(ctx context.Context, roomID string) (domain.CollaborationMode, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	documentID, err := domain.ParseDocumentRoomID(roomID)
+	if err != nil {
+		return "", err
+	}
+
+	document, err := c.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return "", err
+	}
+	return document.GetCollaborationMode(), nil
+}
+
+// ApplyUpdate 合并客户端发送的 Yjs 更新，需要文档编辑权限
+// 更新先持久化再并入内存状态，累计条数过多时压缩为一条
+func (c *collaborationService) ApplyUpdate(ctx context.Context, roomID string, userID int64, socketID string, update []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	decoded, err := yjs.DecodeUpdate(update)
+	if err != nil {
+		return domain.ErrInvalidCollaborationUpdate
+	}
+
+	session, err := c.getActiveEditor(ctx, roomID, userID, socketID)
+	if err != nil {
+		return err
+	}
+
+	if err := c.requireMode(ctx, session.DocumentID, domain.CollaborationModeYjs); err != nil {
+		return err
+	}
+	if err := c.requirePermission(ctx, session.DocumentID, userID, domain.PermissionEdit); err != nil {
+		return err
+	}
+
+	// 空更新不需要持久化
+	if decoded.IsEmpty() {
+		return nil
+	}
+
+	state, unlock := c.lockYjsState(session.ID)
+	defer unlock()
+
+	if err := c.collaborationRepo.StoreUpdate(ctx, &domain.CollaborationUpdate{
+		SessionID: session.ID,
+		Data:      update,
+	}); err != nil {
+		return err
+	}
+	c.recordAccess(ctx, userID, session.DocumentID, domain.DocumentAccessEdit)
+
+	if err := c.refreshYjsState(ctx, session.ID, state); err != nil {
+		return err
+	}
+	if state.pending >= yjsCompactThreshold {
+		return c.compactYjsState(ctx, session.ID, state)
+	}
+	return nil
+}
+
+// GetStateVector 获取房间合并状态的状态向量
+func (c *collaborationService) GetStateVector(ctx context.Context, roomID string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	state, unlock, err := c.loadYjsState(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return yjs.EncodeStateVectorFromUpdate(state.update)
+}
+
+// GetUpdateDiff 获取对方状态向量之后缺少的更新，新加入的客户端据此补齐而无需下载完整内容
+func (c *collaborationService) GetUpdateDiff(ctx context.Context, roomID string, stateVector []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.contextTimeout)
+	defer cancel()
+
+	if _, err := yjs.DecodeStateVector(stateVector); err != nil {
+		return nil, domain.ErrInvalidCollaborationUpdate
+	}
+
+	state, unlock, err := c.loadYjsState(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return yjs.DiffUpdate(state.update, stateVector)
+}
+
+// === 辅助方法 ===
+
+// requireMode 检查文档是否处于指定的协作模式
+func (c *collaborationService) requireMode(ctx context.Context, documentID int64, mode domain.CollaborationMode) error {
+	document, err := c.documentRepo.GetByID(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	if document.GetCollaborationMode() != mode {
+		return domain.ErrCollaborationModeMismatch
+	}
+	return nil
+}
+
+// loadYjsState 获取房间的 Yjs 合并状态并合并最新更新，返回时状态已加锁
+func (c *collaborationService) loadYjsState(ctx context.Context, roomID string) (*yjsState, func(), error) {
+	session, err := c.collaborationRepo.GetSessionByRoomID(ctx, roomID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.requireMode(ctx, session.DocumentID, domain.CollaborationModeYjs); err != nil {
+		return nil, nil, err
+	}
+
+	state, unlock := c.lockYjsState(session.ID)
+	if err := c.refreshYjsState(ctx, session.ID, state); err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return state, unlock, nil
+}
+
+// lockYjsState 获取会话的 Yjs 合并状态并加锁，不存在时创建空状态
+func (c *collaborationService) lockYjsState(sessionID int64) (*yjsState, func()) {
+	value, _ := c.yjsStates.LoadOrStore(sessionID, &yjsState{update: yjs.EmptyUpdate()})
+	state := value.(*yjsState)
+	state.mu.Lock()
+	return state, state.mu.Unlock
+}
+
+// refreshYjsState 将数据库中尚未合并的更新并入状态，调用方需持有状态锁
+func (c *collaborationService) refreshYjsState(ctx context.Context, sessionID int64, state *yjsState) error {
+	updates, err := c.collaborationRepo.GetUpdatesAfterID(ctx, sessionID, state.lastID)
+	if err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	data := make([][]byte, 0, len(updates)+1)
+	data = append(data, state.update)
+	for _, update := range updates {
+		data = append(data, update.Data)
+	}
+
+	merged, err := yjs.MergeUpdates(data...)
+	if err != nil {
+		return err
+	}
+
+	state.update = merged
+	state.lastID = updates[len(updates)-1].ID
+	state.pending += len(updates)
+	return nil
+}
+
+// compactYjsState 用合并状态替换已合并的更新记录，调用方需持有状态锁
+func (c *collaborationService) compactYjsState(ctx context.Context, sessionID int64, state *yjsState) error {
+	if state.pending <= 1 {
+		return nil
+	}
+
+	// 压缩后的记录ID大于 lastID，下次刷新时会再次合并，合并是幂等的
+	if err := c.collaborationRepo.CompactUpdates(ctx, sessionID, state.lastID, &domain.CollaborationUpdate{
+		SessionID: sessionID,
+		Data:      state.update,
+	}); err != nil {
+		return err
+	}
+	state.pending = 0
+	return nil
+}
+
+// releaseYjsState 会话无人在线时压缩更新并释放内存中的合并状态
+func (c *collaborationService) releaseYjsState(ctx context.Context, sessionID int64) error {
+	value, exists := c.yjsStates.Load(sessionID)
+	if !exists {
+		return nil
+	}
+	state := value.(*yjsState)
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	c.yjsStates.Delete(sessionID)
+	if err := c.refreshYjsState(ctx, sessionID, state); err != nil {
+		return err
+	}
+	return c.compactYjsState(ctx, sessionID, state)
+}
@@ -9,14 +9,17 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	Server    ServerConfig    `mapstructure:"server"`
-	Database  DatabaseConfig  `mapstructure:"database"`
-	Redis     RedisConfig     `mapstructure:"redis"`
-	App       AppConfig       `mapstructure:"app"`
-	Email     EmailConfig     `mapstructure:"email"`
-	WebSocket WebSocketConfig `mapstructure:"websocket"`
-	OAuth     OAuthConfig     `mapstructure:"oauth"`
-	Auth      AuthConfig      `mapstructure:"auth"`
+	Server        ServerConfig        `mapstructure:"server"`
+	Database      DatabaseConfig      `mapstructure:"database"`
+	Redis         RedisConfig         `mapstructure:"redis"`
+	App           AppConfig           `mapstructure:"app"`
+	Email         EmailConfig         `mapstructure:"email"`
+	WebSocket     WebSocketConfig     `mapstructure:"websocket"`
+	Collaboration CollaborationConfig `mapstructure:"collaboration"`
+	Document      DocumentConfig      `mapstructure:"document"`
+	Search        SearchConfig        `mapstructure:"search"`
+	OAuth         OAuthConfig         `mapstructure:"oauth"`
+	Auth          AuthConfig          `mapstructure:"auth"`
 }
 
 // ServerConfig 服务器配置
@@ -80,11 +83,46 @@ type WebSocketConfig struct {
 	Path            string `mapstructure:"path"`
 	ReadBufferSize  int    `mapstructure:"read_buffer_size"`
 	WriteBufferSize int    `mapstructure:"write_buffer_size"`
-	MaxMessageSize  int64  `mapstructure:"max_message_size"`
-	PingPeriod      int    `mapstructure:"ping_period"` // 秒
-	PongWait        int    `mapstructure:"pong_wait"`   // 秒
-	WriteWait       int    `mapstructure:"write_wait"`  // 秒
+	MaxMessageSize  int64  `mapstructure:"max_message_size"` // 字节
+	PingPeriod      int    `mapstructure:"ping_period"`      // 秒
+	PongWait        int    `mapstructure:"pong_wait"`        // 秒
+	WriteWait       int    `mapstructure:"write_wait"`       // 秒
 	MaxConnections  int    `mapstructure:"max_connections"`
+	Backplane       string `mapstructure:"backplane"` // 跨实例消息总线：redis 或 local（单实例）
+
+	MaxConnectionsPerUser int `mapstructure:"max_connections_per_user"` // 单用户在单实例上的最大连接数
+	MaxConnectionsPerRoom int `mapstructure:"max_connections_per_room"` // 单房间在单实例上的最大连接数，旁观者不计入
+
+	DrainTimeout        int `mapstructure:"drain_timeout"`         // 关闭时等待正在处理的操作确认的最长时间（秒）
+	DrainReconnectDelay int `mapstructure:"drain_reconnect_delay"` // 关闭时建议客户端重连前随机等待的最长时间（秒）
+}
+
+// CollaborationConfig 实时协作配置
+type CollaborationConfig struct {
+	SnapshotInterval        int `mapstructure:"snapshot_interval"`         // 检查点扫描间隔（秒）
+	SnapshotOperations      int `mapstructure:"snapshot_operations"`       // 累计多少个操作后生成检查点
+	SnapshotIdle            int `mapstructure:"snapshot_idle"`             // 会话空闲多久后生成检查点（秒）
+	OperationRetentionHours int `mapstructure:"operation_retention_hours"` // 检查点之前的操作保留时长
+}
+
+// DocumentConfig 文档配置
+type DocumentConfig struct {
+	VersionInterval         int `mapstructure:"version_interval"`          // 同一用户连续保存合并为一个版本的间隔（分钟）
+	VersionRetentionDays    int `mapstructure:"version_retention_days"`    // 自动版本默认保留天数，空间可单独设置
+	VersionRetentionCount   int `mapstructure:"version_retention_count"`   // 每个文档默认保留的自动版本数，空间可单独设置
+	TrashRetentionDays      int `mapstructure:"trash_retention_days"`      // 回收站条目保留天数，到期后永久删除，0 表示不自动清理
+	TrashPurgeInterval      int `mapstructure:"trash_purge_interval"`      // 回收站过期清理间隔（分钟）
+	AccessFlushInterval     int `mapstructure:"access_flush_interval"`     // 文档访问从 Redis 持久化到数据库的间隔（秒）
+	DuplicateAsyncThreshold int `mapstructure:"duplicate_async_threshold"` // 复制文件夹时同步复制的最大文档数，超过时在后台执行
+}
+
+// SearchConfig 文档搜索配置
+type SearchConfig struct {
+	Engine   string `mapstructure:"engine"`    // 搜索引擎：mysql 为 MySQL 全文索引，embedded 为嵌入式倒排索引（仅限单实例）
+	IndexDir string `mapstructure:"index_dir"` // 嵌入式索引的存储目录
+
+	QuickSwitchTTL        int `mapstructure:"quick_switch_ttl"`         // 快速切换标题索引的缓存时间（秒）
+	QuickSwitchMaxEntries int `mapstructure:"quick_switch_max_entries"` // 每个用户标题索引最多收录的文档数
 }
 
 // OAuthConfig OAuth 认证配置
@@ -226,11 +264,37 @@ func setDefaults() {
 	viper.SetDefault("websocket.path", "/ws")
 	viper.SetDefault("websocket.read_buffer_size", 1024)
 	viper.SetDefault("websocket.write_buffer_size", 1024)
-	viper.SetDefault("websocket.max_message_size", 512)
-	viper.SetDefault("websocket.ping_period", 54) // 54秒
-	viper.SetDefault("websocket.pong_wait", 60)   // 60秒
-	viper.SetDefault("websocket.write_wait", 10)  // 10秒
+	viper.SetDefault("websocket.max_message_size", 1<<20) // 1MB
+	viper.SetDefault("websocket.ping_period", 54)         // 54秒
+	viper.SetDefault("websocket.pong_wait", 60)           // 60秒
+	viper.SetDefault("websocket.write_wait", 10)          // 10秒
 	viper.SetDefault("websocket.max_connections", 1000)
+	viper.SetDefault("websocket.max_connections_per_user", 10)
+	viper.SetDefault("websocket.max_connections_per_room", 100)
+	viper.SetDefault("websocket.drain_timeout", 15)
+	viper.SetDefault("websocket.drain_reconnect_delay", 5)
+	viper.SetDefault("websocket.backplane", "redis")
+
+	// Collaboration defaults
+	viper.SetDefault("collaboration.snapshot_interval", 10)
+	viper.SetDefault("collaboration.snapshot_operations", 200)
+	viper.SetDefault("collaboration.snapshot_idle", 30)
+	viper.SetDefault("collaboration.operation_retention_hours", 168) // 7天
+
+	// Document defaults
+	viper.SetDefault("document.version_interval", 10) // 10分钟
+	viper.SetDefault("document.version_retention_days", 90)
+	viper.SetDefault("document.version_retention_count", 100)
+	viper.SetDefault("document.trash_retention_days", 30)
+	viper.SetDefault("document.trash_purge_interval", 60)  // 1小时
+	viper.SetDefault("document.access_flush_interval", 60) // 1分钟
+	viper.SetDefault("document.duplicate_async_threshold", 100)
+
+	// Search defaults
+	viper.SetDefault("search.engine", "mysql")
+	viper.SetDefault("search.index_dir", "./data/search")
+	viper.SetDefault("search.quick_switch_ttl", 600) // 10分钟
+	viper.SetDefault("search.quick_switch_max_entries", 5000)
 
 	// OAuth defaults
 	// GitHub OAuth
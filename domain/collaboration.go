@@ -2,9 +2,48 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// DocumentRoomPrefix 文档协作房间ID前缀
+// 协作房间与文档一一对应，房间ID格式为 "document:<文档ID>"
+const DocumentRoomPrefix = "document:"
+
+// DocumentRoomID 根据文档ID生成协作房间ID
+func DocumentRoomID(documentID int64) string {
+	return DocumentRoomPrefix + strconv.FormatInt(documentID, 10)
+}
+
+// ParseDocumentRoomID 从协作房间ID中解析文档ID
+func ParseDocumentRoomID(roomID string) (int64, error) {
+	if !strings.HasPrefix(roomID, DocumentRoomPrefix) {
+		return 0, ErrInvalidDocument
+	}
+	documentID, err := strconv.ParseInt(strings.TrimPrefix(roomID, DocumentRoomPrefix), 10, 64)
+	if err != nil || documentID <= 0 {
+		return 0, ErrInvalidDocument
+	}
+	return documentID, nil
+}
+
+// CollaborationMode 文档实时协作模式
+type CollaborationMode string
+
+const (
+	CollaborationModeOT  CollaborationMode = "ot"  // JSON 操作流，服务端做操作转换
+	CollaborationModeYjs CollaborationMode = "yjs" // Yjs 二进制同步协议，服务端只合并更新
 )
 
+// IsValid 检查协作模式是否有效
+func (m CollaborationMode) IsValid() bool {
+	return m == CollaborationModeOT || m == CollaborationModeYjs
+}
+
 // CollaborationSessionStatus 协作会话状态枚举
 type CollaborationSessionStatus int
 
@@ -17,14 +56,18 @@ const (
 // CollaborationSession 协作会话实体
 // 管理文档的实时协作会话
 type CollaborationSession struct {
-	ID         int64                      `json:"id" gorm:"primaryKey;autoIncrement"`
-	DocumentID int64                      `json:"document_id" gorm:"not null;index"`
-	RoomID     string                     `json:"room_id" gorm:"type:varchar(100);uniqueIndex;not null"`
-	Status     CollaborationSessionStatus `json:"status" gorm:"type:tinyint;default:0;index"`
-	MaxUsers   int                        `json:"max_users" gorm:"default:10"`
-	CreatedAt  time.Time                  `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt  time.Time                  `json:"updated_at" gorm:"autoUpdateTime"`
-	ClosedAt   *time.Time                 `json:"closed_at"`
+	ID                 int64                      `json:"id" gorm:"primaryKey;autoIncrement"`
+	DocumentID         int64                      `json:"document_id" gorm:"not null;index"`
+	RoomID             string                     `json:"room_id" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Status             CollaborationSessionStatus `json:"status" gorm:"type:tinyint;default:0;index"`
+	MaxUsers           int                        `json:"max_users" gorm:"default:10"`
+	Revision           int64                      `json:"revision" gorm:"not null;default:0"`            // 当前修订号，每提交一批操作递增
+	LastSeq            int64                      `json:"last_seq" gorm:"not null;default:0"`            // 最后一个操作的序号，每个操作递增
+	CheckpointRevision int64                      `json:"checkpoint_revision" gorm:"not null;default:0"` // 检查点修订号，文档内容已包含该修订及之前的操作
+	CheckpointSeq      int64                      `json:"checkpoint_seq" gorm:"not null;default:0"`      // 检查点对应的操作序号，不大于该序号的操作可按保留期清理
+	CreatedAt          time.Time                  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt          time.Time                  `json:"updated_at" gorm:"autoUpdateTime"`
+	ClosedAt           *time.Time                 `json:"closed_at"`
 
 	// 关联数据
 	Document     *Document                 `json:"document,omitempty" gorm:"-"`
@@ -41,8 +84,9 @@ type CollaborationUser struct {
 	SocketID  string     `json:"socket_id" gorm:"type:varchar(100);index"`
 	CursorPos int        `json:"cursor_pos" gorm:"default:0"`
 	IsActive  bool       `json:"is_active" gorm:"default:true"`
+	Spectator bool       `json:"spectator" gorm:"not null;default:false"` // 旁观者只读，不占用会话人数上限
 	JoinedAt  time.Time  `json:"joined_at" gorm:"autoCreateTime"`
-	LeftAt    *time.Time `json:"left_at"`
+	LeftAt    *time.Time `json:"left_at" gorm:"index"`
 
 	// 关联数据
 	User    *User                 `json:"user,omitempty" gorm:"-"`
@@ -62,21 +106,78 @@ const (
 // CollaborationOperation 协作操作实体
 // 记录协作过程中的所有操作，用于操作转换和冲突解决
 type CollaborationOperation struct {
-	ID        int64                      `json:"id" gorm:"primaryKey;autoIncrement"`
-	SessionID int64                      `json:"session_id" gorm:"not null;index"`
-	UserID    int64                      `json:"user_id" gorm:"not null;index"`
-	Type      CollaborationOperationType `json:"type" gorm:"type:varchar(20);not null"`
-	Position  int                        `json:"position" gorm:"not null"`
-	Content   string                     `json:"content" gorm:"type:text"`
-	Length    int                        `json:"length" gorm:"default:0"`
-	Metadata  string                     `json:"metadata" gorm:"type:json"` // JSON格式的额外数据
-	Timestamp time.Time                  `json:"timestamp" gorm:"autoCreateTime"`
+	ID         int64                      `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionID  int64                      `json:"session_id" gorm:"not null;index;index:idx_collaboration_operation_revision,priority:1;index:idx_collaboration_operation_seq,priority:1;index:idx_collaboration_operation_client,priority:1"`
+	Revision   int64                      `json:"revision" gorm:"not null;default:0;index:idx_collaboration_operation_revision,priority:2"` // 所属修订号，同一修订可能包含多个拆分后的操作
+	Seq        int64                      `json:"seq" gorm:"not null;default:0;index:idx_collaboration_operation_seq,priority:2"`           // 会话内操作序号，从 1 开始连续递增，用于断线续传
+	UserID     int64                      `json:"user_id" gorm:"not null;index;index:idx_collaboration_operation_client,priority:2"`
+	ClientOpID string                     `json:"client_op_id" gorm:"type:varchar(64);index:idx_collaboration_operation_client,priority:3"` // 客户端生成的操作ID，用于识别确认丢失后的重发
+	Type       CollaborationOperationType `json:"type" gorm:"type:varchar(20);not null"`
+	Position   int                        `json:"position" gorm:"not null"`
+	Content    string                     `json:"content" gorm:"type:text"`
+	Length     int                        `json:"length" gorm:"default:0"`
+	Metadata   string                     `json:"metadata" gorm:"type:json"` // JSON格式的额外数据
+	Timestamp  time.Time                  `json:"timestamp" gorm:"autoCreateTime"`
 
 	// 关联数据
 	User    *User                 `json:"user,omitempty" gorm:"-"`
 	Session *CollaborationSession `json:"session,omitempty" gorm:"-"`
 }
 
+// CollaborationUpdate Yjs 协作更新实体
+// 保存 Yjs v1 编码的增量更新，压缩后同一会话只保留一条合并后的更新
+type CollaborationUpdate struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionID int64     `json:"session_id" gorm:"not null;index"`
+	Data      []byte    `json:"-" gorm:"type:longblob;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// CollaborationCheckpoint 协作检查点历史
+// 记录每个检查点时的文档内容，回放时从不晚于目标序号的最近检查点开始应用操作
+// 序号为 0 的检查点是会话创建时的文档内容
+type CollaborationCheckpoint struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionID int64     `json:"session_id" gorm:"not null;index:idx_collaboration_checkpoint_seq,priority:1"`
+	Revision  int64     `json:"revision" gorm:"not null;default:0"`
+	Seq       int64     `json:"seq" gorm:"not null;default:0;index:idx_collaboration_checkpoint_seq,priority:2"`
+	Content   string    `json:"-" gorm:"type:longtext"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// CollaborationCommit 提交协作操作的结果
+type CollaborationCommit struct {
+	Revision   int64                     `json:"revision"`   // 操作所属的修订号
+	Seq        int64                     `json:"seq"`        // 操作中最后一个的序号
+	Operations []*CollaborationOperation `json:"operations"` // 转换后的操作，被并发操作完全抵消时为空
+	Duplicate  bool                      `json:"duplicate"`  // 是否为已提交操作的重发
+}
+
+// CollaborationSnapshot 协作文档快照
+// 断线期间的操作已被清理时，客户端用快照替换本地内容
+type CollaborationSnapshot struct {
+	Content  string `json:"content"`
+	Revision int64  `json:"revision"`
+	Seq      int64  `json:"seq"`
+}
+
+// CollaborationHistory 协作操作历史的一页
+// 按序号排列，NextSeq 作为下一页的 afterSeq
+type CollaborationHistory struct {
+	Operations []*CollaborationOperation `json:"operations"`
+	NextSeq    int64                     `json:"next_seq"`
+	HasMore    bool                      `json:"has_more"`
+}
+
+// CollaborationCatchUp 断线重连后需要补发的内容
+// Snapshot 不为空时表示缺失的操作已不可用，Operations 为空
+type CollaborationCatchUp struct {
+	Revision   int64                     `json:"revision"`
+	Seq        int64                     `json:"seq"`
+	Operations []*CollaborationOperation `json:"operations"`
+	Snapshot   *CollaborationSnapshot    `json:"snapshot,omitempty"`
+}
+
 // Validate 验证协作会话
 func (cs *CollaborationSession) Validate() error {
 	if cs.DocumentID <= 0 {
@@ -91,6 +192,11 @@ func (cs *CollaborationSession) Validate() error {
 	return nil
 }
 
+// PendingOperations 检查点之后尚未写入文档内容的操作数
+func (cs *CollaborationSession) PendingOperations() int64 {
+	return cs.LastSeq - cs.CheckpointSeq
+}
+
 // IsActive 检查会话是否活跃
 func (cs *CollaborationSession) IsActive() bool {
 	return cs.Status == CollaborationSessionStatusActive
@@ -144,6 +250,15 @@ func (cu *CollaborationUser) UpdateCursor(position int) {
 	cu.CursorPos = position
 }
 
+// BeforeCreate 钩子 - 在创建记录前自动调用
+func (co *CollaborationOperation) BeforeCreate(tx *gorm.DB) error {
+	// 确保 Metadata 是有效的 JSON
+	if co.Metadata == "" || !json.Valid([]byte(co.Metadata)) {
+		co.Metadata = "{}"
+	}
+	return nil
+}
+
 // Validate 验证协作操作
 func (co *CollaborationOperation) Validate() error {
 	if co.SessionID <= 0 {
@@ -183,15 +298,49 @@ type CollaborationRepository interface {
 	GetUsersBySessionID(ctx context.Context, sessionID int64) ([]*CollaborationUser, error)
 	GetActiveUsersBySessionID(ctx context.Context, sessionID int64) ([]*CollaborationUser, error)
 	UpdateUser(ctx context.Context, user *CollaborationUser) error
-	RemoveUser(ctx context.Context, sessionID, userID int64) error
+	RemoveUser(ctx context.Context, sessionID int64, socketID string) error // 将连接对应的参与者标记为已离开
 
 	// 操作管理
 	StoreOperation(ctx context.Context, operation *CollaborationOperation) error
 	GetOperationsBySessionID(ctx context.Context, sessionID int64, offset, limit int) ([]*CollaborationOperation, error)
 	GetOperationsAfterTimestamp(ctx context.Context, sessionID int64, timestamp time.Time) ([]*CollaborationOperation, error)
+	GetOperationsAfterRevision(ctx context.Context, sessionID int64, revision int64) ([]*CollaborationOperation, error)
+	// GetOperationsAfterSeq 按序号顺序获取 seq 之后的最多 limit 个操作
+	GetOperationsAfterSeq(ctx context.Context, sessionID int64, seq int64, limit int) ([]*CollaborationOperation, error)
+	// GetOperationsByClientOpID 获取用户以 clientOpID 提交的操作，未提交过时返回空列表
+	GetOperationsByClientOpID(ctx context.Context, sessionID int64, userID int64, clientOpID string) ([]*CollaborationOperation, error)
+	// CommitOperations 以 revision 提交一批操作并分配连续的序号，要求会话当前修订号为 revision-1，否则返回 ErrCollaborationRevisionConflict
+	CommitOperations(ctx context.Context, sessionID int64, revision int64, operations []*CollaborationOperation) error
+	// GetOperationsInRange 按序号顺序获取 seq 之后、时间在 [from, to) 内的最多 limit 个操作，零值时间表示不限制
+	GetOperationsInRange(ctx context.Context, sessionID int64, from, to time.Time, seq int64, limit int) ([]*CollaborationOperation, error)
+
+	// Yjs 更新管理
+	StoreUpdate(ctx context.Context, update *CollaborationUpdate) error
+	GetUpdatesAfterID(ctx context.Context, sessionID int64, afterID int64) ([]*CollaborationUpdate, error)
+	// CompactUpdates 删除 ID 不大于 upToID 的更新，并保存合并后的更新
+	CompactUpdates(ctx context.Context, sessionID int64, upToID int64, merged *CollaborationUpdate) error
+
+	// 检查点
+	// GetSessionsPendingCheckpoint 获取待生成检查点的会话：未写入的操作不少于 minOperations，或最后活动早于 idleBefore
+	GetSessionsPendingCheckpoint(ctx context.Context, minOperations int64, idleBefore time.Time, limit int) ([]*CollaborationSession, error)
+	// SaveCheckpoint 写入文档内容并推进会话检查点，要求会话检查点序号仍为 prevSeq，否则返回 ErrCollaborationCheckpointStale；
+	// 文档已归档时返回 ErrDocumentArchived
+	SaveCheckpoint(ctx context.Context, session *CollaborationSession, prevSeq int64, content string) error
+	// StoreCheckpoint 保存检查点历史
+	StoreCheckpoint(ctx context.Context, checkpoint *CollaborationCheckpoint) error
+	// GetCheckpointAtOrBefore 获取序号不大于 seq 的最近一个检查点历史，不存在时返回 ErrCollaborationCheckpointNotFound
+	GetCheckpointAtOrBefore(ctx context.Context, sessionID int64, seq int64) (*CollaborationCheckpoint, error)
+	// RebaseSession 以会话之外写入的文档内容作为新的检查点，修订号和序号各前进一位，基于旧内容的客户端需重新同步；
+	// 同时删除基于旧内容的 Yjs 更新；有尚未写入检查点的操作时返回 ErrDocumentCollaborating
+	RebaseSession(ctx context.Context, sessionID int64, content string) error
+	// ReplaceDocumentContent 在一个事务中写入文档内容，并以其作为文档协作会话的新检查点，返回文档新的版本号
+	// 会话行加锁直到写入完成，期间的操作提交等待后转为重新同步；OT 会话有尚未写入检查点的操作，或 Yjs 会话有在线连接时
+	// 返回 ErrDocumentCollaborating；expectedVersion 的含义与 DocumentRepository.UpdateContent 相同
+	ReplaceDocumentContent(ctx context.Context, documentID int64, content string, expectedVersion int64) (int64, error)
 
 	// 清理操作
 	CleanupInactiveSessions(ctx context.Context, inactiveThreshold time.Duration) error
+	// CleanupOldOperations 删除指定时间之前且已包含在检查点中的操作、会话当前检查点之前的检查点历史，以及在此之前离开的参与者记录
 	CleanupOldOperations(ctx context.Context, olderThan time.Time) error
 }
 
@@ -203,21 +352,58 @@ type CollaborationUsecase interface {
 	CloseSession(ctx context.Context, roomID string, userID int64) error
 
 	// 用户管理
-	JoinSession(ctx context.Context, roomID string, userID int64, socketID string) (*CollaborationUser, error)
-	LeaveSession(ctx context.Context, roomID string, userID int64) error
+	// 参与者按连接登记，socketID 为 WebSocket 连接ID，同一用户的多个连接互不影响
+	JoinSession(ctx context.Context, roomID string, userID int64, socketID string, spectator bool) (*CollaborationUser, error)
+	LeaveSession(ctx context.Context, roomID string, socketID string) error
 	GetSessionParticipants(ctx context.Context, roomID string) ([]*CollaborationUser, error)
-	UpdateUserCursor(ctx context.Context, roomID string, userID int64, position int) error
+	UpdateUserCursor(ctx context.Context, roomID string, userID int64, socketID string, position int) error
 
 	// 操作管理
-	ApplyOperation(ctx context.Context, roomID string, userID int64, operation *CollaborationOperation) error
-	GetOperations(ctx context.Context, roomID string, afterTimestamp *time.Time) ([]*CollaborationOperation, error)
-	SyncDocument(ctx context.Context, roomID string, userID int64, content string) error
+	// ApplyOperation 将基于 baseRevision 的操作转换后提交，clientOpID 已提交过时直接返回原结果
+	ApplyOperation(ctx context.Context, roomID string, userID int64, socketID string, baseRevision int64, clientOpID string, operations []*CollaborationOperation) (*CollaborationCommit, error)
+	// GetOperations 获取序号 afterSeq 之后的操作
+	GetOperations(ctx context.Context, roomID string, afterSeq int64) ([]*CollaborationOperation, error)
+	// ResumeSession 获取客户端断线期间缺失的操作，操作已被清理时返回快照
+	ResumeSession(ctx context.Context, roomID string, userID int64, socketID string, lastSeq int64) (*CollaborationCatchUp, error)
+	SyncDocument(ctx context.Context, roomID string, userID int64, socketID string, content string) error
+
+	// 历史回放
+	// GetOperationHistory 分页获取文档在 [from, to) 内的操作，需要文档管理权限
+	GetOperationHistory(ctx context.Context, documentID int64, userID int64, from, to time.Time, afterSeq int64, limit int) (*CollaborationHistory, error)
+	// GetContentAtSeq 重建文档在序号 seq 的操作应用后的内容，需要文档管理权限
+	GetContentAtSeq(ctx context.Context, documentID int64, userID int64, seq int64) (*CollaborationSnapshot, error)
+
+	// Yjs 同步
+	GetCollaborationMode(ctx context.Context, roomID string) (CollaborationMode, error)
+	// ApplyUpdate 合并客户端发送的 Yjs 更新并持久化
+	ApplyUpdate(ctx context.Context, roomID string, userID int64, socketID string, update []byte) error
+	// GetStateVector 获取服务端合并状态的状态向量
+	GetStateVector(ctx context.Context, roomID string) ([]byte, error)
+	// GetUpdateDiff 获取对方状态向量之后缺少的更新
+	GetUpdateDiff(ctx context.Context, roomID string, stateVector []byte) ([]byte, error)
 
 	// 权限检查
 	CheckCollaborationPermission(ctx context.Context, userID int64, documentID int64) (bool, error)
 
+	// 快照
+	// CheckpointSessions 将检查点之后的操作应用到文档内容，返回生成检查点的会话数
+	CheckpointSessions(ctx context.Context, minOperations int64, idle time.Duration) (int, error)
+
 	// 清理操作
 	CleanupInactiveSessions(ctx context.Context) error
+	// CleanupOldOperations 删除超过保留期且已写入检查点的操作
+	CleanupOldOperations(ctx context.Context, retention time.Duration) error
+}
+
+// CollaborationContentGuard 协调协作会话之外对文档内容的写入
+// REST 写入和版本恢复直接改写文档内容，之后的协作操作需以新内容为基准，不能应用在旧内容上
+type CollaborationContentGuard interface {
+	// CheckContentWritable 文档的 OT 会话有尚未写入检查点的操作，或 Yjs 会话有在线连接时返回 ErrDocumentCollaborating
+	CheckContentWritable(ctx context.Context, documentID int64) error
+	// RebaseSession 以文档新写入的内容作为协作会话的检查点，文档没有协作会话时不做任何事
+	RebaseSession(ctx context.Context, documentID int64, content string) error
+	// WriteContent 检查内容可写、写入文档内容并更新协作会话的检查点，三步在同一事务中完成，返回文档新的版本号
+	WriteContent(ctx context.Context, documentID int64, content string, expectedVersion int64) (int64, error)
 }
 
 // CollaborationService 协作服务接口
@@ -234,8 +420,46 @@ type CollaborationService interface {
 	GetRoomUsers(ctx context.Context, roomID string) ([]int64, error)
 
 	// 操作转换
-	TransformOperation(ctx context.Context, operation *CollaborationOperation, concurrentOps []*CollaborationOperation) (*CollaborationOperation, error)
+	TransformOperation(ctx context.Context, operation *CollaborationOperation, concurrentOps []*CollaborationOperation) ([]*CollaborationOperation, error)
 
 	// 健康检查
 	HealthCheck(ctx context.Context) error
 }
+
+// HubMessage 通过消息总线在实例间转发的 WebSocket 消息
+// RoomID 和 UserID 二选一，分别表示房间广播和按用户发送
+type HubMessage struct {
+	NodeID  string          `json:"node_id"`           // 发布消息的实例ID
+	RoomID  string          `json:"room_id,omitempty"` // 目标房间
+	UserID  int64           `json:"user_id,omitempty"` // 目标用户
+	Event   string          `json:"event,omitempty"`   // 事件名，二进制消息为空
+	Data    json.RawMessage `json:"data,omitempty"`    // 事件数据
+	Binary  []byte          `json:"binary,omitempty"`  // 二进制消息（Yjs 同步协议）
+	Exclude string          `json:"exclude,omitempty"` // 不接收消息的连接ID，通常是发送者
+}
+
+// HubMember 集群范围内的房间成员
+type HubMember struct {
+	RoomID    string `json:"room_id"`
+	UserID    int64  `json:"user_id"`
+	SocketID  string `json:"socket_id"`
+	NodeID    string `json:"node_id"`   // 连接所在的实例ID
+	Spectator bool   `json:"spectator"` // 是否以旁观者身份加入
+}
+
+// HubBackplane WebSocket Hub 消息总线接口
+// 多实例部署时负责跨实例转发房间广播和用户消息，并维护集群范围的房间成员
+type HubBackplane interface {
+	// NodeID 当前实例ID
+	NodeID() string
+
+	// 消息转发
+	Publish(ctx context.Context, message *HubMessage) error
+	// Subscribe 订阅其他实例发布的消息并维持当前实例的心跳，阻塞直到 ctx 取消或订阅出错
+	Subscribe(ctx context.Context, handler func(message *HubMessage)) error
+
+	// 房间成员
+	AddMember(ctx context.Context, member *HubMember) error
+	RemoveMember(ctx context.Context, roomID string, socketID string) error
+	GetMembers(ctx context.Context, roomID string) ([]*HubMember, error)
+}
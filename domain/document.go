@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"strings"
 	"time"
 )
 
@@ -11,23 +12,30 @@ type DocumentAggregateUsecase interface {
 	// === 文档核心操作（委托给DocumentUsecase） ===
 	CreateDocument(ctx context.Context, userID int64, title, content string, docType DocumentType, parentID, spaceID *int64, sortOrder int, isStarred bool) (*Document, error)
 	GetDocument(ctx context.Context, userID, documentID int64) (*Document, error)
-	UpdateDocument(ctx context.Context, userID, documentID int64, title string, docType *DocumentType, parentID *int64, sortOrder *int, isStarred *bool) (*Document, error)
+	UpdateDocument(ctx context.Context, userID, documentID int64, title string, docType *DocumentType, parentID *int64, sortOrder *int, isStarred *bool, expectedVersion int64) (*Document, error)
 	DeleteDocument(ctx context.Context, userID, documentID int64) error
 	RestoreDocument(ctx context.Context, userID, documentID int64) error
 
 	// 文档内容操作
-	UpdateDocumentContent(ctx context.Context, userID, documentID int64, content string) error
-	GetDocumentContent(ctx context.Context, userID, documentID int64) (string, error)
+	UpdateDocumentContent(ctx context.Context, userID, documentID int64, content string, expectedVersion int64) (int64, error)
+	GetDocumentContent(ctx context.Context, userID, documentID int64) (string, int64, error)
+	SetCollaborationMode(ctx context.Context, userID, documentID int64, mode CollaborationMode) error
 
 	// 文档查询与搜索
-	GetMyDocuments(ctx context.Context, userID int64, parentID *int64, includeDeleted bool) ([]*Document, error)
-	GetDocumentTree(ctx context.Context, userID int64, rootID *int64) ([]*Document, error)
-	SearchDocuments(ctx context.Context, userID int64, keyword string, docType *DocumentType, limit, offset int) ([]*DocumentSearchResult, error)
-	GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*Document, error)
+	GetMyDocuments(ctx context.Context, userID int64, parentID *int64, includeDeleted, includeArchived bool) ([]*Document, error)
+	GetDocumentTree(ctx context.Context, userID int64, rootID *int64, includeArchived bool) ([]*Document, error)
+	SearchDocuments(ctx context.Context, userID int64, filter *DocumentSearchFilter) (*DocumentSearchPage, error)
+	QuickSwitch(ctx context.Context, userID int64, query string, limit int) ([]*QuickSwitchResult, error)
+	GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*RecentDocument, error)
 
 	// 文档操作
 	MoveDocument(ctx context.Context, userID, documentID int64, newParentID *int64) error
-	DuplicateDocument(ctx context.Context, userID, documentID int64, newTitle string) (*Document, error)
+	DuplicateDocument(ctx context.Context, userID, documentID int64, options *DocumentDuplicateOptions) (*DocumentDuplicateResult, error)
+	GetDuplicateJob(ctx context.Context, userID int64, jobID string) (*DocumentDuplicateJob, error)
+
+	// 文档归档
+	ArchiveDocument(ctx context.Context, userID, documentID int64) (*Document, error)
+	UnarchiveDocument(ctx context.Context, userID, documentID int64) (*Document, error)
 
 	// 批量操作
 	BatchDeleteDocuments(ctx context.Context, userID int64, documentIDs []int64) error
@@ -41,7 +49,7 @@ type DocumentAggregateUsecase interface {
 	GetMySharedDocuments(ctx context.Context, userID int64) ([]*DocumentShare, error)
 
 	// 分享访问
-	GetSharedDocument(ctx context.Context, linkID, password string, accessIP string) (*DocumentAccessInfo, error)
+	GetSharedDocument(ctx context.Context, userID int64, linkID, password string, accessIP string) (*DocumentAccessInfo, error)
 	ValidateShareAccess(ctx context.Context, linkID, password string) (*DocumentShare, error)
 
 	// === 文档权限操作（委托给DocumentPermissionUsecase） ===
@@ -62,6 +70,21 @@ type DocumentAggregateUsecase interface {
 	GetFavoriteDocuments(ctx context.Context, userID int64) ([]*DocumentFavorite, error)
 	IsFavoriteDocument(ctx context.Context, userID, documentID int64) (bool, error)
 
+	// === 文档版本操作（委托给DocumentVersionUsecase） ===
+	ListDocumentVersions(ctx context.Context, userID, documentID int64, namedOnly bool, page, pageSize int) ([]*DocumentVersion, int64, error)
+	GetDocumentVersion(ctx context.Context, userID, documentID, versionID int64) (*DocumentVersion, error)
+	CreateNamedVersion(ctx context.Context, userID, documentID int64, name string) (*DocumentVersion, error)
+	NameDocumentVersion(ctx context.Context, userID, documentID, versionID int64, name string) (*DocumentVersion, error)
+	RestoreDocumentVersion(ctx context.Context, userID, documentID, versionID int64) (*DocumentVersion, error)
+	DiffDocumentVersions(ctx context.Context, userID, documentID, fromID, toID int64, granularity DiffGranularity) (*DocumentDiff, error)
+	MarkDocumentVersionViewed(ctx context.Context, userID, documentID, versionID int64) (*DocumentVersionView, error)
+	GetLastViewedDocumentVersion(ctx context.Context, userID, documentID int64) (*DocumentVersionView, error)
+
+	// === 回收站操作（委托给DocumentTrashUsecase） ===
+	ListTrash(ctx context.Context, userID int64, spaceID *int64, page, pageSize int) ([]*DocumentTrashItem, int64, error)
+	RestoreFromTrash(ctx context.Context, userID, documentID int64) (*Document, error)
+	DeleteDocumentPermanently(ctx context.Context, userID, documentID int64) error
+
 	// === 聚合根级别的复合操作 ===
 	GetDocumentWithAccessInfo(ctx context.Context, userID, documentID int64) (*DocumentAccessInfo, error)
 	GetDocumentFullInfo(ctx context.Context, userID, documentID int64) (*DocumentFullInfo, error)
@@ -104,11 +127,50 @@ type DocumentSearchResult struct {
 	Type       DocumentType `json:"type"`
 	IsStarred  bool         `json:"is_starred"`
 	IsFavorite bool         `json:"is_favorite"`
+	IsArchived bool         `json:"is_archived"`
 	UpdatedAt  time.Time    `json:"updated_at"`
 	Owner      *User        `json:"owner,omitempty"`
 	Permission Permission   `json:"permission"`
 	ParentPath string       `json:"parent_path,omitempty"` // 父路径，用于搜索结果显示
 	MatchScore float64      `json:"match_score,omitempty"` // 匹配分数
+
+	// 高亮
+	TitleHighlights []SearchHighlight `json:"title_highlights,omitempty"` // 标题中的匹配位置
+	Snippets        []SearchSnippet   `json:"snippets,omitempty"`         // 正文中包含匹配的摘要
+}
+
+// DocumentSearchPage 一页文档搜索结果及所有命中文档的分面统计
+type DocumentSearchPage struct {
+	Results []*DocumentSearchResult `json:"results"`
+	Total   int64                   `json:"total"`
+	Facets  *DocumentSearchFacets   `json:"facets"`
+}
+
+// DocumentSearchFacets 搜索结果按空间、所有者和类型的分面统计，按文档数从多到少排列
+type DocumentSearchFacets struct {
+	Spaces []*SearchFacetCount    `json:"spaces"`
+	Owners []*SearchFacetCount    `json:"owners"`
+	Types  map[DocumentType]int64 `json:"types"`
+}
+
+// SearchFacetCount 分面中一个取值的文档数
+type SearchFacetCount struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"` // 空间名称或所有者显示名称
+	Count int64  `json:"count"`
+}
+
+// SearchHighlight 匹配位置，偏移和长度按 Unicode 字符计
+type SearchHighlight struct {
+	Offset int `json:"offset"`
+	Length int `json:"length"`
+}
+
+// SearchSnippet 正文摘要
+type SearchSnippet struct {
+	Text       string            `json:"text"`
+	Start      int               `json:"start"`      // 摘要在正文中的起始字符偏移，大于 0 时前面有省略
+	Highlights []SearchHighlight `json:"highlights"` // 相对摘要起始位置的匹配位置
 }
 
 // DocumentOperationResult 文档操作结果
@@ -168,9 +230,12 @@ func ValidateDocumentOperation(userID, documentID int64, operation string, docum
 	}
 
 	// 检查文档状态
-	if !document.IsActive() && operation != "restore" {
+	if document.IsDeleted() && operation != "restore" {
 		return ErrDocumentNotFound // 软删除的文档对外表现为不存在
 	}
+	if document.IsArchived() && isWriteOperation(operation) {
+		return ErrDocumentArchived // 归档的文档只读
+	}
 
 	// 基于操作类型检查权限
 	switch operation {
@@ -182,7 +247,7 @@ func ValidateDocumentOperation(userID, documentID int64, operation string, docum
 		if !IsPermissionSufficient(permission, PermissionEdit) {
 			return ErrPermissionDenied
 		}
-	case "delete", "move", "duplicate":
+	case "delete", "move", "duplicate", "archive":
 		if userID != document.OwnerID && !IsPermissionSufficient(permission, PermissionManage) {
 			return ErrPermissionDenied
 		}
@@ -201,23 +266,26 @@ func ValidateDocumentOperation(userID, documentID int64, operation string, docum
 	return nil
 }
 
-// ValidateDocumentHierarchy 验证文档层级关系
-func ValidateDocumentHierarchy(document *Document, newParentID *int64, allDocuments []*Document) error {
-	if newParentID == nil {
-		return nil // 移动到根目录
+// isWriteOperation 检查操作是否会修改文档，归档的文档不允许这些操作
+func isWriteOperation(operation string) bool {
+	switch operation {
+	case "edit", "update_content", "move":
+		return true
 	}
+	return false
+}
 
-	// 查找新父文档
-	var newParent *Document
-	for _, doc := range allDocuments {
-		if doc.ID == *newParentID {
-			newParent = doc
-			break
-		}
+// ValidateDocumentHierarchy 验证文档层级关系
+// newParent 为空表示移动到根目录；新的父文件夹是文档自身或位于文档的子树中时会形成循环引用，
+// 依据物化路径判断，不需要读取其他文档
+func ValidateDocumentHierarchy(document *Document, newParent *Document) error {
+	if newParent == nil {
+		return nil // 移动到根目录
 	}
 
-	if newParent == nil {
-		return ErrDocumentNotFound
+	// 归档的文件夹只读，不能移入文档
+	if newParent.IsArchived() {
+		return ErrParentDocumentArchived
 	}
 
 	// 检查新父文档是否为文件夹
@@ -226,52 +294,13 @@ func ValidateDocumentHierarchy(document *Document, newParentID *int64, allDocume
 	}
 
 	// 检查是否会形成循环引用
-	if wouldCreateCycle(document.ID, *newParentID, allDocuments) {
+	if newParent.IsInSubtreeOf(document) {
 		return ErrConflict
 	}
 
 	return nil
 }
 
-// wouldCreateCycle 检查移动操作是否会创建循环引用
-func wouldCreateCycle(documentID, newParentID int64, allDocuments []*Document) bool {
-	// 从新父文档开始，向上遍历父文档链
-	currentID := newParentID
-	visited := make(map[int64]bool)
-
-	for currentID != 0 {
-		if visited[currentID] {
-			return true // 检测到循环
-		}
-
-		if currentID == documentID {
-			return true // 将要移动的文档在父链中
-		}
-
-		visited[currentID] = true
-
-		// 查找当前文档的父文档
-		var found bool
-		for _, doc := range allDocuments {
-			if doc.ID == currentID {
-				if doc.ParentID == nil {
-					currentID = 0 // 到达根目录
-				} else {
-					currentID = *doc.ParentID
-				}
-				found = true
-				break
-			}
-		}
-
-		if !found {
-			break // 文档不存在，停止检查
-		}
-	}
-
-	return false
-}
-
 // ValidateBatchOperation 验证批量操作
 func ValidateBatchOperation(userID int64, documentIDs []int64, operation string) error {
 	if len(documentIDs) == 0 {
@@ -300,40 +329,59 @@ func ValidateBatchOperation(userID int64, documentIDs []int64, operation string)
 }
 
 // GetDocumentBreadcrumb 获取文档面包屑导航
-func GetDocumentBreadcrumb(document *Document, allDocuments []*Document) []*Document {
+// ancestors 为按物化路径读取的祖先文档，按由根目录到父文件夹的顺序排列；
+// 缺失的祖先之上的部分被忽略，面包屑总是连续到父文件夹
+func GetDocumentBreadcrumb(document *Document, ancestors []*Document) []*Document {
 	if document == nil {
 		return nil
 	}
 
-	var breadcrumb []*Document
-	current := document
-	visited := make(map[int64]bool)
+	ancestorMap := make(map[int64]*Document, len(ancestors))
+	for _, ancestor := range ancestors {
+		ancestorMap[ancestor.ID] = ancestor
+	}
 
-	// 向上遍历父文档链，构建面包屑
-	for current != nil && current.ParentID != nil {
-		if visited[current.ID] {
-			break // 检测到循环，停止
+	ancestorIDs := document.AncestorIDs()
+	breadcrumb := make([]*Document, 0, len(ancestorIDs))
+	for i := len(ancestorIDs) - 1; i >= 0; i-- {
+		ancestor, ok := ancestorMap[ancestorIDs[i]]
+		if !ok {
+			break
 		}
-		visited[current.ID] = true
+		breadcrumb = append(breadcrumb, ancestor)
+	}
 
-		// 查找父文档
-		var parent *Document
-		for _, doc := range allDocuments {
-			if doc.ID == *current.ParentID {
-				parent = doc
-				break
-			}
+	// 由父文件夹向上收集，反转为由根目录到父文件夹
+	for i, j := 0, len(breadcrumb)-1; i < j; i, j = i+1, j-1 {
+		breadcrumb[i], breadcrumb[j] = breadcrumb[j], breadcrumb[i]
+	}
+	return breadcrumb
+}
+
+// GetDocumentParentPath 获取文档的父路径，由根目录到父文件夹的标题以 " / " 连接
+// documents 按ID索引，需包含文档的所有祖先，缺失的祖先之上的部分被忽略
+func GetDocumentParentPath(document *Document, documents map[int64]*Document) string {
+	if document == nil {
+		return ""
+	}
+
+	var titles []string
+	visited := map[int64]bool{document.ID: true}
+	for parentID := document.ParentID; parentID != nil; {
+		if visited[*parentID] {
+			break // 检测到循环，停止
 		}
+		visited[*parentID] = true
 
-		if parent != nil {
-			breadcrumb = append([]*Document{parent}, breadcrumb...)
-			current = parent
-		} else {
+		parent, ok := documents[*parentID]
+		if !ok {
 			break
 		}
+		titles = append([]string{parent.Title}, titles...)
+		parentID = parent.ParentID
 	}
 
-	return breadcrumb
+	return strings.Join(titles, " / ")
 }
 
 // BuildDocumentAccessInfo 构建文档访问信息
@@ -362,8 +410,22 @@ func BuildDocumentAccessInfo(user *User, document *Document, permissions []*Docu
 		Document:   document,
 		IsOwner:    isOwner,
 		Permission: permission,
-		CanEdit:    IsPermissionSufficient(permission, PermissionEdit),
+		CanEdit:    IsPermissionSufficient(permission, PermissionEdit) && !document.IsArchived(),
 		CanShare:   IsPermissionSufficient(permission, PermissionManage),
 		CanManage:  IsPermissionSufficient(permission, PermissionManage),
 	}
 }
+
+// BuildShareAccessInfo 构建通过分享链接访问文档的信息
+// 分享链接的访问者未登录，权限以分享设置为准，不能再分享或管理；归档的文档即使分享为编辑权限也只读
+func BuildShareAccessInfo(share *DocumentShare, document *Document) *DocumentAccessInfo {
+	if share == nil || document == nil {
+		return nil
+	}
+
+	return &DocumentAccessInfo{
+		Document:   document,
+		Permission: share.Permission,
+		CanEdit:    IsPermissionSufficient(share.Permission, PermissionEdit) && !document.IsArchived(),
+	}
+}
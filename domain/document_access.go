@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// DocumentAccessAction 用户对文档的访问类型
+type DocumentAccessAction string
+
+const (
+	DocumentAccessView DocumentAccessAction = "view" // 打开或读取文档，包括通过分享链接和协作会话
+	DocumentAccessEdit DocumentAccessAction = "edit" // 创建文档或修改标题、内容
+)
+
+// IsValid 检查访问类型是否有效
+func (a DocumentAccessAction) IsValid() bool {
+	return a == DocumentAccessView || a == DocumentAccessEdit
+}
+
+// DocumentAccess 用户对文档的访问记录
+// 访问先写入 Redis，由工作者定期持久化，每个用户在每个文档上只有一条记录
+type DocumentAccess struct {
+	ID             int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         int64      `json:"user_id" gorm:"not null;uniqueIndex:idx_document_access,priority:1;index:idx_document_access_recent,priority:1"`
+	DocumentID     int64      `json:"document_id" gorm:"not null;uniqueIndex:idx_document_access,priority:2;index"`
+	LastViewedAt   *time.Time `json:"last_viewed_at"`
+	LastEditedAt   *time.Time `json:"last_edited_at"`
+	LastAccessedAt time.Time  `json:"last_accessed_at" gorm:"not null;index:idx_document_access_recent,priority:2"` // 最后查看和最后编辑中较晚的一个
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (DocumentAccess) TableName() string {
+	return "document_accesses"
+}
+
+// DocumentAccessEvent 一次文档访问
+type DocumentAccessEvent struct {
+	UserID     int64                `json:"user_id"`
+	DocumentID int64                `json:"document_id"`
+	Action     DocumentAccessAction `json:"action"`
+	AccessedAt time.Time            `json:"accessed_at"`
+}
+
+// RecentActivity 最近文档列表中文档最近一次相关活动的类型
+type RecentActivity string
+
+const (
+	RecentActivityViewed RecentActivity = "viewed" // 用户打开过
+	RecentActivityEdited RecentActivity = "edited" // 用户编辑过
+	RecentActivityShared RecentActivity = "shared" // 他人通过权限或私有分享共享给用户
+)
+
+// RecentDocument 最近文档列表中的文档
+type RecentDocument struct {
+	Document     *Document      `json:"document"` // 不含内容
+	Activity     RecentActivity `json:"activity"` // 最近一次活动的类型
+	ActivityAt   time.Time      `json:"activity_at"`
+	LastViewedAt *time.Time     `json:"last_viewed_at,omitempty"`
+	LastEditedAt *time.Time     `json:"last_edited_at,omitempty"`
+	SharedAt     *time.Time     `json:"shared_at,omitempty"`
+	SharedBy     *int64         `json:"shared_by,omitempty"`
+}
+
+// === 仓储接口 ===
+
+// DocumentAccessRepository 文档访问记录仓储接口
+type DocumentAccessRepository interface {
+	// Upsert 合并访问并写入，已有记录时只将时间更新为更晚的值
+	Upsert(ctx context.Context, events []*DocumentAccessEvent) error
+	// GetRecentByUser 按最后访问时间倒序获取用户的访问记录
+	GetRecentByUser(ctx context.Context, userID int64, limit int) ([]*DocumentAccess, error)
+}
+
+// DocumentAccessCacheRepository 文档访问的 Redis 缓存接口
+// 每个用户按访问类型各有一个有序集合，只保留最近的若干个文档；等待持久化的访问单独存放
+type DocumentAccessCacheRepository interface {
+	// Record 记录访问并加入等待持久化的访问
+	Record(ctx context.Context, event *DocumentAccessEvent) error
+	// GetRecent 按访问时间倒序获取用户最近访问的文档
+	GetRecent(ctx context.Context, userID int64, action DocumentAccessAction, limit int) ([]*DocumentAccessEvent, error)
+
+	// TakePending 取出等待持久化的访问，持久化成功后调用 AckPending 删除
+	// 上次取出的访问未确认时再次返回它们，新的访问留到下一次
+	TakePending(ctx context.Context) ([]*DocumentAccessEvent, error)
+	AckPending(ctx context.Context) error
+}
+
+// === 业务逻辑接口 ===
+
+// DocumentAccessRecorder 文档访问记录接口
+// 由文档、分享和协作服务在用户查看或编辑文档后调用，记录失败只记录日志
+type DocumentAccessRecorder interface {
+	RecordAccess(ctx context.Context, userID, documentID int64, action DocumentAccessAction)
+}
+
+// DocumentAccessUsecase 文档访问业务逻辑接口
+type DocumentAccessUsecase interface {
+	DocumentAccessRecorder
+
+	// GetRecentDocuments 获取用户最近打开、编辑以及被共享的文档，按最近一次活动倒序
+	// 只包含用户当前仍可查看的未删除文档
+	GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*RecentDocument, error)
+	// RecentDocumentIDs 按访问时间倒序获取用户最近打开或编辑的文档ID，只读取 Redis，不检查权限
+	RecentDocumentIDs(ctx context.Context, userID int64, limit int) ([]int64, error)
+
+	// Flush 将 Redis 中等待持久化的访问写入数据库，返回写入的访问数
+	Flush(ctx context.Context) (int, error)
+}
@@ -2,6 +2,9 @@ package domain
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +16,16 @@ const (
 	DocumentTypeFolder DocumentType = "FOLDER" // 文件夹
 )
 
+const (
+	DocumentRootPath = "/" // 根目录的物化路径
+	MaxDocumentDepth = 32  // 文档树的最大层数，物化路径列的长度按此预留
+)
+
+// BuildDocumentPath 由父文档的物化路径和文档ID组成文档的物化路径，根目录下的文档父路径为 DocumentRootPath
+func BuildDocumentPath(parentPath string, id int64) string {
+	return parentPath + strconv.FormatInt(id, 10) + "/"
+}
+
 // DocumentStatus 文档状态枚举
 type DocumentStatus int
 
@@ -26,11 +39,13 @@ const (
 // 这是文档聚合的根实体，包含文档的核心属性和行为
 type Document struct {
 	ID       int64          `json:"id" gorm:"primaryKey;autoIncrement"`
-	Title    string         `json:"title" gorm:"type:varchar(255);not null;index"`
+	Title    string         `json:"title" gorm:"type:varchar(255);not null;index;index:idx_documents_title_fulltext,class:FULLTEXT,option:WITH PARSER ngram"`
 	Content  string         `json:"content" gorm:"type:longtext"`                         // JSON格式的文档内容
 	Type     DocumentType   `json:"type" gorm:"type:varchar(20);not null;default:'FILE'"` // 文档类型
 	Status   DocumentStatus `json:"status" gorm:"type:tinyint;not null;default:0"`        // 文档状态
 	ParentID *int64         `json:"parent_id" gorm:"index"`                               // 父文件夹ID，根目录为nil
+	Path     string         `json:"-" gorm:"type:varchar(700);not null;default:'';index"` // 物化路径，由根目录到文档自身的ID组成，如 /1/5/12/，由仓储在创建和移动时维护
+	Depth    int            `json:"-" gorm:"not null;default:0"`                          // 层级，根目录下的文档为 0
 	SpaceID  *int64         `json:"space_id" gorm:"index"`                                // 所属空间ID，可选
 	OwnerID  int64          `json:"owner_id" gorm:"not null;index"`                       // 文档所有者ID
 
@@ -38,11 +53,26 @@ type Document struct {
 	SortOrder int  `json:"sort_order" gorm:"default:0"`     // 排序顺序
 	IsStarred bool `json:"is_starred" gorm:"default:false"` // 是否星标
 
+	// 协作设置
+	CollaborationMode CollaborationMode `json:"collaboration_mode" gorm:"type:varchar(20);not null;default:'ot'"` // 实时协作模式
+
+	// 并发控制
+	Version int64 `json:"version" gorm:"not null;default:1"` // 写入版本号，每次写入递增，用于乐观并发控制
+
 	// 时间字段
 	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
 	DeletedAt *time.Time `json:"deleted_at" gorm:"index"` // 软删除时间
 
+	// 回收站
+	TrashRootID *int64 `json:"trash_root_id,omitempty" gorm:"index"` // 所在回收站条目的根文档ID，直接删除的文档为自身，随文件夹删除的子孙文档为该文件夹
+
+	// 归档
+	ArchivedAt *time.Time `json:"archived_at,omitempty"` // 归档时间，归档的文档只读
+
+	// 全文检索
+	PlainText string `json:"-" gorm:"type:longtext;->:false;<-;index:idx_documents_plain_text_fulltext,class:FULLTEXT,option:WITH PARSER ngram"` // 从内容中提取的纯文本，由仓储在写入内容时维护，只写不读
+
 	// 关联数据（不存储在数据库中）
 	Owner    *User       `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
 	Parent   *Document   `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
@@ -52,6 +82,14 @@ type Document struct {
 
 // === 实体方法 ===
 
+// GetCollaborationMode 获取文档的实时协作模式，未设置时为操作转换模式
+func (d *Document) GetCollaborationMode() CollaborationMode {
+	if d.CollaborationMode == "" {
+		return CollaborationModeOT
+	}
+	return d.CollaborationMode
+}
+
 // Validate 验证文档实体
 func (d *Document) Validate() error {
 	if d.Title == "" {
@@ -91,27 +129,107 @@ func (d *Document) IsRootDocument() bool {
 	return d.ParentID == nil
 }
 
+// PathIDs 按物化路径由根目录到文档自身的ID
+func (d *Document) PathIDs() []int64 {
+	parts := strings.Split(strings.Trim(d.Path, "/"), "/")
+	ids := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		if id, err := strconv.ParseInt(part, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// AncestorIDs 按物化路径由根目录到父文件夹的所有祖先ID
+func (d *Document) AncestorIDs() []int64 {
+	ids := d.PathIDs()
+	if len(ids) == 0 {
+		return ids
+	}
+	return ids[:len(ids)-1]
+}
+
+// IsInSubtreeOf 检查文档是否为 root 自身或其子孙文档
+func (d *Document) IsInSubtreeOf(root *Document) bool {
+	if d.ID == root.ID {
+		return true
+	}
+	return root.Path != "" && strings.HasPrefix(d.Path, root.Path)
+}
+
 // CanBeParent 检查是否可以作为父目录
 func (d *Document) CanBeParent() bool {
 	return d.IsFolder() && d.IsActive()
 }
 
-// SoftDelete 软删除文档
+// SoftDelete 软删除文档，文档作为回收站条目的根
 func (d *Document) SoftDelete() {
 	d.Status = DocumentStatusDeleted
 	now := time.Now()
 	d.DeletedAt = &now
+	rootID := d.ID
+	d.TrashRootID = &rootID
 }
 
-// Archive 归档文档
+// Archive 归档文档，归档的文档只读
 func (d *Document) Archive() {
 	d.Status = DocumentStatusArchived
+	now := time.Now()
+	d.ArchivedAt = &now
+}
+
+// Unarchive 取消归档
+func (d *Document) Unarchive() {
+	d.Status = DocumentStatusActive
+	d.ArchivedAt = nil
+}
+
+// IsArchived 检查文档是否已归档
+func (d *Document) IsArchived() bool {
+	return d.Status == DocumentStatusArchived
 }
 
 // Restore 恢复文档
 func (d *Document) Restore() {
 	d.Status = DocumentStatusActive
 	d.DeletedAt = nil
+	d.TrashRootID = nil
+}
+
+// IsDeleted 检查文档是否在回收站中
+func (d *Document) IsDeleted() bool {
+	return d.Status == DocumentStatusDeleted
+}
+
+// GetTrashRootID 获取文档所在回收站条目的根文档ID，未记录时为文档自身
+func (d *Document) GetTrashRootID() int64 {
+	if d.TrashRootID == nil {
+		return d.ID
+	}
+	return *d.TrashRootID
+}
+
+// CheckVersion 检查文档版本号是否与期望的一致，期望版本号为 0 时不校验
+func (d *Document) CheckVersion(expectedVersion int64) error {
+	if expectedVersion > 0 && expectedVersion != d.Version {
+		return &DocumentModifiedError{CurrentVersion: d.Version}
+	}
+	return nil
+}
+
+// DocumentModifiedError 文档已被其他写入修改，携带文档当前的版本号
+// 可通过 errors.Is(err, ErrDocumentModified) 判断
+type DocumentModifiedError struct {
+	CurrentVersion int64
+}
+
+func (e *DocumentModifiedError) Error() string {
+	return fmt.Sprintf("%s: current version %d", ErrDocumentModified, e.CurrentVersion)
+}
+
+func (e *DocumentModifiedError) Unwrap() error {
+	return ErrDocumentModified
 }
 
 // === 仓储接口 ===
@@ -121,29 +239,35 @@ type DocumentRepository interface {
 	// 文档基本操作
 	Store(ctx context.Context, document *Document) error
 	GetByID(ctx context.Context, id int64) (*Document, error)
-	Update(ctx context.Context, document *Document) error
+	Update(ctx context.Context, document *Document) error // 按 document.Version 比较并更新，成功后版本号递增
 	Delete(ctx context.Context, id int64) error
 	SoftDelete(ctx context.Context, id int64) error
 
 	// 文档查询
 	GetByOwner(ctx context.Context, ownerID int64, includeDeleted bool) ([]*Document, error)
-	GetByParent(ctx context.Context, parentID *int64, ownerID int64) ([]*Document, error)
+	GetByParent(ctx context.Context, parentID *int64, ownerID int64, includeArchived bool) ([]*Document, error)
 	GetBySpace(ctx context.Context, spaceID int64, ownerID int64) ([]*Document, error)
-	GetDocumentTree(ctx context.Context, rootID *int64, ownerID int64) ([]*Document, error)
-
-	// 文档搜索
-	SearchDocuments(ctx context.Context, userID int64, keyword string, docType *DocumentType, limit, offset int) ([]*Document, error)
+	GetDocumentTree(ctx context.Context, rootID *int64, ownerID int64, includeArchived bool) ([]*Document, error)
+	GetWithAncestors(ctx context.Context, ids []int64) ([]*Document, error)           // 获取文档及其所有祖先文档，只含ID、父文档ID、标题和物化路径
+	GetByIDs(ctx context.Context, ids []int64, withContent bool) ([]*Document, error) // 按ID批量获取文档，withContent 为 false 时不含内容
+	GetSubtreeIDs(ctx context.Context, rootID int64) ([]int64, error)                 // 获取文档自身及所有子孙文档的ID，不区分状态
 	GetStarredDocuments(ctx context.Context, userID int64) ([]*Document, error)
-	GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*Document, error)
+
+	// 搜索索引
+	ListUndeleted(ctx context.Context, afterID int64, limit int) ([]*Document, error) // 按ID顺序分批获取ID大于 afterID 的未删除文档，用于重建索引
 
 	// 文档内容操作
-	UpdateContent(ctx context.Context, id int64, content string) error
+	UpdateContent(ctx context.Context, id int64, content string, expectedVersion int64) (int64, error) // expectedVersion 为 0 时不校验版本，返回新的版本号；文档已归档时返回 ErrDocumentArchived
 	GetContent(ctx context.Context, id int64) (string, error)
 
 	// 文档状态操作
 	UpdateStatus(ctx context.Context, id int64, status DocumentStatus) error
 	ToggleStar(ctx context.Context, id int64, userID int64, starred bool) error
-	MoveDocument(ctx context.Context, id int64, newParentID *int64) error
+	MoveDocument(ctx context.Context, id int64, newParentID *int64) error // 同时改写子树的物化路径；新的父文档位于子树中时返回 ErrConflict，超过最大层数时返回 ErrDocumentTreeTooDeep
+
+	// 文档归档操作
+	Archive(ctx context.Context, id int64) ([]int64, error)   // 归档文档以及未归档的子孙文档，返回本次归档的文档ID
+	Unarchive(ctx context.Context, id int64) ([]int64, error) // 取消归档文档以及已归档的子孙文档，返回本次取消归档的文档ID
 
 	// 批量操作
 	BatchDelete(ctx context.Context, ids []int64, userID int64) error
@@ -157,25 +281,28 @@ type DocumentUsecase interface {
 	// 文档管理
 	CreateDocument(ctx context.Context, userID int64, title, content string, docType DocumentType, parentID, spaceID *int64, sortOrder int, isStarred bool) (*Document, error)
 	GetDocument(ctx context.Context, userID, documentID int64) (*Document, error)
-	UpdateDocument(ctx context.Context, userID, documentID int64, title string, docType *DocumentType, parentID *int64, sortOrder *int, isStarred *bool) (*Document, error)
+	UpdateDocument(ctx context.Context, userID, documentID int64, title string, docType *DocumentType, parentID *int64, sortOrder *int, isStarred *bool, expectedVersion int64) (*Document, error)
 	DeleteDocument(ctx context.Context, userID, documentID int64) error
 	RestoreDocument(ctx context.Context, userID, documentID int64) error
 
 	// 文档内容管理
-	UpdateDocumentContent(ctx context.Context, userID, documentID int64, content string) error
-	GetDocumentContent(ctx context.Context, userID, documentID int64) (string, error)
+	UpdateDocumentContent(ctx context.Context, userID, documentID int64, content string, expectedVersion int64) (int64, error)
+	GetDocumentContent(ctx context.Context, userID, documentID int64) (string, int64, error)
+	SetCollaborationMode(ctx context.Context, userID, documentID int64, mode CollaborationMode) error
 
 	// 文档查询
-	GetMyDocuments(ctx context.Context, userID int64, parentID *int64, includeDeleted bool) ([]*Document, error)
-	GetDocumentTree(ctx context.Context, userID int64, rootID *int64) ([]*Document, error)
-	SearchDocuments(ctx context.Context, userID int64, keyword string, docType *DocumentType, limit, offset int) ([]*Document, error)
+	GetMyDocuments(ctx context.Context, userID int64, parentID *int64, includeDeleted, includeArchived bool) ([]*Document, error)
+	GetDocumentTree(ctx context.Context, userID int64, rootID *int64, includeArchived bool) ([]*Document, error)
 	GetStarredDocuments(ctx context.Context, userID int64) ([]*Document, error)
-	GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*Document, error)
+	GetDocumentBreadcrumb(ctx context.Context, userID, documentID int64) ([]*Document, error)
 
 	// 文档操作
 	MoveDocument(ctx context.Context, userID, documentID int64, newParentID *int64) error
 	ToggleStarDocument(ctx context.Context, userID, documentID int64) (bool, error)
-	DuplicateDocument(ctx context.Context, userID, documentID int64, newTitle string) (*Document, error)
+
+	// 文档归档
+	ArchiveDocument(ctx context.Context, userID, documentID int64) (*Document, error)
+	UnarchiveDocument(ctx context.Context, userID, documentID int64) (*Document, error)
 
 	// 批量操作
 	BatchDeleteDocuments(ctx context.Context, userID int64, documentIDs []int64) error
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// DocumentDuplicateOptions 复制文档的选项
+// 未指定目标父文件夹和目标空间时，副本放在原文档所在的父文件夹和空间中
+type DocumentDuplicateOptions struct {
+	NewTitle        string // 副本根文档的标题，为空时为原标题加“ - 副本”
+	TargetParentID  *int64 // 目标父文件夹，副本归属该文件夹所在的空间
+	TargetSpaceID   *int64 // 目标空间，未指定目标父文件夹时复制到该空间的根目录
+	CopyPermissions bool   // 同时复制其他用户的授权
+	CopyFavorites   bool   // 同时复制操作用户自己的收藏
+}
+
+// DocumentDuplicatePlan 复制文档子树的执行计划，由业务层完成权限检查后交给仓储执行
+type DocumentDuplicatePlan struct {
+	SourceID        int64   // 被复制的根文档
+	DocumentIDs     []int64 // 允许复制的文档，不在其中的子孙文档连同其子树一起跳过
+	OwnerID         int64   // 副本的所有者
+	Title           string  // 副本根文档的标题
+	ParentID        *int64  // 副本根文档的父文件夹
+	SpaceID         *int64  // 所有副本所属的空间
+	CopyPermissions bool
+	CopyFavorites   bool
+}
+
+// DocumentDuplicateJobStatus 复制任务状态
+type DocumentDuplicateJobStatus string
+
+const (
+	DocumentDuplicateJobPending   DocumentDuplicateJobStatus = "pending"   // 等待执行
+	DocumentDuplicateJobRunning   DocumentDuplicateJobStatus = "running"   // 执行中
+	DocumentDuplicateJobCompleted DocumentDuplicateJobStatus = "completed" // 已完成
+	DocumentDuplicateJobFailed    DocumentDuplicateJobStatus = "failed"    // 失败，已复制的文档随事务回滚
+)
+
+// DocumentDuplicateJob 异步复制任务
+// 子树较大时复制在后台执行，客户端按任务ID轮询进度
+type DocumentDuplicateJob struct {
+	ID         string                     `json:"id"`
+	UserID     int64                      `json:"user_id"`
+	SourceID   int64                      `json:"source_id"`
+	Status     DocumentDuplicateJobStatus `json:"status"`
+	Total      int                        `json:"total"`               // 需要复制的文档数
+	Copied     int                        `json:"copied"`              // 已复制的文档数
+	ResultID   *int64                     `json:"result_id,omitempty"` // 完成后副本根文档的ID
+	Error      string                     `json:"error,omitempty"`     // 失败原因
+	CreatedAt  time.Time                  `json:"created_at"`
+	UpdatedAt  time.Time                  `json:"updated_at"`
+	FinishedAt *time.Time                 `json:"finished_at,omitempty"`
+}
+
+// IsFinished 检查任务是否已结束
+func (j *DocumentDuplicateJob) IsFinished() bool {
+	return j.Status == DocumentDuplicateJobCompleted || j.Status == DocumentDuplicateJobFailed
+}
+
+// DocumentDuplicateResult 复制结果
+// 子树较小时同步复制并返回副本根文档，否则返回异步任务
+type DocumentDuplicateResult struct {
+	Document *Document             `json:"document,omitempty"`
+	Job      *DocumentDuplicateJob `json:"job,omitempty"`
+}
+
+// === 仓储接口 ===
+
+// DocumentDuplicateRepository 文档复制仓储接口
+type DocumentDuplicateRepository interface {
+	// Duplicate 在一个事务中按层复制文档子树，保留层级结构和排序，返回副本根文档的ID
+	// 每写入一批副本后以累计复制的文档数调用 progress，progress 可为空
+	Duplicate(ctx context.Context, plan *DocumentDuplicatePlan, progress func(copied int)) (int64, error)
+}
+
+// DocumentDuplicateJobRepository 异步复制任务仓储接口
+// 任务存放在 Redis 中，多个实例都能查询进度，过期后自动删除
+type DocumentDuplicateJobRepository interface {
+	SaveJob(ctx context.Context, job *DocumentDuplicateJob) error
+	GetJob(ctx context.Context, jobID string) (*DocumentDuplicateJob, error) // 任务不存在或已过期时返回 ErrDuplicateJobNotFound
+}
+
+// === 业务逻辑接口 ===
+
+// DocumentDuplicateUsecase 文档复制业务逻辑接口
+type DocumentDuplicateUsecase interface {
+	// DuplicateDocument 复制文档，文件夹连同用户可查看的子孙文档一起复制
+	// 需要对原文档的查看权限，以及对目标父文件夹的编辑权限或目标空间的编辑权限
+	DuplicateDocument(ctx context.Context, userID, documentID int64, options *DocumentDuplicateOptions) (*DocumentDuplicateResult, error)
+	// GetDuplicateJob 获取用户自己的异步复制任务
+	GetDuplicateJob(ctx context.Context, userID int64, jobID string) (*DocumentDuplicateJob, error)
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// DocumentEventType 文档事件类型
+type DocumentEventType string
+
+const (
+	DocumentEventContentChanged DocumentEventType = "content_changed"     // 内容变更
+	DocumentEventContentReset   DocumentEventType = "content_reset"       // 内容在协作会话之外被整体替换（恢复版本、REST 写入），协作连接随后被关闭，需重新同步
+	DocumentEventTitleChanged   DocumentEventType = "title_changed"       // 标题变更
+	DocumentEventPresenceCount  DocumentEventType = "presence_count"      // 在线人数变化
+	DocumentEventDeleted        DocumentEventType = "document_deleted"    // 文档已删除
+	DocumentEventArchived       DocumentEventType = "document_archived"   // 文档已归档，变为只读
+	DocumentEventUnarchived     DocumentEventType = "document_unarchived" // 文档已取消归档
+)
+
+// DocumentEvent 文档事件
+// 经协作房间广播给 WebSocket 客户端，并以只读事件流提供给无法建立 WebSocket 连接的订阅方
+type DocumentEvent struct {
+	Type       DocumentEventType      `json:"type"`
+	DocumentID int64                  `json:"document_id"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// NewDocumentEvent 创建文档事件
+func NewDocumentEvent(eventType DocumentEventType, documentID int64, data map[string]interface{}) *DocumentEvent {
+	return &DocumentEvent{
+		Type:       eventType,
+		DocumentID: documentID,
+		Data:       data,
+		Timestamp:  time.Now(),
+	}
+}
+
+// DocumentEventPublisher 文档事件发布接口
+type DocumentEventPublisher interface {
+	// PublishDocumentEvent 向文档协作房间广播事件，发布失败不影响业务操作
+	PublishDocumentEvent(ctx context.Context, event *DocumentEvent)
+}
+
+// DocumentEventFeed 文档只读事件订阅接口
+type DocumentEventFeed interface {
+	// SubscribeDocument 订阅文档事件，ctx 结束或订阅被服务端终止时关闭返回的通道
+	// 文档删除事件是最后一个事件
+	SubscribeDocument(ctx context.Context, documentID int64) (<-chan *DocumentEvent, error)
+}
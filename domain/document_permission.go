@@ -98,6 +98,7 @@ type DocumentPermissionRepository interface {
 
 	// 查询
 	GetByDocument(ctx context.Context, documentID int64) ([]*DocumentPermission, error)
+	GetByDocuments(ctx context.Context, documentIDs []int64) ([]*DocumentPermission, error) // 批量获取多个文档的权限，不加载关联用户
 	GetByUser(ctx context.Context, userID int64) ([]*DocumentPermission, error)
 	GetUserPermission(ctx context.Context, documentID, userID int64) (*DocumentPermission, error)
 
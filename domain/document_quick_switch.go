@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// QuickSwitchEntry 快速切换标题索引中的文档
+type QuickSwitchEntry struct {
+	ID         int64        `json:"id"`
+	Title      string       `json:"title"`
+	Initials   string       `json:"initials,omitempty"` // 标题的拼音首字母，标题不含汉字时为空
+	Type       DocumentType `json:"type"`
+	ParentPath string       `json:"parent_path,omitempty"` // 由用户可见的祖先标题拼接的父路径
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// QuickSwitchTitles 用户的标题索引
+// 包含用户可以查看的所有文档标题以及用于加权的收藏，整体缓存在 Redis 中；
+// 最近访问变化频繁，每次查询时从访问记录读取
+type QuickSwitchTitles struct {
+	Entries     []*QuickSwitchEntry `json:"entries"`
+	FavoriteIDs []int64             `json:"favorite_ids,omitempty"`
+	BuiltAt     time.Time           `json:"built_at"`
+}
+
+// QuickSwitchAudience 上次同步时可以看到文档的用户
+// 文档的标题、状态或可见范围变化时，新旧两组用户的标题索引都需要失效
+type QuickSwitchAudience struct {
+	Signature string  `json:"signature"` // 标题、状态和可见范围的摘要，不变时无需失效
+	UserIDs   []int64 `json:"user_ids"`
+}
+
+// QuickSwitchResult 快速切换的匹配结果
+type QuickSwitchResult struct {
+	ID         int64             `json:"id"`
+	Title      string            `json:"title"`
+	Type       DocumentType      `json:"type"`
+	ParentPath string            `json:"parent_path,omitempty"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	MatchType  string            `json:"match_type,omitempty"` // prefix、contains、pinyin 或 fuzzy，查询为空时为空
+	Highlights []SearchHighlight `json:"highlights,omitempty"` // 标题中的匹配位置
+	Score      float64           `json:"score"`
+	IsRecent   bool              `json:"is_recent"`
+	IsFavorite bool              `json:"is_favorite"`
+}
+
+// === 缓存接口 ===
+
+// QuickSwitchCacheRepository 快速切换标题索引缓存接口
+type QuickSwitchCacheRepository interface {
+	// GetTitles 获取用户的标题索引，未缓存时返回 nil
+	GetTitles(ctx context.Context, userID int64) (*QuickSwitchTitles, error)
+	SetTitles(ctx context.Context, userID int64, titles *QuickSwitchTitles, expiration time.Duration) error
+	DeleteTitles(ctx context.Context, userIDs ...int64) error
+
+	// GetAudiences 批量获取文档上次同步时的可见用户，没有记录的文档不在结果中
+	GetAudiences(ctx context.Context, documentIDs []int64) (map[int64]*QuickSwitchAudience, error)
+	SetAudience(ctx context.Context, documentID int64, audience *QuickSwitchAudience) error
+	DeleteAudiences(ctx context.Context, documentIDs ...int64) error
+}
+
+// === 业务逻辑接口 ===
+
+// DocumentTitleSyncer 快速切换标题索引同步接口
+// 由搜索索引同步时调用，同步失败只记录日志，缓存过期后自动重建
+type DocumentTitleSyncer interface {
+	// SyncTitles 文档写入搜索索引或从中移除后，使标题或可见范围有变化的文档的新旧可见用户的标题索引失效
+	SyncTitles(ctx context.Context, documents []*SearchDocument, removedIDs []int64)
+	// InvalidateUsers 使用户的标题索引失效，用于收藏、空间成员等只影响个别用户的变化
+	InvalidateUsers(ctx context.Context, userIDs ...int64)
+}
+
+// DocumentQuickSwitchUsecase 快速切换业务逻辑接口
+type DocumentQuickSwitchUsecase interface {
+	DocumentTitleSyncer
+
+	// QuickSwitch 按前缀、包含、拼音首字母和模糊匹配查找用户可以查看的文档标题，
+	// 最近访问和收藏的文档加权；查询为空时返回最近访问和收藏的文档
+	QuickSwitch(ctx context.Context, userID int64, query string, limit int) ([]*QuickSwitchResult, error)
+}
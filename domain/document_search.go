@@ -0,0 +1,221 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// SearchDocument 搜索索引中的文档
+// 由文档、正文纯文本和可查看文档的用户组成，文档、其权限、分享或所属空间变化后整体重新写入
+type SearchDocument struct {
+	ID           int64          `json:"id"`
+	Title        string         `json:"title"`
+	PlainText    string         `json:"plain_text"` // 从内容中提取的纯文本
+	Type         DocumentType   `json:"type"`
+	Status       DocumentStatus `json:"status"`
+	IsStarred    bool           `json:"is_starred"`
+	OwnerID      int64          `json:"owner_id"`
+	SpaceIDs     []int64        `json:"space_ids,omitempty"` // 文档的主空间以及通过关联加入的空间
+	ParentID     *int64         `json:"parent_id,omitempty"`
+	ReaderIDs    []int64        `json:"reader_ids,omitempty"`    // 所有者以外有查看权限的用户
+	ShareReaders []SearchReader `json:"share_readers,omitempty"` // 私有分享指定的用户
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// SearchReader 通过私有分享可以查看文档的用户，分享过期后不再可见
+type SearchReader struct {
+	UserID    int64      `json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CanRead 用户是否可以在搜索结果中看到文档
+// 所有者、被授予权限或分享的用户以及文档所在空间的成员可以查看，spaceIDs 为用户所在的空间
+func (d *SearchDocument) CanRead(userID int64, spaceIDs []int64, now time.Time) bool {
+	if d.OwnerID == userID || d.IsSharedWith(userID, now) {
+		return true
+	}
+	for _, spaceID := range spaceIDs {
+		if d.InSpace(spaceID) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSharedWith 文档是否通过权限或未过期的私有分享共享给了所有者以外的用户
+func (d *SearchDocument) IsSharedWith(userID int64, now time.Time) bool {
+	if d.OwnerID == userID {
+		return false
+	}
+	for _, readerID := range d.ReaderIDs {
+		if readerID == userID {
+			return true
+		}
+	}
+	for _, reader := range d.ShareReaders {
+		if reader.UserID == userID && (reader.ExpiresAt == nil || reader.ExpiresAt.After(now)) {
+			return true
+		}
+	}
+	return false
+}
+
+// InSpace 文档是否属于空间
+func (d *SearchDocument) InSpace(spaceID int64) bool {
+	for _, id := range d.SpaceIDs {
+		if id == spaceID {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchQuery 搜索条件
+// 关键词为空时按过滤条件列出文档，所有过滤条件之间为并且关系
+type SearchQuery struct {
+	UserID          int64         // 只返回该用户可以查看的文档
+	SpaceIDs        []int64       // 用户所在的空间，空间内的文档对用户可见
+	Keyword         string        // 搜索关键词，多个检索词以空白分隔，都需要出现
+	Type            *DocumentType // 文档类型，为空时不限
+	IncludeArchived bool          // 是否包含已归档的文档
+
+	OwnerID       *int64     // 所有者
+	SpaceID       *int64     // 所属空间
+	AncestorID    *int64     // 只返回该文件夹的子孙文档
+	UpdatedAfter  *time.Time // 更新时间范围，包含边界
+	UpdatedBefore *time.Time
+	CreatedAfter  *time.Time // 创建时间范围，包含边界
+	CreatedBefore *time.Time
+	DocumentIDs   []int64 // 限定的文档范围，为 nil 时不限，用于收藏过滤
+	StarredOnly   bool    // 只返回星标文档
+	SharedWithMe  bool    // 只返回他人通过权限或私有分享共享给用户的文档
+
+	Limit      int
+	Offset     int
+	Now        time.Time // 判断分享是否过期的时间
+	SkipFacets bool      // 不统计分面，只需要命中文档时使用
+}
+
+// SearchIndexHit 搜索索引命中的文档
+type SearchIndexHit struct {
+	ID        int64   // 文档ID
+	Score     float64 // 相关度，标题匹配加权
+	PlainText string  // 正文纯文本，用于生成摘要
+}
+
+// SearchIndexResult 搜索索引的查询结果
+type SearchIndexResult struct {
+	Hits   []*SearchIndexHit // 按相关度排序的当前页
+	Total  int64             // 命中的文档总数
+	Facets *SearchFacets     // 所有命中文档的分面统计
+}
+
+// SearchFacets 搜索结果的分面统计，按分页前所有命中的文档计数
+// 文档属于多个空间时在每个空间中各计一次
+type SearchFacets struct {
+	Spaces map[int64]int64        `json:"spaces"` // 空间ID -> 文档数
+	Owners map[int64]int64        `json:"owners"` // 所有者ID -> 文档数
+	Types  map[DocumentType]int64 `json:"types"`  // 文档类型 -> 文档数
+}
+
+// NewSearchFacets 创建空的分面统计
+func NewSearchFacets() *SearchFacets {
+	return &SearchFacets{
+		Spaces: make(map[int64]int64),
+		Owners: make(map[int64]int64),
+		Types:  make(map[DocumentType]int64),
+	}
+}
+
+// Add 将一个命中文档计入分面统计
+func (f *SearchFacets) Add(document *SearchDocument) {
+	for _, spaceID := range document.SpaceIDs {
+		f.Spaces[spaceID]++
+	}
+	f.Owners[document.OwnerID]++
+	f.Types[document.Type]++
+}
+
+// DocumentSearchHit 搜索命中的文档
+type DocumentSearchHit struct {
+	Document   *Document // 文档，不含内容
+	PlainText  string    // 正文纯文本，用于生成摘要
+	Score      float64   // 相关度，标题匹配加权
+	ParentPath string    // 父路径，由根目录到父文件夹的标题
+}
+
+// DocumentSearchFilter 文档搜索的关键词和过滤条件
+type DocumentSearchFilter struct {
+	Keyword         string
+	Type            *DocumentType
+	IncludeArchived bool
+
+	OwnerID       *int64
+	SpaceID       *int64
+	ParentID      *int64 // 只搜索该文件夹的子孙文档
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	FavoritesOnly bool // 只搜索用户收藏的文档
+	StarredOnly   bool // 只搜索星标文档
+	SharedWithMe  bool // 只搜索他人共享给用户的文档
+
+	Limit  int
+	Offset int
+}
+
+// HasFilters 是否设置了关键词以外的过滤条件
+func (f *DocumentSearchFilter) HasFilters() bool {
+	return f.Type != nil || f.OwnerID != nil || f.SpaceID != nil || f.ParentID != nil ||
+		f.UpdatedAfter != nil || f.UpdatedBefore != nil || f.CreatedAfter != nil || f.CreatedBefore != nil ||
+		f.FavoritesOnly || f.StarredOnly || f.SharedWithMe
+}
+
+// DocumentSearchHits 一页搜索命中的文档
+type DocumentSearchHits struct {
+	Hits   []*DocumentSearchHit
+	Total  int64
+	Facets *SearchFacets
+}
+
+// === 索引接口 ===
+
+// SearchIndex 文档搜索索引接口
+// 索引只保存搜索需要的数据，是文档的派生数据，可以随时从文档重建
+type SearchIndex interface {
+	// Index 写入文档，已存在时整体替换
+	Index(ctx context.Context, document *SearchDocument) error
+	// Delete 从索引中删除文档，不存在的文档被忽略
+	Delete(ctx context.Context, documentIDs ...int64) error
+	// Search 按相关度查询用户可以查看的文档
+	Search(ctx context.Context, query *SearchQuery) (*SearchIndexResult, error)
+	// Reset 清空索引，重建前调用
+	Reset(ctx context.Context) error
+	// Close 将未持久化的数据写入存储并释放资源
+	Close() error
+}
+
+// === 业务逻辑接口 ===
+
+// DocumentSearchSyncer 搜索索引同步接口
+// 文档或其权限写入后调用，同步失败只记录日志，不影响写入，可通过重建索引修复
+type DocumentSearchSyncer interface {
+	// SyncDocuments 按文档当前状态更新索引，已删除或不存在的文档从索引中移除
+	SyncDocuments(ctx context.Context, documentIDs ...int64)
+	// SyncSubtree 同步文档自身及所有子孙文档，用于删除、恢复等作用于整个子树的操作
+	SyncSubtree(ctx context.Context, rootID int64)
+}
+
+// DocumentSearchUsecase 文档搜索业务逻辑接口
+type DocumentSearchUsecase interface {
+	DocumentSearchSyncer
+
+	// SearchDocuments 搜索用户拥有、被共享或所在空间中的文档，结果按相关度排序，
+	// 附带每个文档的父路径以及所有命中文档的分面统计
+	SearchDocuments(ctx context.Context, userID int64, filter *DocumentSearchFilter) (*DocumentSearchHits, error)
+
+	// Reindex 清空索引并从所有未删除的文档重建，返回写入的文档数
+	Reindex(ctx context.Context) (int, error)
+}
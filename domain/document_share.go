@@ -150,6 +150,8 @@ type DocumentShareRepository interface {
 	AddShareUser(ctx context.Context, shareUser *DocumentShareUser) error
 	RemoveShareUser(ctx context.Context, shareID, userID int64) error
 	GetShareUsers(ctx context.Context, shareID int64) ([]*DocumentShareUser, error)
+	GetShareUsersByDocuments(ctx context.Context, documentIDs []int64) ([]*DocumentShareUser, error) // 批量获取多个文档的私有分享用户，附带所属分享
+	GetShareUsersByUser(ctx context.Context, userID int64) ([]*DocumentShareUser, error)             // 获取用户被加入的私有分享，附带所属分享
 
 	// 统计
 	IncrementViewCount(ctx context.Context, shareID int64, accessIP string) error
@@ -170,7 +172,7 @@ type DocumentShareUsecase interface {
 	DeleteShareLink(ctx context.Context, userID, shareID int64) error
 
 	// 访问与鉴权
-	GetSharedDocument(ctx context.Context, linkID, password string, accessIP string) (*Document, error)
+	GetSharedDocument(ctx context.Context, userID int64, linkID, password string, accessIP string) (*Document, error) // userID 为已登录的访问者，匿名访问时为 0
 	ValidateShareAccess(ctx context.Context, linkID, password string) (*DocumentShare, error)
 	RecordShareAccess(ctx context.Context, shareID int64, accessIP string) error
 
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// DocumentTrashItem 回收站条目
+// 回收站只列出被直接删除的文档，随文件夹一起删除的子孙文档只计入 DescendantCount
+type DocumentTrashItem struct {
+	Document        *Document  `json:"document"`           // 被删除的文档，不含内容
+	DescendantCount int64      `json:"descendant_count"`   // 随之一起删除的子孙文档数
+	PurgeAt         *time.Time `json:"purge_at,omitempty"` // 到期后被永久删除的时间，未开启自动清理时为空
+}
+
+// DocumentTrashFilter 回收站查询条件
+// SpaceID 为空时查询用户拥有的文档，否则查询归属该空间的文档
+type DocumentTrashFilter struct {
+	OwnerID int64
+	SpaceID *int64
+}
+
+// === 仓储接口 ===
+
+// DocumentTrashRepository 回收站仓储接口
+// 回收站条目以根文档标识，条目包含根文档以及与其一起删除的子孙文档
+type DocumentTrashRepository interface {
+	// List 按删除时间倒序分页获取回收站条目
+	List(ctx context.Context, filter DocumentTrashFilter, offset, limit int) ([]*DocumentTrashItem, int64, error)
+	// ListExpired 获取删除时间早于 before 的回收站条目根文档ID
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]int64, error)
+
+	// Restore 恢复文档以及与其一起删除的子孙文档，各文档恢复为删除前的状态（正常或已归档），返回恢复的文档数
+	// relocate 为 true 时将文档移动到根目录，用于原父文件夹已不存在或仍在回收站中的情况
+	Restore(ctx context.Context, documentID int64, relocate bool) (int64, error)
+	// Purge 永久删除文档以及与其一起删除的子孙文档，同时删除它们的分享、权限、收藏、空间关联、历史版本、访问记录和协作数据
+	// 返回删除的文档数
+	Purge(ctx context.Context, documentID int64) (int64, error)
+}
+
+// === 业务逻辑接口 ===
+
+// DocumentTrashUsecase 回收站业务逻辑接口
+type DocumentTrashUsecase interface {
+	// ListTrash 分页获取回收站条目，spaceID 为空时获取用户自己的回收站，否则需要是空间成员
+	ListTrash(ctx context.Context, userID int64, spaceID *int64, page, pageSize int) ([]*DocumentTrashItem, int64, error)
+
+	// 以下操作需要是文档所有者，或文档所属空间的所有者、管理员
+	// RestoreFromTrash 恢复文档及其子树，原父文件夹已不存在或仍在回收站中时恢复到根目录
+	RestoreFromTrash(ctx context.Context, userID, documentID int64) (*Document, error)
+	// DeletePermanently 永久删除回收站中的文档及其子树
+	DeletePermanently(ctx context.Context, userID, documentID int64) error
+
+	// PurgeExpired 永久删除超过保留期的回收站条目，返回清理的条目数
+	PurgeExpired(ctx context.Context) (int, error)
+}
@@ -0,0 +1,208 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// DocumentVersionSource 文档版本来源
+type DocumentVersionSource string
+
+const (
+	DocumentVersionSourceSave          DocumentVersionSource = "SAVE"          // 保存内容或标题时自动生成
+	DocumentVersionSourceCollaboration DocumentVersionSource = "COLLABORATION" // 协作检查点写入内容时自动生成
+	DocumentVersionSourceManual        DocumentVersionSource = "MANUAL"        // 手动创建的命名版本
+	DocumentVersionSourceRestore       DocumentVersionSource = "RESTORE"       // 恢复历史版本时生成
+)
+
+// maxDocumentVersionNameLength 版本名称的最大长度
+const maxDocumentVersionNameLength = 100
+
+// DocumentVersion 文档版本实体
+// 保存文档某一时刻的标题和内容，内容被覆盖后可从历史版本恢复
+// 带名称的版本为手动标记的里程碑，不受保留规则清理
+type DocumentVersion struct {
+	ID             int64                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	DocumentID     int64                 `json:"document_id" gorm:"not null;index:idx_document_version_document,priority:1"`
+	Title          string                `json:"title" gorm:"type:varchar(255);not null"`
+	Content        string                `json:"content,omitempty" gorm:"type:longtext"`
+	ContentSize    int                   `json:"content_size" gorm:"default:0"`                          // 内容字节数，列表中不返回内容时用于展示
+	Source         DocumentVersionSource `json:"source" gorm:"type:varchar(20);not null;default:'SAVE'"` // 版本来源
+	Name           string                `json:"name" gorm:"type:varchar(100);index"`                    // 版本名称，为空表示自动版本
+	AuthorID       int64                 `json:"author_id" gorm:"not null;index"`                        // 产生该版本的用户
+	RestoredFromID *int64                `json:"restored_from_id,omitempty"`                             // 恢复来源版本ID
+	CreatedAt      time.Time             `json:"created_at" gorm:"autoCreateTime;index:idx_document_version_document,priority:2"`
+	UpdatedAt      time.Time             `json:"updated_at" gorm:"autoUpdateTime"` // 节流期内同一用户的连续保存会更新同一个版本
+
+	// 关联数据
+	Author *User `json:"author,omitempty" gorm:"-"`
+}
+
+// DocumentVersionRetention 文档版本保留规则
+// 命名版本始终保留，自动版本超过保留天数或超过保留个数时被清理，0 表示不限制
+type DocumentVersionRetention struct {
+	Days     int `json:"days"`
+	MaxCount int `json:"max_count"`
+}
+
+// DocumentVersionView 用户最后查看的文档版本
+// 用于对比"上次查看之后的变化"，每个用户在每个文档上只有一条记录
+type DocumentVersionView struct {
+	ID         int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	DocumentID int64     `json:"document_id" gorm:"not null;uniqueIndex:idx_document_version_view,priority:1"`
+	UserID     int64     `json:"user_id" gorm:"not null;uniqueIndex:idx_document_version_view,priority:2"`
+	VersionID  int64     `json:"version_id" gorm:"not null;index"`
+	ViewedAt   time.Time `json:"viewed_at"`
+}
+
+// DiffGranularity 文本差异的粒度
+type DiffGranularity string
+
+const (
+	DiffGranularityLine DiffGranularity = "line" // 按行
+	DiffGranularityWord DiffGranularity = "word" // 按行对齐后在变化的行内按词细化
+)
+
+// DiffOperation 差异操作
+type DiffOperation string
+
+const (
+	DiffEqual   DiffOperation = "equal"   // 未变化
+	DiffInsert  DiffOperation = "insert"  // 新增
+	DiffDelete  DiffOperation = "delete"  // 删除
+	DiffReplace DiffOperation = "replace" // 替换，仅用于内容节点
+)
+
+// DiffChunk 文本差异片段，按顺序拼接相等和删除片段得到旧文本，拼接相等和新增片段得到新文本
+type DiffChunk struct {
+	Op   DiffOperation `json:"op"`
+	Text string        `json:"text"`
+}
+
+// DiffNodeChange JSON 内容模型中单个节点的变化
+// Path 为 JSON Pointer，删除的节点使用旧内容中的路径，其余使用新内容中的路径
+type DiffNodeChange struct {
+	Op   DiffOperation   `json:"op"`
+	Path string          `json:"path"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// DocumentDiff 两个文档版本之间的差异
+type DocumentDiff struct {
+	DocumentID  int64            `json:"document_id"`
+	From        *DocumentVersion `json:"from"` // 起始版本，不含内容
+	To          *DocumentVersion `json:"to"`   // 目标版本，不含内容，ID 为 0 表示文档当前内容
+	Granularity DiffGranularity  `json:"granularity"`
+
+	Title []DiffChunk      `json:"title"` // 标题的按词差异
+	Text  []DiffChunk      `json:"text"`  // 内容文本的差异，JSON 内容取其中的文本
+	Nodes []DiffNodeChange `json:"nodes"` // JSON 内容的节点差异，内容不是 JSON 时为空
+	HTML  string           `json:"html"`  // 标题和文本差异渲染的 HTML
+}
+
+// === 实体方法 ===
+
+// Validate 验证文档版本
+func (dv *DocumentVersion) Validate() error {
+	if dv.DocumentID <= 0 {
+		return ErrInvalidDocument
+	}
+	if dv.AuthorID <= 0 {
+		return ErrInvalidUser
+	}
+	return ValidateDocumentVersionName(dv.Name)
+}
+
+// IsNamed 检查是否为命名版本
+func (dv *DocumentVersion) IsNamed() bool {
+	return dv.Name != ""
+}
+
+// IsAutomatic 检查是否为自动生成的版本，自动版本在节流期内可以合并
+func (dv *DocumentVersion) IsAutomatic() bool {
+	return !dv.IsNamed() && (dv.Source == DocumentVersionSourceSave || dv.Source == DocumentVersionSourceCollaboration)
+}
+
+// SetSnapshot 设置版本的标题和内容
+func (dv *DocumentVersion) SetSnapshot(title, content string) {
+	dv.Title = title
+	dv.Content = content
+	dv.ContentSize = len(content)
+}
+
+// IsValid 检查差异粒度是否有效
+func (g DiffGranularity) IsValid() bool {
+	return g == DiffGranularityLine || g == DiffGranularityWord
+}
+
+// ValidateDocumentVersionName 验证版本名称，空名称表示取消命名
+func ValidateDocumentVersionName(name string) error {
+	if strings.TrimSpace(name) != name || len([]rune(name)) > maxDocumentVersionNameLength {
+		return ErrInvalidDocumentVersionName
+	}
+	return nil
+}
+
+// === 仓储接口 ===
+
+// DocumentVersionRepository 文档版本仓储接口
+type DocumentVersionRepository interface {
+	Store(ctx context.Context, version *DocumentVersion) error
+	GetByID(ctx context.Context, id int64) (*DocumentVersion, error)
+	Update(ctx context.Context, version *DocumentVersion) error
+
+	// GetLatest 获取文档最新的版本，没有版本时返回 ErrDocumentVersionNotFound
+	GetLatest(ctx context.Context, documentID int64) (*DocumentVersion, error)
+	// ListByDocument 按时间倒序分页获取文档的版本，不含内容，namedOnly 为 true 时只返回命名版本
+	ListByDocument(ctx context.Context, documentID int64, namedOnly bool, offset, limit int) ([]*DocumentVersion, int64, error)
+
+	// Prune 按保留规则删除文档的自动版本，命名版本、最新版本和被标记为最后查看的版本始终保留
+	Prune(ctx context.Context, documentID int64, retention DocumentVersionRetention) error
+
+	// 最后查看版本
+	// GetView 获取用户在文档上的最后查看记录，没有记录时返回 ErrDocumentVersionViewNotFound
+	GetView(ctx context.Context, documentID, userID int64) (*DocumentVersionView, error)
+	// SaveView 保存用户在文档上的最后查看记录，已有记录时覆盖
+	SaveView(ctx context.Context, view *DocumentVersionView) error
+	// IsViewed 检查版本是否被用户标记为最后查看的版本
+	IsViewed(ctx context.Context, versionID int64) (bool, error)
+}
+
+// === 业务逻辑接口 ===
+
+// DocumentVersionRecorder 文档版本记录接口
+// 文档内容或标题写入后调用，记录失败不影响写入
+type DocumentVersionRecorder interface {
+	// RecordVersion 为文档当前的标题和内容记录自动版本
+	// 节流期内同一用户的连续保存合并为一个版本，内容和标题都未变化时不记录
+	RecordVersion(ctx context.Context, documentID, authorID int64, source DocumentVersionSource) error
+}
+
+// DocumentVersionUsecase 文档版本业务逻辑接口
+type DocumentVersionUsecase interface {
+	DocumentVersionRecorder
+
+	// 版本查询，需要文档查看权限
+	ListVersions(ctx context.Context, userID, documentID int64, namedOnly bool, page, pageSize int) ([]*DocumentVersion, int64, error)
+	GetVersion(ctx context.Context, userID, documentID, versionID int64) (*DocumentVersion, error)
+
+	// 版本管理，需要文档编辑权限
+	// CreateNamedVersion 以文档当前内容创建命名版本
+	CreateNamedVersion(ctx context.Context, userID, documentID int64, name string) (*DocumentVersion, error)
+	// NameVersion 为已有版本设置名称，名称为空时取消命名，之后按保留规则清理
+	NameVersion(ctx context.Context, userID, documentID, versionID int64, name string) (*DocumentVersion, error)
+	// RestoreVersion 将文档恢复为历史版本的标题和内容，并记录一个新的恢复版本
+	RestoreVersion(ctx context.Context, userID, documentID, versionID int64) (*DocumentVersion, error)
+
+	// 版本对比，需要文档查看权限
+	// DiffVersions 对比两个版本，fromID 为 0 时使用用户最后查看的版本，toID 为 0 时使用文档当前内容
+	// granularity 为空时按词对比
+	DiffVersions(ctx context.Context, userID, documentID, fromID, toID int64, granularity DiffGranularity) (*DocumentDiff, error)
+	// MarkVersionViewed 将版本标记为用户最后查看的版本，versionID 为 0 时标记文档当前内容
+	MarkVersionViewed(ctx context.Context, userID, documentID, versionID int64) (*DocumentVersionView, error)
+	// GetLastViewedVersion 获取用户最后查看的版本记录
+	GetLastViewedVersion(ctx context.Context, userID, documentID int64) (*DocumentVersionView, error)
+}
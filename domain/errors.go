@@ -53,6 +53,29 @@ var (
 	ErrDocumentLocked       = errors.New("document is locked")
 	ErrAuthorIDRequired     = errors.New("author id is required")
 	ErrInvalidDocument      = errors.New("invalid document")
+	ErrDocumentTreeTooDeep  = errors.New("document tree too deep")
+
+	// 文档并发控制相关错误
+	ErrDocumentModified = errors.New("document has been modified")
+
+	// 回收站相关错误
+	ErrDocumentNotInTrash = errors.New("document is not in trash")
+
+	// 文档归档相关错误
+	ErrDocumentArchived       = errors.New("document is archived")
+	ErrParentDocumentArchived = errors.New("parent document is archived")
+
+	// 文档复制相关错误
+	ErrDuplicateIntoSelf    = errors.New("cannot duplicate document into itself")
+	ErrDuplicateJobNotFound = errors.New("duplicate job not found")
+
+	// 文档版本相关错误
+	ErrDocumentVersionNotFound    = errors.New("document version not found")
+	ErrInvalidDocumentVersionName = errors.New("invalid document version name")
+
+	// 文档版本对比相关错误
+	ErrDocumentVersionViewNotFound = errors.New("document version view not found")
+	ErrInvalidDiffGranularity      = errors.New("invalid diff granularity")
 
 	// 分享相关错误
 	ErrShareLinkNotFound    = errors.New("share link not found")
@@ -99,6 +122,20 @@ var (
 	ErrCollaborationSessionNotFound  = errors.New("collaboration session not found")
 	ErrCollaborationPermissionDenied = errors.New("collaboration permission denied")
 	ErrInvalidCollaborationOperation = errors.New("invalid collaboration operation")
+	ErrCollaborationRevisionConflict = errors.New("collaboration revision conflict")
+	ErrInvalidCollaborationRevision  = errors.New("invalid collaboration revision")
+	ErrInvalidCollaborationMode      = errors.New("invalid collaboration mode")
+	ErrCollaborationModeMismatch     = errors.New("collaboration mode mismatch")
+	ErrInvalidCollaborationUpdate    = errors.New("invalid collaboration update")
+	ErrCollaborationOpsCompacted     = errors.New("collaboration operations compacted")
+	ErrCollaborationCheckpointStale  = errors.New("collaboration checkpoint stale")
+	ErrCollaborationSessionFull      = errors.New("collaboration session is full")
+	ErrDocumentCollaborating         = errors.New("document has pending collaboration changes")
+	ErrDocumentFeedUnavailable       = errors.New("document event feed unavailable")
+
+	// 协作历史回放相关错误
+	ErrCollaborationCheckpointNotFound = errors.New("collaboration checkpoint not found")
+	ErrInvalidCollaborationSeq         = errors.New("invalid collaboration seq")
 
 	// 邮件相关错误
 	ErrEmailNotFound         = errors.New("email not found")
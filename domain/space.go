@@ -43,6 +43,10 @@ type Space struct {
 	IsPublic    bool        `json:"is_public" gorm:"default:false;index"`                      // 是否公开
 	Status      SpaceStatus `json:"status" gorm:"type:tinyint;default:0"`
 
+	// 文档版本保留规则，0 表示使用系统默认值
+	VersionRetentionDays  int `json:"version_retention_days" gorm:"default:0"`  // 自动版本保留天数
+	VersionRetentionCount int `json:"version_retention_count" gorm:"default:0"` // 每个文档保留的自动版本数
+
 	// 关联关系
 	OrganizationID *int64 `json:"organization_id" gorm:"index"` // 所属组织ID（可选，个人空间为nil）
 	CreatedBy      int64  `json:"created_by" gorm:"not null;index"`
@@ -103,6 +107,9 @@ func (s *Space) Validate() error {
 	if s.Type != SpaceTypeWorkspace && s.Type != SpaceTypeProject && s.Type != SpaceTypePersonal {
 		return ErrInvalidSpaceType
 	}
+	if s.VersionRetentionDays < 0 || s.VersionRetentionCount < 0 {
+		return ErrInvalidSpace
+	}
 	return nil
 }
 
@@ -125,6 +132,18 @@ func (s *Space) CanAccess(userID int64) bool {
 	return false
 }
 
+// VersionRetention 获取空间的文档版本保留规则，未设置的项使用默认值
+func (s *Space) VersionRetention(defaults DocumentVersionRetention) DocumentVersionRetention {
+	retention := defaults
+	if s.VersionRetentionDays > 0 {
+		retention.Days = s.VersionRetentionDays
+	}
+	if s.VersionRetentionCount > 0 {
+		retention.MaxCount = s.VersionRetentionCount
+	}
+	return retention
+}
+
 // IsPersonalSpace 检查是否为个人空间
 func (s *Space) IsPersonalSpace() bool {
 	return s.Type == SpaceTypePersonal
@@ -209,6 +228,7 @@ type SpaceRepository interface {
 	RemoveDocument(ctx context.Context, spaceID, documentID int64) error
 	GetSpaceDocuments(ctx context.Context, spaceID int64) ([]*Document, error)
 	IsDocumentInSpace(ctx context.Context, spaceID, documentID int64) (bool, error)
+	GetDocumentSpaces(ctx context.Context, documentIDs []int64) ([]*SpaceDocument, error) // 批量获取多个文档的空间关联
 }
 
 // SpaceUsecase 空间业务逻辑接口
@@ -260,4 +280,7 @@ type UpdateSpacePara struct {
 	Color     *string
 	SpaceType *SpaceType
 	IsPublic  *bool
+
+	VersionRetentionDays  *int
+	VersionRetentionCount *int
 }
@@ -11,6 +11,7 @@ import (
 
 	"DOC/config"
 	"DOC/domain"
+	"DOC/pkg/diff"
 )
 
 // NewMySQLConnection 创建 MySQL 数据库连接
@@ -75,16 +76,238 @@ func AutoMigrate(db *gorm.DB) error {
 		&domain.DocumentFavorite{},        // 文档收藏表
 		&domain.DocumentPermission{},      // 文档权限表
 		&domain.DocumentShare{},           // 文档分享表
+		&domain.DocumentVersion{},         // 文档版本表
+		&domain.DocumentVersionView{},     // 文档最后查看版本表
+		&domain.DocumentAccess{},          // 文档访问记录表
+		&domain.CollaborationSession{},    // 协作会话表
+		&domain.CollaborationUser{},       // 协作参与者表
+		&domain.CollaborationOperation{},  // 协作操作表
+		&domain.CollaborationUpdate{},     // Yjs 协作更新表
+		&domain.CollaborationCheckpoint{}, // 协作检查点历史表
 		&domain.Email{},                   // 邮件表
 	)
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %v", err)
 	}
 
+	if err := backfillDocumentPlainText(db); err != nil {
+		return fmt.Errorf("failed to backfill document plain text: %v", err)
+	}
+	if err := backfillDocumentPaths(db); err != nil {
+		return fmt.Errorf("failed to backfill document paths: %v", err)
+	}
+
 	log.Println("Database migration completed successfully")
 	return nil
 }
 
+// backfillDocumentPlainText 为新增全文检索列之前创建的文档提取纯文本
+func backfillDocumentPlainText(db *gorm.DB) error {
+	const batchSize = 200
+
+	total := 0
+	for {
+		var rows []struct {
+			ID      int64
+			Content string
+		}
+		if err := db.Model(&domain.Document{}).
+			Select("id, COALESCE(content, '') AS content").
+			Where("plain_text IS NULL").
+			Limit(batchSize).
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			// 只更新纯文本，不修改版本号和更新时间
+			if err := db.Model(&domain.Document{}).
+				Where("id = ?", row.ID).
+				UpdateColumn("plain_text", diff.PlainText(row.Content)).Error; err != nil {
+				return err
+			}
+		}
+
+		total += len(rows)
+		if len(rows) < batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		log.Printf("Backfilled plain text for %d documents", total)
+	}
+	return nil
+}
+
+// backfillDocumentPaths 为新增物化路径列之前创建的文档按父文档关系生成路径和层级
+// 按批处理：先生成根文档的路径，再逐层生成父文档已有路径的文档；超过最大层数的文档移动到根目录；
+// 剩余的文档处于循环引用中或挂在循环上，每次在循环中ID最小的文档处打断并移动到根目录，然后继续逐层生成
+func backfillDocumentPaths(db *gorm.DB) error {
+	var missing int64
+	if err := db.Model(&domain.Document{}).Where("path = ''").Count(&missing).Error; err != nil {
+		return err
+	}
+	if missing == 0 {
+		return nil
+	}
+
+	total, err := backfillRootPaths(db)
+	if err != nil {
+		return err
+	}
+	detached := 0
+	for {
+		for {
+			count, tooDeep, err := backfillChildPaths(db)
+			if err != nil {
+				return err
+			}
+			total += count
+			detached += tooDeep
+			if count == 0 {
+				break
+			}
+		}
+
+		broken, err := breakDocumentCycle(db)
+		if err != nil {
+			return err
+		}
+		if !broken {
+			break
+		}
+		total++
+		detached++
+	}
+
+	log.Printf("Backfilled paths for %d documents, %d moved to root", total, detached)
+	return nil
+}
+
+// documentPathBatchSize 回填物化路径时每批处理的文档数
+const documentPathBatchSize = 500
+
+// backfillRootPaths 为父文档为空或不存在且尚无路径的文档生成根目录下的路径
+func backfillRootPaths(db *gorm.DB) (int, error) {
+	total := 0
+	for {
+		var ids []int64
+		if err := db.Model(&domain.Document{}).
+			Where("path = ''").
+			Where("parent_id IS NULL OR NOT EXISTS (SELECT 1 FROM documents p WHERE p.id = documents.parent_id)").
+			Order("id ASC").
+			Limit(documentPathBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		// 只更新路径和层级，不修改版本号和更新时间
+		if err := db.Model(&domain.Document{}).
+			Where("id IN ?", ids).
+			UpdateColumns(map[string]interface{}{
+				"path":  gorm.Expr("CONCAT(?, id, '/')", domain.DocumentRootPath),
+				"depth": 0,
+			}).Error; err != nil {
+			return total, err
+		}
+		total += len(ids)
+	}
+}
+
+// backfillChildPaths 为父文档已有路径的一批文档生成路径，返回处理的文档数和因超过最大层数移动到根目录的文档数
+func backfillChildPaths(db *gorm.DB) (int, int, error) {
+	var rows []struct {
+		ID          int64
+		ParentPath  string
+		ParentDepth int
+	}
+	if err := db.Table("documents c").
+		Select("c.id, p.path AS parent_path, p.depth AS parent_depth").
+		Joins("INNER JOIN documents p ON p.id = c.parent_id").
+		Where("c.path = '' AND p.path <> ''").
+		Order("c.id ASC").
+		Limit(documentPathBatchSize).
+		Scan(&rows).Error; err != nil {
+		return 0, 0, err
+	}
+
+	detached := 0
+	for _, row := range rows {
+		columns := map[string]interface{}{
+			"path":  domain.BuildDocumentPath(row.ParentPath, row.ID),
+			"depth": row.ParentDepth + 1,
+		}
+		if row.ParentDepth+1 >= domain.MaxDocumentDepth {
+			// 超过最大层数，移动到根目录
+			columns = map[string]interface{}{
+				"parent_id": nil,
+				"path":      domain.BuildDocumentPath(domain.DocumentRootPath, row.ID),
+				"depth":     0,
+			}
+			detached++
+		}
+		if err := db.Model(&domain.Document{}).Where("id = ?", row.ID).UpdateColumns(columns).Error; err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(rows), detached, nil
+}
+
+// breakDocumentCycle 沿尚无路径的ID最小的文档的父文档链找到循环，将循环中ID最小的文档移动到根目录
+// 没有尚无路径的文档时返回 false
+func breakDocumentCycle(db *gorm.DB) (bool, error) {
+	var ids []int64
+	if err := db.Model(&domain.Document{}).Where("path = ''").Order("id ASC").Limit(1).Pluck("id", &ids).Error; err != nil {
+		return false, err
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	// 剩余文档的父文档都存在且尚无路径，沿父文档链必然回到已访问的文档
+	seen := make(map[int64]int)
+	var chain []int64
+	id := ids[0]
+	for {
+		if index, ok := seen[id]; ok {
+			chain = chain[index:]
+			break
+		}
+		seen[id] = len(chain)
+		chain = append(chain, id)
+
+		var document domain.Document
+		if err := db.Select("id", "parent_id").Where("id = ?", id).First(&document).Error; err != nil {
+			return false, err
+		}
+		if document.ParentID == nil {
+			// 回填期间被其他进程移动到根目录，从该文档处继续
+			chain = []int64{id}
+			break
+		}
+		id = *document.ParentID
+	}
+
+	lowest := chain[0]
+	for _, candidate := range chain {
+		if candidate < lowest {
+			lowest = candidate
+		}
+	}
+	log.Printf("Breaking document parent cycle at document %d", lowest)
+	return true, db.Model(&domain.Document{}).
+		Where("id = ?", lowest).
+		UpdateColumns(map[string]interface{}{
+			"parent_id": nil,
+			"path":      domain.BuildDocumentPath(domain.DocumentRootPath, lowest),
+			"depth":     0,
+		}).Error
+}
+
 func SeedData(db *gorm.DB) error {
 	return nil
 }
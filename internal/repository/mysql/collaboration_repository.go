@@ -0,0 +1,594 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"DOC/domain"
+	"DOC/pkg/diff"
+)
+
+// collaborationRepository MySQL协作仓储实现
+// 实现 domain.CollaborationRepository 接口，负责协作会话、参与者和操作记录的持久化
+type collaborationRepository struct {
+	db *gorm.DB
+}
+
+// NewCollaborationRepository 创建新的协作仓储实例
+func NewCollaborationRepository(db *gorm.DB) domain.CollaborationRepository {
+	return &collaborationRepository{
+		db: db,
+	}
+}
+
+// === 会话管理 ===
+
+// StoreSession 保存协作会话
+func (c *collaborationRepository) StoreSession(ctx context.Context, session *domain.CollaborationSession) error {
+	if err := c.db.WithContext(ctx).Create(session).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetSessionByID 根据ID获取协作会话
+func (c *collaborationRepository) GetSessionByID(ctx context.Context, id int64) (*domain.CollaborationSession, error) {
+	var session domain.CollaborationSession
+	if err := c.db.WithContext(ctx).Where("id = ?", id).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrCollaborationSessionNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSessionByRoomID 根据房间ID获取协作会话
+func (c *collaborationRepository) GetSessionByRoomID(ctx context.Context, roomID string) (*domain.CollaborationSession, error) {
+	var session domain.CollaborationSession
+	if err := c.db.WithContext(ctx).Where("room_id = ?", roomID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrCollaborationSessionNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSessionByDocumentID 根据文档ID获取最近一个未关闭的协作会话
+func (c *collaborationRepository) GetSessionByDocumentID(ctx context.Context, documentID int64) (*domain.CollaborationSession, error) {
+	var session domain.CollaborationSession
+	if err := c.db.WithContext(ctx).
+		Where("document_id = ? AND status != ?", documentID, domain.CollaborationSessionStatusClosed).
+		Order("id DESC").
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrCollaborationSessionNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// UpdateSession 更新协作会话
+func (c *collaborationRepository) UpdateSession(ctx context.Context, session *domain.CollaborationSession) error {
+	session.UpdatedAt = time.Now()
+	if err := c.db.WithContext(ctx).Save(session).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteSession 删除协作会话及其参与者、操作、更新和检查点历史记录
+func (c *collaborationRepository) DeleteSession(ctx context.Context, id int64) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", id).Delete(&domain.CollaborationOperation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", id).Delete(&domain.CollaborationUpdate{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", id).Delete(&domain.CollaborationCheckpoint{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("session_id = ?", id).Delete(&domain.CollaborationUser{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.CollaborationSession{}, id).Error
+	})
+}
+
+// === 用户管理 ===
+
+// StoreUser 保存协作参与者
+func (c *collaborationRepository) StoreUser(ctx context.Context, user *domain.CollaborationUser) error {
+	if err := c.db.WithContext(ctx).Create(user).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetUsersBySessionID 获取会话的所有参与者（包括已离开的）
+func (c *collaborationRepository) GetUsersBySessionID(ctx context.Context, sessionID int64) ([]*domain.CollaborationUser, error) {
+	var users []*domain.CollaborationUser
+	if err := c.db.WithContext(ctx).
+		Where("session_id = ?", sessionID).
+		Order("joined_at ASC").
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetActiveUsersBySessionID 获取会话中当前在线的参与者
+func (c *collaborationRepository) GetActiveUsersBySessionID(ctx context.Context, sessionID int64) ([]*domain.CollaborationUser, error) {
+	var users []*domain.CollaborationUser
+	if err := c.db.WithContext(ctx).
+		Where("session_id = ? AND is_active = ?", sessionID, true).
+		Order("joined_at ASC").
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdateUser 更新协作参与者
+func (c *collaborationRepository) UpdateUser(ctx context.Context, user *domain.CollaborationUser) error {
+	if err := c.db.WithContext(ctx).Save(user).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveUser 将连接对应的参与者标记为已离开
+// 参与记录保留到操作保留期后由 CleanupOldOperations 删除，这里只更新在线状态和离开时间；同一用户其他连接的记录不受影响
+func (c *collaborationRepository) RemoveUser(ctx context.Context, sessionID int64, socketID string) error {
+	if err := c.db.WithContext(ctx).
+		Model(&domain.CollaborationUser{}).
+		Where("session_id = ? AND socket_id = ? AND is_active = ?", sessionID, socketID, true).
+		Updates(map[string]interface{}{
+			"is_active": false,
+			"left_at":   time.Now(),
+		}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// === 操作管理 ===
+
+// StoreOperation 保存协作操作
+func (c *collaborationRepository) StoreOperation(ctx context.Context, operation *domain.CollaborationOperation) error {
+	if err := c.db.WithContext(ctx).Create(operation).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetOperationsBySessionID 分页获取会话的操作记录，按写入顺序排列
+func (c *collaborationRepository) GetOperationsBySessionID(ctx context.Context, sessionID int64, offset, limit int) ([]*domain.CollaborationOperation, error) {
+	var operations []*domain.CollaborationOperation
+	if err := c.db.WithContext(ctx).
+		Where("session_id = ?", sessionID).
+		Order("id ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&operations).Error; err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// GetOperationsAfterTimestamp 获取指定时间之后的操作记录
+func (c *collaborationRepository) GetOperationsAfterTimestamp(ctx context.Context, sessionID int64, timestamp time.Time) ([]*domain.CollaborationOperation, error) {
+	var operations []*domain.CollaborationOperation
+	if err := c.db.WithContext(ctx).
+		Where("session_id = ? AND timestamp > ?", sessionID, timestamp).
+		Order("id ASC").
+		Find(&operations).Error; err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// GetOperationsAfterRevision 获取指定修订号之后的操作记录，按修订号和写入顺序排列
+func (c *collaborationRepository) GetOperationsAfterRevision(ctx context.Context, sessionID int64, revision int64) ([]*domain.CollaborationOperation, error) {
+	var operations []*domain.CollaborationOperation
+	if err := c.db.WithContext(ctx).
+		Where("session_id = ? AND revision > ?", sessionID, revision).
+		Order("revision ASC, id ASC").
+		Find(&operations).Error; err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// GetOperationsAfterSeq 按序号顺序获取指定序号之后的操作记录
+func (c *collaborationRepository) GetOperationsAfterSeq(ctx context.Context, sessionID int64, seq int64, limit int) ([]*domain.CollaborationOperation, error) {
+	var operations []*domain.CollaborationOperation
+	if err := c.db.WithContext(ctx).
+		Where("session_id = ? AND seq > ?", sessionID, seq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&operations).Error; err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// GetOperationsInRange 按序号顺序获取指定序号之后、时间在 [from, to) 内的操作记录，零值时间表示不限制
+func (c *collaborationRepository) GetOperationsInRange(ctx context.Context, sessionID int64, from, to time.Time, seq int64, limit int) ([]*domain.CollaborationOperation, error) {
+	query := c.db.WithContext(ctx).Where("session_id = ? AND seq > ?", sessionID, seq)
+	if !from.IsZero() {
+		query = query.Where("timestamp >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("timestamp < ?", to)
+	}
+
+	var operations []*domain.CollaborationOperation
+	if err := query.Order("seq ASC").Limit(limit).Find(&operations).Error; err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// GetOperationsByClientOpID 获取用户以指定客户端操作ID提交的操作记录
+func (c *collaborationRepository) GetOperationsByClientOpID(ctx context.Context, sessionID int64, userID int64, clientOpID string) ([]*domain.CollaborationOperation, error) {
+	var operations []*domain.CollaborationOperation
+	if err := c.db.WithContext(ctx).
+		Where("session_id = ? AND user_id = ? AND client_op_id = ?", sessionID, userID, clientOpID).
+		Order("seq ASC").
+		Find(&operations).Error; err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// CommitOperations 以指定修订号提交一批操作
+// 通过比较并更新会话修订号保证多实例下修订号连续且不重复，操作序号在同一事务内分配
+func (c *collaborationRepository) CommitOperations(ctx context.Context, sessionID int64, revision int64, operations []*domain.CollaborationOperation) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.CollaborationSession{}).
+			Where("id = ? AND revision = ?", sessionID, revision-1).
+			Updates(map[string]interface{}{
+				"revision":   revision,
+				"last_seq":   gorm.Expr("last_seq + ?", len(operations)),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return domain.ErrCollaborationRevisionConflict
+		}
+
+		if len(operations) == 0 {
+			return nil
+		}
+
+		// 会话行已被本事务锁定，读取的序号不会被其他提交修改
+		var lastSeq int64
+		if err := tx.Model(&domain.CollaborationSession{}).
+			Select("last_seq").
+			Where("id = ?", sessionID).
+			Scan(&lastSeq).Error; err != nil {
+			return err
+		}
+
+		seq := lastSeq - int64(len(operations))
+		for _, operation := range operations {
+			seq++
+			operation.SessionID = sessionID
+			operation.Revision = revision
+			operation.Seq = seq
+		}
+		return tx.Create(&operations).Error
+	})
+}
+
+// === Yjs 更新管理 ===
+
+// StoreUpdate 保存 Yjs 更新
+func (c *collaborationRepository) StoreUpdate(ctx context.Context, update *domain.CollaborationUpdate) error {
+	if err := c.db.WithContext(ctx).Create(update).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetUpdatesAfterID 获取指定ID之后的更新，按写入顺序排列
+func (c *collaborationRepository) GetUpdatesAfterID(ctx context.Context, sessionID int64, afterID int64) ([]*domain.CollaborationUpdate, error) {
+	var updates []*domain.CollaborationUpdate
+	if err := c.db.WithContext(ctx).
+		Where("session_id = ? AND id > ?", sessionID, afterID).
+		Order("id ASC").
+		Find(&updates).Error; err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// CompactUpdates 用合并后的更新替换 ID 不大于 upToID 的更新
+// 压缩期间其他实例写入的更新 ID 更大，不受影响
+func (c *collaborationRepository) CompactUpdates(ctx context.Context, sessionID int64, upToID int64, merged *domain.CollaborationUpdate) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ? AND id <= ?", sessionID, upToID).
+			Delete(&domain.CollaborationUpdate{}).Error; err != nil {
+			return err
+		}
+
+		merged.ID = 0
+		merged.SessionID = sessionID
+		return tx.Create(merged).Error
+	})
+}
+
+// === 检查点 ===
+
+// GetSessionsPendingCheckpoint 获取待生成检查点的会话，最久未活动的优先
+func (c *collaborationRepository) GetSessionsPendingCheckpoint(ctx context.Context, minOperations int64, idleBefore time.Time, limit int) ([]*domain.CollaborationSession, error) {
+	var sessions []*domain.CollaborationSession
+	if err := c.db.WithContext(ctx).
+		Where("last_seq > checkpoint_seq AND (last_seq - checkpoint_seq >= ? OR updated_at < ?)", minOperations, idleBefore).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// SaveCheckpoint 在同一事务中写入文档内容、推进会话检查点并记录检查点历史
+// 检查点按比较并更新推进，多个实例同时生成时只有一个生效；
+// 归档状态在写入文档的同一条语句中检查，文档已归档时返回 ErrDocumentArchived，检查点不推进
+func (c *collaborationRepository) SaveCheckpoint(ctx context.Context, session *domain.CollaborationSession, prevSeq int64, content string) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 不更新 updated_at，以免影响空闲判断
+		result := tx.Model(&domain.CollaborationSession{}).
+			Where("id = ? AND checkpoint_seq = ?", session.ID, prevSeq).
+			UpdateColumns(map[string]interface{}{
+				"checkpoint_revision": session.CheckpointRevision,
+				"checkpoint_seq":      session.CheckpointSeq,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return domain.ErrCollaborationCheckpointStale
+		}
+
+		if err := tx.Create(&domain.CollaborationCheckpoint{
+			SessionID: session.ID,
+			Revision:  session.CheckpointRevision,
+			Seq:       session.CheckpointSeq,
+			Content:   content,
+		}).Error; err != nil {
+			return err
+		}
+
+		result = tx.Model(&domain.Document{}).
+			Where("id = ? AND status <> ?", session.DocumentID, domain.DocumentStatusArchived).
+			Updates(map[string]interface{}{
+				"content":    content,
+				"plain_text": diff.PlainText(content),
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return domain.ErrDocumentArchived
+		}
+		return nil
+	})
+}
+
+// StoreCheckpoint 保存检查点历史
+func (c *collaborationRepository) StoreCheckpoint(ctx context.Context, checkpoint *domain.CollaborationCheckpoint) error {
+	if err := c.db.WithContext(ctx).Create(checkpoint).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetCheckpointAtOrBefore 获取序号不大于指定序号的最近一个检查点历史
+func (c *collaborationRepository) GetCheckpointAtOrBefore(ctx context.Context, sessionID int64, seq int64) (*domain.CollaborationCheckpoint, error) {
+	var checkpoint domain.CollaborationCheckpoint
+	if err := c.db.WithContext(ctx).
+		Where("session_id = ? AND seq <= ?", sessionID, seq).
+		Order("seq DESC").
+		First(&checkpoint).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrCollaborationCheckpointNotFound
+		}
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// RebaseSession 在同一事务中推进会话的修订号、序号和检查点，并保存新的检查点历史
+// 会话行加锁，与提交操作互斥；修订号和序号的空缺使基于旧内容的提交和断线续传转为重新同步
+func (c *collaborationRepository) RebaseSession(ctx context.Context, sessionID int64, content string) error {
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var session domain.CollaborationSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", sessionID).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domain.ErrCollaborationSessionNotFound
+			}
+			return err
+		}
+		if session.PendingOperations() > 0 {
+			return domain.ErrDocumentCollaborating
+		}
+		return rebaseSession(tx, &session, content)
+	})
+}
+
+// ReplaceDocumentContent 在同一事务中锁定文档的协作会话、检查内容可写、写入文档内容并推进会话检查点
+// 会话行先于文档行加锁，与生成检查点的加锁顺序一致；文档没有协作会话时只写入内容
+func (c *collaborationRepository) ReplaceDocumentContent(ctx context.Context, documentID int64, content string, expectedVersion int64) (int64, error) {
+	var version int64
+	err := c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var sessions []domain.CollaborationSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("room_id = ?", domain.DocumentRoomID(documentID)).
+			Limit(1).
+			Find(&sessions).Error; err != nil {
+			return err
+		}
+
+		if len(sessions) > 0 {
+			if err := checkContentWritable(tx, &sessions[0]); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if version, err = updateDocumentContent(tx, documentID, content, expectedVersion); err != nil {
+			return err
+		}
+
+		if len(sessions) == 0 {
+			return nil
+		}
+		return rebaseSession(tx, &sessions[0], content)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// checkContentWritable 检查已加锁的会话是否允许在会话之外写入文档内容
+// OT 会话有尚未写入检查点的操作，或 Yjs 会话有在线连接时返回 ErrDocumentCollaborating
+func checkContentWritable(tx *gorm.DB, session *domain.CollaborationSession) error {
+	if session.PendingOperations() > 0 {
+		return domain.ErrDocumentCollaborating
+	}
+
+	var document domain.Document
+	if err := tx.Select("id", "collaboration_mode").Where("id = ?", session.DocumentID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ErrDocumentNotFound
+		}
+		return err
+	}
+	if document.GetCollaborationMode() != domain.CollaborationModeYjs {
+		return nil
+	}
+
+	var online int64
+	if err := tx.Model(&domain.CollaborationUser{}).
+		Where("session_id = ? AND is_active = ?", session.ID, true).
+		Count(&online).Error; err != nil {
+		return err
+	}
+	if online > 0 {
+		return domain.ErrDocumentCollaborating
+	}
+	return nil
+}
+
+// rebaseSession 推进已加锁会话的修订号、序号和检查点，保存新的检查点历史并删除基于旧内容的 Yjs 更新
+func rebaseSession(tx *gorm.DB, session *domain.CollaborationSession, content string) error {
+	revision := session.Revision + 1
+	seq := session.LastSeq + 1
+	// 不更新 updated_at，以免影响空闲判断
+	if err := tx.Model(session).UpdateColumns(map[string]interface{}{
+		"revision":            revision,
+		"last_seq":            seq,
+		"checkpoint_revision": revision,
+		"checkpoint_seq":      seq,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Create(&domain.CollaborationCheckpoint{
+		SessionID: session.ID,
+		Revision:  revision,
+		Seq:       seq,
+		Content:   content,
+	}).Error; err != nil {
+		return err
+	}
+
+	// Yjs 更新基于旧内容，删除后由下一个连接以新内容重新建立
+	return tx.Where("session_id = ?", session.ID).Delete(&domain.CollaborationUpdate{}).Error
+}
+
+// === 清理操作 ===
+
+// CleanupInactiveSessions 关闭长时间无活动的会话，并将其参与者标记为离开
+func (c *collaborationRepository) CleanupInactiveSessions(ctx context.Context, inactiveThreshold time.Duration) error {
+	now := time.Now()
+	cutoff := now.Add(-inactiveThreshold)
+
+	return c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var sessionIDs []int64
+		if err := tx.Model(&domain.CollaborationSession{}).
+			Where("status != ? AND updated_at < ?", domain.CollaborationSessionStatusClosed, cutoff).
+			Pluck("id", &sessionIDs).Error; err != nil {
+			return err
+		}
+
+		if len(sessionIDs) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&domain.CollaborationUser{}).
+			Where("session_id IN ? AND is_active = ?", sessionIDs, true).
+			Updates(map[string]interface{}{
+				"is_active": false,
+				"left_at":   now,
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&domain.CollaborationSession{}).
+			Where("id IN ?", sessionIDs).
+			Updates(map[string]interface{}{
+				"status":     domain.CollaborationSessionStatusClosed,
+				"closed_at":  now,
+				"updated_at": now,
+			}).Error
+	})
+}
+
+// CleanupOldOperations 删除指定时间之前的操作记录、检查点历史和已离开的参与者记录
+// 只删除已包含在会话检查点中的操作，检查点之后的操作仍用于生成快照
+// 会话当前检查点的历史始终保留
+func (c *collaborationRepository) CleanupOldOperations(ctx context.Context, olderThan time.Time) error {
+	checkpoints := c.db.Model(&domain.CollaborationSession{}).
+		Select("checkpoint_seq").
+		Where("collaboration_sessions.id = collaboration_operations.session_id")
+
+	if err := c.db.WithContext(ctx).
+		Where("timestamp < ? AND seq <= (?)", olderThan, checkpoints).
+		Delete(&domain.CollaborationOperation{}).Error; err != nil {
+		return err
+	}
+
+	historyCheckpoints := c.db.Model(&domain.CollaborationSession{}).
+		Select("checkpoint_seq").
+		Where("collaboration_sessions.id = collaboration_checkpoints.session_id")
+
+	if err := c.db.WithContext(ctx).
+		Where("created_at < ? AND seq < (?)", olderThan, historyCheckpoints).
+		Delete(&domain.CollaborationCheckpoint{}).Error; err != nil {
+		return err
+	}
+
+	// 已离开的参与者记录只用于审计，同样按保留期删除，会话的参与者记录不会随会话时长无限增长
+	if err := c.db.WithContext(ctx).
+		Where("is_active = ? AND left_at < ?", false, olderThan).
+		Delete(&domain.CollaborationUser{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"DOC/domain"
+)
+
+// 每条语句写入的访问记录数
+const accessUpsertBatchSize = 500
+
+// documentAccessRepository MySQL文档访问记录仓储实现
+// 实现 domain.DocumentAccessRepository 接口
+type documentAccessRepository struct {
+	db *gorm.DB
+}
+
+// NewDocumentAccessRepository 创建新的文档访问记录仓储实例
+func NewDocumentAccessRepository(db *gorm.DB) domain.DocumentAccessRepository {
+	return &documentAccessRepository{db: db}
+}
+
+// Upsert 按用户和文档合并访问后写入
+// 已有记录时查看和编辑时间只更新为更晚的值，不同实例重复写入同一批访问不会使时间倒退
+func (d *documentAccessRepository) Upsert(ctx context.Context, events []*domain.DocumentAccessEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	type accessKey struct{ userID, documentID int64 }
+	merged := make(map[accessKey]*domain.DocumentAccess)
+	accesses := make([]*domain.DocumentAccess, 0, len(events))
+	for _, event := range events {
+		key := accessKey{event.UserID, event.DocumentID}
+		access, ok := merged[key]
+		if !ok {
+			access = &domain.DocumentAccess{UserID: event.UserID, DocumentID: event.DocumentID}
+			merged[key] = access
+			accesses = append(accesses, access)
+		}
+
+		accessedAt := event.AccessedAt
+		switch event.Action {
+		case domain.DocumentAccessView:
+			access.LastViewedAt = laterTime(access.LastViewedAt, accessedAt)
+		case domain.DocumentAccessEdit:
+			access.LastEditedAt = laterTime(access.LastEditedAt, accessedAt)
+		}
+		if accessedAt.After(access.LastAccessedAt) {
+			access.LastAccessedAt = accessedAt
+		}
+	}
+
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "document_id"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "last_viewed_at"}, Value: gorm.Expr(laterColumnSQL("last_viewed_at"))},
+			{Column: clause.Column{Name: "last_edited_at"}, Value: gorm.Expr(laterColumnSQL("last_edited_at"))},
+			{Column: clause.Column{Name: "last_accessed_at"}, Value: gorm.Expr("GREATEST(last_accessed_at, VALUES(last_accessed_at))")},
+			{Column: clause.Column{Name: "updated_at"}, Value: gorm.Expr("VALUES(updated_at)")},
+		},
+	}).CreateInBatches(accesses, accessUpsertBatchSize).Error
+}
+
+// GetRecentByUser 按最后访问时间倒序获取用户的访问记录
+func (d *documentAccessRepository) GetRecentByUser(ctx context.Context, userID int64, limit int) ([]*domain.DocumentAccess, error) {
+	var accesses []*domain.DocumentAccess
+	if err := d.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("last_accessed_at DESC").
+		Limit(limit).
+		Find(&accesses).Error; err != nil {
+		return nil, err
+	}
+	return accesses, nil
+}
+
+// laterTime 返回两个时间中较晚的一个
+func laterTime(current *time.Time, t time.Time) *time.Time {
+	if current != nil && current.After(t) {
+		return current
+	}
+	return &t
+}
+
+// laterColumnSQL 可为空的时间列与新写入的值中较晚的一个，GREATEST 遇到 NULL 时返回 NULL，需先用另一方补齐
+func laterColumnSQL(column string) string {
+	return "GREATEST(COALESCE(" + column + ", VALUES(" + column + ")), COALESCE(VALUES(" + column + "), " + column + "))"
+}
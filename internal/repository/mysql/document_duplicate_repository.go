@@ -0,0 +1,257 @@
+package mysql
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"DOC/domain"
+	"DOC/pkg/diff"
+)
+
+const (
+	duplicateBatchSize     = 100  // 每批读取并写入的文档数，读取时含内容，批次不宜过大
+	duplicateStructureSize = 1000 // 读取层级结构时每条语句的文档数
+)
+
+// documentDuplicateRepository MySQL文档复制仓储实现
+// 实现 domain.DocumentDuplicateRepository 接口
+type documentDuplicateRepository struct {
+	db *gorm.DB
+}
+
+// NewDocumentDuplicateRepository 创建新的文档复制仓储实例
+func NewDocumentDuplicateRepository(db *gorm.DB) domain.DocumentDuplicateRepository {
+	return &documentDuplicateRepository{db: db}
+}
+
+// Duplicate 在一个事务中按层复制文档子树，返回副本根文档的ID
+// 父文档的副本先于子文档写入，子文档的副本直接指向父文档的副本；副本保留原文档的排序，
+// 状态为正常，已归档的原文档复制后可以编辑；任何一步失败时已写入的副本全部回滚
+func (d *documentDuplicateRepository) Duplicate(ctx context.Context, plan *domain.DocumentDuplicatePlan, progress func(copied int)) (int64, error) {
+	var rootID int64
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1. 读取层级结构并从根文档按层展开
+		levels, err := duplicateLevels(tx, plan)
+		if err != nil {
+			return err
+		}
+		if len(levels) == 0 {
+			return domain.ErrDocumentNotFound
+		}
+
+		// 2. 副本子树放到目标父文件夹下后不能超过最大层数
+		parentPath, parentDepth, err := documentParentPath(tx, plan.ParentID)
+		if err != nil {
+			return err
+		}
+		if parentDepth+len(levels) >= domain.MaxDocumentDepth {
+			return domain.ErrDocumentTreeTooDeep
+		}
+
+		// 3. 逐层分批写入副本，原文档ID到副本ID和副本物化路径的映射供下一层使用
+		copies := make(map[int64]int64)
+		paths := make(map[int64]string)
+		copied := 0
+		for depth, level := range levels {
+			for start := 0; start < len(level); start += duplicateBatchSize {
+				end := start + duplicateBatchSize
+				if end > len(level) {
+					end = len(level)
+				}
+				if err := duplicateBatch(tx, plan, level[start:end], parentPath, parentDepth+1+depth, copies, paths); err != nil {
+					return err
+				}
+
+				copied += end - start
+				if progress != nil {
+					progress(copied)
+				}
+			}
+		}
+
+		rootID = copies[plan.SourceID]
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rootID, nil
+}
+
+// duplicateLevels 读取允许复制的未删除文档的层级结构，返回从根文档开始逐层的文档ID
+// 父文档不在其中的文档连同其子树一起跳过
+func duplicateLevels(tx *gorm.DB, plan *domain.DocumentDuplicatePlan) ([][]int64, error) {
+	children := make(map[int64][]int64)
+	found := false
+	for start := 0; start < len(plan.DocumentIDs); start += duplicateStructureSize {
+		end := start + duplicateStructureSize
+		if end > len(plan.DocumentIDs) {
+			end = len(plan.DocumentIDs)
+		}
+
+		var nodes []*domain.Document
+		if err := tx.Model(&domain.Document{}).
+			Select("id", "parent_id").
+			Where("id IN ? AND status <> ?", plan.DocumentIDs[start:end], domain.DocumentStatusDeleted).
+			Find(&nodes).Error; err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			if node.ID == plan.SourceID {
+				found = true
+				continue
+			}
+			if node.ParentID != nil {
+				children[*node.ParentID] = append(children[*node.ParentID], node.ID)
+			}
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	return expandDuplicateLevels(plan.SourceID, children), nil
+}
+
+// expandDuplicateLevels 从根文档按层展开子文档，每层按ID排序，children 中不可达的文档不出现在结果中
+func expandDuplicateLevels(sourceID int64, children map[int64][]int64) [][]int64 {
+	var levels [][]int64
+	level := []int64{sourceID}
+	for len(level) > 0 {
+		levels = append(levels, level)
+		var next []int64
+		for _, id := range level {
+			next = append(next, children[id]...)
+		}
+		sort.Slice(next, func(i, j int) bool { return next[i] < next[j] })
+		level = next
+	}
+	return levels
+}
+
+// duplicateBatch 复制同一层的一批文档，以及按计划需要复制的授权和收藏
+// rootParentPath 为副本根文档父文件夹的物化路径，depth 为这一层副本的层级
+func duplicateBatch(tx *gorm.DB, plan *domain.DocumentDuplicatePlan, ids []int64, rootParentPath string, depth int, copies map[int64]int64, paths map[int64]string) error {
+	var sources []*domain.Document
+	if err := tx.Where("id IN ?", ids).Order("id ASC").Find(&sources).Error; err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	documents := make([]*domain.Document, len(sources))
+	parentPaths := make([]string, len(sources))
+	for i, source := range sources {
+		document := &domain.Document{
+			Title:             source.Title,
+			Content:           source.Content,
+			PlainText:         diff.PlainText(source.Content),
+			Type:              source.Type,
+			Status:            domain.DocumentStatusActive,
+			SpaceID:           plan.SpaceID,
+			OwnerID:           plan.OwnerID,
+			Depth:             depth,
+			SortOrder:         source.SortOrder,
+			CollaborationMode: source.GetCollaborationMode(),
+			Version:           1,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+		}
+		if source.ID == plan.SourceID {
+			document.Title = plan.Title
+		}
+		document.ParentID, parentPaths[i] = copyParent(plan, source, rootParentPath, copies, paths)
+		documents[i] = document
+	}
+	if err := tx.Omit(clause.Associations).Create(&documents).Error; err != nil {
+		return err
+	}
+
+	// 物化路径包含副本自身的ID，写入后按父文件夹分组补齐
+	byParentPath := make(map[string][]int64)
+	for i, source := range sources {
+		copies[source.ID] = documents[i].ID
+		paths[source.ID] = domain.BuildDocumentPath(parentPaths[i], documents[i].ID)
+		byParentPath[parentPaths[i]] = append(byParentPath[parentPaths[i]], documents[i].ID)
+	}
+	for parentPath, copyIDs := range byParentPath {
+		if err := tx.Model(&domain.Document{}).
+			Where("id IN ?", copyIDs).
+			UpdateColumn("path", gorm.Expr("CONCAT(?, id, '/')", parentPath)).Error; err != nil {
+			return err
+		}
+	}
+
+	if plan.CopyPermissions {
+		if err := duplicatePermissions(tx, plan.OwnerID, ids, copies); err != nil {
+			return err
+		}
+	}
+	if plan.CopyFavorites {
+		if err := duplicateFavorites(tx, plan.OwnerID, ids, copies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyParent 获取副本的父文档ID和父文件夹的物化路径
+// 副本根文档放到计划的目标父文件夹下，其余副本放到各自父文档的副本下，父文档的副本需已写入 copies 和 paths
+func copyParent(plan *domain.DocumentDuplicatePlan, source *domain.Document, rootParentPath string, copies map[int64]int64, paths map[int64]string) (*int64, string) {
+	if source.ID == plan.SourceID {
+		return plan.ParentID, rootParentPath
+	}
+	parentID := copies[*source.ParentID]
+	return &parentID, paths[*source.ParentID]
+}
+
+// duplicatePermissions 将其他用户在原文档上的授权复制到副本，授权人为副本的所有者
+func duplicatePermissions(tx *gorm.DB, ownerID int64, ids []int64, copies map[int64]int64) error {
+	var permissions []*domain.DocumentPermission
+	if err := tx.Where("document_id IN ? AND user_id <> ?", ids, ownerID).Find(&permissions).Error; err != nil {
+		return err
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	duplicated := make([]*domain.DocumentPermission, len(permissions))
+	for i, permission := range permissions {
+		duplicated[i] = &domain.DocumentPermission{
+			DocumentID: copies[permission.DocumentID],
+			UserID:     permission.UserID,
+			Permission: permission.Permission,
+			GrantedBy:  ownerID,
+		}
+	}
+	return tx.Omit(clause.Associations).Create(&duplicated).Error
+}
+
+// duplicateFavorites 将副本所有者对原文档的收藏连同自定义标题复制到副本，其他用户的收藏不复制
+func duplicateFavorites(tx *gorm.DB, ownerID int64, ids []int64, copies map[int64]int64) error {
+	var favorites []*domain.DocumentFavorite
+	if err := tx.Where("document_id IN ? AND user_id = ?", ids, ownerID).Find(&favorites).Error; err != nil {
+		return err
+	}
+	if len(favorites) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	duplicated := make([]*domain.DocumentFavorite, len(favorites))
+	for i, favorite := range favorites {
+		duplicated[i] = &domain.DocumentFavorite{
+			DocumentID:  copies[favorite.DocumentID],
+			UserID:      ownerID,
+			CustomTitle: favorite.CustomTitle,
+			CreatedAt:   now,
+		}
+	}
+	return tx.Omit(clause.Associations).Create(&duplicated).Error
+}
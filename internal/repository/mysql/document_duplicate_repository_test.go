@@ -0,0 +1,46 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"DOC/domain"
+)
+
+func TestExpandDuplicateLevels(t *testing.T) {
+	// 1 -> 4, 2；2 -> 6, 3；6 -> 8；9 的父文档 7 不在复制范围内
+	children := map[int64][]int64{
+		1: {4, 2},
+		2: {6, 3},
+		6: {8},
+		7: {9},
+	}
+
+	levels := expandDuplicateLevels(1, children)
+	assert.Equal(t, [][]int64{{1}, {2, 4}, {3, 6}, {8}}, levels)
+
+	// 没有子文档时只有根文档一层
+	assert.Equal(t, [][]int64{{5}}, expandDuplicateLevels(5, children))
+}
+
+func TestCopyParent(t *testing.T) {
+	targetID := int64(30)
+	plan := &domain.DocumentDuplicatePlan{SourceID: 1, ParentID: &targetID}
+
+	// 副本根文档放到目标父文件夹下
+	copies := map[int64]int64{}
+	paths := map[int64]string{}
+	parentID, parentPath := copyParent(plan, &domain.Document{ID: 1}, "/20/30/", copies, paths)
+	assert.Equal(t, &targetID, parentID)
+	assert.Equal(t, "/20/30/", parentPath)
+
+	// 根文档的副本写入后，子文档的副本放到根文档副本下，路径以副本的路径为前缀
+	copies[1] = 101
+	paths[1] = domain.BuildDocumentPath(parentPath, 101)
+	sourceParent := int64(1)
+	parentID, parentPath = copyParent(plan, &domain.Document{ID: 2, ParentID: &sourceParent}, "/20/30/", copies, paths)
+	assert.Equal(t, int64(101), *parentID)
+	assert.Equal(t, "/20/30/101/", parentPath)
+	assert.Equal(t, "/20/30/101/102/", domain.BuildDocumentPath(parentPath, 102))
+}
@@ -74,6 +74,21 @@ func (d *documentPermissionRepository) GetByDocument(ctx context.Context, docume
 	return permissions, nil
 }
 
+// GetByDocuments 批量获取多个文档的权限，不加载关联用户
+func (d *documentPermissionRepository) GetByDocuments(ctx context.Context, documentIDs []int64) ([]*domain.DocumentPermission, error) {
+	if len(documentIDs) == 0 {
+		return []*domain.DocumentPermission{}, nil
+	}
+
+	var permissions []*domain.DocumentPermission
+	if err := d.db.WithContext(ctx).
+		Where("document_id IN ?", documentIDs).
+		Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
 // GetByUser 根据用户ID获取所有权限
 func (d *documentPermissionRepository) GetByUser(ctx context.Context, userID int64) ([]*domain.DocumentPermission, error) {
 	var permissions []*domain.DocumentPermission
@@ -3,13 +3,52 @@ package mysql
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"DOC/domain"
+	"DOC/pkg/diff"
 )
 
+const (
+	// subtreePathSQL 物化路径以指定文档的路径开头的文档，即文档自身以及所有子孙文档；文档不存在时不匹配任何文档
+	subtreePathSQL = `documents.path LIKE CONCAT((SELECT a.path FROM documents a WHERE a.id = ? AND a.path <> ''), '%')`
+
+	// subtreeCutSQL 从子树根到文档自身的路径上（含两端）的文档 b，后接附加条件，用于在满足条件的文档处截断子树
+	// 参数依次为子树根ID和附加条件的参数，与 subtreePathSQL 一起使用
+	subtreeCutSQL = `SELECT 1 FROM documents b WHERE b.path <> '' AND documents.path LIKE CONCAT(b.path, '%') AND ` +
+		`b.path LIKE CONCAT((SELECT a.path FROM documents a WHERE a.id = ? AND a.path <> ''), '%')`
+)
+
+// subtreeCut 生成在满足 condition 的文档处截断子树的条件，condition 中以 b 引用路径上的文档
+func subtreeCut(condition string) string {
+	return "EXISTS (" + subtreeCutSQL + " AND (" + condition + "))"
+}
+
+// archiveSubtreeIDs 未删除的文档自身以及未删除的子孙文档中尚未归档的文档，已删除的子文件夹及其子孙不包括在内
+func archiveSubtreeIDs(tx *gorm.DB, id int64) ([]int64, error) {
+	var ids []int64
+	err := tx.Model(&domain.Document{}).
+		Where(subtreePathSQL, id).
+		Where("documents.status = ?", domain.DocumentStatusActive).
+		Where("NOT "+subtreeCut("b.status = ?"), id, domain.DocumentStatusDeleted).
+		Pluck("documents.id", &ids).Error
+	return ids, err
+}
+
+// unarchiveSubtreeIDs 已归档的文档自身以及从它开始连续归档的子孙文档，未归档的子文件夹下单独归档的文档不包括在内
+func unarchiveSubtreeIDs(tx *gorm.DB, id int64) ([]int64, error) {
+	var ids []int64
+	err := tx.Model(&domain.Document{}).
+		Where(subtreePathSQL, id).
+		Where("NOT "+subtreeCut("b.status <> ?"), id, domain.DocumentStatusArchived).
+		Pluck("documents.id", &ids).Error
+	return ids, err
+}
+
 // documentRepository MySQL文档仓储实现
 // 实现 domain.DocumentRepository 接口，负责文档数据的持久化操作
 type documentRepository struct {
@@ -25,10 +64,29 @@ func NewDocumentRepository(db *gorm.DB) domain.DocumentRepository {
 
 // Store 保存文档
 func (d *documentRepository) Store(ctx context.Context, document *domain.Document) error {
-	if err := d.db.WithContext(ctx).Create(document).Error; err != nil {
-		return err
+	if document.Version == 0 {
+		document.Version = 1
 	}
-	return nil
+	document.PlainText = diff.PlainText(document.Content)
+
+	// 物化路径包含文档自身的ID，写入后才能确定
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		parentPath, parentDepth, err := documentParentPath(tx, document.ParentID)
+		if err != nil {
+			return err
+		}
+		if parentDepth+1 >= domain.MaxDocumentDepth {
+			return domain.ErrDocumentTreeTooDeep
+		}
+
+		document.Depth = parentDepth + 1
+		if err := tx.Create(document).Error; err != nil {
+			return err
+		}
+
+		document.Path = domain.BuildDocumentPath(parentPath, document.ID)
+		return tx.Model(document).UpdateColumn("path", document.Path).Error
+	})
 }
 
 // GetByID 根据ID获取文档
@@ -44,14 +102,68 @@ func (d *documentRepository) GetByID(ctx context.Context, id int64) (*domain.Doc
 }
 
 // Update 更新文档
+// 以 document.Version 作为期望版本号比较并更新，成功后版本号递增；
+// 文档已被其他写入修改时返回 DocumentModifiedError；父文档改变时同时改写子树的物化路径
 func (d *documentRepository) Update(ctx context.Context, document *domain.Document) error {
+	expectedVersion := document.Version
+	document.Version = expectedVersion + 1
 	document.UpdatedAt = time.Now()
-	if err := d.db.WithContext(ctx).Save(document).Error; err != nil {
+	document.PlainText = diff.PlainText(document.Content)
+
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current domain.Document
+		if err := tx.Select("id", "parent_id").Where("id = ?", document.ID).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domain.ErrDocumentNotFound
+			}
+			return err
+		}
+
+		result := tx.Model(document).
+			Where("version = ?", expectedVersion).
+			Select("*").
+			Omit("id", "parent_id", "path", "depth", "created_at", clause.Associations).
+			Updates(document)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return versionConflict(tx, document.ID)
+		}
+
+		if sameParent(current.ParentID, document.ParentID) {
+			return nil
+		}
+		path, depth, err := moveSubtree(tx, document.ID, document.ParentID)
+		if err != nil {
+			return err
+		}
+		document.Path = path
+		document.Depth = depth
+		return nil
+	})
+	if err != nil {
+		document.Version = expectedVersion
 		return err
 	}
 	return nil
 }
 
+// versionConflict 比较并更新未命中时区分文档不存在、已归档和版本冲突
+func versionConflict(db *gorm.DB, id int64) error {
+	var document domain.Document
+	if err := db.Select("id", "version", "status").Where("id = ?", id).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ErrDocumentNotFound
+		}
+		return err
+	}
+	if document.IsArchived() {
+		return domain.ErrDocumentArchived
+	}
+	return &domain.DocumentModifiedError{CurrentVersion: document.Version}
+}
+
 // Delete 硬删除文档
 func (d *documentRepository) Delete(ctx context.Context, id int64) error {
 	if err := d.db.WithContext(ctx).Delete(&domain.Document{}, id).Error; err != nil {
@@ -60,20 +172,11 @@ func (d *documentRepository) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
-// SoftDelete 软删除文档
+// SoftDelete 软删除文档，文件夹连同未删除的子孙文档一起移入回收站
 func (d *documentRepository) SoftDelete(ctx context.Context, id int64) error {
-	// 先获取文档
-	doc, err := d.GetByID(ctx, id)
-	if err != nil {
-		return err
-	}
-
-	// 使用领域对象的软删除方法
-	// todo， 软删除使用领域对象的删除方法
-	doc.SoftDelete()
-
-	// 更新到数据库
-	return d.Update(ctx, doc)
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return moveToTrash(tx, id)
+	})
 }
 
 // GetByOwner 根据所有者ID获取文档列表
@@ -92,9 +195,9 @@ func (d *documentRepository) GetByOwner(ctx context.Context, ownerID int64, incl
 }
 
 // GetByParent 根据父文档ID获取子文档列表
-func (d *documentRepository) GetByParent(ctx context.Context, parentID *int64, ownerID int64) ([]*domain.Document, error) {
+func (d *documentRepository) GetByParent(ctx context.Context, parentID *int64, ownerID int64, includeArchived bool) ([]*domain.Document, error) {
 	var documents []*domain.Document
-	query := d.db.WithContext(ctx).Where("owner_id = ? AND status != ?", ownerID, domain.DocumentStatusDeleted)
+	query := d.db.WithContext(ctx).Where("owner_id = ? AND status IN ?", ownerID, listedStatuses(includeArchived))
 
 	if parentID == nil {
 		query = query.Where("parent_id IS NULL")
@@ -121,102 +224,189 @@ func (d *documentRepository) GetBySpace(ctx context.Context, spaceID int64, owne
 }
 
 // GetDocumentTree 获取文档树结构
-func (d *documentRepository) GetDocumentTree(ctx context.Context, rootID *int64, ownerID int64) ([]*domain.Document, error) {
-	var documents []*domain.Document
-
-	// 使用递归CTE查询获取完整的文档树
-	sql := `
-		WITH RECURSIVE document_tree AS (
-			-- 基础查询：获取根节点
-			SELECT * FROM documents 
-			WHERE owner_id = ? AND status != ? AND parent_id ` +
-		map[bool]string{true: "IS NULL", false: "= ?"}[rootID == nil] + `
-			
-			UNION ALL
-			
-			-- 递归查询：获取子节点
-			SELECT d.* FROM documents d
-			INNER JOIN document_tree dt ON d.parent_id = dt.id
-			WHERE d.owner_id = ? AND d.status != ?
-		)
-		SELECT * FROM document_tree
-		ORDER BY parent_id ASC, sort_order ASC, created_at DESC
-	`
-
-	var args []interface{}
-	args = append(args, ownerID, domain.DocumentStatusDeleted)
+// rootID 为空时获取根目录下的所有文档树，否则获取指定文档的子孙文档；按物化路径前缀一次读取，
+// 路径上有文档不属于用户或状态不符的文档连同其子树一起排除
+func (d *documentRepository) GetDocumentTree(ctx context.Context, rootID *int64, ownerID int64, includeArchived bool) ([]*domain.Document, error) {
+	query := d.db.WithContext(ctx).Where("owner_id = ? AND status IN ?", ownerID, listedStatuses(includeArchived))
 	if rootID != nil {
-		args = append(args, *rootID)
+		query = query.Where(subtreePathSQL+" AND documents.id <> ?", *rootID, *rootID)
 	}
-	args = append(args, ownerID, domain.DocumentStatusDeleted)
 
-	if err := d.db.WithContext(ctx).Raw(sql, args...).Scan(&documents).Error; err != nil {
+	var candidates []*domain.Document
+	if err := query.Order("parent_id ASC, sort_order ASC, created_at DESC").Find(&candidates).Error; err != nil {
 		return nil, err
 	}
+
+	candidateIDs := make(map[int64]bool, len(candidates))
+	for _, document := range candidates {
+		candidateIDs[document.ID] = true
+	}
+
+	documents := make([]*domain.Document, 0, len(candidates))
+	for _, document := range candidates {
+		if inTree(document, rootID, candidateIDs) {
+			documents = append(documents, document)
+		}
+	}
 	return documents, nil
 }
 
-// SearchDocuments 搜索文档
-func (d *documentRepository) SearchDocuments(ctx context.Context, userID int64, keyword string, docType *domain.DocumentType, limit, offset int) ([]*domain.Document, error) {
+// inTree 检查文档到树根之间的祖先是否都在候选文档中
+func inTree(document *domain.Document, rootID *int64, candidateIDs map[int64]bool) bool {
+	ancestorIDs := document.AncestorIDs()
+	start := 0
+	if rootID != nil {
+		// 跳过树根及其之上的祖先
+		for i, id := range ancestorIDs {
+			if id == *rootID {
+				start = i + 1
+				break
+			}
+		}
+	}
+	for _, id := range ancestorIDs[start:] {
+		if !candidateIDs[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// GetWithAncestors 获取文档及其所有祖先文档，只含ID、父文档ID、标题和物化路径
+// 祖先ID取自文档的物化路径，无论层级多深都只需两次查询
+func (d *documentRepository) GetWithAncestors(ctx context.Context, ids []int64) ([]*domain.Document, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	db := d.db.WithContext(ctx)
 	var documents []*domain.Document
-	query := d.db.WithContext(ctx).
-		Where("owner_id = ? AND status != ?", userID, domain.DocumentStatusDeleted)
+	if err := db.Select("id", "parent_id", "title", "path").Where("id IN ?", ids).Find(&documents).Error; err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool, len(documents))
+	for _, document := range documents {
+		seen[document.ID] = true
+	}
+	var ancestorIDs []int64
+	for _, document := range documents {
+		for _, id := range document.AncestorIDs() {
+			if !seen[id] {
+				seen[id] = true
+				ancestorIDs = append(ancestorIDs, id)
+			}
+		}
+	}
+	if len(ancestorIDs) == 0 {
+		return documents, nil
+	}
+
+	var ancestors []*domain.Document
+	if err := db.Select("id", "parent_id", "title", "path").Where("id IN ?", ancestorIDs).Find(&ancestors).Error; err != nil {
+		return nil, err
+	}
+	return append(documents, ancestors...), nil
+}
 
-	if keyword != "" {
-		query = query.Where("title LIKE ? OR content LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+// GetByIDs 按ID批量获取文档，withContent 为 false 时不含内容
+func (d *documentRepository) GetByIDs(ctx context.Context, ids []int64, withContent bool) ([]*domain.Document, error) {
+	if len(ids) == 0 {
+		return []*domain.Document{}, nil
 	}
 
-	if docType != nil {
-		query = query.Where("type = ?", *docType)
+	query := d.db.WithContext(ctx).Where("id IN ?", ids)
+	if !withContent {
+		query = query.Omit("content")
 	}
 
-	if err := query.
-		Order("updated_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&documents).Error; err != nil {
+	var documents []*domain.Document
+	if err := query.Find(&documents).Error; err != nil {
 		return nil, err
 	}
 	return documents, nil
 }
 
-// GetStarredDocuments 获取用户星标文档
-func (d *documentRepository) GetStarredDocuments(ctx context.Context, userID int64) ([]*domain.Document, error) {
+// GetSubtreeIDs 获取文档自身及所有子孙文档的ID，不区分状态
+func (d *documentRepository) GetSubtreeIDs(ctx context.Context, rootID int64) ([]int64, error) {
+	var ids []int64
+	if err := d.db.WithContext(ctx).
+		Model(&domain.Document{}).
+		Where(subtreePathSQL, rootID).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ListUndeleted 按ID顺序分批获取ID大于 afterID 的未删除文档
+func (d *documentRepository) ListUndeleted(ctx context.Context, afterID int64, limit int) ([]*domain.Document, error) {
 	var documents []*domain.Document
 	if err := d.db.WithContext(ctx).
-		Where("owner_id = ? AND is_starred = ? AND status != ?", userID, true, domain.DocumentStatusDeleted).
-		Order("updated_at DESC").
+		Where("id > ? AND status <> ?", afterID, domain.DocumentStatusDeleted).
+		Order("id ASC").
+		Limit(limit).
 		Find(&documents).Error; err != nil {
 		return nil, err
 	}
 	return documents, nil
 }
 
-// GetRecentDocuments 获取用户最近访问的文档
-func (d *documentRepository) GetRecentDocuments(ctx context.Context, userID int64, limit int) ([]*domain.Document, error) {
+// GetStarredDocuments 获取用户星标文档
+func (d *documentRepository) GetStarredDocuments(ctx context.Context, userID int64) ([]*domain.Document, error) {
 	var documents []*domain.Document
 	if err := d.db.WithContext(ctx).
-		Where("owner_id = ? AND status != ?", userID, domain.DocumentStatusDeleted).
+		Where("owner_id = ? AND is_starred = ? AND status = ?", userID, true, domain.DocumentStatusActive).
 		Order("updated_at DESC").
-		Limit(limit).
 		Find(&documents).Error; err != nil {
 		return nil, err
 	}
 	return documents, nil
 }
 
-// UpdateContent 更新文档内容
-func (d *documentRepository) UpdateContent(ctx context.Context, id int64, content string) error {
-	if err := d.db.WithContext(ctx).
-		Model(&domain.Document{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"content":    content,
-			"updated_at": time.Now(),
-		}).Error; err != nil {
+// UpdateContent 更新文档内容并递增版本号，返回新的版本号
+// expectedVersion 大于 0 时按版本号比较并更新，文档已被其他写入修改时返回 DocumentModifiedError；
+// 归档状态在同一条语句中检查，与归档并发的写入不会落到已归档的文档上
+func (d *documentRepository) UpdateContent(ctx context.Context, id int64, content string, expectedVersion int64) (int64, error) {
+	var version int64
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		version, err = updateDocumentContent(tx, id, content, expectedVersion)
 		return err
+	})
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return version, nil
+}
+
+// updateDocumentContent 在事务中更新文档内容并递增版本号，返回新的版本号，规则与 UpdateContent 相同
+func updateDocumentContent(tx *gorm.DB, id int64, content string, expectedVersion int64) (int64, error) {
+	query := tx.Model(&domain.Document{}).Where("id = ? AND status <> ?", id, domain.DocumentStatusArchived)
+	if expectedVersion > 0 {
+		query = query.Where("version = ?", expectedVersion)
+	}
+	result := query.Updates(map[string]interface{}{
+		"content":    content,
+		"plain_text": diff.PlainText(content),
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, versionConflict(tx, id)
+	}
+
+	var version int64
+	if err := tx.Model(&domain.Document{}).
+		Where("id = ?", id).
+		Select("version").
+		Scan(&version).Error; err != nil {
+		return 0, err
+	}
+	return version, nil
 }
 
 // GetContent 获取文档内容
@@ -239,6 +429,7 @@ func (d *documentRepository) GetContent(ctx context.Context, id int64) (string,
 func (d *documentRepository) UpdateStatus(ctx context.Context, id int64, status domain.DocumentStatus) error {
 	updates := map[string]interface{}{
 		"status":     status,
+		"version":    gorm.Expr("version + 1"),
 		"updated_at": time.Now(),
 	}
 
@@ -266,6 +457,7 @@ func (d *documentRepository) ToggleStar(ctx context.Context, id int64, userID in
 		Where("id = ? AND owner_id = ?", id, userID).
 		Updates(map[string]interface{}{
 			"is_starred": starred,
+			"version":    gorm.Expr("version + 1"),
 			"updated_at": time.Now(),
 		}).Error; err != nil {
 		return err
@@ -273,68 +465,262 @@ func (d *documentRepository) ToggleStar(ctx context.Context, id int64, userID in
 	return nil
 }
 
-// MoveDocument 移动文档到新的父目录
+// MoveDocument 移动文档到新的父目录，子树的物化路径在同一事务中改写
 func (d *documentRepository) MoveDocument(ctx context.Context, id int64, newParentID *int64) error {
-	updates := map[string]interface{}{
-		"updated_at": time.Now(),
-	}
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, _, err := moveSubtree(tx, id, newParentID); err != nil {
+			return err
+		}
+		return tx.Model(&domain.Document{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": time.Now(),
+			}).Error
+	})
+}
 
-	if newParentID == nil {
-		updates["parent_id"] = nil
-	} else {
-		updates["parent_id"] = *newParentID
+// Archive 归档文档以及未归档的子孙文档，返回本次归档的文档ID
+// 文档不存在或已删除时返回 ErrDocumentNotFound，已归档的文档不重复归档
+func (d *documentRepository) Archive(ctx context.Context, id int64) ([]int64, error) {
+	var ids []int64
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&domain.Document{}).
+			Where("id = ? AND status <> ?", id, domain.DocumentStatusDeleted).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return domain.ErrDocumentNotFound
+		}
+
+		var err error
+		if ids, err = archiveSubtreeIDs(tx, id); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		return tx.Model(&domain.Document{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":      domain.DocumentStatusArchived,
+				"archived_at": &now,
+				"version":     gorm.Expr("version + 1"),
+				"updated_at":  now,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
 	}
+	return ids, nil
+}
 
-	if err := d.db.WithContext(ctx).
-		Model(&domain.Document{}).
-		Where("id = ?", id).
-		Updates(updates).Error; err != nil {
-		return err
+// Unarchive 取消归档文档以及已归档的子孙文档，返回本次取消归档的文档ID
+// 文档不存在或已删除时返回 ErrDocumentNotFound，未归档的文档返回空列表
+func (d *documentRepository) Unarchive(ctx context.Context, id int64) ([]int64, error) {
+	var ids []int64
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&domain.Document{}).
+			Where("id = ? AND status <> ?", id, domain.DocumentStatusDeleted).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return domain.ErrDocumentNotFound
+		}
+
+		var err error
+		if ids, err = unarchiveSubtreeIDs(tx, id); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		return tx.Model(&domain.Document{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":      domain.DocumentStatusActive,
+				"archived_at": nil,
+				"version":     gorm.Expr("version + 1"),
+				"updated_at":  time.Now(),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return ids, nil
 }
 
-// BatchDelete 批量软删除文档
+// BatchDelete 批量软删除文档，每个文档分别作为回收站条目，文件夹连同子孙文档一起删除
 func (d *documentRepository) BatchDelete(ctx context.Context, ids []int64, userID int64) error {
 	if len(ids) == 0 {
 		return nil
 	}
 
-	now := time.Now()
-	if err := d.db.WithContext(ctx).
-		Model(&domain.Document{}).
-		Where("id IN ? AND owner_id = ?", ids, userID).
-		Updates(map[string]interface{}{
-			"status":     domain.DocumentStatusDeleted,
-			"deleted_at": &now,
-			"updated_at": now,
-		}).Error; err != nil {
-		return err
-	}
-	return nil
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ownedIDs []int64
+		if err := tx.Model(&domain.Document{}).
+			Where("id IN ? AND owner_id = ? AND status <> ?", ids, userID, domain.DocumentStatusDeleted).
+			Pluck("id", &ownedIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range ownedIDs {
+			// 已随前面的文件夹一起删除的文档跳过
+			if err := moveToTrash(tx, id); err != nil && !errors.Is(err, domain.ErrDocumentNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-// BatchMove 批量移动文档
+// BatchMove 批量移动文档，只移动用户拥有的文档，任何一个文档不能移动时全部回滚
 func (d *documentRepository) BatchMove(ctx context.Context, ids []int64, newParentID *int64, userID int64) error {
 	if len(ids) == 0 {
 		return nil
 	}
 
-	updates := map[string]interface{}{
-		"updated_at": time.Now(),
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ownedIDs []int64
+		if err := tx.Model(&domain.Document{}).
+			Where("id IN ? AND owner_id = ?", ids, userID).
+			Order("id ASC").
+			Pluck("id", &ownedIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range ownedIDs {
+			if _, _, err := moveSubtree(tx, id, newParentID); err != nil {
+				return err
+			}
+		}
+		if len(ownedIDs) == 0 {
+			return nil
+		}
+
+		return tx.Model(&domain.Document{}).
+			Where("id IN ?", ownedIDs).
+			Updates(map[string]interface{}{
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": time.Now(),
+			}).Error
+	})
+}
+
+// documentParentPath 获取并锁定父文档的物化路径和层级，parentID 为空时为根目录，层级为 -1
+// 父文档加锁直到事务结束，并发移动父文档时等待本事务完成，不会用过期的路径写入子文档
+func documentParentPath(tx *gorm.DB, parentID *int64) (string, int, error) {
+	if parentID == nil {
+		return domain.DocumentRootPath, -1, nil
 	}
 
-	if newParentID == nil {
-		updates["parent_id"] = nil
-	} else {
-		updates["parent_id"] = *newParentID
+	var parent domain.Document
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "path", "depth").
+		Where("id = ?", *parentID).
+		First(&parent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", 0, domain.ErrDocumentNotFound
+		}
+		return "", 0, err
 	}
+	return parent.Path, parent.Depth, nil
+}
 
-	if err := d.db.WithContext(ctx).
-		Model(&domain.Document{}).
-		Where("id IN ? AND owner_id = ?", ids, userID).
-		Updates(updates).Error; err != nil {
-		return err
+// moveSubtree 将文档移动到新的父文档下，并以一条语句改写整棵子树的物化路径和层级，返回文档新的路径和层级
+// 新的父文档为文档自身或位于子树中时返回 ErrConflict，子树最深的文档超过最大层数时返回 ErrDocumentTreeTooDeep
+// 文档和新的父文档按ID顺序一起加锁后再检查路径：两个事务同时把 X 移到 Y 下、把 Y 移到 X 下时，
+// 后加锁的一方读到先完成的移动写入的路径，检查时返回 ErrConflict，不会形成循环
+func moveSubtree(tx *gorm.DB, id int64, newParentID *int64) (string, int, error) {
+	ids := []int64{id}
+	if newParentID != nil && *newParentID != id {
+		ids = append(ids, *newParentID)
 	}
-	return nil
+
+	var locked []domain.Document
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "path", "depth").
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&locked).Error; err != nil {
+		return "", 0, err
+	}
+	if len(locked) != len(ids) {
+		return "", 0, domain.ErrDocumentNotFound
+	}
+
+	var document domain.Document
+	parentPath, parentDepth := domain.DocumentRootPath, -1
+	for _, row := range locked {
+		if row.ID == id {
+			document = row
+		}
+		if newParentID != nil && row.ID == *newParentID {
+			parentPath, parentDepth = row.Path, row.Depth
+		}
+	}
+	if document.Path == "" || parentPath == "" {
+		return "", 0, domain.ErrInvalidDocument
+	}
+
+	var maxDepth int
+	if err := tx.Model(&domain.Document{}).
+		Where("path LIKE ?", document.Path+"%").
+		Select("COALESCE(MAX(depth), 0)").
+		Scan(&maxDepth).Error; err != nil {
+		return "", 0, err
+	}
+	path, delta, err := planSubtreeMove(&document, parentPath, parentDepth, maxDepth)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := tx.Model(&document).UpdateColumn("parent_id", newParentID).Error; err != nil {
+		return "", 0, err
+	}
+
+	if err := tx.Model(&domain.Document{}).
+		Where("path LIKE ?", document.Path+"%").
+		UpdateColumns(map[string]interface{}{
+			"path":  gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", path, len(document.Path)+1),
+			"depth": gorm.Expr("depth + ?", delta),
+		}).Error; err != nil {
+		return "", 0, err
+	}
+	return path, document.Depth + delta, nil
+}
+
+// planSubtreeMove 计算文档移动到父路径 parentPath 下后的新路径和子树的层级变化，maxDepth 为子树中最深文档的层级
+// 新的父文档为文档自身或位于子树中时返回 ErrConflict，超过最大层数时返回 ErrDocumentTreeTooDeep
+// 子树中各文档的新路径为新路径加上原路径去掉文档原路径前缀后的部分
+func planSubtreeMove(document *domain.Document, parentPath string, parentDepth, maxDepth int) (string, int, error) {
+	if strings.HasPrefix(parentPath, document.Path) {
+		return "", 0, domain.ErrConflict
+	}
+	delta := parentDepth + 1 - document.Depth
+	if maxDepth+delta >= domain.MaxDocumentDepth {
+		return "", 0, domain.ErrDocumentTreeTooDeep
+	}
+	return domain.BuildDocumentPath(parentPath, document.ID), delta, nil
+}
+
+// sameParent 比较两个父文档ID是否相同，都为空表示都在根目录
+func sameParent(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// listedStatuses 列表和搜索中显示的文档状态，默认不显示已归档的文档
+func listedStatuses(includeArchived bool) []domain.DocumentStatus {
+	if includeArchived {
+		return []domain.DocumentStatus{domain.DocumentStatusActive, domain.DocumentStatusArchived}
+	}
+	return []domain.DocumentStatus{domain.DocumentStatusActive}
 }
@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"DOC/domain"
+)
+
+func TestPlanSubtreeMove(t *testing.T) {
+	// 文档 5 位于 /1/5/，子树最深的文档层级为 3
+	document := &domain.Document{ID: 5, Path: "/1/5/", Depth: 1}
+
+	// 移动到 /2/7/ 下：路径改写到新父路径下，层级整体加 1
+	path, delta, err := planSubtreeMove(document, "/2/7/", 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "/2/7/5/", path)
+	assert.Equal(t, 1, delta)
+
+	// 移动到根目录：层级整体减 1
+	path, delta, err = planSubtreeMove(document, domain.DocumentRootPath, -1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "/5/", path)
+	assert.Equal(t, -1, delta)
+}
+
+func TestPlanSubtreeMoveCycle(t *testing.T) {
+	document := &domain.Document{ID: 5, Path: "/1/5/", Depth: 1}
+
+	// 移动到自身或子孙文档下形成循环
+	_, _, err := planSubtreeMove(document, "/1/5/", 1, 3)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	_, _, err = planSubtreeMove(document, "/1/5/9/12/", 3, 3)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	// 路径前缀按完整的ID段比较，/1/50/ 不在 /1/5/ 的子树中
+	_, _, err = planSubtreeMove(document, "/1/50/", 1, 3)
+	assert.NoError(t, err)
+}
+
+func TestPlanSubtreeMoveTooDeep(t *testing.T) {
+	document := &domain.Document{ID: 5, Path: "/1/5/", Depth: 1}
+
+	// 子树最深的文档移动后达到最大层数
+	parentDepth := domain.MaxDocumentDepth - 3
+	_, _, err := planSubtreeMove(document, "/2/", parentDepth, 3)
+	assert.ErrorIs(t, err, domain.ErrDocumentTreeTooDeep)
+
+	_, _, err = planSubtreeMove(document, "/2/", parentDepth-1, 3)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,230 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"DOC/domain"
+	"DOC/pkg/search"
+)
+
+const (
+	// titleBoost 标题匹配相关度的权重
+	titleBoost = 3
+
+	// ngramTokenSize 与 MySQL ngram_token_size 一致，短于该长度的检索词无法命中全文索引
+	ngramTokenSize = 2
+
+	// inSpacesSQL 文档的主空间或通过关联加入的空间在给定空间中
+	inSpacesSQL = `documents.space_id IN ? OR EXISTS (SELECT 1 FROM space_documents sd
+		WHERE sd.document_id = documents.id AND sd.space_id IN ?)`
+)
+
+// documentSearchIndex 基于 MySQL ngram 全文索引的搜索索引实现
+// 实现 domain.SearchIndex 接口，直接查询文档表，纯文本列由文档仓储在写入内容时维护，
+// 因此写入、删除和清空都不需要额外操作
+type documentSearchIndex struct {
+	db *gorm.DB
+}
+
+// NewDocumentSearchIndex 创建新的 MySQL 搜索索引实例
+func NewDocumentSearchIndex(db *gorm.DB) domain.SearchIndex {
+	return &documentSearchIndex{
+		db: db,
+	}
+}
+
+// Index 文档表即索引，无需写入
+func (d *documentSearchIndex) Index(ctx context.Context, document *domain.SearchDocument) error {
+	return nil
+}
+
+// Delete 文档表即索引，无需删除
+func (d *documentSearchIndex) Delete(ctx context.Context, documentIDs ...int64) error {
+	return nil
+}
+
+// Search 搜索用户拥有、被授予权限、被私有分享或所在空间中的文档
+// 使用 ngram 全文索引匹配标题和正文纯文本，标题匹配加权后按相关度排序；
+// 检索词都短于 ngram 长度时退化为标题模糊匹配，相关度为 0；关键词为空时只按过滤条件查询
+func (d *documentSearchIndex) Search(ctx context.Context, query *domain.SearchQuery) (*domain.SearchIndexResult, error) {
+	result := &domain.SearchIndexResult{Hits: []*domain.SearchIndexHit{}, Facets: domain.NewSearchFacets()}
+	keyword := strings.TrimSpace(query.Keyword)
+
+	db := d.filter(d.db.WithContext(ctx).Model(&domain.Document{}), query)
+
+	against := booleanQuery(keyword)
+	if against != "" {
+		db = db.Where("MATCH(title) AGAINST(? IN BOOLEAN MODE) OR MATCH(plain_text) AGAINST(? IN BOOLEAN MODE)", against, against)
+	} else if keyword != "" {
+		db = db.Where("title LIKE ?", "%"+keyword+"%")
+	}
+	db = db.Session(&gorm.Session{})
+
+	// 1. 统计命中总数
+	if err := db.Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+	if result.Total == 0 {
+		return result, nil
+	}
+
+	// 2. 按相关度查出当前页
+	page := db
+	if against != "" {
+		page = page.
+			Select("id, COALESCE(plain_text, '') AS plain_text, MATCH(title) AGAINST(? IN BOOLEAN MODE) * ? + MATCH(plain_text) AGAINST(? IN BOOLEAN MODE) AS score",
+				against, titleBoost, against).
+			Order("score DESC")
+	} else {
+		page = page.Select("id, COALESCE(plain_text, '') AS plain_text, 0 AS score")
+	}
+
+	if err := page.
+		Order("updated_at DESC").
+		Order("id DESC").
+		Limit(query.Limit).
+		Offset(query.Offset).
+		Scan(&result.Hits).Error; err != nil {
+		return nil, err
+	}
+
+	// 3. 统计所有命中文档的分面
+	if query.SkipFacets {
+		return result, nil
+	}
+	if err := d.facets(ctx, db, result.Facets); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// filter 添加可见性和过滤条件
+func (d *documentSearchIndex) filter(db *gorm.DB, query *domain.SearchQuery) *gorm.DB {
+	now := query.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	db = db.Where("documents.status IN ?", listedStatuses(query.IncludeArchived))
+
+	// 可见性：所有者、被授予权限或私有分享的用户，以及文档所在空间的成员
+	sharedCondition := d.db.
+		Where("EXISTS (SELECT 1 FROM document_permissions p WHERE p.document_id = documents.id AND p.user_id = ?)", query.UserID).
+		Or(`EXISTS (SELECT 1 FROM document_share_users su INNER JOIN document_shares s ON s.id = su.share_id
+			WHERE s.document_id = documents.id AND su.user_id = ? AND (s.expires_at IS NULL OR s.expires_at > ?))`, query.UserID, now)
+	if query.SharedWithMe {
+		db = db.Where("documents.owner_id <> ?", query.UserID).Where(sharedCondition)
+	} else {
+		visible := d.db.Where("documents.owner_id = ?", query.UserID).Or(sharedCondition)
+		if len(query.SpaceIDs) > 0 {
+			visible = visible.Or(inSpacesSQL, query.SpaceIDs, query.SpaceIDs)
+		}
+		db = db.Where(visible)
+	}
+
+	if query.Type != nil {
+		db = db.Where("documents.type = ?", *query.Type)
+	}
+	if query.OwnerID != nil {
+		db = db.Where("documents.owner_id = ?", *query.OwnerID)
+	}
+	if query.SpaceID != nil {
+		spaceIDs := []int64{*query.SpaceID}
+		db = db.Where(inSpacesSQL, spaceIDs, spaceIDs)
+	}
+	if query.AncestorID != nil {
+		db = db.Where(subtreePathSQL+" AND documents.id <> ?", *query.AncestorID, *query.AncestorID)
+	}
+	if query.UpdatedAfter != nil {
+		db = db.Where("documents.updated_at >= ?", *query.UpdatedAfter)
+	}
+	if query.UpdatedBefore != nil {
+		db = db.Where("documents.updated_at <= ?", *query.UpdatedBefore)
+	}
+	if query.CreatedAfter != nil {
+		db = db.Where("documents.created_at >= ?", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		db = db.Where("documents.created_at <= ?", *query.CreatedBefore)
+	}
+	if query.DocumentIDs != nil {
+		if len(query.DocumentIDs) == 0 {
+			return db.Where("1 = 0")
+		}
+		db = db.Where("documents.id IN ?", query.DocumentIDs)
+	}
+	if query.StarredOnly {
+		db = db.Where("documents.is_starred = ?", true)
+	}
+	return db
+}
+
+// facets 按空间、所有者和类型统计命中的文档
+// 空间包括文档的主空间和通过关联加入的空间，同一文档在同一空间中只计一次
+func (d *documentSearchIndex) facets(ctx context.Context, matched *gorm.DB, facets *domain.SearchFacets) error {
+	var types []struct {
+		Type  domain.DocumentType
+		Count int64
+	}
+	if err := matched.Select("documents.type AS type, COUNT(*) AS count").Group("documents.type").Scan(&types).Error; err != nil {
+		return err
+	}
+	for _, row := range types {
+		facets.Types[row.Type] = row.Count
+	}
+
+	var rows []struct {
+		ID    int64
+		Count int64
+	}
+	if err := matched.Select("documents.owner_id AS id, COUNT(*) AS count").Group("documents.owner_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		facets.Owners[row.ID] = row.Count
+	}
+
+	primary := matched.Select("documents.id AS document_id, documents.space_id AS space_id").Where("documents.space_id IS NOT NULL")
+	associated := d.db.Table("space_documents").
+		Select("document_id, space_id").
+		Where("document_id IN (?)", matched.Select("documents.id"))
+	rows = nil
+	if err := d.db.WithContext(ctx).
+		Raw("SELECT space_id AS id, COUNT(DISTINCT document_id) AS count FROM (? UNION ?) AS s GROUP BY space_id", primary, associated).
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		facets.Spaces[row.ID] = row.Count
+	}
+	return nil
+}
+
+// Reset 文档表即索引，无需清空
+func (d *documentSearchIndex) Reset(ctx context.Context) error {
+	return nil
+}
+
+// Close 连接由调用方管理，无需关闭
+func (d *documentSearchIndex) Close() error {
+	return nil
+}
+
+// booleanQuery 将搜索关键词转换为 BOOLEAN MODE 查询，每个检索词都必须出现
+// 检索词按短语匹配，短于 ngram 长度的检索词被忽略，全部被忽略时返回空字符串
+func booleanQuery(keyword string) string {
+	var parts []string
+	for _, term := range search.Terms(keyword) {
+		term = strings.ReplaceAll(term, `"`, "")
+		if utf8.RuneCountInString(term) < ngramTokenSize {
+			continue
+		}
+		parts = append(parts, `+"`+term+`"`)
+	}
+	return strings.Join(parts, " ")
+}
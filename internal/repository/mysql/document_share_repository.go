@@ -176,6 +176,35 @@ func (d *documentShareRepository) GetShareUsers(ctx context.Context, shareID int
 	return shareUsers, nil
 }
 
+// GetShareUsersByDocuments 批量获取多个文档的私有分享用户，附带所属分享，不区分是否过期
+func (d *documentShareRepository) GetShareUsersByDocuments(ctx context.Context, documentIDs []int64) ([]*domain.DocumentShareUser, error) {
+	if len(documentIDs) == 0 {
+		return []*domain.DocumentShareUser{}, nil
+	}
+
+	var shareUsers []*domain.DocumentShareUser
+	if err := d.db.WithContext(ctx).
+		Joins("Share").
+		Where("Share.document_id IN ? AND Share.share_type = ?", documentIDs, domain.ShareTypePrivate).
+		Find(&shareUsers).Error; err != nil {
+		return nil, err
+	}
+	return shareUsers, nil
+}
+
+// GetShareUsersByUser 获取用户被加入的私有分享，附带所属分享，不区分是否过期
+func (d *documentShareRepository) GetShareUsersByUser(ctx context.Context, userID int64) ([]*domain.DocumentShareUser, error) {
+	var shareUsers []*domain.DocumentShareUser
+	if err := d.db.WithContext(ctx).
+		Joins("Share").
+		Where("document_share_users.user_id = ? AND Share.share_type = ?", userID, domain.ShareTypePrivate).
+		Order("document_share_users.added_at DESC").
+		Find(&shareUsers).Error; err != nil {
+		return nil, err
+	}
+	return shareUsers, nil
+}
+
 // IncrementViewCount 增加访问量并记录访问信息
 func (d *documentShareRepository) IncrementViewCount(ctx context.Context, shareID int64, accessIP string) error {
 	now := time.Now()
@@ -0,0 +1,253 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"DOC/domain"
+)
+
+// activeSubtreeIDs 未删除的文档自身以及未删除的子孙文档，已删除的子文件夹属于其他回收站条目，其子孙不包括在内
+func activeSubtreeIDs(tx *gorm.DB, id int64) ([]int64, error) {
+	var ids []int64
+	err := tx.Model(&domain.Document{}).
+		Where(subtreePathSQL, id).
+		Where("NOT "+subtreeCut("b.status = ?"), id, domain.DocumentStatusDeleted).
+		Pluck("documents.id", &ids).Error
+	return ids, err
+}
+
+// trashSubtreeIDs 文档自身以及从它开始连续属于同一回收站条目的子孙文档
+func trashSubtreeIDs(tx *gorm.DB, id int64, trashRootID int64) ([]int64, error) {
+	var ids []int64
+	err := tx.Model(&domain.Document{}).
+		Where(subtreePathSQL, id).
+		Where("(documents.id = ? OR NOT "+subtreeCut("b.id <> ? AND (b.status <> ? OR NOT (b.trash_root_id <=> ?))")+")",
+			id, id, id, domain.DocumentStatusDeleted, trashRootID).
+		Pluck("documents.id", &ids).Error
+	return ids, err
+}
+
+// documentTrashRepository MySQL回收站仓储实现
+// 实现 domain.DocumentTrashRepository 接口，负责回收站条目的查询、恢复和永久删除
+type documentTrashRepository struct {
+	db *gorm.DB
+}
+
+// NewDocumentTrashRepository 创建新的回收站仓储实例
+func NewDocumentTrashRepository(db *gorm.DB) domain.DocumentTrashRepository {
+	return &documentTrashRepository{db: db}
+}
+
+// List 按删除时间倒序分页获取回收站条目
+func (d *documentTrashRepository) List(ctx context.Context, filter domain.DocumentTrashFilter, offset, limit int) ([]*domain.DocumentTrashItem, int64, error) {
+	// 未记录回收站根的文档是按单个文档删除的，同样作为条目
+	query := d.db.WithContext(ctx).
+		Model(&domain.Document{}).
+		Where("status = ? AND (trash_root_id IS NULL OR trash_root_id = id)", domain.DocumentStatusDeleted)
+	if filter.SpaceID != nil {
+		query = query.Where("space_id = ?", *filter.SpaceID)
+	} else {
+		query = query.Where("owner_id = ?", filter.OwnerID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var documents []*domain.Document
+	if err := query.
+		Omit("content").
+		Order("deleted_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&documents).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(documents) == 0 {
+		return []*domain.DocumentTrashItem{}, total, nil
+	}
+
+	// 统计每个条目随之一起删除的子孙文档数
+	rootIDs := make([]int64, len(documents))
+	for i, document := range documents {
+		rootIDs[i] = document.ID
+	}
+	var counts []struct {
+		TrashRootID int64
+		Count       int64
+	}
+	if err := d.db.WithContext(ctx).
+		Model(&domain.Document{}).
+		Select("trash_root_id, COUNT(*) AS count").
+		Where("status = ? AND trash_root_id IN ? AND id <> trash_root_id", domain.DocumentStatusDeleted, rootIDs).
+		Group("trash_root_id").
+		Scan(&counts).Error; err != nil {
+		return nil, 0, err
+	}
+	descendants := make(map[int64]int64, len(counts))
+	for _, count := range counts {
+		descendants[count.TrashRootID] = count.Count
+	}
+
+	items := make([]*domain.DocumentTrashItem, len(documents))
+	for i, document := range documents {
+		items[i] = &domain.DocumentTrashItem{
+			Document:        document,
+			DescendantCount: descendants[document.ID],
+		}
+	}
+	return items, total, nil
+}
+
+// ListExpired 获取删除时间早于 before 的回收站条目根文档ID
+func (d *documentTrashRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]int64, error) {
+	var ids []int64
+	if err := d.db.WithContext(ctx).
+		Model(&domain.Document{}).
+		Where("status = ? AND (trash_root_id IS NULL OR trash_root_id = id) AND deleted_at < ?", domain.DocumentStatusDeleted, before).
+		Order("deleted_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Restore 恢复文档以及与其一起删除的子孙文档，各文档恢复为删除前的状态
+// 移入回收站时保留归档时间，有归档时间的文档恢复为已归档，其余恢复为正常
+func (d *documentTrashRepository) Restore(ctx context.Context, documentID int64, relocate bool) (int64, error) {
+	var restored int64
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids, err := trashSubtree(tx, documentID)
+		if err != nil {
+			return err
+		}
+
+		if relocate {
+			if _, _, err := moveSubtree(tx, documentID, nil); err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&domain.Document{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":        gorm.Expr("CASE WHEN archived_at IS NULL THEN ? ELSE ? END", domain.DocumentStatusActive, domain.DocumentStatusArchived),
+				"deleted_at":    nil,
+				"trash_root_id": nil,
+				"version":       gorm.Expr("version + 1"),
+				"updated_at":    time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		restored = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return restored, nil
+}
+
+// Purge 永久删除文档以及与其一起删除的子孙文档
+func (d *documentTrashRepository) Purge(ctx context.Context, documentID int64) (int64, error) {
+	var purged int64
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids, err := trashSubtree(tx, documentID)
+		if err != nil {
+			return err
+		}
+
+		// 删除分享及其指定用户
+		shareIDs := tx.Model(&domain.DocumentShare{}).Select("id").Where("document_id IN ?", ids)
+		if err := tx.Where("share_id IN (?)", shareIDs).Delete(&domain.DocumentShareUser{}).Error; err != nil {
+			return err
+		}
+
+		// 删除协作会话及其参与者、操作、Yjs 更新和检查点历史
+		sessionIDs := tx.Model(&domain.CollaborationSession{}).Select("id").Where("document_id IN ?", ids)
+		for _, model := range []interface{}{
+			&domain.CollaborationUser{},
+			&domain.CollaborationOperation{},
+			&domain.CollaborationUpdate{},
+			&domain.CollaborationCheckpoint{},
+		} {
+			if err := tx.Where("session_id IN (?)", sessionIDs).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// 删除其他关联数据
+		for _, model := range []interface{}{
+			&domain.CollaborationSession{},
+			&domain.DocumentShare{},
+			&domain.DocumentPermission{},
+			&domain.DocumentFavorite{},
+			&domain.SpaceDocument{},
+			&domain.DocumentVersionView{},
+			&domain.DocumentVersion{},
+			&domain.DocumentAccess{},
+		} {
+			if err := tx.Where("document_id IN ?", ids).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Where("id IN ?", ids).Delete(&domain.Document{})
+		if result.Error != nil {
+			return result.Error
+		}
+		purged = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// trashSubtree 获取回收站中的文档以及与其一起删除的子孙文档ID
+// 文档不在回收站中时返回 ErrDocumentNotInTrash
+func trashSubtree(tx *gorm.DB, documentID int64) ([]int64, error) {
+	var document domain.Document
+	if err := tx.Select("id", "status", "trash_root_id").Where("id = ?", documentID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrDocumentNotFound
+		}
+		return nil, err
+	}
+	if !document.IsDeleted() {
+		return nil, domain.ErrDocumentNotInTrash
+	}
+
+	return trashSubtreeIDs(tx, documentID, document.GetTrashRootID())
+}
+
+// moveToTrash 将文档以及未删除的子孙文档移入回收站，文档作为回收站条目的根
+// 文档不存在或已在回收站中时返回 ErrDocumentNotFound
+func moveToTrash(tx *gorm.DB, documentID int64) error {
+	ids, err := activeSubtreeIDs(tx, documentID)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return domain.ErrDocumentNotFound
+	}
+
+	now := time.Now()
+	return tx.Model(&domain.Document{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"status":        domain.DocumentStatusDeleted,
+			"deleted_at":    &now,
+			"trash_root_id": documentID,
+			"version":       gorm.Expr("version + 1"),
+			"updated_at":    now,
+		}).Error
+}
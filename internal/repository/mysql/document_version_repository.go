@@ -0,0 +1,179 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"DOC/domain"
+)
+
+// documentVersionRepository MySQL文档版本仓储实现
+// 实现 domain.DocumentVersionRepository 接口，负责文档版本数据的持久化操作
+type documentVersionRepository struct {
+	db *gorm.DB
+}
+
+// NewDocumentVersionRepository 创建新的文档版本仓储实例
+func NewDocumentVersionRepository(db *gorm.DB) domain.DocumentVersionRepository {
+	return &documentVersionRepository{db: db}
+}
+
+// Store 保存文档版本
+func (d *documentVersionRepository) Store(ctx context.Context, version *domain.DocumentVersion) error {
+	if err := d.db.WithContext(ctx).Create(version).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetByID 根据ID获取文档版本
+func (d *documentVersionRepository) GetByID(ctx context.Context, id int64) (*domain.DocumentVersion, error) {
+	var version domain.DocumentVersion
+	if err := d.db.WithContext(ctx).Where("id = ?", id).First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrDocumentVersionNotFound
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// Update 更新文档版本
+func (d *documentVersionRepository) Update(ctx context.Context, version *domain.DocumentVersion) error {
+	version.UpdatedAt = time.Now()
+	if err := d.db.WithContext(ctx).Save(version).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetLatest 获取文档最新的版本
+func (d *documentVersionRepository) GetLatest(ctx context.Context, documentID int64) (*domain.DocumentVersion, error) {
+	var version domain.DocumentVersion
+	if err := d.db.WithContext(ctx).
+		Where("document_id = ?", documentID).
+		Order("created_at DESC, id DESC").
+		First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrDocumentVersionNotFound
+		}
+		return nil, err
+	}
+	return &version, nil
+}
+
+// ListByDocument 分页获取文档的版本列表，不加载内容
+func (d *documentVersionRepository) ListByDocument(ctx context.Context, documentID int64, namedOnly bool, offset, limit int) ([]*domain.DocumentVersion, int64, error) {
+	query := d.db.WithContext(ctx).Model(&domain.DocumentVersion{}).Where("document_id = ?", documentID)
+	if namedOnly {
+		query = query.Where("name <> ''")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var versions []*domain.DocumentVersion
+	if err := query.
+		Omit("content").
+		Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&versions).Error; err != nil {
+		return nil, 0, err
+	}
+	return versions, total, nil
+}
+
+// Prune 按保留规则删除文档的自动版本
+// 超过保留天数的自动版本和按时间倒序超出保留个数的自动版本被删除
+// 命名版本、最新版本和被用户标记为最后查看的版本始终保留
+func (d *documentVersionRepository) Prune(ctx context.Context, documentID int64, retention domain.DocumentVersionRetention) error {
+	latest, err := d.GetLatest(ctx, documentID)
+	if err != nil {
+		if errors.Is(err, domain.ErrDocumentVersionNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		viewed := tx.Model(&domain.DocumentVersionView{}).
+			Select("version_id").
+			Where("document_id = ?", documentID)
+		automatic := func() *gorm.DB {
+			return tx.Where("document_id = ? AND name = '' AND id <> ? AND id NOT IN (?)", documentID, latest.ID, viewed)
+		}
+
+		// 删除超过保留天数的自动版本
+		if retention.Days > 0 {
+			cutoff := time.Now().AddDate(0, 0, -retention.Days)
+			if err := automatic().
+				Where("created_at < ?", cutoff).
+				Delete(&domain.DocumentVersion{}).Error; err != nil {
+				return err
+			}
+		}
+
+		// 删除超出保留个数的自动版本
+		if retention.MaxCount > 0 {
+			var expiredIDs []int64
+			if err := tx.Model(&domain.DocumentVersion{}).
+				Where("document_id = ? AND name = ''", documentID).
+				Order("created_at DESC, id DESC").
+				Offset(retention.MaxCount).
+				Limit(1000).
+				Pluck("id", &expiredIDs).Error; err != nil {
+				return err
+			}
+			if len(expiredIDs) > 0 {
+				if err := automatic().
+					Where("id IN ?", expiredIDs).
+					Delete(&domain.DocumentVersion{}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// GetView 获取用户在文档上的最后查看记录
+func (d *documentVersionRepository) GetView(ctx context.Context, documentID, userID int64) (*domain.DocumentVersionView, error) {
+	var view domain.DocumentVersionView
+	if err := d.db.WithContext(ctx).
+		Where("document_id = ? AND user_id = ?", documentID, userID).
+		First(&view).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrDocumentVersionViewNotFound
+		}
+		return nil, err
+	}
+	return &view, nil
+}
+
+// SaveView 保存用户在文档上的最后查看记录，已有记录时更新查看的版本和时间
+func (d *documentVersionRepository) SaveView(ctx context.Context, view *domain.DocumentVersionView) error {
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "document_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"version_id", "viewed_at"}),
+	}).Create(view).Error
+}
+
+// IsViewed 检查版本是否被任一用户标记为最后查看的版本
+func (d *documentVersionRepository) IsViewed(ctx context.Context, versionID int64) (bool, error) {
+	var count int64
+	if err := d.db.WithContext(ctx).
+		Model(&domain.DocumentVersionView{}).
+		Where("version_id = ?", versionID).
+		Limit(1).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
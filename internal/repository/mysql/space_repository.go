@@ -223,6 +223,23 @@ func (s *spaceRepository) IsDocumentInSpace(ctx context.Context, spaceID, docume
 	return count > 0, nil
 }
 
+func (s *spaceRepository) GetDocumentSpaces(ctx context.Context, documentIDs []int64) ([]*domain.SpaceDocument, error) {
+	if len(documentIDs) == 0 {
+		return []*domain.SpaceDocument{}, nil
+	}
+
+	var spaceDocuments []*domain.SpaceDocument
+	err := s.db.WithContext(ctx).
+		Where("document_id IN ?", documentIDs).
+		Find(&spaceDocuments).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return spaceDocuments, nil
+}
+
 func NewSpaceRepository(db *gorm.DB) domain.SpaceRepository {
 	return &spaceRepository{db: db}
 }
@@ -0,0 +1,149 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"DOC/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// 文档访问相关键
+const (
+	DocumentAccessRecentPrefix = "document_access:recent:"  // 用户按访问类型的最近访问，后接 {userID}:{action}
+	DocumentAccessPendingKey   = "document_access:pending"  // 等待持久化的访问
+	DocumentAccessFlushingKey  = "document_access:flushing" // 已取出、正在持久化的访问
+)
+
+const (
+	DocumentAccessRecentSize   = 200                 // 每个用户每种访问类型保留的文档数
+	DocumentAccessRecentExpire = 30 * 24 * time.Hour // 用户不再访问时最近访问的保留时间，之后只能从数据库读取
+)
+
+// DocumentAccessCacheRepository 文档访问的 Redis 缓存实现
+// 最近访问使用有序集合，成员为文档ID，分数为访问时间的毫秒数；
+// 等待持久化的访问使用哈希，字段为 {userID}:{documentID}:{action}，同一字段只保留最后一次访问时间
+type DocumentAccessCacheRepository struct {
+	client *redis.Client
+}
+
+// NewDocumentAccessCacheRepository 创建新的文档访问缓存实例
+func NewDocumentAccessCacheRepository(client *redis.Client) domain.DocumentAccessCacheRepository {
+	return &DocumentAccessCacheRepository{
+		client: client,
+	}
+}
+
+func (r *DocumentAccessCacheRepository) Record(ctx context.Context, event *domain.DocumentAccessEvent) error {
+	key := r.recentKey(event.UserID, event.Action)
+	score := event.AccessedAt.UnixMilli()
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(score), Member: event.DocumentID})
+		pipe.ZRemRangeByRank(ctx, key, 0, -DocumentAccessRecentSize-1) // 只保留分数最高的若干个
+		pipe.Expire(ctx, key, DocumentAccessRecentExpire)
+		pipe.HSet(ctx, DocumentAccessPendingKey, r.pendingField(event), score)
+		return nil
+	})
+	return err
+}
+
+func (r *DocumentAccessCacheRepository) GetRecent(ctx context.Context, userID int64, action domain.DocumentAccessAction, limit int) ([]*domain.DocumentAccessEvent, error) {
+	if limit <= 0 {
+		return []*domain.DocumentAccessEvent{}, nil
+	}
+
+	members, err := r.client.ZRevRangeWithScores(ctx, r.recentKey(userID, action), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*domain.DocumentAccessEvent, 0, len(members))
+	for _, member := range members {
+		value, ok := member.Member.(string)
+		if !ok {
+			continue
+		}
+		documentID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		events = append(events, &domain.DocumentAccessEvent{
+			UserID:     userID,
+			DocumentID: documentID,
+			Action:     action,
+			AccessedAt: time.UnixMilli(int64(member.Score)),
+		})
+	}
+	return events, nil
+}
+
+func (r *DocumentAccessCacheRepository) TakePending(ctx context.Context) ([]*domain.DocumentAccessEvent, error) {
+	// 上次取出的访问仍未确认时不再取出新的访问，保证同一字段的访问按时间顺序写入
+	// 没有新的访问时 pending 不存在，RENAMENX 返回 no such key
+	err := r.client.RenameNX(ctx, DocumentAccessPendingKey, DocumentAccessFlushingKey).Err()
+	if err != nil && !strings.Contains(err.Error(), "no such key") {
+		return nil, err
+	}
+
+	fields, err := r.client.HGetAll(ctx, DocumentAccessFlushingKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*domain.DocumentAccessEvent, 0, len(fields))
+	for field, value := range fields {
+		event, ok := r.parsePending(field, value)
+		if !ok {
+			continue // 格式错误的字段随确认一起删除
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (r *DocumentAccessCacheRepository) AckPending(ctx context.Context) error {
+	return r.client.Del(ctx, DocumentAccessFlushingKey).Err()
+}
+
+func (r *DocumentAccessCacheRepository) recentKey(userID int64, action domain.DocumentAccessAction) string {
+	return fmt.Sprintf("%s%d:%s", DocumentAccessRecentPrefix, userID, action)
+}
+
+func (r *DocumentAccessCacheRepository) pendingField(event *domain.DocumentAccessEvent) string {
+	return fmt.Sprintf("%d:%d:%s", event.UserID, event.DocumentID, event.Action)
+}
+
+// parsePending 解析等待持久化的访问字段和访问时间
+func (r *DocumentAccessCacheRepository) parsePending(field, value string) (*domain.DocumentAccessEvent, bool) {
+	parts := strings.Split(field, ":")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	documentID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	action := domain.DocumentAccessAction(parts[2])
+	if !action.IsValid() {
+		return nil, false
+	}
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	return &domain.DocumentAccessEvent{
+		UserID:     userID,
+		DocumentID: documentID,
+		Action:     action,
+		AccessedAt: time.UnixMilli(millis),
+	}, true
+}
@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"DOC/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// DocumentDuplicateJobPrefix 异步复制任务键前缀，后接任务ID
+const DocumentDuplicateJobPrefix = "document_duplicate:job:"
+
+// DocumentDuplicateJobExpire 复制任务的保留时间，从最后一次更新开始计算
+const DocumentDuplicateJobExpire = 24 * time.Hour
+
+// DocumentDuplicateJobRepository 异步复制任务的 Redis 实现
+// 任务以 JSON 整体存储，每次更新进度时整体覆盖
+type DocumentDuplicateJobRepository struct {
+	client *redis.Client
+}
+
+// NewDocumentDuplicateJobRepository 创建新的复制任务仓储实例
+func NewDocumentDuplicateJobRepository(client *redis.Client) domain.DocumentDuplicateJobRepository {
+	return &DocumentDuplicateJobRepository{
+		client: client,
+	}
+}
+
+func (r *DocumentDuplicateJobRepository) SaveJob(ctx context.Context, job *domain.DocumentDuplicateJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return domain.ErrMarsh
+	}
+	return r.client.Set(ctx, DocumentDuplicateJobPrefix+job.ID, data, DocumentDuplicateJobExpire).Err()
+}
+
+func (r *DocumentDuplicateJobRepository) GetJob(ctx context.Context, jobID string) (*domain.DocumentDuplicateJob, error) {
+	result, err := r.client.Get(ctx, DocumentDuplicateJobPrefix+jobID).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, domain.ErrDuplicateJobNotFound
+		}
+		return nil, err
+	}
+
+	var job domain.DocumentDuplicateJob
+	if err := json.Unmarshal(result, &job); err != nil {
+		return nil, domain.ErrUnMarsh
+	}
+	return &job, nil
+}
@@ -0,0 +1,189 @@
+package redis
+
+import (
+	"DOC/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// WebSocket 消息总线相关键
+const (
+	HubChannel          = "ws_hub"           // 跨实例消息频道
+	HubRoomMemberPrefix = "ws_room_members:" // 房间成员哈希：连接ID -> 成员信息
+	HubNodePrefix       = "ws_node:"         // 实例心跳
+	HubNodeExpire       = 90 * time.Second   // 实例心跳过期时间
+	HubHeartbeatPeriod  = HubNodeExpire / 3  // 实例心跳刷新间隔
+	HubRoomMemberExpire = 24 * time.Hour     // 房间成员哈希过期时间，防止空房间残留
+	hubHeartbeatTimeout = 3 * time.Second    // 心跳写入和注销超时
+)
+
+// HubBackplane 基于 Redis 发布订阅的 Hub 消息总线，房间成员按实例心跳判断是否有效
+type HubBackplane struct {
+	client *redis.Client
+	nodeID string
+}
+
+// NewHubBackplane 创建基于 Redis 发布订阅的 Hub 消息总线
+func NewHubBackplane(client *redis.Client) domain.HubBackplane {
+	return &HubBackplane{
+		client: client,
+		nodeID: uuid.New().String(),
+	}
+}
+
+// NodeID 获取本实例ID
+func (h *HubBackplane) NodeID() string {
+	return h.nodeID
+}
+
+// Publish 以本实例身份发布消息
+func (h *HubBackplane) Publish(ctx context.Context, message *domain.HubMessage) error {
+	message.NodeID = h.nodeID
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return h.client.Publish(ctx, HubChannel, payload).Err()
+}
+
+// Subscribe 订阅其他实例发布的消息并维持本实例心跳，直到 ctx 取消或订阅断开
+func (h *HubBackplane) Subscribe(ctx context.Context, handler func(message *domain.HubMessage)) error {
+	pubsub := h.client.Subscribe(ctx, HubChannel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+	if err := h.heartbeat(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		// 正常退出时立即注销实例，本实例的成员随之失效
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), hubHeartbeatTimeout)
+		defer cancel()
+		h.client.Del(cleanupCtx, generateHubNodeKey(h.nodeID))
+	}()
+
+	ticker := time.NewTicker(HubHeartbeatPeriod)
+	defer ticker.Stop()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-ticker.C:
+			if err := h.heartbeat(ctx); err != nil {
+				log.Printf("刷新实例心跳失败: node=%s, err=%v", h.nodeID, err)
+			}
+
+		case msg, ok := <-messages:
+			if !ok {
+				return fmt.Errorf("hub backplane subscription closed")
+			}
+
+			var message domain.HubMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+				log.Printf("消息总线数据解析失败: %v", err)
+				continue
+			}
+			// 本实例发布的消息已在本地投递
+			if message.NodeID == h.nodeID {
+				continue
+			}
+			handler(&message)
+		}
+	}
+}
+
+// AddMember 将本实例上的连接加入房间成员
+func (h *HubBackplane) AddMember(ctx context.Context, member *domain.HubMember) error {
+	member.NodeID = h.nodeID
+	payload, err := json.Marshal(member)
+	if err != nil {
+		return err
+	}
+
+	key := generateHubRoomMemberKey(member.RoomID)
+	pipe := h.client.TxPipeline()
+	pipe.HSet(ctx, key, member.SocketID, payload)
+	pipe.Expire(ctx, key, HubRoomMemberExpire)
+	// 同时刷新实例心跳，订阅建立前加入的成员也不会被其他实例当作失效
+	pipe.Set(ctx, generateHubNodeKey(h.nodeID), time.Now().Unix(), HubNodeExpire)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RemoveMember 将连接移出房间成员
+func (h *HubBackplane) RemoveMember(ctx context.Context, roomID string, socketID string) error {
+	return h.client.HDel(ctx, generateHubRoomMemberKey(roomID), socketID).Err()
+}
+
+// GetMembers 获取房间成员，心跳已过期实例上的成员会被剔除
+func (h *HubBackplane) GetMembers(ctx context.Context, roomID string) ([]*domain.HubMember, error) {
+	key := generateHubRoomMemberKey(roomID)
+	values, err := h.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*domain.HubMember, 0, len(values))
+	nodes := make(map[string]*redis.IntCmd)
+	pipe := h.client.Pipeline()
+	for socketID, value := range values {
+		var member domain.HubMember
+		if err := json.Unmarshal([]byte(value), &member); err != nil {
+			log.Printf("房间成员数据解析失败: room=%s, socket=%s, err=%v", roomID, socketID, err)
+			continue
+		}
+		members = append(members, &member)
+		if _, exists := nodes[member.NodeID]; !exists {
+			nodes[member.NodeID] = pipe.Exists(ctx, generateHubNodeKey(member.NodeID))
+		}
+	}
+	if len(nodes) == 0 {
+		return members, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	alive := members[:0]
+	var stale []string
+	for _, member := range members {
+		if nodes[member.NodeID].Val() > 0 {
+			alive = append(alive, member)
+		} else {
+			stale = append(stale, member.SocketID)
+		}
+	}
+	if len(stale) > 0 {
+		h.client.HDel(ctx, key, stale...)
+	}
+
+	return alive, nil
+}
+
+// heartbeat 刷新实例心跳
+func (h *HubBackplane) heartbeat(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, hubHeartbeatTimeout)
+	defer cancel()
+	return h.client.Set(ctx, generateHubNodeKey(h.nodeID), time.Now().Unix(), HubNodeExpire).Err()
+}
+
+// generateHubRoomMemberKey 生成房间成员键
+func generateHubRoomMemberKey(roomID string) string {
+	return fmt.Sprintf("%s%s", HubRoomMemberPrefix, roomID)
+}
+
+// generateHubNodeKey 生成实例心跳键
+func generateHubNodeKey(nodeID string) string {
+	return fmt.Sprintf("%s%s", HubNodePrefix, nodeID)
+}
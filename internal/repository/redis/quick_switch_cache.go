@@ -0,0 +1,132 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"DOC/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// 快速切换相关键前缀
+const (
+	QuickSwitchTitlesPrefix   = "quick_switch:titles:"   // 用户的标题索引
+	QuickSwitchAudiencePrefix = "quick_switch:audience:" // 文档上次同步时的可见用户
+)
+
+// QuickSwitchAudienceExpire 可见用户记录的保留时间，过期后下次同步只能使新的可见用户失效，
+// 此时旧的可见用户依靠标题索引自身的过期时间刷新
+const QuickSwitchAudienceExpire = 30 * 24 * time.Hour
+
+// QuickSwitchCacheRepository 快速切换标题索引的 Redis 缓存实现
+// 标题索引以 JSON 整体存储，一次读取即可在内存中完成匹配
+type QuickSwitchCacheRepository struct {
+	client *redis.Client
+}
+
+// NewQuickSwitchCacheRepository 创建新的快速切换缓存实例
+func NewQuickSwitchCacheRepository(client *redis.Client) domain.QuickSwitchCacheRepository {
+	return &QuickSwitchCacheRepository{
+		client: client,
+	}
+}
+
+// GetTitles 获取用户的标题索引，未缓存时返回 nil
+func (r *QuickSwitchCacheRepository) GetTitles(ctx context.Context, userID int64) (*domain.QuickSwitchTitles, error) {
+	result, err := r.client.Get(ctx, r.titlesKey(userID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var titles domain.QuickSwitchTitles
+	if err := json.Unmarshal(result, &titles); err != nil {
+		return nil, domain.ErrUnMarsh
+	}
+	return &titles, nil
+}
+
+// SetTitles 缓存用户的标题索引
+func (r *QuickSwitchCacheRepository) SetTitles(ctx context.Context, userID int64, titles *domain.QuickSwitchTitles, expiration time.Duration) error {
+	data, err := json.Marshal(titles)
+	if err != nil {
+		return domain.ErrMarsh
+	}
+	return r.client.Set(ctx, r.titlesKey(userID), data, expiration).Err()
+}
+
+// DeleteTitles 批量删除用户的标题索引
+func (r *QuickSwitchCacheRepository) DeleteTitles(ctx context.Context, userIDs ...int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = r.titlesKey(userID)
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// GetAudiences 批量获取文档上次同步时的可见用户，没有记录的文档不在结果中
+func (r *QuickSwitchCacheRepository) GetAudiences(ctx context.Context, documentIDs []int64) (map[int64]*domain.QuickSwitchAudience, error) {
+	audiences := make(map[int64]*domain.QuickSwitchAudience)
+	if len(documentIDs) == 0 {
+		return audiences, nil
+	}
+
+	keys := make([]string, len(documentIDs))
+	for i, documentID := range documentIDs {
+		keys[i] = r.audienceKey(documentID)
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // 键不存在
+		}
+		var audience domain.QuickSwitchAudience
+		if err := json.Unmarshal([]byte(data), &audience); err != nil {
+			continue // 损坏的记录视为不存在
+		}
+		audiences[documentIDs[i]] = &audience
+	}
+	return audiences, nil
+}
+
+// SetAudience 记录文档本次同步时的可见用户
+func (r *QuickSwitchCacheRepository) SetAudience(ctx context.Context, documentID int64, audience *domain.QuickSwitchAudience) error {
+	data, err := json.Marshal(audience)
+	if err != nil {
+		return domain.ErrMarsh
+	}
+	return r.client.Set(ctx, r.audienceKey(documentID), data, QuickSwitchAudienceExpire).Err()
+}
+
+// DeleteAudiences 批量删除文档的可见用户记录
+func (r *QuickSwitchCacheRepository) DeleteAudiences(ctx context.Context, documentIDs ...int64) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+	keys := make([]string, len(documentIDs))
+	for i, documentID := range documentIDs {
+		keys[i] = r.audienceKey(documentID)
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+func (r *QuickSwitchCacheRepository) titlesKey(userID int64) string {
+	return fmt.Sprintf("%s%d", QuickSwitchTitlesPrefix, userID)
+}
+
+func (r *QuickSwitchCacheRepository) audienceKey(documentID int64) string {
+	return fmt.Sprintf("%s%d", QuickSwitchAudiencePrefix, documentID)
+}
@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"DOC/domain"
+	"DOC/internal/rest/dto"
+	"DOC/internal/rest/middleware"
+)
+
+// CollaborationHandler 协作历史处理器
+// 提供协作操作的回放（time-lapse），供文档管理者审计每个操作的作者并逐步查看文档的演变
+type CollaborationHandler struct {
+	collaborationService domain.CollaborationUsecase
+}
+
+// NewCollaborationHandler 创建协作历史处理器
+func NewCollaborationHandler(collaborationService domain.CollaborationUsecase) *CollaborationHandler {
+	return &CollaborationHandler{
+		collaborationService: collaborationService,
+	}
+}
+
+// GetOperationHistory 分页获取文档在时间区间内的协作操作
+// GET /api/v1/documents/:id/history/operations
+func (h *CollaborationHandler) GetOperationHistory(c *gin.Context) {
+	// 1. 获取用户ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		ResponseUnauthorized(c, "用户未认证")
+		return
+	}
+
+	// 2. 获取文档ID和查询参数
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	var query dto.OperationHistoryQueryDto
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ResponseBadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		ResponseBadRequest(c, "开始时间必须早于结束时间")
+		return
+	}
+
+	// 3. 获取操作历史
+	history, err := h.collaborationService.GetOperationHistory(c.Request.Context(), param.ID, userID, query.From, query.To, query.AfterSeq, query.Limit)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	ResponseOK(c, "Success", history)
+}
+
+// GetContentAtSeq 获取文档在指定操作序号时的内容
+// GET /api/v1/documents/:id/history/content
+func (h *CollaborationHandler) GetContentAtSeq(c *gin.Context) {
+	// 1. 获取用户ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		ResponseUnauthorized(c, "用户未认证")
+		return
+	}
+
+	// 2. 获取文档ID和查询参数
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	var query dto.ContentAtSeqQueryDto
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ResponseBadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+
+	// 3. 重建历史内容
+	snapshot, err := h.collaborationService.GetContentAtSeq(c.Request.Context(), param.ID, userID, *query.Seq)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	ResponseOK(c, "Success", snapshot)
+}
+
+// handleError 将协作历史相关错误转换为 HTTP 响应
+func (h *CollaborationHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrDocumentNotFound):
+		ResponseNotFound(c, "文档不存在")
+	case errors.Is(err, domain.ErrCollaborationPermissionDenied):
+		ResponseForbidden(c, "权限不足，需要文档管理权限")
+	case errors.Is(err, domain.ErrCollaborationSessionNotFound):
+		ResponseNotFound(c, "文档没有协作记录")
+	case errors.Is(err, domain.ErrInvalidCollaborationSeq):
+		ResponseBadRequest(c, "操作序号超出范围")
+	case errors.Is(err, domain.ErrCollaborationOpsCompacted):
+		Response(c, http.StatusGone, "该位置的历史操作已超过保留期被清理", nil)
+	default:
+		ResponseInternalServerError(c, "服务器内部错误")
+	}
+}
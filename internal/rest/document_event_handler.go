@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"DOC/domain"
+	"DOC/internal/rest/dto"
+	"DOC/internal/rest/middleware"
+)
+
+const (
+	// 事件流心跳间隔，防止代理因连接空闲而断开
+	eventStreamHeartbeat = 15 * time.Second
+
+	// 事件流重新校验访问权限的间隔，权限被收回或分享失效后结束事件流
+	eventStreamRecheck = time.Minute
+
+	// 访问权限失效时发送的事件
+	eventAccessRevoked = "access_revoked"
+)
+
+// DocumentEventHandler 文档事件流处理器
+// 以 Server-Sent Events 推送文档的只读事件，供无法建立 WebSocket 连接的看板、嵌入式查看器和分享链接读者使用
+type DocumentEventHandler struct {
+	aggregateService domain.DocumentAggregateUsecase
+	feed             domain.DocumentEventFeed
+}
+
+// NewDocumentEventHandler 创建文档事件流处理器
+func NewDocumentEventHandler(aggregateService domain.DocumentAggregateUsecase, feed domain.DocumentEventFeed) *DocumentEventHandler {
+	return &DocumentEventHandler{
+		aggregateService: aggregateService,
+		feed:             feed,
+	}
+}
+
+// StreamDocumentEvents 订阅文档事件流，需要文档查看权限
+// GET /api/v1/documents/:id/events
+func (h *DocumentEventHandler) StreamDocumentEvents(c *gin.Context) {
+	// 1. 获取用户ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		ResponseUnauthorized(c, "用户未认证")
+		return
+	}
+
+	// 2. 获取文档ID参数
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 3. 检查查看权限，事件流期间定期重新检查
+	checkAccess := func(ctx context.Context) error {
+		_, err := h.aggregateService.CheckDocumentAccess(ctx, userID, param.ID, domain.PermissionView)
+		return err
+	}
+	if err := checkAccess(c.Request.Context()); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// 4. 推送事件
+	h.stream(c, param.ID, checkAccess)
+}
+
+// StreamSharedDocumentEvents 通过分享链接订阅文档事件流
+// GET /api/v1/documents/shared/:linkId/events
+func (h *DocumentEventHandler) StreamSharedDocumentEvents(c *gin.Context) {
+	// 1. 获取分享链接参数
+	var param dto.ShareLinkParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的分享链接")
+		return
+	}
+	password := c.Query("password")
+
+	// 2. 验证分享访问，事件流期间定期重新验证
+	share, err := h.aggregateService.ValidateShareAccess(c.Request.Context(), param.LinkID, password)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	checkAccess := func(ctx context.Context) error {
+		_, err := h.aggregateService.ValidateShareAccess(ctx, param.LinkID, password)
+		return err
+	}
+
+	// 3. 推送事件
+	h.stream(c, share.DocumentID, checkAccess)
+}
+
+// stream 订阅文档事件并以 SSE 格式推送，直到客户端断开、文档被删除或访问权限失效
+func (h *DocumentEventHandler) stream(c *gin.Context, documentID int64, checkAccess func(ctx context.Context) error) {
+	ctx := c.Request.Context()
+	events, err := h.feed.SubscribeDocument(ctx, documentID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁止 Nginx 缓冲
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	recheck := time.NewTicker(eventStreamRecheck)
+	defer recheck.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(string(event.Type), event)
+			return event.Type != domain.DocumentEventDeleted
+
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+
+		case <-recheck.C:
+			if err := checkAccess(ctx); err != nil {
+				c.SSEvent(eventAccessRevoked, gin.H{"document_id": documentID})
+				return false
+			}
+			return true
+		}
+	})
+}
+
+// handleError 将订阅前的业务错误转换为HTTP响应
+func (h *DocumentEventHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrDocumentNotFound):
+		ResponseNotFound(c, "文档不存在")
+	case errors.Is(err, domain.ErrPermissionDenied):
+		ResponseForbidden(c, "权限不足")
+	case errors.Is(err, domain.ErrShareLinkNotFound):
+		ResponseNotFound(c, "分享链接不存在")
+	case errors.Is(err, domain.ErrShareLinkExpired):
+		ResponseNotFound(c, "分享链接已过期")
+	case errors.Is(err, domain.ErrInvalidSharePassword):
+		ResponseForbidden(c, "分享密码错误")
+	case errors.Is(err, domain.ErrDocumentFeedUnavailable):
+		ResponseServiceUnavailable(c, "事件流暂不可用，请稍后重试")
+	default:
+		ResponseInternalServerError(c, "服务器内部错误")
+	}
+}
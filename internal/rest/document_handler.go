@@ -4,6 +4,9 @@ import (
 	"DOC/internal/rest/middleware"
 	"encoding/json"
 	"errors"
+	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
@@ -94,6 +97,7 @@ func (h *DocumentHandler) GetDocument(c *gin.Context) {
 	}
 
 	// 4. 返回文档信息
+	setDocumentETag(c, document.Version)
 	ResponseOK(c, "Success", document)
 }
 
@@ -120,6 +124,11 @@ func (h *DocumentHandler) UpdateDocument(c *gin.Context) {
 		//c.JSON(http.StatusBadRequest, dto.ErrorResponse("请求参数无效: "+err.Error(), "INVALID_PARAMS"))
 		return
 	}
+	expectedVersion, ok := parseIfMatch(c)
+	if !ok {
+		ResponseBadRequest(c, "If-Match 请求头无效")
+		return
+	}
 
 	// 3. 调用业务服务更新文档
 	document, err := h.aggregateService.UpdateDocument(
@@ -131,6 +140,7 @@ func (h *DocumentHandler) UpdateDocument(c *gin.Context) {
 		req.ParentID,
 		req.SortOrder,
 		req.IsStarred,
+		expectedVersion,
 	)
 
 	if err != nil {
@@ -139,6 +149,7 @@ func (h *DocumentHandler) UpdateDocument(c *gin.Context) {
 	}
 
 	// 4. 返回更新后的文档
+	setDocumentETag(c, document.Version)
 	ResponseOK(c, "Success", document)
 }
 
@@ -164,10 +175,15 @@ func (h *DocumentHandler) DeleteDocument(c *gin.Context) {
 	// 3. 调用业务服务删除文档
 	var err error
 	if permanent {
-		// 永久删除（暂未实现在领域服务中，这里作为扩展）
-		err = h.aggregateService.DeleteDocument(c.Request.Context(), userID, param.ID)
+		// 永久删除，未在回收站中的文档先移入回收站
+		err = h.aggregateService.DeleteDocumentPermanently(c.Request.Context(), userID, param.ID)
+		if errors.Is(err, domain.ErrDocumentNotInTrash) {
+			if err = h.aggregateService.DeleteDocument(c.Request.Context(), userID, param.ID); err == nil {
+				err = h.aggregateService.DeleteDocumentPermanently(c.Request.Context(), userID, param.ID)
+			}
+		}
 	} else {
-		// 软删除
+		// 软删除，移入回收站
 		err = h.aggregateService.DeleteDocument(c.Request.Context(), userID, param.ID)
 	}
 
@@ -180,6 +196,115 @@ func (h *DocumentHandler) DeleteDocument(c *gin.Context) {
 	ResponseOK(c, "Success", nil)
 }
 
+// ArchiveDocument 归档文档，文件夹连同其子孙文档一起归档
+// POST /api/v1/documents/:id/archive
+func (h *DocumentHandler) ArchiveDocument(c *gin.Context) {
+	h.setArchived(c, true)
+}
+
+// UnarchiveDocument 取消归档文档
+// POST /api/v1/documents/:id/unarchive
+func (h *DocumentHandler) UnarchiveDocument(c *gin.Context) {
+	h.setArchived(c, false)
+}
+
+// setArchived 归档或取消归档文档，返回更新后的文档
+func (h *DocumentHandler) setArchived(c *gin.Context, archived bool) {
+	// 1. 获取用户ID和文档ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 2. 调用业务服务归档或取消归档
+	var document *domain.Document
+	var err error
+	if archived {
+		document, err = h.aggregateService.ArchiveDocument(c.Request.Context(), userID, param.ID)
+	} else {
+		document, err = h.aggregateService.UnarchiveDocument(c.Request.Context(), userID, param.ID)
+	}
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 3. 返回更新后的文档
+	setDocumentETag(c, document.Version)
+	ResponseOK(c, "Success", dto.FromDocument(document))
+}
+
+// DuplicateDocument 复制文档，文件夹连同子孙文档一起复制
+// POST /api/v1/documents/:id/duplicate
+// 文档较少时同步复制并返回副本，否则返回 202 和异步任务，客户端通过任务查询进度
+func (h *DocumentHandler) DuplicateDocument(c *gin.Context) {
+	// 1. 获取用户ID和文档ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 2. 绑定复制参数，请求体可以为空
+	var req dto.DuplicateDocumentDto
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			ResponseBadRequest(c, "复制参数无效"+err.Error())
+			return
+		}
+	}
+
+	// 3. 调用业务服务复制文档
+	result, err := h.aggregateService.DuplicateDocument(c.Request.Context(), userID, param.ID, req.ToOptions())
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 4. 同步复制返回副本，异步复制返回任务
+	if result.Job != nil {
+		Response(c, http.StatusAccepted, "Accepted", dto.FromDuplicateResult(result))
+		return
+	}
+	ResponseCreated(c, "Created", dto.FromDuplicateResult(result))
+}
+
+// GetDuplicateJob 获取异步复制任务的进度
+// GET /api/v1/documents/duplicate-jobs/:jobId
+func (h *DocumentHandler) GetDuplicateJob(c *gin.Context) {
+	// 1. 获取用户ID和任务ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.DuplicateJobParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的任务ID")
+		return
+	}
+
+	// 2. 调用业务服务获取任务
+	job, err := h.aggregateService.GetDuplicateJob(c.Request.Context(), userID, param.JobID)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	ResponseOK(c, "Success", dto.FromDuplicateJob(job))
+}
+
 // === 文档内容操作处理器 ===
 
 // GetDocumentContent 获取文档内容
@@ -199,7 +324,7 @@ func (h *DocumentHandler) GetDocumentContent(c *gin.Context) {
 	}
 
 	// 2. 调用业务服务获取内容
-	content, err := h.aggregateService.GetDocumentContent(c.Request.Context(), userID, param.ID)
+	content, version, err := h.aggregateService.GetDocumentContent(c.Request.Context(), userID, param.ID)
 	if err != nil {
 		h.handleBusinessError(c, err)
 		return
@@ -214,6 +339,7 @@ func (h *DocumentHandler) GetDocumentContent(c *gin.Context) {
 	}
 
 	// 4. 返回内容
+	setDocumentETag(c, version)
 	ResponseOK(c, "Success", jsonContent)
 }
 
@@ -240,13 +366,19 @@ func (h *DocumentHandler) UpdateDocumentContent(c *gin.Context) {
 		//c.JSON(http.StatusBadRequest, dto.ErrorResponse("请求参数无效: "+err.Error(), "INVALID_PARAMS"))
 		return
 	}
+	expectedVersion, ok := parseIfMatch(c)
+	if !ok {
+		ResponseBadRequest(c, "If-Match 请求头无效")
+		return
+	}
 
 	// 3. 调用业务服务更新内容
-	err := h.aggregateService.UpdateDocumentContent(
+	version, err := h.aggregateService.UpdateDocumentContent(
 		c.Request.Context(),
 		userID,
 		param.ID,
 		req.GetContentString(),
+		expectedVersion,
 	)
 
 	if err != nil {
@@ -254,6 +386,40 @@ func (h *DocumentHandler) UpdateDocumentContent(c *gin.Context) {
 		return
 	}
 
+	// 4. 返回成功响应
+	setDocumentETag(c, version)
+	ResponseOK(c, "Success", nil)
+}
+
+// SetCollaborationMode 设置文档的实时协作模式
+// PUT /api/v1/documents/:id/collaboration-mode
+func (h *DocumentHandler) SetCollaborationMode(c *gin.Context) {
+	// 1. 获取用户ID和文档ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 2. 绑定请求参数
+	var req dto.SetCollaborationModeDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseBadRequest(c, "请求参数无效"+err.Error())
+		return
+	}
+
+	// 3. 调用业务服务设置协作模式
+	err := h.aggregateService.SetCollaborationMode(c.Request.Context(), userID, param.ID, domain.CollaborationMode(req.Mode))
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
 	// 4. 返回成功响应
 	ResponseOK(c, "Success", nil)
 }
@@ -283,6 +449,7 @@ func (h *DocumentHandler) GetMyDocuments(c *gin.Context) {
 		userID,
 		query.ParentID,
 		query.IncludeDeleted,
+		query.IncludeArchived,
 	)
 
 	if err != nil {
@@ -323,35 +490,93 @@ func (h *DocumentHandler) SearchDocuments(c *gin.Context) {
 		query.Limit = 20
 	}
 
-	// 4. 调用业务服务搜索文档
-	results, err := h.aggregateService.SearchDocuments(
-		c.Request.Context(),
-		userID,
-		query.Keyword,
-		query.ToDocumentType(),
-		query.Limit,
-		query.Offset,
-	)
+	filter, err := query.ToFilter()
+	if err != nil {
+		ResponseBadRequest(c, "时间范围格式无效")
+		return
+	}
+	if strings.TrimSpace(filter.Keyword) == "" && !filter.HasFilters() {
+		ResponseBadRequest(c, "搜索关键词和过滤条件不能都为空")
+		return
+	}
 
+	// 4. 调用业务服务搜索文档
+	page, err := h.aggregateService.SearchDocuments(c.Request.Context(), userID, filter)
 	if err != nil {
 		h.handleBusinessError(c, err)
 		return
 	}
 
 	// 5. 转换为响应DTO
-	searchDTOs := make([]*dto.DocumentSearchItemDto, len(results))
-	for i, result := range results {
+	searchDTOs := make([]*dto.DocumentSearchItemDto, len(page.Results))
+	for i, result := range page.Results {
 		searchDTOs[i] = dto.FromDocumentSearchResult(result)
 	}
 
-	// 6. 返回搜索结果
-	// todo 确认返回结构
-	ResponseOK(c, "Success", searchDTOs)
-	//c.JSON(http.StatusOK, dto.SuccessResponse(&dto.DocumentSearchResponseDto{
-	//	Documents: searchDTOs,
-	//	Total:     len(searchDTOs),
-	//	Keyword:   query.Keyword,
-	//}))
+	// 6. 返回搜索结果和分面统计
+	ResponseOK(c, "Success", &dto.DocumentSearchResponseDto{
+		Documents: searchDTOs,
+		Total:     page.Total,
+		Keyword:   query.Keyword,
+		Facets:    page.Facets,
+	})
+}
+
+// GetRecentDocuments 获取最近打开、编辑以及被共享的文档
+// GET /api/v1/documents/recent
+func (h *DocumentHandler) GetRecentDocuments(c *gin.Context) {
+	// 1. 获取用户ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	// 2. 绑定查询参数
+	var query dto.RecentDocumentsQueryDto
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ResponseBadRequest(c, "查询参数无效"+err.Error())
+		return
+	}
+
+	// 3. 获取最近文档，数量由服务限制在允许范围内
+	recent, err := h.aggregateService.GetRecentDocuments(c.Request.Context(), userID, query.Limit)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 4. 转换为响应DTO
+	recentDTOs := make([]*dto.RecentDocumentDto, len(recent))
+	for i, item := range recent {
+		recentDTOs[i] = dto.FromRecentDocument(item)
+	}
+	ResponseOK(c, "Success", recentDTOs)
+}
+
+// QuickSwitch 按标题快速查找文档
+// GET /api/v1/documents/quick-switch
+func (h *DocumentHandler) QuickSwitch(c *gin.Context) {
+	// 1. 获取用户ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	// 2. 绑定查询参数
+	var query dto.QuickSwitchQueryDto
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ResponseBadRequest(c, "查询参数无效"+err.Error())
+		return
+	}
+
+	// 3. 匹配标题，数量由服务限制在允许范围内
+	results, err := h.aggregateService.QuickSwitch(c.Request.Context(), userID, query.Q, query.Limit)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	ResponseOK(c, "Success", results)
 }
 
 // === 文档分享操作处理器 ===
@@ -427,12 +652,14 @@ func (h *DocumentHandler) GetSharedDocument(c *gin.Context) {
 	// 2. 获取密码参数
 	password := c.Query("password")
 
-	// 3. 获取访问IP
+	// 3. 获取访问IP和已登录的访问者，访问者用于记录最近访问
 	accessIP := c.ClientIP()
+	userID, _ := middleware.GetCurrentUserID(c)
 
 	// 4. 调用业务服务获取分享文档
 	accessInfo, err := h.aggregateService.GetSharedDocument(
 		c.Request.Context(),
+		userID,
 		param.LinkID,
 		password,
 		accessIP,
@@ -735,7 +962,11 @@ func (h *DocumentHandler) CheckDocumentAccess(c *gin.Context) {
 
 // handleBusinessError 处理业务错误，将领域错误转换为HTTP响应
 func (h *DocumentHandler) handleBusinessError(c *gin.Context, err error) {
+	var modified *domain.DocumentModifiedError
 	switch {
+	case errors.As(err, &modified):
+		setDocumentETag(c, modified.CurrentVersion)
+		ResponsePreconditionFailed(c, "文档已被修改", dto.DocumentModifiedResponseDto{CurrentVersion: modified.CurrentVersion})
 	case errors.Is(err, domain.ErrDocumentNotFound):
 		ResponseNotFound(c, "权限不足")
 	case errors.Is(err, domain.ErrPermissionDenied):
@@ -759,9 +990,65 @@ func (h *DocumentHandler) handleBusinessError(c *gin.Context, err error) {
 	case errors.Is(err, domain.ErrBatchSizeExceeded):
 		ResponseBadRequest(c, "批量操作数量超限")
 		//c.JSON(http.StatusBadRequest, dto.ErrorResponse("批量操作数量超限", "BATCH_SIZE_EXCEEDED"))
+	case errors.Is(err, domain.ErrInvalidCollaborationMode):
+		ResponseBadRequest(c, "协作模式无效")
+	case errors.Is(err, domain.ErrDocumentVersionNotFound):
+		ResponseNotFound(c, "文档版本不存在")
+	case errors.Is(err, domain.ErrInvalidDocumentVersionName):
+		ResponseBadRequest(c, "版本名称无效")
+	case errors.Is(err, domain.ErrDocumentVersionViewNotFound):
+		ResponseNotFound(c, "尚未标记最后查看的版本")
+	case errors.Is(err, domain.ErrInvalidDiffGranularity):
+		ResponseBadRequest(c, "差异粒度无效")
+	case errors.Is(err, domain.ErrDocumentArchived):
+		ResponseConflict(c, "文档已归档，只读")
+	case errors.Is(err, domain.ErrDocumentCollaborating):
+		ResponseConflict(c, "文档正在协作编辑，请稍后重试")
+	case errors.Is(err, domain.ErrParentDocumentArchived):
+		ResponseConflict(c, "父文件夹已归档")
+	case errors.Is(err, domain.ErrDocumentNotInTrash):
+		ResponseBadRequest(c, "文档不在回收站中")
+	case errors.Is(err, domain.ErrSpaceNotFound):
+		ResponseNotFound(c, "空间不存在")
+	case errors.Is(err, domain.ErrNotSpaceMember):
+		ResponseForbidden(c, "不是空间成员")
+	case errors.Is(err, domain.ErrSpacePermissionDenied):
+		ResponseForbidden(c, "没有空间的编辑权限")
+	case errors.Is(err, domain.ErrDuplicateIntoSelf):
+		ResponseBadRequest(c, "不能复制到自身或其子文件夹中")
+	case errors.Is(err, domain.ErrDuplicateJobNotFound):
+		ResponseNotFound(c, "复制任务不存在或已过期")
+	case errors.Is(err, domain.ErrDocumentTreeTooDeep):
+		ResponseBadRequest(c, "文档层级超过上限")
+	case errors.Is(err, domain.ErrBadParamInput):
+		ResponseBadRequest(c, "参数无效")
 	default:
 		// 记录未知错误（在实际项目中应该使用日志库）
 		ResponseInternalServerError(c, "服务器内部错误")
 		//c.JSON(http.StatusInternalServerError, dto.ErrorResponse("服务器内部错误", "INTERNAL_ERROR"))
 	}
 }
+
+// setDocumentETag 以文档版本号设置 ETag 响应头
+func setDocumentETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// parseIfMatch 解析 If-Match 请求头中的文档版本号
+// 未携带或为 * 时返回 0 表示不校验版本；弱校验前缀 W/ 会被忽略，以兼容压缩代理改写的 ETag
+func parseIfMatch(c *gin.Context) (int64, bool) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return 0, true
+	}
+
+	tag, err := strconv.Unquote(strings.TrimPrefix(value, "W/"))
+	if err != nil {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
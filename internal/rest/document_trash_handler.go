@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"DOC/internal/rest/dto"
+	"DOC/internal/rest/middleware"
+)
+
+// === 回收站操作处理器 ===
+
+// ListTrash 分页获取回收站条目
+// GET /api/v1/documents/trash
+func (h *DocumentHandler) ListTrash(c *gin.Context) {
+	// 1. 获取用户ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	// 2. 绑定查询参数
+	var query dto.DocumentTrashQueryDto
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ResponseBadRequest(c, "查询参数无效"+err.Error())
+		return
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+
+	// 3. 调用业务服务获取回收站条目
+	items, total, err := h.aggregateService.ListTrash(c.Request.Context(), userID, query.SpaceID, query.Page, query.PageSize)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 4. 转换为响应DTO
+	itemDTOs := make([]*dto.DocumentTrashItemDto, len(items))
+	for i, item := range items {
+		itemDTOs[i] = dto.FromDocumentTrashItem(item)
+	}
+
+	ResponseOK(c, "Success", &dto.DocumentTrashListResponseDto{
+		Items: itemDTOs,
+		Total: total,
+		Page:  query.Page,
+		Size:  len(itemDTOs),
+	})
+}
+
+// RestoreFromTrash 从回收站恢复文档及其子树
+// POST /api/v1/documents/:id/restore
+func (h *DocumentHandler) RestoreFromTrash(c *gin.Context) {
+	// 1. 获取用户ID和文档ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 2. 调用业务服务恢复文档
+	document, err := h.aggregateService.RestoreFromTrash(c.Request.Context(), userID, param.ID)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 3. 返回恢复后的文档，原父文件夹不存在时 parent_id 为空
+	setDocumentETag(c, document.Version)
+	ResponseOK(c, "Success", document)
+}
@@ -0,0 +1,270 @@
+package rest
+
+import (
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	"DOC/domain"
+	"DOC/internal/rest/dto"
+	"DOC/internal/rest/middleware"
+)
+
+// === 文档版本操作处理器 ===
+
+// ListDocumentVersions 分页获取文档的历史版本
+// GET /api/v1/documents/:id/versions
+func (h *DocumentHandler) ListDocumentVersions(c *gin.Context) {
+	// 1. 获取用户ID和文档ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 2. 绑定查询参数
+	var query dto.DocumentVersionListQueryDto
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ResponseBadRequest(c, "查询参数无效"+err.Error())
+		return
+	}
+	if query.Page == 0 {
+		query.Page = 1
+	}
+
+	// 3. 调用业务服务获取版本列表
+	versions, total, err := h.aggregateService.ListDocumentVersions(c.Request.Context(), userID, param.ID, query.NamedOnly, query.Page, query.PageSize)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 4. 转换为响应DTO
+	versionDTOs := make([]*dto.DocumentVersionResponseDto, len(versions))
+	for i, version := range versions {
+		versionDTOs[i] = dto.FromDocumentVersion(version)
+	}
+
+	ResponseOK(c, "Success", &dto.DocumentVersionListResponseDto{
+		Versions: versionDTOs,
+		Total:    total,
+		Page:     query.Page,
+		Size:     len(versionDTOs),
+	})
+}
+
+// GetDocumentVersion 获取文档的指定版本，包含内容
+// GET /api/v1/documents/:id/versions/:versionId
+func (h *DocumentHandler) GetDocumentVersion(c *gin.Context) {
+	// 1. 获取用户ID和版本参数
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.DocumentVersionParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档或版本ID")
+		return
+	}
+
+	// 2. 调用业务服务获取版本
+	version, err := h.aggregateService.GetDocumentVersion(c.Request.Context(), userID, param.ID, param.VersionID)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 3. 返回版本详情
+	ResponseOK(c, "Success", dto.FromDocumentVersion(version))
+}
+
+// CreateNamedVersion 以文档当前内容创建命名版本
+// POST /api/v1/documents/:id/versions
+func (h *DocumentHandler) CreateNamedVersion(c *gin.Context) {
+	// 1. 获取用户ID和文档ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 2. 绑定请求参数
+	var req dto.CreateNamedVersionDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseBadRequest(c, "请求参数无效"+err.Error())
+		return
+	}
+
+	// 3. 调用业务服务创建版本
+	version, err := h.aggregateService.CreateNamedVersion(c.Request.Context(), userID, param.ID, req.Name)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 4. 返回新版本
+	ResponseCreated(c, "Created", dto.FromDocumentVersion(version))
+}
+
+// NameDocumentVersion 为已有版本设置或取消名称
+// PUT /api/v1/documents/:id/versions/:versionId
+func (h *DocumentHandler) NameDocumentVersion(c *gin.Context) {
+	// 1. 获取用户ID和版本参数
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.DocumentVersionParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档或版本ID")
+		return
+	}
+
+	// 2. 绑定请求参数
+	var req dto.NameDocumentVersionDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseBadRequest(c, "请求参数无效"+err.Error())
+		return
+	}
+
+	// 3. 调用业务服务更新版本名称
+	version, err := h.aggregateService.NameDocumentVersion(c.Request.Context(), userID, param.ID, param.VersionID, req.Name)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 4. 返回更新后的版本
+	ResponseOK(c, "Success", dto.FromDocumentVersion(version))
+}
+
+// RestoreDocumentVersion 将文档恢复为历史版本，返回新记录的恢复版本
+// POST /api/v1/documents/:id/versions/:versionId/restore
+func (h *DocumentHandler) RestoreDocumentVersion(c *gin.Context) {
+	// 1. 获取用户ID和版本参数
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.DocumentVersionParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档或版本ID")
+		return
+	}
+
+	// 2. 调用业务服务恢复版本
+	version, err := h.aggregateService.RestoreDocumentVersion(c.Request.Context(), userID, param.ID, param.VersionID)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 3. 返回恢复版本
+	ResponseOK(c, "Success", dto.FromDocumentVersion(version))
+}
+
+// DiffDocumentVersions 对比文档的两个版本
+// GET /api/v1/documents/:id/diff?from=&to=&granularity=
+func (h *DocumentHandler) DiffDocumentVersions(c *gin.Context) {
+	// 1. 获取用户ID和文档ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 2. 绑定查询参数
+	var query dto.DocumentDiffQueryDto
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ResponseBadRequest(c, "查询参数无效"+err.Error())
+		return
+	}
+
+	// 3. 调用业务服务对比版本
+	diff, err := h.aggregateService.DiffDocumentVersions(c.Request.Context(), userID, param.ID, query.From, query.To, domain.DiffGranularity(query.Granularity))
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 4. 返回差异
+	ResponseOK(c, "Success", dto.FromDocumentDiff(diff))
+}
+
+// GetLastViewedVersion 获取用户最后查看的版本
+// GET /api/v1/documents/:id/last-viewed
+func (h *DocumentHandler) GetLastViewedVersion(c *gin.Context) {
+	// 1. 获取用户ID和文档ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 2. 调用业务服务获取最后查看记录
+	view, err := h.aggregateService.GetLastViewedDocumentVersion(c.Request.Context(), userID, param.ID)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 3. 返回最后查看记录
+	ResponseOK(c, "Success", view)
+}
+
+// MarkDocumentVersionViewed 标记用户最后查看的版本，请求体为空时标记文档当前内容
+// PUT /api/v1/documents/:id/last-viewed
+func (h *DocumentHandler) MarkDocumentVersionViewed(c *gin.Context) {
+	// 1. 获取用户ID和文档ID
+	userID, exist := middleware.GetCurrentUserID(c)
+	if userID == 0 || !exist {
+		return
+	}
+
+	var param dto.IDParamDto
+	if err := c.ShouldBindUri(&param); err != nil {
+		ResponseBadRequest(c, "无效的文档ID")
+		return
+	}
+
+	// 2. 绑定请求参数，请求体可以为空
+	var req dto.MarkVersionViewedDto
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ResponseBadRequest(c, "请求参数无效"+err.Error())
+		return
+	}
+
+	// 3. 调用业务服务标记最后查看的版本
+	view, err := h.aggregateService.MarkDocumentVersionViewed(c.Request.Context(), userID, param.ID, req.VersionID)
+	if err != nil {
+		h.handleBusinessError(c, err)
+		return
+	}
+
+	// 4. 返回最后查看记录
+	ResponseOK(c, "Success", view)
+}
@@ -0,0 +1,19 @@
+package dto
+
+import "time"
+
+// === 协作历史回放相关的DTO定义 ===
+
+// OperationHistoryQueryDto 协作操作历史查询参数
+// 时间为 RFC3339 格式，区间为 [from, to)，不传表示不限制；after_seq 为上一页返回的 next_seq
+type OperationHistoryQueryDto struct {
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"` // 开始时间（含）
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`   // 结束时间（不含）
+	AfterSeq int64     `form:"after_seq" binding:"omitempty,min=0"`          // 从该序号之后开始
+	Limit    int       `form:"limit" binding:"omitempty,min=1,max=500"`      // 每页操作数，默认 100
+}
+
+// ContentAtSeqQueryDto 历史内容查询参数
+type ContentAtSeqQueryDto struct {
+	Seq *int64 `form:"seq" binding:"required,min=0"` // 操作序号，0 表示协作会话开始时的内容
+}
@@ -138,15 +138,21 @@ type ShareLinkParamDto struct {
 	LinkID string `uri:"linkId" binding:"required"` // 分享链接ID参数
 }
 
+// DuplicateJobParamDto 复制任务路径参数DTO
+type DuplicateJobParamDto struct {
+	JobID string `uri:"jobId" binding:"required"` // 复制任务ID参数
+}
+
 // === 查询参数DTO ===
 
 // DocumentQueryDto 文档查询参数DTO
 type DocumentQueryDto struct {
-	ParentID       *int64  `form:"parent_id,omitempty"`                                                                 // 父文件夹ID
-	SpaceID        *int64  `form:"space_id,omitempty"`                                                                  // 空间ID
-	IncludeDeleted bool    `form:"include_deleted,omitempty"`                                                           // 是否包含已删除的文档
-	Type           *string `form:"type,omitempty" validate:"omitempty,oneof=FILE FOLDER"`                               // 文档类型过滤
-	SortBy         string  `form:"sort_by,omitempty" validate:"omitempty,oneof=title created_at updated_at sort_order"` // 排序字段
-	SortOrder      string  `form:"sort_order,omitempty" validate:"omitempty,oneof=asc desc"`                            // 排序方向
-	PaginationDto          // 嵌入分页参数
+	ParentID        *int64  `form:"parent_id,omitempty"`                                                                 // 父文件夹ID
+	SpaceID         *int64  `form:"space_id,omitempty"`                                                                  // 空间ID
+	IncludeDeleted  bool    `form:"include_deleted,omitempty"`                                                           // 是否包含已删除的文档
+	IncludeArchived bool    `form:"include_archived,omitempty"`                                                          // 是否包含已归档的文档
+	Type            *string `form:"type,omitempty" validate:"omitempty,oneof=FILE FOLDER"`                               // 文档类型过滤
+	SortBy          string  `form:"sort_by,omitempty" validate:"omitempty,oneof=title created_at updated_at sort_order"` // 排序字段
+	SortOrder       string  `form:"sort_order,omitempty" validate:"omitempty,oneof=asc desc"`                            // 排序方向
+	PaginationDto           // 嵌入分页参数
 }
@@ -71,6 +71,17 @@ func (dto *UpdateDocumentContentDto) GetContentString() string {
 	return string(dto.Content)
 }
 
+// DocumentModifiedResponseDto 文档版本冲突响应DTO
+// If-Match 与文档当前版本不一致时返回，客户端应基于当前版本重新合并后再提交
+type DocumentModifiedResponseDto struct {
+	CurrentVersion int64 `json:"current_version"` // 文档当前的版本号
+}
+
+// SetCollaborationModeDto 设置文档协作模式请求DTO
+type SetCollaborationModeDto struct {
+	Mode string `json:"mode" binding:"required,oneof=ot yjs"` // 协作模式：ot 为 JSON 操作流，yjs 为 Yjs 二进制同步
+}
+
 // ShareDocumentDto 分享文档请求DTO
 // 对应API规范中的ShareDocumentDto
 type ShareDocumentDto struct {
@@ -98,12 +109,58 @@ func (dto *ShareDocumentDto) GetExpiresAt() (*time.Time, error) {
 	return &t, nil
 }
 
+// DuplicateDocumentDto 复制文档请求DTO
+// 未指定目标父文件夹和目标空间时复制到原文档所在的位置
+type DuplicateDocumentDto struct {
+	Title           string `json:"title,omitempty" validate:"omitempty,max=255"` // 副本标题，为空时为原标题加“ - 副本”
+	TargetParentID  *int64 `json:"target_parent_id,omitempty"`                   // 目标父文件夹ID
+	TargetSpaceID   *int64 `json:"target_space_id,omitempty"`                    // 目标空间ID
+	CopyPermissions bool   `json:"copy_permissions,omitempty"`                   // 是否复制其他用户的授权
+	CopyFavorites   bool   `json:"copy_favorites,omitempty"`                     // 是否复制自己的收藏
+}
+
+// ToOptions 转换为领域模型的复制选项
+func (dto *DuplicateDocumentDto) ToOptions() *domain.DocumentDuplicateOptions {
+	return &domain.DocumentDuplicateOptions{
+		NewTitle:        dto.Title,
+		TargetParentID:  dto.TargetParentID,
+		TargetSpaceID:   dto.TargetSpaceID,
+		CopyPermissions: dto.CopyPermissions,
+		CopyFavorites:   dto.CopyFavorites,
+	}
+}
+
 // DocumentSearchQueryDto 文档搜索查询DTO
+// 关键词为空时需要至少设置一个过滤条件，时间范围使用 RFC3339 格式并包含边界
 type DocumentSearchQueryDto struct {
-	Keyword string  `form:"keyword" binding:"required" validate:"required,min=1"`            // 搜索关键词
-	Type    *string `form:"type,omitempty" validate:"omitempty,oneof=FILE FOLDER"`           // 文档类型过滤
-	Limit   int     `form:"limit,omitempty" validate:"omitempty,min=1,max=100" default:"20"` // 每页数量
-	Offset  int     `form:"offset,omitempty" validate:"omitempty,min=0" default:"0"`         // 偏移量
+	Keyword         string  `form:"keyword,omitempty"`                                               // 搜索关键词
+	Type            *string `form:"type,omitempty" validate:"omitempty,oneof=FILE FOLDER"`           // 文档类型过滤
+	IncludeArchived bool    `form:"include_archived,omitempty"`                                      // 是否包含已归档的文档
+	Limit           int     `form:"limit,omitempty" validate:"omitempty,min=1,max=100" default:"20"` // 每页数量
+	Offset          int     `form:"offset,omitempty" validate:"omitempty,min=0" default:"0"`         // 偏移量
+
+	OwnerID       *int64  `form:"owner_id,omitempty"`       // 所有者
+	SpaceID       *int64  `form:"space_id,omitempty"`       // 所属空间
+	ParentID      *int64  `form:"parent_id,omitempty"`      // 只搜索该文件夹的子孙文档
+	UpdatedAfter  *string `form:"updated_after,omitempty"`  // 更新时间下限
+	UpdatedBefore *string `form:"updated_before,omitempty"` // 更新时间上限
+	CreatedAfter  *string `form:"created_after,omitempty"`  // 创建时间下限
+	CreatedBefore *string `form:"created_before,omitempty"` // 创建时间上限
+	Favorites     bool    `form:"favorites,omitempty"`      // 只搜索收藏的文档
+	Starred       bool    `form:"starred,omitempty"`        // 只搜索星标文档
+	SharedWithMe  bool    `form:"shared_with_me,omitempty"` // 只搜索他人共享给我的文档
+}
+
+// QuickSwitchQueryDto 快速切换查询DTO
+// 查询为空时返回最近访问和收藏的文档
+type QuickSwitchQueryDto struct {
+	Q     string `form:"q,omitempty"`                                                    // 标题、拼音首字母或模糊查询
+	Limit int    `form:"limit,omitempty" validate:"omitempty,min=1,max=50" default:"10"` // 返回数量
+}
+
+// RecentDocumentsQueryDto 最近文档查询DTO
+type RecentDocumentsQueryDto struct {
+	Limit int `form:"limit,omitempty" validate:"omitempty,min=1,max=100" default:"20"` // 返回数量
 }
 
 // ToDocumentType 转换为领域模型的文档类型
@@ -115,6 +172,50 @@ func (dto *DocumentSearchQueryDto) ToDocumentType() *domain.DocumentType {
 	return &docType
 }
 
+// ToFilter 转换为领域模型的搜索条件，时间格式无效时返回错误
+func (dto *DocumentSearchQueryDto) ToFilter() (*domain.DocumentSearchFilter, error) {
+	filter := &domain.DocumentSearchFilter{
+		Keyword:         dto.Keyword,
+		Type:            dto.ToDocumentType(),
+		IncludeArchived: dto.IncludeArchived,
+		OwnerID:         dto.OwnerID,
+		SpaceID:         dto.SpaceID,
+		ParentID:        dto.ParentID,
+		FavoritesOnly:   dto.Favorites,
+		StarredOnly:     dto.Starred,
+		SharedWithMe:    dto.SharedWithMe,
+		Limit:           dto.Limit,
+		Offset:          dto.Offset,
+	}
+
+	var err error
+	if filter.UpdatedAfter, err = parseOptionalTime(dto.UpdatedAfter); err != nil {
+		return nil, err
+	}
+	if filter.UpdatedBefore, err = parseOptionalTime(dto.UpdatedBefore); err != nil {
+		return nil, err
+	}
+	if filter.CreatedAfter, err = parseOptionalTime(dto.CreatedAfter); err != nil {
+		return nil, err
+	}
+	if filter.CreatedBefore, err = parseOptionalTime(dto.CreatedBefore); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// parseOptionalTime 解析 RFC3339 格式的可选时间
+func parseOptionalTime(value *string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 // BatchOperationDto 批量操作请求DTO
 type BatchOperationDto struct {
 	DocumentIDs []int64 `json:"document_ids" binding:"required,min=1,max=100"` // 文档ID列表
@@ -134,9 +235,14 @@ type DocumentResponseDto struct {
 	OwnerID   int64     `json:"owner_id"`            // 所有者ID
 	SortOrder int       `json:"sort_order"`          // 排序顺序
 	IsStarred bool      `json:"is_starred"`          // 是否星标
+	Version   int64     `json:"version"`             // 写入版本号，与 ETag 一致
 	CreatedAt time.Time `json:"created_at"`          // 创建时间
 	UpdatedAt time.Time `json:"updated_at"`          // 更新时间
 
+	// 归档状态
+	IsArchived bool       `json:"is_archived"`           // 是否已归档，归档的文档只读
+	ArchivedAt *time.Time `json:"archived_at,omitempty"` // 归档时间
+
 	// 关联信息（可选）
 	Owner         *UserInfoDto        `json:"owner,omitempty"`         // 所有者信息
 	Parent        *DocumentBriefDto   `json:"parent,omitempty"`        // 父文档信息
@@ -162,6 +268,7 @@ type DocumentSearchItemDto struct {
 	Type          string            `json:"type"`                    // 文档类型
 	IsStarred     bool              `json:"is_starred"`              // 是否星标
 	IsFavorite    bool              `json:"is_favorite"`             // 是否收藏
+	IsArchived    bool              `json:"is_archived"`             // 是否已归档
 	CreatedAt     time.Time         `json:"created_at"`              // 创建时间
 	UpdatedAt     time.Time         `json:"updated_at"`              // 更新时间
 	LastViewed    *time.Time        `json:"last_viewed,omitempty"`   // 最后查看时间
@@ -172,10 +279,104 @@ type DocumentSearchItemDto struct {
 	Owner         *UserInfoDto      `json:"owner,omitempty"`         // 所有者信息
 	ParentPath    string            `json:"parent_path,omitempty"`   // 父路径
 	MatchScore    float64           `json:"match_score,omitempty"`   // 匹配分数
+
+	// 高亮，偏移和长度按 Unicode 字符计
+	TitleHighlights []domain.SearchHighlight `json:"title_highlights,omitempty"` // 标题中的匹配位置
+	Snippets        []domain.SearchSnippet   `json:"snippets,omitempty"`         // 正文中包含匹配的摘要
+}
+
+// DocumentSearchResponseDto 文档搜索响应DTO
+type DocumentSearchResponseDto struct {
+	Documents []*DocumentSearchItemDto     `json:"documents"` // 当前页的搜索结果
+	Total     int64                        `json:"total"`     // 命中的文档总数
+	Keyword   string                       `json:"keyword"`   // 搜索关键词
+	Facets    *domain.DocumentSearchFacets `json:"facets"`    // 按空间、所有者和类型的分面统计
+}
+
+// RecentDocumentDto 最近文档DTO
+type RecentDocumentDto struct {
+	Document     *DocumentResponseDto `json:"document"`                 // 文档信息
+	Activity     string               `json:"activity"`                 // 最近一次活动：viewed、edited 或 shared
+	ActivityAt   time.Time            `json:"activity_at"`              // 最近一次活动的时间
+	LastViewedAt *time.Time           `json:"last_viewed_at,omitempty"` // 最后查看时间
+	LastEditedAt *time.Time           `json:"last_edited_at,omitempty"` // 最后编辑时间
+	SharedAt     *time.Time           `json:"shared_at,omitempty"`      // 共享给我的时间
+	SharedBy     *int64               `json:"shared_by,omitempty"`      // 共享者ID
+}
+
+// DuplicateJobResponseDto 异步复制任务DTO
+type DuplicateJobResponseDto struct {
+	ID         string     `json:"id"`                    // 任务ID
+	SourceID   int64      `json:"source_id"`             // 被复制的文档ID
+	Status     string     `json:"status"`                // 任务状态：pending、running、completed 或 failed
+	Total      int        `json:"total"`                 // 需要复制的文档数
+	Copied     int        `json:"copied"`                // 已复制的文档数
+	ResultID   *int64     `json:"result_id,omitempty"`   // 完成后副本根文档的ID
+	Error      string     `json:"error,omitempty"`       // 失败原因
+	CreatedAt  time.Time  `json:"created_at"`            // 创建时间
+	UpdatedAt  time.Time  `json:"updated_at"`            // 最后更新时间
+	FinishedAt *time.Time `json:"finished_at,omitempty"` // 结束时间
+}
+
+// DuplicateDocumentResponseDto 复制文档响应DTO
+// 同步复制时返回副本根文档，异步复制时返回任务
+type DuplicateDocumentResponseDto struct {
+	Document *DocumentResponseDto     `json:"document,omitempty"` // 副本根文档
+	Job      *DuplicateJobResponseDto `json:"job,omitempty"`      // 异步复制任务
 }
 
 // === DTO转换函数 ===
 
+// FromDuplicateJob 从领域模型转换为复制任务DTO
+func FromDuplicateJob(job *domain.DocumentDuplicateJob) *DuplicateJobResponseDto {
+	if job == nil {
+		return nil
+	}
+
+	return &DuplicateJobResponseDto{
+		ID:         job.ID,
+		SourceID:   job.SourceID,
+		Status:     string(job.Status),
+		Total:      job.Total,
+		Copied:     job.Copied,
+		ResultID:   job.ResultID,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+		FinishedAt: job.FinishedAt,
+	}
+}
+
+// FromDuplicateResult 从领域模型转换为复制文档响应DTO
+func FromDuplicateResult(result *domain.DocumentDuplicateResult) *DuplicateDocumentResponseDto {
+	if result == nil {
+		return nil
+	}
+
+	response := &DuplicateDocumentResponseDto{Job: FromDuplicateJob(result.Job)}
+	if result.Document != nil {
+		response.Document = FromDocument(result.Document)
+	}
+	return response
+}
+
+// FromRecentDocument 从领域模型转换为最近文档DTO
+func FromRecentDocument(recent *domain.RecentDocument) *RecentDocumentDto {
+	if recent == nil {
+		return nil
+	}
+
+	return &RecentDocumentDto{
+		Document:     FromDocument(recent.Document),
+		Activity:     string(recent.Activity),
+		ActivityAt:   recent.ActivityAt,
+		LastViewedAt: recent.LastViewedAt,
+		LastEditedAt: recent.LastEditedAt,
+		SharedAt:     recent.SharedAt,
+		SharedBy:     recent.SharedBy,
+	}
+}
+
 // FromDocument 从领域模型转换为响应DTO
 func FromDocument(doc *domain.Document) *DocumentResponseDto {
 	if doc == nil {
@@ -191,8 +392,12 @@ func FromDocument(doc *domain.Document) *DocumentResponseDto {
 		OwnerID:   doc.OwnerID,
 		SortOrder: doc.SortOrder,
 		IsStarred: doc.IsStarred,
+		Version:   doc.Version,
 		CreatedAt: doc.CreatedAt,
 		UpdatedAt: doc.UpdatedAt,
+
+		IsArchived: doc.IsArchived(),
+		ArchivedAt: doc.ArchivedAt,
 	}
 
 	// 转换关联信息
@@ -242,11 +447,15 @@ func FromDocumentSearchResult(result *domain.DocumentSearchResult) *DocumentSear
 		Type:       string(result.Type),
 		IsStarred:  result.IsStarred,
 		IsFavorite: result.IsFavorite,
+		IsArchived: result.IsArchived,
 		UpdatedAt:  result.UpdatedAt,
 		IsOwner:    result.Owner != nil,
 		Permission: string(result.Permission),
 		ParentPath: result.ParentPath,
 		MatchScore: result.MatchScore,
+
+		TitleHighlights: result.TitleHighlights,
+		Snippets:        result.Snippets,
 	}
 
 	if result.Owner != nil {
@@ -0,0 +1,56 @@
+package dto
+
+import (
+	"time"
+
+	"DOC/domain"
+)
+
+// === 回收站相关的DTO定义 ===
+
+// DocumentTrashQueryDto 回收站列表查询参数
+type DocumentTrashQueryDto struct {
+	SpaceID  *int64 `form:"space_id" binding:"omitempty,min=1"`          // 空间ID，不传时查询自己的回收站
+	Page     int    `form:"page" binding:"omitempty,min=1"`              // 页码，从 1 开始
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"` // 每页数量，默认 20
+}
+
+// DocumentTrashItemDto 回收站条目响应DTO
+type DocumentTrashItemDto struct {
+	ID              int64      `json:"id"`                  // 文档ID
+	Title           string     `json:"title"`               // 文档标题
+	Type            string     `json:"type"`                // 文档类型
+	ParentID        *int64     `json:"parent_id,omitempty"` // 删除前的父文件夹ID
+	SpaceID         *int64     `json:"space_id,omitempty"`  // 所属空间ID
+	OwnerID         int64      `json:"owner_id"`            // 所有者ID
+	DescendantCount int64      `json:"descendant_count"`    // 随之一起删除的子孙文档数
+	DeletedAt       *time.Time `json:"deleted_at"`          // 删除时间
+	PurgeAt         *time.Time `json:"purge_at,omitempty"`  // 到期后永久删除的时间
+}
+
+// DocumentTrashListResponseDto 回收站列表响应DTO
+type DocumentTrashListResponseDto struct {
+	Items []*DocumentTrashItemDto `json:"items"`
+	Total int64                   `json:"total"`
+	Page  int                     `json:"page"`
+	Size  int                     `json:"size"`
+}
+
+// FromDocumentTrashItem 从回收站条目领域模型转换为DTO
+func FromDocumentTrashItem(item *domain.DocumentTrashItem) *DocumentTrashItemDto {
+	if item == nil || item.Document == nil {
+		return nil
+	}
+
+	return &DocumentTrashItemDto{
+		ID:              item.Document.ID,
+		Title:           item.Document.Title,
+		Type:            string(item.Document.Type),
+		ParentID:        item.Document.ParentID,
+		SpaceID:         item.Document.SpaceID,
+		OwnerID:         item.Document.OwnerID,
+		DescendantCount: item.DescendantCount,
+		DeletedAt:       item.Document.DeletedAt,
+		PurgeAt:         item.PurgeAt,
+	}
+}
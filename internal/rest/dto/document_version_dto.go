@@ -0,0 +1,124 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"DOC/domain"
+)
+
+// === 文档版本相关的DTO定义 ===
+
+// DocumentVersionParamDto 文档版本路径参数DTO
+type DocumentVersionParamDto struct {
+	ID        int64 `uri:"id" binding:"required,min=1"`        // 文档ID
+	VersionID int64 `uri:"versionId" binding:"required,min=1"` // 版本ID
+}
+
+// DocumentVersionListQueryDto 文档版本列表查询参数
+type DocumentVersionListQueryDto struct {
+	NamedOnly bool `form:"named_only"`                                  // 只返回命名版本
+	Page      int  `form:"page" binding:"omitempty,min=1"`              // 页码，从 1 开始
+	PageSize  int  `form:"page_size" binding:"omitempty,min=1,max=100"` // 每页数量，默认 20
+}
+
+// CreateNamedVersionDto 创建命名版本请求DTO
+type CreateNamedVersionDto struct {
+	Name string `json:"name" binding:"required,max=100"` // 版本名称
+}
+
+// NameDocumentVersionDto 设置版本名称请求DTO
+type NameDocumentVersionDto struct {
+	Name string `json:"name" binding:"max=100"` // 版本名称，为空时取消命名
+}
+
+// DocumentDiffQueryDto 文档版本对比查询参数
+// from 不传时从用户最后查看的版本开始，to 不传时对比到文档当前内容
+type DocumentDiffQueryDto struct {
+	From        int64  `form:"from" binding:"omitempty,min=1"`                  // 起始版本ID
+	To          int64  `form:"to" binding:"omitempty,min=1"`                    // 目标版本ID
+	Granularity string `form:"granularity" binding:"omitempty,oneof=line word"` // 文本差异粒度，默认 word
+}
+
+// MarkVersionViewedDto 标记最后查看版本请求DTO
+type MarkVersionViewedDto struct {
+	VersionID int64 `json:"version_id" binding:"omitempty,min=1"` // 版本ID，不传时标记文档当前内容
+}
+
+// DocumentVersionResponseDto 文档版本响应DTO
+type DocumentVersionResponseDto struct {
+	ID             int64                        `json:"id"`                         // 版本ID
+	DocumentID     int64                        `json:"document_id"`                // 文档ID
+	Title          string                       `json:"title"`                      // 版本标题
+	Content        json.RawMessage              `json:"content,omitempty"`          // 版本内容（JSON格式），列表中不返回
+	ContentSize    int                          `json:"content_size"`               // 内容字节数
+	Source         domain.DocumentVersionSource `json:"source"`                     // 版本来源
+	Name           string                       `json:"name"`                       // 版本名称，为空表示自动版本
+	AuthorID       int64                        `json:"author_id"`                  // 产生该版本的用户
+	RestoredFromID *int64                       `json:"restored_from_id,omitempty"` // 恢复来源版本ID
+	CreatedAt      time.Time                    `json:"created_at"`                 // 创建时间
+	UpdatedAt      time.Time                    `json:"updated_at"`                 // 最后更新时间
+}
+
+// DocumentVersionListResponseDto 文档版本列表响应DTO
+type DocumentVersionListResponseDto struct {
+	Versions []*DocumentVersionResponseDto `json:"versions"`
+	Total    int64                         `json:"total"`
+	Page     int                           `json:"page"`
+	Size     int                           `json:"size"`
+}
+
+// DocumentDiffResponseDto 文档版本对比响应DTO
+type DocumentDiffResponseDto struct {
+	DocumentID  int64                       `json:"document_id"` // 文档ID
+	From        *DocumentVersionResponseDto `json:"from"`        // 起始版本
+	To          *DocumentVersionResponseDto `json:"to"`          // 目标版本，id 为 0 表示文档当前内容
+	Granularity domain.DiffGranularity      `json:"granularity"` // 文本差异粒度
+	Title       []domain.DiffChunk          `json:"title"`       // 标题差异
+	Text        []domain.DiffChunk          `json:"text"`        // 内容文本差异
+	Nodes       []domain.DiffNodeChange     `json:"nodes"`       // 内容节点差异
+	HTML        string                      `json:"html"`        // 渲染后的 HTML
+}
+
+// FromDocumentDiff 从文档差异领域模型转换为DTO
+func FromDocumentDiff(diff *domain.DocumentDiff) *DocumentDiffResponseDto {
+	if diff == nil {
+		return nil
+	}
+
+	return &DocumentDiffResponseDto{
+		DocumentID:  diff.DocumentID,
+		From:        FromDocumentVersion(diff.From),
+		To:          FromDocumentVersion(diff.To),
+		Granularity: diff.Granularity,
+		Title:       diff.Title,
+		Text:        diff.Text,
+		Nodes:       diff.Nodes,
+		HTML:        diff.HTML,
+	}
+}
+
+// FromDocumentVersion 从文档版本领域模型转换为DTO
+func FromDocumentVersion(version *domain.DocumentVersion) *DocumentVersionResponseDto {
+	if version == nil {
+		return nil
+	}
+
+	dto := &DocumentVersionResponseDto{
+		ID:             version.ID,
+		DocumentID:     version.DocumentID,
+		Title:          version.Title,
+		ContentSize:    version.ContentSize,
+		Source:         version.Source,
+		Name:           version.Name,
+		AuthorID:       version.AuthorID,
+		RestoredFromID: version.RestoredFromID,
+		CreatedAt:      version.CreatedAt,
+		UpdatedAt:      version.UpdatedAt,
+	}
+	if version.Content != "" {
+		dto.Content = json.RawMessage(version.Content)
+	}
+
+	return dto
+}
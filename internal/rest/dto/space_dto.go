@@ -22,6 +22,10 @@ type UpdateSpaceDto struct {
 	Type        *domain.SpaceType `json:"type,omitempty" validate:"omitempty,oneof=WORKSPACE PROJECT PERSONAL"`
 	IsPublic    *bool             `json:"is_public,omitempty"`
 	IsDeleted   *bool             `json:"is_deleted,omitempty"`
+
+	// 文档版本保留规则，0 表示使用系统默认值
+	VersionRetentionDays  *int `json:"version_retention_days,omitempty" validate:"omitempty,min=0"`
+	VersionRetentionCount *int `json:"version_retention_count,omitempty" validate:"omitempty,min=0"`
 }
 
 // AddMemberDto 添加空间成员请求DTO
@@ -58,6 +62,9 @@ type SpaceResponse struct {
 	DocumentCount  int                `json:"document_count"`
 	MemberCount    int                `json:"member_count"`
 
+	VersionRetentionDays  int `json:"version_retention_days"`
+	VersionRetentionCount int `json:"version_retention_count"`
+
 	// 关联信息
 	Organization *OrganizationResponse  `json:"organization,omitempty"`
 	Creator      *UserResponse          `json:"creator,omitempty"`
@@ -134,6 +141,9 @@ func ToSpaceResponse(space *domain.Space) *SpaceResponse {
 		UpdatedAt:      space.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		DocumentCount:  space.DocumentCount,
 		MemberCount:    space.MemberCount,
+
+		VersionRetentionDays:  space.VersionRetentionDays,
+		VersionRetentionCount: space.VersionRetentionCount,
 	}
 
 	// 转换关联数据
@@ -12,8 +12,8 @@ func CORSMiddleware() gin.HandlerFunc {
 		// 设置 CORS 头
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		c.Header("Access-Control-Expose-Headers", "Content-Length")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, If-Match")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, ETag")
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		// 处理预检请求
@@ -49,8 +49,9 @@ func DefaultCORSConfig() CORSConfig {
 			"X-CSRF-Token",
 			"Authorization",
 			"X-Requested-With",
+			"If-Match",
 		},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           86400, // 24 hours
 	}
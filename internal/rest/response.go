@@ -64,6 +64,11 @@ func ResponseConflict(c *gin.Context, message string) {
 	Response(c, http.StatusConflict, message, nil)
 }
 
+// ResponsePreconditionFailed 返回412错误
+func ResponsePreconditionFailed(c *gin.Context, message string, data interface{}) {
+	Response(c, http.StatusPreconditionFailed, message, data)
+}
+
 // ResponseTooManyRequests 返回429错误
 func ResponseTooManyRequests(c *gin.Context, message string) {
 	Response(c, http.StatusTooManyRequests, message, nil)
@@ -74,6 +79,11 @@ func ResponseInternalServerError(c *gin.Context, message string) {
 	Response(c, http.StatusInternalServerError, message, nil)
 }
 
+// ResponseServiceUnavailable 返回503错误
+func ResponseServiceUnavailable(c *gin.Context, message string) {
+	Response(c, http.StatusServiceUnavailable, message, nil)
+}
+
 // ResponseCreated 返回201成功响应
 func ResponseCreated(c *gin.Context, message string, data interface{}) {
 	Response(c, http.StatusCreated, message, data)
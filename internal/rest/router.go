@@ -19,6 +19,8 @@ type RouterConfig struct {
 	OrganizationUsecase      domain.OrganizationUsecase
 	SpaceUsecase             domain.SpaceUsecase
 	DocumentAggregateUsecase domain.DocumentAggregateUsecase // 文档聚合服务
+	DocumentEventFeed        domain.DocumentEventFeed        // 文档事件订阅（可为空，为空时不提供事件流）
+	CollaborationUsecase     domain.CollaborationUsecase     // 协作服务（可为空，为空时不提供协作历史回放）
 	Config                   *config.Config
 }
 
@@ -100,7 +102,7 @@ func setupRoutes(router *gin.Engine, cfg RouterConfig) {
 
 			// 文档管理相关路由
 			if cfg.DocumentAggregateUsecase != nil {
-				setupDocumentRoutesV1(v1, cfg.DocumentAggregateUsecase, cfg.Config)
+				setupDocumentRoutesV1(v1, cfg.DocumentAggregateUsecase, cfg.DocumentEventFeed, cfg.CollaborationUsecase, cfg.Config)
 			}
 		}
 	}
@@ -311,8 +313,10 @@ func setupSpaceRoutesV1(v1 *gin.RouterGroup, spaceUsecase domain.SpaceUsecase, c
 // 参数说明：
 // - rg: 路由组
 // - documentService: 文档聚合服务接口
+// - eventFeed: 文档事件订阅接口
+// - collaborationService: 协作业务逻辑接口
 // - cfg: 应用配置
-func setupDocumentRoutesV1(rg *gin.RouterGroup, documentService domain.DocumentAggregateUsecase, cfg *config.Config) {
+func setupDocumentRoutesV1(rg *gin.RouterGroup, documentService domain.DocumentAggregateUsecase, eventFeed domain.DocumentEventFeed, collaborationService domain.CollaborationUsecase, cfg *config.Config) {
 	// 创建 JWT 管理器
 	jwtManager := jwt.NewJWTManager(
 		cfg.App.JWTSecret,
@@ -340,8 +344,31 @@ func setupDocumentRoutesV1(rg *gin.RouterGroup, documentService domain.DocumentA
 		documents.GET("/:id/content", documentHandler.GetDocumentContent)    // GET /api/v1/documents/:id/content - 获取文档内容
 		documents.PUT("/:id/content", documentHandler.UpdateDocumentContent) // PUT /api/v1/documents/:id/content - 更新文档内容
 
+		// === 文档归档操作 ===
+		documents.POST("/:id/archive", documentHandler.ArchiveDocument)     // POST /api/v1/documents/:id/archive - 归档文档，文件夹连同子孙文档一起归档
+		documents.POST("/:id/unarchive", documentHandler.UnarchiveDocument) // POST /api/v1/documents/:id/unarchive - 取消归档
+
+		// === 文档复制操作 ===
+		documents.POST("/:id/duplicate", documentHandler.DuplicateDocument)      // POST /api/v1/documents/:id/duplicate - 复制文档，文件夹连同子孙文档一起复制
+		documents.GET("/duplicate-jobs/:jobId", documentHandler.GetDuplicateJob) // GET /api/v1/documents/duplicate-jobs/:jobId - 获取异步复制任务的进度
+
+		// === 文档协作设置 ===
+		documents.PUT("/:id/collaboration-mode", documentHandler.SetCollaborationMode) // PUT /api/v1/documents/:id/collaboration-mode - 设置实时协作模式
+
+		// === 文档版本操作 ===
+		documents.GET("/:id/versions", documentHandler.ListDocumentVersions)                       // GET /api/v1/documents/:id/versions - 获取历史版本列表
+		documents.POST("/:id/versions", documentHandler.CreateNamedVersion)                        // POST /api/v1/documents/:id/versions - 以当前内容创建命名版本
+		documents.GET("/:id/versions/:versionId", documentHandler.GetDocumentVersion)              // GET /api/v1/documents/:id/versions/:versionId - 获取版本详情
+		documents.PUT("/:id/versions/:versionId", documentHandler.NameDocumentVersion)             // PUT /api/v1/documents/:id/versions/:versionId - 设置版本名称
+		documents.POST("/:id/versions/:versionId/restore", documentHandler.RestoreDocumentVersion) // POST /api/v1/documents/:id/versions/:versionId/restore - 恢复到该版本
+		documents.GET("/:id/diff", documentHandler.DiffDocumentVersions)                           // GET /api/v1/documents/:id/diff - 对比两个版本，默认从最后查看的版本到当前内容
+		documents.GET("/:id/last-viewed", documentHandler.GetLastViewedVersion)                    // GET /api/v1/documents/:id/last-viewed - 获取最后查看的版本
+		documents.PUT("/:id/last-viewed", documentHandler.MarkDocumentVersionViewed)               // PUT /api/v1/documents/:id/last-viewed - 标记最后查看的版本
+
 		// === 文档搜索 ===
-		documents.GET("/search", documentHandler.SearchDocuments) // GET /api/v1/documents/search - 搜索文档
+		documents.GET("/search", documentHandler.SearchDocuments)    // GET /api/v1/documents/search - 搜索文档
+		documents.GET("/quick-switch", documentHandler.QuickSwitch)  // GET /api/v1/documents/quick-switch - 按标题快速切换文档
+		documents.GET("/recent", documentHandler.GetRecentDocuments) // GET /api/v1/documents/recent - 获取最近打开、编辑以及被共享的文档
 
 		// === 文档分享操作 ===
 		documents.POST("/:id/share", documentHandler.CreateShareLink)           // POST /api/v1/documents/:id/share - 创建分享链接
@@ -355,6 +382,11 @@ func setupDocumentRoutesV1(rg *gin.RouterGroup, documentService domain.DocumentA
 		documents.PUT("/:id/shared/title", documentHandler.SetFavoriteCustomTitle)     // PUT /api/v1/documents/:id/shared/title - 设置收藏自定义标题
 		documents.DELETE("/:id/shared", documentHandler.RemoveFavoriteDocument)        // DELETE /api/v1/documents/:id/shared - 移除收藏
 
+		// === 回收站操作 ===
+		documents.GET("/trash", documentHandler.ListTrash)               // GET /api/v1/documents/trash - 获取回收站条目，space_id 指定空间
+		documents.POST("/:id/restore", documentHandler.RestoreFromTrash) // POST /api/v1/documents/:id/restore - 恢复文档及其子树
+		// 永久删除使用 DELETE /api/v1/documents/:id?permanent=true
+
 		// === 批量操作 ===
 		documents.DELETE("/batch", documentHandler.BatchDeleteDocuments) // DELETE /api/v1/documents/batch - 批量删除文档
 		documents.PUT("/batch/move", documentHandler.BatchMoveDocuments) // PUT /api/v1/documents/batch/move - 批量移动文档
@@ -364,6 +396,22 @@ func setupDocumentRoutesV1(rg *gin.RouterGroup, documentService domain.DocumentA
 	// 通过分享链接访问文档的路由，不需要用户登录
 	documents.GET("/shared/:linkId", documentHandler.GetSharedDocument) // GET /api/v1/documents/shared/:linkId - 通过分享链接访问文档
 
+	// === 文档事件流（Server-Sent Events） ===
+	if eventFeed != nil {
+		eventHandler := NewDocumentEventHandler(documentService, eventFeed)
+		documents.GET("/:id/events", eventHandler.StreamDocumentEvents) // GET /api/v1/documents/:id/events - 订阅文档事件流
+
+		// 分享链接读者无需登录，注册在认证路由组之外
+		rg.GET("/documents/shared/:linkId/events", eventHandler.StreamSharedDocumentEvents) // GET /api/v1/documents/shared/:linkId/events - 通过分享链接订阅文档事件流
+	}
+
+	// === 协作历史回放（需要文档管理权限） ===
+	if collaborationService != nil {
+		collaborationHandler := NewCollaborationHandler(collaborationService)
+		documents.GET("/:id/history/operations", collaborationHandler.GetOperationHistory) // GET /api/v1/documents/:id/history/operations - 分页获取协作操作历史
+		documents.GET("/:id/history/content", collaborationHandler.GetContentAtSeq)        // GET /api/v1/documents/:id/history/content - 获取指定操作序号时的文档内容
+	}
+
 	// === 用户权限检查路由 ===
 	// 检查用户对特定文档的访问权限
 	userRoutes := rg.Group("/users")
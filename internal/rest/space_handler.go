@@ -256,6 +256,9 @@ func (h *SpaceHandler) Update(c *gin.Context) {
 		Color:     req.Color,
 		SpaceType: req.Type,
 		IsPublic:  req.IsPublic,
+
+		VersionRetentionDays:  req.VersionRetentionDays,
+		VersionRetentionCount: req.VersionRetentionCount,
 	}
 
 	// 调用业务逻辑
@@ -0,0 +1,438 @@
+package websocket
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"DOC/domain"
+)
+
+const (
+	// 同一连接感知状态的最小广播间隔，期间的更新合并为最后一次
+	awarenessThrottle = 100 * time.Millisecond
+
+	// 输入状态在最后一次活动后保持的时间
+	awarenessTypingTimeout = 3 * time.Second
+
+	// 无活动超过该时间视为空闲
+	awarenessIdleTimeout = time.Minute
+
+	// 无活动超过该时间视为离开
+	awarenessAwayTimeout = 5 * time.Minute
+
+	// 向其他实例发送感知心跳的间隔
+	awarenessHeartbeatPeriod = 15 * time.Second
+
+	// 其他实例的感知状态超过该时间未收到心跳即过期
+	awarenessExpire = 3 * awarenessHeartbeatPeriod
+
+	// 感知状态检查间隔
+	awarenessTickPeriod = time.Second
+
+	// 单个连接最多上报的选区数量
+	maxAwarenessSelections = 16
+)
+
+// 感知相关事件
+const (
+	eventAwarenessUpdate    = "awareness_update"
+	eventAwarenessRemoved   = "awareness_removed"
+	eventAwarenessHeartbeat = "awareness_heartbeat" // 仅在实例间转发
+	eventAwarenessQuery     = "awareness_query"     // 仅在实例间转发
+)
+
+// AwarenessStatus 用户在文档中的活动状态
+type AwarenessStatus string
+
+const (
+	AwarenessStatusActive AwarenessStatus = "active" // 活跃
+	AwarenessStatusTyping AwarenessStatus = "typing" // 正在输入
+	AwarenessStatusIdle   AwarenessStatus = "idle"   // 空闲
+	AwarenessStatusAway   AwarenessStatus = "away"   // 离开
+)
+
+// awarenessColors 用户颜色调色板
+var awarenessColors = []string{
+	"#E53935", "#D81B60", "#8E24AA", "#5E35B1",
+	"#3949AB", "#1E88E5", "#00897B", "#43A047",
+	"#7CB342", "#F4511E", "#6D4C41", "#546E7A",
+}
+
+// Selection 选区，anchor 为起点，head 为光标所在位置，二者相等时为光标
+type Selection struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// AwarenessState 连接在房间内的感知状态
+type AwarenessState struct {
+	UserID       int64           `json:"user_id"`
+	SocketID     string          `json:"socket_id"`
+	Name         string          `json:"name"`
+	AvatarURL    string          `json:"avatar_url"`
+	Color        string          `json:"color"`
+	Selections   []Selection     `json:"selections"`
+	Status       AwarenessStatus `json:"status"`
+	LastActiveAt time.Time       `json:"last_active_at"`
+}
+
+// awarenessEntry Hub 保存的感知状态
+type awarenessEntry struct {
+	state     AwarenessState
+	reported  AwarenessStatus // 客户端上报的状态，实际状态还取决于无活动时长
+	local     bool            // 是否为本实例的连接
+	expiresAt time.Time       // 其他实例的状态在此时间后过期
+	lastSent  time.Time       // 上次广播时间
+	pending   bool            // 是否有被节流的更新尚未广播
+}
+
+// awarenessShard 感知状态分片：房间 -> 连接ID -> 状态
+// 与房间注册表按相同方式分片，不同房间的感知更新不争用同一把锁
+type awarenessShard struct {
+	mu    sync.Mutex
+	rooms map[string]map[string]*awarenessEntry
+}
+
+// awarenessShard 获取房间所在的感知状态分片
+func (h *Hub) awarenessShard(roomID string) *awarenessShard {
+	return &h.awarenessShards[shardIndex(roomID)]
+}
+
+// awarenessInput 客户端提交的感知数据
+type awarenessInput struct {
+	Selections []Selection     `json:"selections"`
+	Status     AwarenessStatus `json:"status"`
+	Position   *int            `json:"position"` // 兼容 cursor_update 的单个光标位置
+}
+
+// validate 校验并规范化感知数据
+func (in *awarenessInput) validate() error {
+	if len(in.Selections) == 0 && in.Position != nil {
+		in.Selections = []Selection{{Anchor: *in.Position, Head: *in.Position}}
+	}
+	if len(in.Selections) > maxAwarenessSelections {
+		return domain.ErrInvalidCollaborationOperation
+	}
+	for _, selection := range in.Selections {
+		if selection.Anchor < 0 || selection.Head < 0 {
+			return domain.ErrInvalidCollaborationOperation
+		}
+	}
+
+	switch in.Status {
+	case "":
+		in.Status = AwarenessStatusActive
+	case AwarenessStatusActive, AwarenessStatusTyping, AwarenessStatusAway:
+	default:
+		return domain.ErrInvalidCollaborationOperation
+	}
+	return nil
+}
+
+// awarenessColor 根据用户ID选取固定的颜色
+func awarenessColor(userID int64) string {
+	h := fnv.New32a()
+	var buf [8]byte
+	for i := range buf {
+		buf[i] = byte(userID >> (8 * i))
+	}
+	h.Write(buf[:])
+	return awarenessColors[h.Sum32()%uint32(len(awarenessColors))]
+}
+
+// effectiveStatus 根据上报状态和无活动时长计算实际状态
+func (e *awarenessEntry) effectiveStatus(now time.Time) AwarenessStatus {
+	inactive := now.Sub(e.state.LastActiveAt)
+	switch {
+	case e.reported == AwarenessStatusAway || inactive >= awarenessAwayTimeout:
+		return AwarenessStatusAway
+	case inactive >= awarenessIdleTimeout:
+		return AwarenessStatusIdle
+	case e.reported == AwarenessStatusTyping && inactive < awarenessTypingTimeout:
+		return AwarenessStatusTyping
+	default:
+		return AwarenessStatusActive
+	}
+}
+
+// UpdateAwareness 更新连接的感知状态并广播，广播频率受节流限制
+func (h *Hub) UpdateAwareness(client *Client, roomID string, input awarenessInput) {
+	now := time.Now()
+
+	shard := h.awarenessShard(roomID)
+	shard.mu.Lock()
+	room := shard.rooms[roomID]
+	if room == nil {
+		room = make(map[string]*awarenessEntry)
+		shard.rooms[roomID] = room
+	}
+	entry := room[client.ID]
+	if entry == nil {
+		entry = &awarenessEntry{local: true}
+		room[client.ID] = entry
+	}
+
+	entry.reported = input.Status
+	entry.state = AwarenessState{
+		UserID:       client.UserID,
+		SocketID:     client.ID,
+		Name:         client.name,
+		AvatarURL:    client.avatarURL,
+		Color:        awarenessColor(client.UserID),
+		Selections:   input.Selections,
+		LastActiveAt: now,
+	}
+	entry.state.Status = entry.effectiveStatus(now)
+
+	// 节流：间隔内的更新只保留最新状态，到期后统一广播
+	if wait := awarenessThrottle - now.Sub(entry.lastSent); wait > 0 {
+		if !entry.pending {
+			entry.pending = true
+			time.AfterFunc(wait, func() { h.flushAwareness(roomID, client) })
+		}
+		shard.mu.Unlock()
+		return
+	}
+	entry.lastSent = now
+	state := entry.state
+	shard.mu.Unlock()
+
+	h.sendAwareness(roomID, state, client)
+}
+
+// flushAwareness 广播被节流的感知状态
+func (h *Hub) flushAwareness(roomID string, client *Client) {
+	shard := h.awarenessShard(roomID)
+	shard.mu.Lock()
+	entry := shard.rooms[roomID][client.ID]
+	if entry == nil || !entry.pending {
+		shard.mu.Unlock()
+		return
+	}
+	entry.pending = false
+	entry.lastSent = time.Now()
+	state := entry.state
+	shard.mu.Unlock()
+
+	h.sendAwareness(roomID, state, client)
+}
+
+// sendAwareness 将感知状态发给房间内其他连接和其他实例
+func (h *Hub) sendAwareness(roomID string, state AwarenessState, sender *Client) {
+	h.broadcastAwareness(roomID, eventAwarenessUpdate, state, sender.ID, sender.ID)
+	h.publish(&domain.HubMessage{RoomID: roomID, Event: eventAwarenessUpdate, Exclude: sender.ID}, state)
+}
+
+// broadcastAwareness 向本实例的房间成员广播感知事件
+// 同一连接的感知事件可以合并，慢连接只收到最新状态
+func (h *Hub) broadcastAwareness(roomID, event string, data interface{}, socketID string, excludeID string) {
+	h.broadcastLocal(roomID, event, data, excludeID, "awareness:"+socketID)
+}
+
+// dropAwareness 删除连接的感知状态并通知本实例的其他连接
+func (h *Hub) dropAwareness(roomID string, client *Client) {
+	shard := h.awarenessShard(roomID)
+	shard.mu.Lock()
+	_, exists := shard.rooms[roomID][client.ID]
+	if exists {
+		delete(shard.rooms[roomID], client.ID)
+		if len(shard.rooms[roomID]) == 0 {
+			delete(shard.rooms, roomID)
+		}
+	}
+	shard.mu.Unlock()
+
+	if exists {
+		h.broadcastAwareness(roomID, eventAwarenessRemoved, awarenessRemovedData(client.UserID, client.ID), client.ID, client.ID)
+	}
+}
+
+// awarenessRemovedData 感知状态移除通知数据
+func awarenessRemovedData(userID int64, socketID string) map[string]interface{} {
+	return map[string]interface{}{
+		"user_id":   userID,
+		"socket_id": socketID,
+	}
+}
+
+// awarenessSnapshot 获取房间内所有连接的感知状态
+func (h *Hub) awarenessSnapshot(roomID string) []AwarenessState {
+	now := time.Now()
+
+	shard := h.awarenessShard(roomID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	states := make([]AwarenessState, 0, len(shard.rooms[roomID]))
+	for _, entry := range shard.rooms[roomID] {
+		state := entry.state
+		state.Status = entry.effectiveStatus(now)
+		states = append(states, state)
+	}
+	return states
+}
+
+// awarenessEvent 待投递给本实例连接的感知事件
+type awarenessEvent struct {
+	roomID   string
+	event    string
+	data     interface{}
+	socketID string // 感知状态所属的连接
+}
+
+// tickAwareness 定期更新空闲状态、清理过期的其他实例状态，并向其他实例发送心跳
+// 各分片依次加锁，检查期间其他分片的感知更新不受影响
+func (h *Hub) tickAwareness(now time.Time) {
+	sendHeartbeat := false
+	if h.backplane != nil {
+		last := h.lastAwarenessHeartbeat.Load()
+		sendHeartbeat = now.Sub(time.Unix(0, last)) >= awarenessHeartbeatPeriod &&
+			h.lastAwarenessHeartbeat.CompareAndSwap(last, now.UnixNano())
+	}
+
+	for i := range h.awarenessShards {
+		h.tickAwarenessShard(&h.awarenessShards[i], now, sendHeartbeat)
+	}
+}
+
+// tickAwarenessShard 检查一个分片内的感知状态
+func (h *Hub) tickAwarenessShard(shard *awarenessShard, now time.Time, sendHeartbeat bool) {
+	var events []awarenessEvent
+	heartbeats := make(map[string][]AwarenessState)
+
+	shard.mu.Lock()
+	for roomID, room := range shard.rooms {
+		for socketID, entry := range room {
+			if !entry.local && now.After(entry.expiresAt) {
+				delete(room, socketID)
+				events = append(events, awarenessEvent{roomID, eventAwarenessRemoved, awarenessRemovedData(entry.state.UserID, socketID), socketID})
+				continue
+			}
+
+			// 状态变化由各实例独立计算，只通知本实例的连接
+			if status := entry.effectiveStatus(now); status != entry.state.Status {
+				entry.state.Status = status
+				events = append(events, awarenessEvent{roomID, eventAwarenessUpdate, entry.state, socketID})
+			}
+
+			if sendHeartbeat && entry.local {
+				heartbeats[roomID] = append(heartbeats[roomID], entry.state)
+			}
+		}
+		if len(room) == 0 {
+			delete(shard.rooms, roomID)
+		}
+	}
+	shard.mu.Unlock()
+
+	for _, event := range events {
+		h.broadcastAwareness(event.roomID, event.event, event.data, event.socketID, "")
+	}
+
+	for roomID, states := range heartbeats {
+		h.publish(&domain.HubMessage{RoomID: roomID, Event: eventAwarenessHeartbeat}, states)
+	}
+}
+
+// publishAwarenessHeartbeat 立即向其他实例发送房间内本实例连接的感知状态
+func (h *Hub) publishAwarenessHeartbeat(roomID string) {
+	var states []AwarenessState
+
+	shard := h.awarenessShard(roomID)
+	shard.mu.Lock()
+	for _, entry := range shard.rooms[roomID] {
+		if entry.local {
+			states = append(states, entry.state)
+		}
+	}
+	shard.mu.Unlock()
+
+	if len(states) > 0 {
+		h.publish(&domain.HubMessage{RoomID: roomID, Event: eventAwarenessHeartbeat}, states)
+	}
+}
+
+// handleRemoteAwareness 处理其他实例转发的感知消息，返回需要投递给本实例连接的事件
+func (h *Hub) handleRemoteAwareness(message *domain.HubMessage) []awarenessEvent {
+	now := time.Now()
+
+	switch message.Event {
+	case eventAwarenessUpdate:
+		var state AwarenessState
+		if err := json.Unmarshal(message.Data, &state); err != nil {
+			log.Printf("感知状态解析失败: %v", err)
+			return nil
+		}
+		h.storeRemoteAwareness(message.RoomID, state, now)
+		return []awarenessEvent{{message.RoomID, eventAwarenessUpdate, message.Data, state.SocketID}}
+
+	case eventAwarenessRemoved:
+		var removed struct {
+			SocketID string `json:"socket_id"`
+		}
+		if err := json.Unmarshal(message.Data, &removed); err != nil {
+			log.Printf("感知状态解析失败: %v", err)
+			return nil
+		}
+		shard := h.awarenessShard(message.RoomID)
+		shard.mu.Lock()
+		delete(shard.rooms[message.RoomID], removed.SocketID)
+		shard.mu.Unlock()
+		return []awarenessEvent{{message.RoomID, eventAwarenessRemoved, message.Data, removed.SocketID}}
+
+	case eventAwarenessHeartbeat:
+		var states []AwarenessState
+		if err := json.Unmarshal(message.Data, &states); err != nil {
+			log.Printf("感知心跳解析失败: %v", err)
+			return nil
+		}
+		// 心跳只续期，首次见到的状态才通知本实例的连接
+		var events []awarenessEvent
+		for _, state := range states {
+			if h.storeRemoteAwareness(message.RoomID, state, now) {
+				events = append(events, awarenessEvent{message.RoomID, eventAwarenessUpdate, state, state.SocketID})
+			}
+		}
+		return events
+
+	case eventAwarenessQuery:
+		go h.publishAwarenessHeartbeat(message.RoomID)
+		return nil
+	}
+
+	return nil
+}
+
+// storeRemoteAwareness 保存其他实例连接的感知状态，返回是否为新状态
+func (h *Hub) storeRemoteAwareness(roomID string, state AwarenessState, now time.Time) bool {
+	// 只保存本实例有连接的房间
+	if h.lookupRoom(roomID) == nil {
+		return false
+	}
+
+	shard := h.awarenessShard(roomID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	room := shard.rooms[roomID]
+	if room == nil {
+		room = make(map[string]*awarenessEntry)
+		shard.rooms[roomID] = room
+	}
+	entry, exists := room[state.SocketID]
+	if exists && entry.local {
+		return false
+	}
+	if !exists {
+		entry = &awarenessEntry{}
+		room[state.SocketID] = entry
+	}
+
+	entry.state = state
+	entry.reported = state.Status
+	entry.expiresAt = now.Add(awarenessExpire)
+	return !exists
+}
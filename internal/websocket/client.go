@@ -1,56 +1,90 @@
 package websocket
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
-	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"DOC/domain"
+	"DOC/pkg/yjs"
+
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
 const (
-	// 写入等待时间
-	writeWait = 10 * time.Second
-
-	// 读取等待时间
-	pongWait = 60 * time.Second
+	// 协作业务调用超时时间
+	collaborationTimeout = 5 * time.Second
 
-	// ping 发送间隔，必须小于 pongWait
-	pingPeriod = (pongWait * 9) / 10
+	// 发送队列长度
+	sendQueueSize = 256
 
-	// 最大消息大小
-	maxMessageSize = 512
+	// 慢连接上最多合并的消息键数，超过后断开连接
+	maxCoalescedMessages = 256
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// 在生产环境中应该检查 Origin
-		return true
-	},
-}
-
 // Client WebSocket 客户端连接
 type Client struct {
 	// 基本信息
 	ID     string `json:"id"`      // 连接唯一标识
 	UserID int64  `json:"user_id"` // 用户ID
 
+	// 用户展示信息，用于感知状态
+	name      string
+	avatarURL string
+
 	// WebSocket 连接
 	conn *websocket.Conn
 
 	// 消息发送通道
 	send chan []byte
 
+	// 二进制消息发送通道（Yjs 同步协议）
+	sendBinary chan []byte
+
+	// 连接关闭信号，关闭后不再向发送队列写入
+	done       chan struct{}
+	closeOnce  sync.Once
+	closeCode  int
+	closeText  string
+	closeFlush bool // 关闭前是否先发出队列中的消息
+
+	// 发送队列满时合并的消息：合并键 -> 最新消息，由写协程在队列空出后发送
+	coalesceMu    sync.Mutex
+	coalesced     map[string][]byte
+	coalesceOrder []string
+	wake          chan struct{}
+
+	// 已加入的房间
+	roomsMu sync.Mutex
+	rooms   map[string]roomMembership
+
 	// Hub 引用
 	hub *Hub
 
+	// 协作业务，为空时不做权限校验和持久化
+	collaboration domain.CollaborationUsecase
+
 	// 当前房间
 	CurrentRoom string `json:"current_room"`
 
+	// 当前房间内是否拥有编辑权限
+	canEdit bool
+
+	// 是否以旁观者身份加入当前房间，旁观者只读且不占用房间人数上限
+	spectator atomic.Bool
+
+	// 是否为只读事件流订阅，订阅没有 WebSocket 连接，不出现在房间成员中
+	feed bool
+
+	// 当前房间的协作模式
+	mode domain.CollaborationMode
+
 	// 连接时间
 	ConnectedAt time.Time `json:"connected_at"`
 }
@@ -62,21 +96,32 @@ type Message struct {
 }
 
 // NewClient 创建新的客户端连接
-func NewClient(hub *Hub, conn *websocket.Conn, userID int64) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, userID int64, collaboration domain.CollaborationUsecase) *Client {
 	return &Client{
-		ID:          uuid.New().String(),
-		UserID:      userID,
-		conn:        conn,
-		send:        make(chan []byte, 256),
-		hub:         hub,
-		ConnectedAt: time.Now(),
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		conn:          conn,
+		send:          make(chan []byte, sendQueueSize),
+		sendBinary:    make(chan []byte, sendQueueSize),
+		done:          make(chan struct{}),
+		wake:          make(chan struct{}, 1),
+		hub:           hub,
+		collaboration: collaboration,
+		ConnectedAt:   time.Now(),
 	}
 }
 
 // Start 启动客户端连接处理
+// 超过连接数上限时发送带关闭码的关闭帧后断开
 func (c *Client) Start() {
 	// 注册到 Hub
-	c.hub.register <- c
+	if err := c.hub.registerClient(c); err != nil {
+		code, text := rejectCloseCode(err)
+		log.Printf("拒绝 WebSocket 连接: UserID=%d, SocketID=%s, err=%v", c.UserID, c.ID, err)
+		c.shutdown(code, text)
+		go c.writePump()
+		return
+	}
 
 	// 启动读写协程
 	go c.writePump()
@@ -86,19 +131,22 @@ func (c *Client) Start() {
 // readPump 处理从 WebSocket 连接读取消息
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.leaveSession()
+		c.hub.unregisterClient(c)
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	// 消息超过大小上限时读取失败，连接以 1009 关闭
+	config := c.hub.config
+	c.conn.SetReadLimit(config.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(config.PongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.conn.SetReadDeadline(time.Now().Add(config.PongWait))
 		return nil
 	})
 
 	for {
-		_, messageBytes, err := c.conn.ReadMessage()
+		messageType, messageBytes, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket 读取错误: %v", err)
@@ -106,6 +154,12 @@ func (c *Client) readPump() {
 			break
 		}
 
+		// 二进制消息为 Yjs 同步协议
+		if messageType == websocket.BinaryMessage {
+			c.handleBinaryMessage(messageBytes)
+			continue
+		}
+
 		// 解析消息
 		var msg Message
 		if err := json.Unmarshal(messageBytes, &msg); err != nil {
@@ -120,7 +174,7 @@ func (c *Client) readPump() {
 
 // writePump 处理向 WebSocket 连接写入消息
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.hub.config.PingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -128,33 +182,42 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				// Hub 关闭了发送通道
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+		case message := <-c.send:
+			if err := c.writeQueued(message); err != nil {
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
+		case <-c.wake:
+			// 先发送队列中较早的消息，再发送合并后的最新消息，同键消息保持先后顺序
+			if len(c.send) > 0 {
+				if err := c.writeQueued(<-c.send); err != nil {
+					return
+				}
+			}
+			for _, message := range c.takeCoalesced() {
+				c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
+				if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+					return
+				}
 			}
-			w.Write(message)
 
-			// 批量发送队列中的其他消息
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
+		case <-c.done:
+			// Hub 要求关闭连接，排空时先发出队列中的确认和广播
+			if c.closeFlush && c.flushQueued() != nil {
+				return
 			}
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeText))
+			return
 
-			if err := w.Close(); err != nil {
+		case message := <-c.sendBinary:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
+			if err := c.conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
 				return
 			}
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -162,17 +225,60 @@ func (c *Client) writePump() {
 	}
 }
 
+// writeQueued 将消息和发送队列中已有的消息合并为一帧写出
+func (c *Client) writeQueued(message []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
+	w, err := c.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	w.Write(message)
+
+	// 批量发送队列中的其他消息
+	n := len(c.send)
+	for i := 0; i < n; i++ {
+		w.Write([]byte{'\n'})
+		w.Write(<-c.send)
+	}
+
+	return w.Close()
+}
+
+// flushQueued 发出发送队列和合并队列中剩余的消息
+func (c *Client) flushQueued() error {
+	for len(c.sendBinary) > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
+		if err := c.conn.WriteMessage(websocket.BinaryMessage, <-c.sendBinary); err != nil {
+			return err
+		}
+	}
+	if len(c.send) > 0 {
+		if err := c.writeQueued(<-c.send); err != nil {
+			return err
+		}
+	}
+	for _, message := range c.takeCoalesced() {
+		c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
+		if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // handleMessage 处理接收到的消息
 func (c *Client) handleMessage(msg *Message) {
 	switch msg.Event {
 	case "join_room":
 		c.handleJoinRoom(msg.Data)
+	case "resume":
+		c.handleResume(msg.Data)
 	case "leave_room":
 		c.handleLeaveRoom(msg.Data)
 	case "collaboration_operation":
 		c.handleCollaborationOperation(msg.Data)
-	case "cursor_update":
-		c.handleCursorUpdate(msg.Data)
+	case "awareness_update", "cursor_update":
+		c.handleAwarenessUpdate(msg.Data)
 	default:
 		log.Printf("未知消息类型: %s", msg.Event)
 	}
@@ -191,17 +297,126 @@ func (c *Client) handleJoinRoom(data interface{}) {
 		c.SendError("invalid_room_id", "房间ID无效")
 		return
 	}
+	spectator, _ := dataMap["spectator"].(bool)
+
+	c.joinRoom(roomID, nil, spectator)
+}
+
+// handleResume 处理断线重连后的续传
+// 重新加入房间后补发序号 last_seq 之后的操作，操作已被清理时发送快照
+// 补发的操作带有 client_op_id，客户端据此确认断线前未收到确认的操作
+func (c *Client) handleResume(data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		c.SendError("invalid_data", "续传数据格式错误")
+		return
+	}
+
+	var payload struct {
+		RoomID    string `json:"room_id"`
+		LastSeq   int64  `json:"last_seq"`
+		Spectator bool   `json:"spectator"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		c.SendError("invalid_data", "续传数据格式错误")
+		return
+	}
+	if payload.RoomID == "" {
+		c.SendError("invalid_room_id", "房间ID无效")
+		return
+	}
+
+	c.joinRoom(payload.RoomID, &payload.LastSeq, payload.Spectator)
+}
 
-	// 如果已在其他房间，先离开
-	if c.CurrentRoom != "" && c.CurrentRoom != roomID {
+// joinRoom 加入房间，lastSeq 不为空时为断线续传，spectator 为 true 时以只读旁观者身份加入
+func (c *Client) joinRoom(roomID string, lastSeq *int64, spectator bool) {
+	// 如果已在其他房间或切换旁观身份，先离开
+	if c.CurrentRoom != "" && (c.CurrentRoom != roomID || c.spectator.Load() != spectator) {
+		c.leaveSession()
 		c.hub.LeaveRoom(c, c.CurrentRoom)
 	}
+	c.spectator.Store(spectator)
+
+	// 持有房间操作锁，保证读取的修订号之后的操作都能收到
+	unlock := c.hub.LockRoomOperations(roomID)
+	defer unlock()
+
+	// 校验文档权限并登记协作会话
+	state, err := c.joinSession(roomID)
+	if err != nil {
+		c.sendCollaborationError("join_room_failed", err)
+		return
+	}
 
-	// 加入新房间
+	// 加入新房间，本实例房间已满时撤销会话登记
 	if err := c.hub.JoinRoom(c, roomID); err != nil {
+		if state != nil {
+			c.abandonSession(roomID)
+		}
+		if errors.Is(err, errRoomFull) {
+			c.SendError("room_full", "房间人数已满，可以旁观者身份加入")
+			return
+		}
+		if errors.Is(err, errDraining) {
+			c.SendError("server_draining", "服务器正在重启，请稍后重连")
+			return
+		}
 		c.SendError("join_room_failed", err.Error())
 		return
 	}
+
+	if state == nil {
+		return
+	}
+
+	c.canEdit = state.CanEdit
+	c.mode = state.Mode
+	c.Send("session_state", state)
+
+	// Yjs 模式下主动发送服务端状态向量，客户端据此回复缺少的更新，无需按序号续传
+	if state.Mode == domain.CollaborationModeYjs {
+		c.sendSyncStep1(roomID)
+		return
+	}
+
+	if lastSeq != nil {
+		c.sendCatchUp(roomID, *lastSeq)
+	}
+}
+
+// sendCatchUp 补发断线期间缺失的操作，调用方需持有房间操作锁
+func (c *Client) sendCatchUp(roomID string, lastSeq int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), collaborationTimeout)
+	defer cancel()
+
+	catchUp, err := c.collaboration.ResumeSession(ctx, roomID, c.UserID, c.ID, lastSeq)
+	if err != nil {
+		c.sendCollaborationError("resume_failed", err)
+		return
+	}
+
+	if catchUp.Snapshot != nil {
+		c.Send("collaboration_snapshot", catchUp.Snapshot)
+		return
+	}
+
+	// 按修订号分组，与实时广播的消息格式一致
+	for start := 0; start < len(catchUp.Operations); {
+		end := start + 1
+		for end < len(catchUp.Operations) && catchUp.Operations[end].Revision == catchUp.Operations[start].Revision {
+			end++
+		}
+		c.Send("collaboration_operation", operationEvent(catchUp.Operations[start:end]))
+		start = end
+	}
+
+	c.Send("resume_complete", map[string]interface{}{
+		"room_id":  roomID,
+		"revision": catchUp.Revision,
+		"seq":      catchUp.Seq,
+		"replayed": len(catchUp.Operations),
+	})
 }
 
 // handleLeaveRoom 处理离开房间
@@ -210,59 +425,469 @@ func (c *Client) handleLeaveRoom(data interface{}) {
 		return
 	}
 
+	c.leaveSession()
 	c.hub.LeaveRoom(c, c.CurrentRoom)
 }
 
 // handleCollaborationOperation 处理协作操作
+// 操作经服务端转换并分配修订号后，向发送者确认，并将转换后的操作发给房间内其他用户
 func (c *Client) handleCollaborationOperation(data interface{}) {
 	if c.CurrentRoom == "" {
 		c.SendError("not_in_room", "未加入任何房间")
 		return
 	}
 
-	// 广播操作给房间内其他用户
-	c.hub.BroadcastToRoom(c.CurrentRoom, "collaboration_operation", map[string]interface{}{
-		"user_id":   c.UserID,
-		"socket_id": c.ID,
-		"operation": data,
-		"timestamp": time.Now(),
-	})
+	// 未配置协作业务时直接转发原始操作
+	if c.collaboration == nil {
+		c.hub.BroadcastToRoom(c.CurrentRoom, "collaboration_operation", map[string]interface{}{
+			"user_id":   c.UserID,
+			"socket_id": c.ID,
+			"operation": data,
+			"timestamp": time.Now(),
+		})
+		return
+	}
+
+	// 只读用户不允许提交编辑操作
+	if !c.canEdit {
+		c.SendError("permission_denied", "没有编辑权限")
+		return
+	}
+
+	if c.mode == domain.CollaborationModeYjs {
+		c.SendError("collaboration_mode_mismatch", "当前文档使用 Yjs 同步协议")
+		return
+	}
+
+	payload, err := parseOperationPayload(data)
+	if err != nil {
+		c.sendCollaborationError("invalid_operation", err)
+		return
+	}
+
+	// 排空期间不再提交新操作，客户端重连后以相同的 client_op_id 重新提交
+	if !c.hub.beginOperation() {
+		c.SendError("server_draining", "服务器正在重启，请重连后重新提交")
+		return
+	}
+	defer c.hub.endOperation()
+
+	roomID := c.CurrentRoom
+	unlock := c.hub.LockRoomOperations(roomID)
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), collaborationTimeout)
+	defer cancel()
+
+	commit, err := c.collaboration.ApplyOperation(ctx, roomID, c.UserID, c.ID, payload.BaseRevision, payload.ClientOpID, payload.operations())
+	if err != nil {
+		c.sendCollaborationError("operation_failed", err)
+		return
+	}
+
+	ack := map[string]interface{}{
+		"client_op_id":  payload.ClientOpID,
+		"base_revision": payload.BaseRevision,
+		"revision":      commit.Revision,
+		"seq":           commit.Seq,
+		"duplicate":     commit.Duplicate,
+		"timestamp":     time.Now(),
+	}
+
+	// 重发的操作已广播过，被并发删除完全抵消的操作无需广播，两者都只确认
+	var operation interface{}
+	if len(commit.Operations) > 0 && !commit.Duplicate {
+		event := operationEvent(commit.Operations)
+		event["socket_id"] = c.ID
+		operation = event
+	}
+
+	c.hub.DeliverOperation(roomID, c, operation, ack)
 }
 
-// handleCursorUpdate 处理光标更新
-func (c *Client) handleCursorUpdate(data interface{}) {
+// operationEvent 构造同一修订内操作的广播数据
+func operationEvent(operations []*domain.CollaborationOperation) map[string]interface{} {
+	first, last := operations[0], operations[len(operations)-1]
+	return map[string]interface{}{
+		"user_id":      first.UserID,
+		"client_op_id": first.ClientOpID,
+		"revision":     first.Revision,
+		"seq":          last.Seq,
+		"operations":   operations,
+		"timestamp":    first.Timestamp,
+	}
+}
+
+// handleBinaryMessage 处理 Yjs 同步协议的二进制消息
+// 同步第一步回复服务端的差异更新，第二步和增量更新合并后转发给房间内其他客户端，感知消息直接转发
+func (c *Client) handleBinaryMessage(data []byte) {
 	if c.CurrentRoom == "" {
+		c.SendError("not_in_room", "未加入任何房间")
 		return
 	}
 
-	// 广播光标位置给房间内其他用户
-	c.hub.BroadcastToRoom(c.CurrentRoom, "cursor_update", map[string]interface{}{
-		"user_id":   c.UserID,
-		"socket_id": c.ID,
-		"cursor":    data,
-		"timestamp": time.Now(),
-	})
+	if c.collaboration == nil || c.mode != domain.CollaborationModeYjs {
+		c.SendError("collaboration_mode_mismatch", "当前文档不使用 Yjs 同步协议")
+		return
+	}
+
+	msg, err := yjs.DecodeMessage(data)
+	if err != nil {
+		c.SendError("invalid_message", err.Error())
+		return
+	}
+
+	roomID := c.CurrentRoom
+	ctx, cancel := context.WithTimeout(context.Background(), collaborationTimeout)
+	defer cancel()
+
+	switch msg.Type {
+	case yjs.MessageSync:
+		if msg.SyncType == yjs.SyncStep1 {
+			diff, err := c.collaboration.GetUpdateDiff(ctx, roomID, msg.Payload)
+			if err != nil {
+				c.sendCollaborationError("sync_failed", err)
+				return
+			}
+			c.SendBinary(yjs.EncodeSyncStep2(diff))
+			return
+		}
+
+		// 只读客户端回复的同步第二步直接忽略，主动提交的更新返回错误
+		if !c.canEdit {
+			if msg.SyncType == yjs.SyncUpdate {
+				c.SendError("permission_denied", "没有编辑权限")
+			}
+			return
+		}
+		if bytes.Equal(msg.Payload, yjs.EmptyUpdate()) {
+			return
+		}
+		if !c.hub.beginOperation() {
+			c.SendError("server_draining", "服务器正在重启，请重连后重新提交")
+			return
+		}
+		defer c.hub.endOperation()
+
+		if err := c.collaboration.ApplyUpdate(ctx, roomID, c.UserID, c.ID, msg.Payload); err != nil {
+			c.sendCollaborationError("update_failed", err)
+			return
+		}
+		c.hub.BroadcastBinaryToRoom(roomID, yjs.EncodeSyncUpdate(msg.Payload), c)
+
+	case yjs.MessageAwareness:
+		c.hub.BroadcastBinaryToRoom(roomID, data, c)
+	}
+}
+
+// sendSyncStep1 发送服务端合并状态的状态向量
+func (c *Client) sendSyncStep1(roomID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), collaborationTimeout)
+	defer cancel()
+
+	stateVector, err := c.collaboration.GetStateVector(ctx, roomID)
+	if err != nil {
+		c.sendCollaborationError("sync_failed", err)
+		return
+	}
+	c.SendBinary(yjs.EncodeSyncStep1(stateVector))
+}
+
+// handleAwarenessUpdate 处理感知状态更新（选区、输入状态）
+// cursor_update 只携带 position 时按单个光标处理
+func (c *Client) handleAwarenessUpdate(data interface{}) {
+	if c.CurrentRoom == "" {
+		return
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		c.SendError("invalid_awareness", "感知数据格式错误")
+		return
+	}
+
+	var input awarenessInput
+	if err := json.Unmarshal(raw, &input); err != nil {
+		c.SendError("invalid_awareness", "感知数据格式错误")
+		return
+	}
+	if err := input.validate(); err != nil {
+		c.SendError("invalid_awareness", "感知数据无效")
+		return
+	}
+
+	c.hub.UpdateAwareness(c, c.CurrentRoom, input)
+}
+
+// sessionState 加入房间后下发的协作会话状态
+type sessionState struct {
+	RoomID    string                   `json:"room_id"`
+	Mode      domain.CollaborationMode `json:"mode"`
+	Revision  int64                    `json:"revision"`
+	Seq       int64                    `json:"seq"`
+	CanEdit   bool                     `json:"can_edit"`
+	Spectator bool                     `json:"spectator"`
+}
+
+// joinSession 通过协作业务加入会话，未配置协作业务时返回 nil
+func (c *Client) joinSession(roomID string) (*sessionState, error) {
+	if c.collaboration == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), collaborationTimeout)
+	defer cancel()
+
+	spectator := c.spectator.Load()
+	if _, err := c.collaboration.JoinSession(ctx, roomID, c.UserID, c.ID, spectator); err != nil {
+		return nil, err
+	}
+
+	session, err := c.collaboration.GetSession(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	canEdit, err := c.collaboration.CheckCollaborationPermission(ctx, c.UserID, session.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := c.collaboration.GetCollaborationMode(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionState{
+		RoomID:    roomID,
+		Mode:      mode,
+		Revision:  session.Revision,
+		Seq:       session.LastSeq,
+		CanEdit:   canEdit && !spectator,
+		Spectator: spectator,
+	}, nil
+}
+
+// leaveSession 通过协作业务离开当前会话
+func (c *Client) leaveSession() {
+	if c.collaboration == nil || c.CurrentRoom == "" {
+		return
+	}
+
+	c.abandonSession(c.CurrentRoom)
+	c.canEdit = false
+	c.mode = ""
+}
+
+// abandonSession 通过协作业务注销在指定房间的会话登记
+func (c *Client) abandonSession(roomID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), collaborationTimeout)
+	defer cancel()
+
+	if err := c.collaboration.LeaveSession(ctx, roomID, c.ID); err != nil {
+		log.Printf("离开协作会话失败: room=%s, user=%d, err=%v", roomID, c.UserID, err)
+	}
+}
+
+// operationData 客户端提交的单个操作
+type operationData struct {
+	Type     domain.CollaborationOperationType `json:"type"`
+	Position int                               `json:"position"`
+	Content  string                            `json:"content"`
+	Length   int                               `json:"length"`
+	Metadata json.RawMessage                   `json:"metadata"`
+}
+
+// operationPayload 客户端提交的协作操作消息
+// 可以直接携带单个操作字段，也可以通过 operations 提交按顺序执行的多个操作
+type operationPayload struct {
+	operationData
+	BaseRevision int64           `json:"base_revision"`
+	ClientOpID   string          `json:"client_op_id"`
+	Operations   []operationData `json:"operations"`
+}
+
+// operations 转换为协作操作实体
+func (p *operationPayload) operations() []*domain.CollaborationOperation {
+	items := p.Operations
+	if len(items) == 0 {
+		items = []operationData{p.operationData}
+	}
+
+	operations := make([]*domain.CollaborationOperation, 0, len(items))
+	for _, item := range items {
+		operations = append(operations, &domain.CollaborationOperation{
+			Type:     item.Type,
+			Position: item.Position,
+			Content:  item.Content,
+			Length:   item.Length,
+			Metadata: string(item.Metadata),
+		})
+	}
+	return operations
+}
+
+// parseOperationPayload 将客户端提交的任意 JSON 数据解析为协作操作消息
+func parseOperationPayload(data interface{}) (*operationPayload, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, domain.ErrInvalidCollaborationOperation
+	}
+
+	var payload operationPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, domain.ErrInvalidCollaborationOperation
+	}
+	return &payload, nil
+}
+
+// sendCollaborationError 将协作业务错误转换为客户端错误消息
+func (c *Client) sendCollaborationError(code string, err error) {
+	switch {
+	case errors.Is(err, domain.ErrCollaborationPermissionDenied):
+		code = "permission_denied"
+	case errors.Is(err, domain.ErrCollaborationSessionFull):
+		// 编辑人数已达会话上限，客户端可以旁观者身份加入
+		code = "session_full"
+	case errors.Is(err, domain.ErrCollaborationModeMismatch):
+		code = "collaboration_mode_mismatch"
+	case errors.Is(err, domain.ErrDocumentArchived):
+		// 文档已归档，客户端应切换为只读
+		code = "document_archived"
+	case errors.Is(err, domain.ErrCollaborationOpsCompacted):
+		// 客户端的基准修订过旧，需通过 resume 获取快照
+		code = "resync_required"
+	}
+	c.SendError(code, err.Error())
 }
 
 // Send 发送消息给客户端
 func (c *Client) Send(event string, data interface{}) {
-	message := Message{
-		Event: event,
-		Data:  data,
+	if message := encodeMessage(event, data); message != nil {
+		c.sendRaw(message)
 	}
+}
 
-	messageBytes, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("消息序列化失败: %v", err)
+// sendRaw 发送已编码的消息
+// 慢连接策略：发送队列已满说明客户端跟不上消息速度，断开连接，客户端重连后通过 resume 补齐
+func (c *Client) sendRaw(message []byte) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	select {
+	case c.send <- message:
+	default:
+		log.Printf("发送队列已满，断开慢连接: SocketID=%s", c.ID)
+		c.shutdown(websocket.CloseTryAgainLater, "send queue full")
+	}
+}
+
+// sendCoalesced 发送可合并的消息，如感知状态
+// 发送队列已满时只保留同键的最新消息，队列空出后再发送，合并的键过多时断开连接
+func (c *Client) sendCoalesced(key string, message []byte) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	c.coalesceMu.Lock()
+	// 已有待发送的同键消息时直接替换，保证同键消息的先后顺序
+	if _, pending := c.coalesced[key]; pending {
+		c.coalesced[key] = message
+		c.coalesceMu.Unlock()
+		return
+	}
+
+	select {
+	case c.send <- message:
+		c.coalesceMu.Unlock()
+		return
+	default:
+	}
+
+	if len(c.coalesced) >= maxCoalescedMessages {
+		c.coalesceMu.Unlock()
+		log.Printf("合并消息过多，断开慢连接: SocketID=%s", c.ID)
+		c.shutdown(websocket.CloseTryAgainLater, "send queue full")
+		return
+	}
+	if c.coalesced == nil {
+		c.coalesced = make(map[string][]byte)
+	}
+	c.coalesced[key] = message
+	c.coalesceOrder = append(c.coalesceOrder, key)
+	c.coalesceMu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// takeCoalesced 取出所有合并的消息
+func (c *Client) takeCoalesced() [][]byte {
+	c.coalesceMu.Lock()
+	defer c.coalesceMu.Unlock()
+
+	messages := make([][]byte, 0, len(c.coalesceOrder))
+	for _, key := range c.coalesceOrder {
+		messages = append(messages, c.coalesced[key])
+	}
+	c.coalesced = nil
+	c.coalesceOrder = nil
+	return messages
+}
+
+// SendBinary 发送二进制消息给客户端，发送队列已满时断开连接
+func (c *Client) SendBinary(data []byte) {
+	select {
+	case <-c.done:
 		return
+	default:
 	}
 
 	select {
-	case c.send <- messageBytes:
+	case c.sendBinary <- data:
 	default:
-		// 发送队列已满，关闭连接
-		close(c.send)
+		log.Printf("二进制发送队列已满，断开慢连接: SocketID=%s", c.ID)
+		c.shutdown(websocket.CloseTryAgainLater, "send queue full")
+	}
+}
+
+// shutdown 通知写协程发送关闭帧并关闭连接，只有第一次调用生效
+func (c *Client) shutdown(code int, text string) {
+	c.terminate(code, text, false)
+}
+
+// drain 通知写协程发出队列中的消息后发送关闭帧并关闭连接
+func (c *Client) drain(code int, text string) {
+	c.terminate(code, text, true)
+}
+
+// terminate 关闭连接，flush 为 true 时先发出队列中的消息，只有第一次调用生效
+func (c *Client) terminate(code int, text string, flush bool) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeText = text
+		c.closeFlush = flush
+		close(c.done)
+	})
+}
+
+// joinedRooms 获取客户端已加入的房间
+func (c *Client) joinedRooms() []string {
+	c.roomsMu.Lock()
+	defer c.roomsMu.Unlock()
+
+	roomIDs := make([]string, 0, len(c.rooms))
+	for roomID := range c.rooms {
+		roomIDs = append(roomIDs, roomID)
 	}
+	return roomIDs
 }
 
 // SendError 发送错误消息
@@ -277,6 +902,7 @@ func (c *Client) SendError(code, message string) {
 // Close 关闭客户端连接
 func (c *Client) Close() {
 	if c.CurrentRoom != "" {
+		c.leaveSession()
 		c.hub.LeaveRoom(c, c.CurrentRoom)
 	}
 	c.conn.Close()
@@ -0,0 +1,137 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// 排空时等待连接断开的检查间隔
+	drainPollInterval = 50 * time.Millisecond
+)
+
+// errDraining Hub 正在排空，不再接受新连接、加入房间和协作操作
+var errDraining = errors.New("websocket hub is draining")
+
+// drainState 排空状态和正在处理的协作操作数
+type drainState struct {
+	draining bool
+	inflight int
+	idle     chan struct{} // 排空期间正在处理的操作全部完成时关闭
+}
+
+// Drain 排空本实例的连接，用于重启和滚动发布
+// 1. 不再接受新连接、加入房间和新的协作操作
+// 2. 向所有连接发送 server_draining 事件，附带随机的重连等待时间，避免客户端同时重连
+// 3. 等待正在处理的协作操作持久化并确认，确认消息发出后以 1012 (Service Restart) 关闭连接
+// ctx 结束时不再等待，直接关闭剩余连接；排空后仍需调用 Stop 停止 Hub
+func (h *Hub) Drain(ctx context.Context) error {
+	if !h.isRunning() {
+		return errHubNotRunning
+	}
+
+	idle := h.beginDrain()
+	clients := h.allClients()
+	log.Printf("WebSocket Hub 开始排空: clients=%d", len(clients))
+
+	for _, client := range clients {
+		client.Send("server_draining", map[string]interface{}{
+			"reconnect_after_ms": h.reconnectDelay().Milliseconds(),
+			"timestamp":          time.Now(),
+		})
+	}
+
+	// 等待正在处理的操作完成，并等房间投递完确认和广播
+	var err error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Printf("等待协作操作完成超时，剩余 %d 个", h.inflightOperations())
+	}
+	if err == nil {
+		h.flush()
+	}
+
+	// 发出队列中的消息后关闭连接，事件流订阅随之结束
+	for _, client := range h.allClients() {
+		client.drain(websocket.CloseServiceRestart, "server restarting")
+	}
+	h.closeFeeds()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for h.connections.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Printf("等待连接断开超时，剩余 %d 个", h.connections.Load())
+			return ctx.Err()
+		}
+	}
+
+	log.Println("WebSocket Hub 排空完成")
+	return err
+}
+
+// IsDraining 检查 Hub 是否正在排空
+func (h *Hub) IsDraining() bool {
+	h.drainMu.Lock()
+	defer h.drainMu.Unlock()
+	return h.drain.draining
+}
+
+// beginDrain 进入排空状态，返回正在处理的操作全部完成时关闭的通道
+func (h *Hub) beginDrain() <-chan struct{} {
+	h.drainMu.Lock()
+	defer h.drainMu.Unlock()
+
+	if h.drain.idle == nil {
+		h.drain.draining = true
+		h.drain.idle = make(chan struct{})
+		if h.drain.inflight == 0 {
+			close(h.drain.idle)
+		}
+	}
+	return h.drain.idle
+}
+
+// beginOperation 登记一个正在处理的协作操作，排空期间返回 false，调用方不应再处理该操作
+func (h *Hub) beginOperation() bool {
+	h.drainMu.Lock()
+	defer h.drainMu.Unlock()
+
+	if h.drain.draining {
+		return false
+	}
+	h.drain.inflight++
+	return true
+}
+
+// endOperation 注销一个正在处理的协作操作，需在确认消息投递到房间队列之后调用
+func (h *Hub) endOperation() {
+	h.drainMu.Lock()
+	defer h.drainMu.Unlock()
+
+	h.drain.inflight--
+	if h.drain.draining && h.drain.inflight == 0 {
+		close(h.drain.idle)
+	}
+}
+
+// inflightOperations 获取正在处理的协作操作数
+func (h *Hub) inflightOperations() int {
+	h.drainMu.Lock()
+	defer h.drainMu.Unlock()
+	return h.drain.inflight
+}
+
+// reconnectDelay 获取建议客户端重连前等待的时间，在 [0, DrainReconnectDelay) 内随机分布
+func (h *Hub) reconnectDelay() time.Duration {
+	return time.Duration(rand.Int63n(int64(h.config.DrainReconnectDelay)))
+}
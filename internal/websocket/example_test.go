@@ -1,14 +1,25 @@
 package websocket
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"DOC/domain"
+
+	"github.com/gorilla/websocket"
 )
 
 // TestHub 测试 Hub 基本功能
 func TestHub(t *testing.T) {
 	// 创建 Hub
-	hub := NewHub(nil)
+	hub := NewHub(nil, nil, HubConfig{})
 
 	// 启动 Hub
 	hub.Start()
@@ -30,9 +41,23 @@ func TestHub(t *testing.T) {
 	}
 }
 
+// TestHubRestart 测试停止后的 Hub 不能再次启动
+func TestHubRestart(t *testing.T) {
+	hub := NewHub(nil, nil, HubConfig{})
+	hub.Start()
+	hub.Stop()
+
+	// 再次启动不生效，也不会因为重复关闭停止信号而崩溃
+	hub.Start()
+	if hub.isRunning() {
+		t.Error("停止后的 Hub 不应该再次运行")
+	}
+	hub.Stop()
+}
+
 // TestBroadcastMessage 测试消息广播
 func TestBroadcastMessage(t *testing.T) {
-	hub := NewHub(nil)
+	hub := NewHub(nil, nil, HubConfig{})
 	hub.Start()
 	defer hub.Stop()
 
@@ -47,7 +72,7 @@ func TestBroadcastMessage(t *testing.T) {
 
 // TestRoomManagement 测试房间管理
 func TestRoomManagement(t *testing.T) {
-	hub := NewHub(nil)
+	hub := NewHub(nil, nil, HubConfig{})
 	hub.Start()
 	defer hub.Stop()
 
@@ -57,3 +82,593 @@ func TestRoomManagement(t *testing.T) {
 		t.Error("不存在的房间应该返回空用户列表")
 	}
 }
+
+// memoryBus 进程内消息总线，用于模拟多个实例
+type memoryBus struct {
+	mu      sync.Mutex
+	nodes   []*memoryBackplane
+	members map[string]map[string]*domain.HubMember
+}
+
+// memoryBackplane 单个实例在 memoryBus 上的消息总线
+type memoryBackplane struct {
+	bus     *memoryBus
+	id      string
+	handler func(message *domain.HubMessage)
+	ready   chan struct{}
+}
+
+func (b *memoryBus) join(id string) *memoryBackplane {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	node := &memoryBackplane{bus: b, id: id, ready: make(chan struct{})}
+	b.nodes = append(b.nodes, node)
+	return node
+}
+
+func (m *memoryBackplane) NodeID() string { return m.id }
+
+func (m *memoryBackplane) Publish(ctx context.Context, message *domain.HubMessage) error {
+	message.NodeID = m.id
+	var handlers []func(message *domain.HubMessage)
+	m.bus.mu.Lock()
+	for _, node := range m.bus.nodes {
+		if node != m && node.handler != nil {
+			handlers = append(handlers, node.handler)
+		}
+	}
+	m.bus.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(message)
+	}
+	return nil
+}
+
+func (m *memoryBackplane) Subscribe(ctx context.Context, handler func(message *domain.HubMessage)) error {
+	m.bus.mu.Lock()
+	m.handler = handler
+	m.bus.mu.Unlock()
+	close(m.ready)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (m *memoryBackplane) AddMember(ctx context.Context, member *domain.HubMember) error {
+	m.bus.mu.Lock()
+	defer m.bus.mu.Unlock()
+
+	member.NodeID = m.id
+	if m.bus.members[member.RoomID] == nil {
+		m.bus.members[member.RoomID] = map[string]*domain.HubMember{}
+	}
+	m.bus.members[member.RoomID][member.SocketID] = member
+	return nil
+}
+
+func (m *memoryBackplane) RemoveMember(ctx context.Context, roomID string, socketID string) error {
+	m.bus.mu.Lock()
+	defer m.bus.mu.Unlock()
+
+	delete(m.bus.members[roomID], socketID)
+	return nil
+}
+
+func (m *memoryBackplane) GetMembers(ctx context.Context, roomID string) ([]*domain.HubMember, error) {
+	m.bus.mu.Lock()
+	defer m.bus.mu.Unlock()
+
+	members := make([]*domain.HubMember, 0, len(m.bus.members[roomID]))
+	for _, member := range m.bus.members[roomID] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// newTestClient 创建不带网络连接的客户端并注册到 Hub
+func newTestClient(hub *Hub, socketID string, userID int64) *Client {
+	client := &Client{
+		ID:         socketID,
+		UserID:     userID,
+		hub:        hub,
+		send:       make(chan []byte, 64),
+		sendBinary: make(chan []byte, 64),
+		done:       make(chan struct{}),
+		wake:       make(chan struct{}, 1),
+	}
+	hub.registerClient(client)
+	return client
+}
+
+// receivedEvents 等待客户端所在 Hub 的房间处理完已入队的消息，并取出发送队列中的所有事件
+func receivedEvents(client *Client) map[string]Message {
+	client.hub.flush()
+
+	events := map[string]Message{}
+	for {
+		select {
+		case raw := <-client.send:
+			var msg Message
+			if err := json.Unmarshal(raw, &msg); err == nil {
+				events[msg.Event] = msg
+			}
+		default:
+			return events
+		}
+	}
+}
+
+// TestBackplaneFanOut 测试多个实例通过消息总线互通
+func TestBackplaneFanOut(t *testing.T) {
+	bus := &memoryBus{members: map[string]map[string]*domain.HubMember{}}
+	nodeA, nodeB := bus.join("node-a"), bus.join("node-b")
+
+	hubA := NewHub(nil, nodeA, HubConfig{})
+	hubB := NewHub(nil, nodeB, HubConfig{})
+	hubA.Start()
+	hubB.Start()
+	defer hubA.Stop()
+	defer hubB.Stop()
+	<-nodeA.ready
+	<-nodeB.ready
+
+	roomID := domain.DocumentRoomID(1)
+	alice := newTestClient(hubA, "socket-a", 1)
+	bob := newTestClient(hubB, "socket-b", 2)
+
+	hubA.JoinRoom(alice, roomID)
+	receivedEvents(alice)
+	hubB.JoinRoom(bob, roomID)
+
+	// 其他实例上的成员收到加入通知，新成员看到集群内的所有成员
+	if _, ok := receivedEvents(alice)["user_joined"]; !ok {
+		t.Error("其他实例上的成员应该收到 user_joined")
+	}
+	if users := hubA.GetRoomUsers(roomID); len(users) != 2 {
+		t.Errorf("集群房间成员数量应该为 2，实际为 %d", len(users))
+	}
+	receivedEvents(bob)
+
+	// 房间广播和二进制消息跨实例投递，发送者被排除
+	hubA.BroadcastToRoom(roomID, "cursor_update", map[string]interface{}{"position": 3})
+	hubA.BroadcastBinaryToRoom(roomID, []byte{1, 2, 3}, alice)
+	if _, ok := receivedEvents(bob)["cursor_update"]; !ok {
+		t.Error("其他实例上的成员应该收到房间广播")
+	}
+	select {
+	case data := <-bob.sendBinary:
+		if len(data) != 3 {
+			t.Errorf("二进制消息内容不正确: %v", data)
+		}
+	default:
+		t.Error("其他实例上的成员应该收到二进制消息")
+	}
+	if len(alice.sendBinary) != 0 {
+		t.Error("二进制消息不应发回发送者")
+	}
+
+	// 按用户发送跨实例投递
+	hubB.SendToUser(alice.UserID, "notification", map[string]interface{}{"title": "hello"})
+	if _, ok := receivedEvents(alice)["notification"]; !ok {
+		t.Error("其他实例上的用户应该收到消息")
+	}
+
+	// 离开房间后其他实例收到通知，集群成员同步减少
+	receivedEvents(alice)
+	hubB.LeaveRoom(bob, roomID)
+	if _, ok := receivedEvents(alice)["user_left"]; !ok {
+		t.Error("其他实例上的成员应该收到 user_left")
+	}
+	if users := hubA.GetRoomUsers(roomID); len(users) != 1 {
+		t.Errorf("集群房间成员数量应该为 1，实际为 %d", len(users))
+	}
+}
+
+// TestAwareness 测试感知状态的快照、节流和过期
+func TestAwareness(t *testing.T) {
+	bus := &memoryBus{members: map[string]map[string]*domain.HubMember{}}
+	nodeA, nodeB := bus.join("node-a"), bus.join("node-b")
+
+	hubA := NewHub(nil, nodeA, HubConfig{})
+	hubB := NewHub(nil, nodeB, HubConfig{})
+	hubA.Start()
+	hubB.Start()
+	defer hubA.Stop()
+	defer hubB.Stop()
+	<-nodeA.ready
+	<-nodeB.ready
+
+	roomID := domain.DocumentRoomID(1)
+	alice := newTestClient(hubA, "socket-a", 1)
+	bob := newTestClient(hubA, "socket-b", 2)
+	carol := newTestClient(hubB, "socket-c", 3)
+
+	hubA.JoinRoom(alice, roomID)
+	hubA.JoinRoom(bob, roomID)
+	hubB.JoinRoom(carol, roomID)
+
+	// 新成员的快照包含房间内已有成员的状态和固定颜色
+	var joined struct {
+		Awareness []AwarenessState `json:"awareness"`
+	}
+	raw, _ := json.Marshal(receivedEvents(bob)["room_joined"].Data)
+	json.Unmarshal(raw, &joined)
+	if len(joined.Awareness) != 2 {
+		t.Fatalf("快照应包含 2 个感知状态，实际为 %d", len(joined.Awareness))
+	}
+	for _, state := range joined.Awareness {
+		if state.Color != awarenessColor(state.UserID) {
+			t.Errorf("用户 %d 的颜色不固定", state.UserID)
+		}
+	}
+
+	// 节流间隔内的多次更新只广播最后一次
+	receivedEvents(alice)
+	time.Sleep(awarenessThrottle)
+	hubA.UpdateAwareness(bob, roomID, awarenessInput{Selections: []Selection{{Anchor: 1, Head: 1}}, Status: AwarenessStatusTyping})
+	hubA.UpdateAwareness(bob, roomID, awarenessInput{Selections: []Selection{{Anchor: 2, Head: 5}}, Status: AwarenessStatusTyping})
+	if _, ok := receivedEvents(alice)[eventAwarenessUpdate]; !ok {
+		t.Fatal("第一次更新应立即广播")
+	}
+	time.Sleep(2 * awarenessThrottle)
+	var state AwarenessState
+	raw, _ = json.Marshal(receivedEvents(alice)[eventAwarenessUpdate].Data)
+	json.Unmarshal(raw, &state)
+	if len(state.Selections) != 1 || state.Selections[0].Head != 5 || state.Status != AwarenessStatusTyping {
+		t.Errorf("节流后应广播最新状态，实际为 %+v", state)
+	}
+
+	// 输入状态和空闲状态随无活动时长变化
+	entry := &awarenessEntry{reported: AwarenessStatusTyping, state: AwarenessState{LastActiveAt: time.Now()}}
+	if status := entry.effectiveStatus(time.Now().Add(awarenessTypingTimeout)); status != AwarenessStatusActive {
+		t.Errorf("输入超时后应为 active，实际为 %s", status)
+	}
+	if status := entry.effectiveStatus(time.Now().Add(awarenessIdleTimeout)); status != AwarenessStatusIdle {
+		t.Errorf("无活动后应为 idle，实际为 %s", status)
+	}
+
+	// 其他实例停止心跳后状态过期
+	if len(hubA.awarenessSnapshot(roomID)) != 3 {
+		t.Fatal("应收到其他实例的感知状态")
+	}
+	receivedEvents(alice)
+	hubA.tickAwareness(time.Now().Add(awarenessExpire + time.Second))
+	if len(hubA.awarenessSnapshot(roomID)) != 2 {
+		t.Error("其他实例的感知状态应已过期")
+	}
+	if _, ok := receivedEvents(alice)[eventAwarenessRemoved]; !ok {
+		t.Error("状态过期后应通知房间内的连接")
+	}
+}
+
+// TestSlowConsumer 测试慢连接策略：感知状态合并，其他消息堆积时断开连接
+func TestSlowConsumer(t *testing.T) {
+	hub := NewHub(nil, nil, HubConfig{})
+	hub.Start()
+	defer hub.Stop()
+
+	roomID := domain.DocumentRoomID(1)
+	alice := newTestClient(hub, "socket-a", 1)
+	slow := newTestClient(hub, "socket-s", 2)
+	hub.JoinRoom(alice, roomID)
+	hub.JoinRoom(slow, roomID)
+	receivedEvents(slow)
+
+	// 填满发送队列
+	for len(slow.send) < cap(slow.send) {
+		slow.send <- []byte("{}")
+	}
+
+	// 同一连接的感知状态只保留最新一条
+	for i := 0; i < 10; i++ {
+		hub.broadcastAwareness(roomID, eventAwarenessUpdate, map[string]interface{}{"position": i}, alice.ID, alice.ID)
+	}
+	hub.flush()
+	select {
+	case <-slow.done:
+		t.Fatal("感知状态堆积不应断开连接")
+	default:
+	}
+	coalesced := slow.takeCoalesced()
+	if len(coalesced) != 1 {
+		t.Fatalf("应合并为 1 条消息，实际为 %d", len(coalesced))
+	}
+	var msg struct {
+		Data struct {
+			Position int `json:"position"`
+		} `json:"data"`
+	}
+	json.Unmarshal(coalesced[0], &msg)
+	if msg.Data.Position != 9 {
+		t.Errorf("应保留最新的感知状态，实际为 %d", msg.Data.Position)
+	}
+
+	// 不可合并的消息无法入队时断开连接
+	hub.BroadcastToRoom(roomID, "collaboration_operation", map[string]interface{}{"revision": 1})
+	hub.flush()
+	select {
+	case <-slow.done:
+	default:
+		t.Error("发送队列已满时应断开慢连接")
+	}
+	if _, ok := receivedEvents(alice)["collaboration_operation"]; !ok {
+		t.Error("慢连接不应影响房间内其他连接")
+	}
+}
+
+// TestConnectionLimits 测试连接数上限和旁观者加入
+func TestConnectionLimits(t *testing.T) {
+	hub := NewHub(nil, nil, HubConfig{MaxConnections: 3, MaxConnectionsPerUser: 2, MaxConnectionsPerRoom: 1})
+	hub.Start()
+	defer hub.Stop()
+
+	alice1 := newTestClient(hub, "socket-a1", 1)
+	newTestClient(hub, "socket-a2", 1)
+
+	// 单用户连接数超过上限
+	err := hub.registerClient(&Client{ID: "socket-a3", UserID: 1, send: make(chan []byte, 1)})
+	if code, _ := rejectCloseCode(err); code != CloseUserConnectionLimit {
+		t.Errorf("应以用户连接数上限拒绝，实际为 %v", err)
+	}
+
+	bob := newTestClient(hub, "socket-b", 2)
+	err = hub.registerClient(&Client{ID: "socket-c", UserID: 3, send: make(chan []byte, 1)})
+	if code, _ := rejectCloseCode(err); code != websocket.CloseTryAgainLater {
+		t.Errorf("应以实例连接数上限拒绝，实际为 %v", err)
+	}
+
+	// 房间名额已满时只能以旁观者身份加入
+	roomID := domain.DocumentRoomID(1)
+	if err := hub.JoinRoom(alice1, roomID); err != nil {
+		t.Fatalf("加入房间失败: %v", err)
+	}
+	if err := hub.JoinRoom(bob, roomID); err != errRoomFull {
+		t.Errorf("房间已满时应拒绝加入，实际为 %v", err)
+	}
+	bob.spectator.Store(true)
+	if err := hub.JoinRoom(bob, roomID); err != nil {
+		t.Errorf("旁观者不应占用房间名额: %v", err)
+	}
+
+	// 断开后释放名额
+	hub.unregisterClient(alice1)
+	if stats := hub.GetStats(); stats["total_clients"] != 2 {
+		t.Errorf("断开后连接数应为 2，实际为 %v", stats["total_clients"])
+	}
+	carol := newTestClient(hub, "socket-c", 3)
+	if err := hub.JoinRoom(carol, roomID); err != nil {
+		t.Errorf("成员离开后应释放房间名额: %v", err)
+	}
+}
+
+// TestDocumentFeed 测试文档只读事件流
+func TestDocumentFeed(t *testing.T) {
+	hub := NewHub(nil, nil, HubConfig{MaxConnectionsPerRoom: 1})
+	hub.Start()
+	defer hub.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := hub.SubscribeDocument(ctx, 1)
+	if err != nil {
+		t.Fatalf("订阅事件流失败: %v", err)
+	}
+	next := func() *domain.DocumentEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(3 * time.Second):
+			t.Fatal("等待事件超时")
+			return nil
+		}
+	}
+
+	if event := next(); event.Type != domain.DocumentEventPresenceCount || event.Data["count"] != 0 {
+		t.Errorf("应首先收到在线人数，实际为 %+v", event)
+	}
+
+	// 订阅不占用房间名额，也不出现在成员列表中
+	roomID := domain.DocumentRoomID(1)
+	alice := newTestClient(hub, "socket-a", 1)
+	if err := hub.JoinRoom(alice, roomID); err != nil {
+		t.Fatalf("加入房间失败: %v", err)
+	}
+	if users := hub.GetRoomUsers(roomID); len(users) != 1 {
+		t.Errorf("成员列表不应包含事件流订阅，实际为 %v", users)
+	}
+
+	// 标题变更立即推送，协作操作合并为内容变更
+	hub.BroadcastToRoom(roomID, "collaboration_operation", map[string]interface{}{"user_id": 1, "revision": 1, "seq": 1})
+	hub.BroadcastToRoom(roomID, "collaboration_operation", map[string]interface{}{"user_id": 1, "revision": 2, "seq": 3})
+	hub.PublishDocumentEvent(ctx, domain.NewDocumentEvent(domain.DocumentEventTitleChanged, 1, map[string]interface{}{"title": "新标题"}))
+	if event := next(); event.Type != domain.DocumentEventTitleChanged || event.Data["title"] != "新标题" {
+		t.Errorf("应收到标题变更，实际为 %+v", event)
+	}
+
+	received := make(map[domain.DocumentEventType]*domain.DocumentEvent)
+	for len(received) < 2 {
+		event := next()
+		received[event.Type] = event
+	}
+	if event := received[domain.DocumentEventContentChanged]; event == nil || event.Data["revision"] != int64(2) {
+		t.Errorf("内容变更应只保留最新修订，实际为 %+v", event)
+	}
+	if event := received[domain.DocumentEventPresenceCount]; event == nil || event.Data["count"] != 1 {
+		t.Errorf("在线人数应为 1，实际为 %+v", event)
+	}
+
+	// 文档删除后事件流结束
+	hub.PublishDocumentEvent(ctx, domain.NewDocumentEvent(domain.DocumentEventDeleted, 1, nil))
+	if event := next(); event.Type != domain.DocumentEventDeleted {
+		t.Errorf("应收到文档删除事件，实际为 %+v", event)
+	}
+	if _, ok := <-events; ok {
+		t.Error("文档删除后事件通道应关闭")
+	}
+}
+
+// TestDrain 测试排空：通知客户端、等待正在处理的操作确认后以 1012 关闭连接
+func TestDrain(t *testing.T) {
+	hub := NewHub(nil, nil, HubConfig{})
+	hub.Start()
+	defer hub.Stop()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		NewClient(hub, conn, 1, nil).Start()
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("建立连接失败: %v", err)
+	}
+	defer conn.Close()
+
+	// 按顺序读取事件，同一帧可能包含多条消息
+	var pending [][]byte
+	next := func() (string, error) {
+		for len(pending) == 0 {
+			conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return "", err
+			}
+			pending = bytes.Split(data, []byte{'\n'})
+		}
+		var msg Message
+		json.Unmarshal(pending[0], &msg)
+		pending = pending[1:]
+		return msg.Event, nil
+	}
+	if event, err := next(); event != "connected" {
+		t.Fatalf("应首先收到 connected，实际为 %q, err=%v", event, err)
+	}
+
+	// 模拟一个正在处理的操作
+	if !hub.beginOperation() {
+		t.Fatal("排空前应接受操作")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() { result <- hub.Drain(ctx) }()
+
+	if event, err := next(); event != "server_draining" {
+		t.Fatalf("应收到 server_draining，实际为 %q, err=%v", event, err)
+	}
+	if hub.beginOperation() {
+		t.Error("排空期间不应接受新操作")
+	}
+	if err := hub.registerClient(&Client{ID: "socket-late", UserID: 2}); err != errDraining {
+		t.Errorf("排空期间应拒绝新连接，实际为 %v", err)
+	}
+
+	// 操作确认前不关闭连接
+	select {
+	case err := <-result:
+		t.Fatalf("操作完成前排空不应结束: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	hub.SendToUser(1, "operation_ack", map[string]interface{}{"client_op_id": "op-1"})
+	hub.endOperation()
+
+	if event, err := next(); event != "operation_ack" {
+		t.Fatalf("关闭前应发出确认，实际为 %q, err=%v", event, err)
+	}
+	_, err = next()
+	if !websocket.IsCloseError(err, websocket.CloseServiceRestart) {
+		t.Errorf("应以 1012 关闭连接，实际为 %v", err)
+	}
+
+	if err := <-result; err != nil {
+		t.Errorf("排空失败: %v", err)
+	}
+}
+
+// TestRoomOperationLock 测试房间操作锁：等待者持有引用期间锁不被删除，全部释放后从注册表移除
+func TestRoomOperationLock(t *testing.T) {
+	hub := NewHub(nil, nil, HubConfig{})
+
+	unlock := hub.LockRoomOperations("room-1")
+	var holding sync.Mutex
+	held := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := hub.LockRoomOperations("room-1")
+			holding.Lock()
+			held++
+			if held > 1 {
+				t.Error("同一时刻只能有一个持有者")
+			}
+			holding.Unlock()
+			time.Sleep(time.Millisecond)
+			holding.Lock()
+			held--
+			holding.Unlock()
+			release()
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	unlock()
+	wg.Wait()
+
+	hub.operationMu.Lock()
+	remaining := len(hub.operationLocks)
+	hub.operationMu.Unlock()
+	if remaining != 0 {
+		t.Errorf("锁全部释放后应从注册表删除，剩余 %d", remaining)
+	}
+}
+
+// TestContentReset 测试内容被整体替换后，各实例的协作连接收到通知并以 4009 关闭
+func TestContentReset(t *testing.T) {
+	bus := &memoryBus{members: map[string]map[string]*domain.HubMember{}}
+	nodeA, nodeB := bus.join("node-a"), bus.join("node-b")
+
+	hubA := NewHub(nil, nodeA, HubConfig{})
+	hubB := NewHub(nil, nodeB, HubConfig{})
+	hubA.Start()
+	hubB.Start()
+	defer hubA.Stop()
+	defer hubB.Stop()
+	<-nodeA.ready
+	<-nodeB.ready
+
+	roomID := domain.DocumentRoomID(1)
+	alice := newTestClient(hubA, "socket-a", 1)
+	bob := newTestClient(hubB, "socket-b", 2)
+	hubA.JoinRoom(alice, roomID)
+	hubB.JoinRoom(bob, roomID)
+	receivedEvents(alice)
+	receivedEvents(bob)
+
+	hubA.PublishDocumentEvent(context.Background(), domain.NewDocumentEvent(domain.DocumentEventContentReset, 1, nil))
+
+	for _, client := range []*Client{alice, bob} {
+		if _, ok := receivedEvents(client)[string(domain.DocumentEventContentReset)]; !ok {
+			t.Errorf("%s 应该在关闭前收到 content_reset", client.ID)
+		}
+		select {
+		case <-client.done:
+			if client.closeCode != CloseResyncRequired {
+				t.Errorf("%s 应以 %d 关闭，实际为 %d", client.ID, CloseResyncRequired, client.closeCode)
+			}
+		case <-time.After(3 * time.Second):
+			t.Errorf("%s 应该被关闭", client.ID)
+		}
+	}
+}
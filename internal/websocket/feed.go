@@ -0,0 +1,268 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"DOC/domain"
+	"DOC/pkg/yjs"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// 事件流合并内容变更和在线人数的间隔，避免逐个操作推送
+	feedFlushInterval = time.Second
+
+	// 事件流输出通道长度
+	feedBufferSize = 16
+)
+
+// PublishDocumentEvent 向文档协作房间广播事件，配置消息总线时同时转发给其他实例
+// 内容被整体替换时，各实例在广播之后关闭房间内的协作连接
+func (h *Hub) PublishDocumentEvent(ctx context.Context, event *domain.DocumentEvent) {
+	roomID := domain.DocumentRoomID(event.DocumentID)
+	h.BroadcastToRoom(roomID, string(event.Type), event)
+	if event.Type == domain.DocumentEventContentReset {
+		h.resyncRoom(roomID)
+	}
+}
+
+// resyncRoom 发出队列中的消息后以 CloseResyncRequired 关闭本实例房间内的协作连接，事件流订阅不受影响
+func (h *Hub) resyncRoom(roomID string) {
+	r := h.lookupRoom(roomID)
+	if r == nil {
+		return
+	}
+	r.enqueue(func() {
+		for client := range r.clients {
+			if !client.feed {
+				client.drain(CloseResyncRequired, "document content replaced")
+			}
+		}
+	})
+}
+
+// SubscribeDocument 订阅文档的只读事件流
+// 订阅方以不可见的旁观成员加入房间，复用房间广播，不出现在成员列表中，也不占用房间名额
+// 房间广播被转换为文档事件：协作操作、Yjs 更新合并为内容变更，成员进出合并为在线人数
+func (h *Hub) SubscribeDocument(ctx context.Context, documentID int64) (<-chan *domain.DocumentEvent, error) {
+	if !h.isRunning() || h.IsDraining() {
+		return nil, domain.ErrDocumentFeedUnavailable
+	}
+	if count := h.connections.Add(1); h.config.MaxConnections > 0 && count > int64(h.config.MaxConnections) {
+		h.connections.Add(-1)
+		return nil, domain.ErrDocumentFeedUnavailable
+	}
+
+	client := &Client{
+		ID:          uuid.New().String(),
+		feed:        true,
+		send:        make(chan []byte, sendQueueSize),
+		sendBinary:  make(chan []byte, sendQueueSize),
+		done:        make(chan struct{}),
+		wake:        make(chan struct{}, 1),
+		hub:         h,
+		ConnectedAt: time.Now(),
+	}
+	client.spectator.Store(true)
+
+	roomID := domain.DocumentRoomID(documentID)
+	r, err := h.acquireRoom(client, roomID, true)
+	if err != nil {
+		h.connections.Add(-1)
+		return nil, err
+	}
+	r.enqueue(func() {
+		r.clients[client] = true
+	})
+
+	events := make(chan *domain.DocumentEvent, feedBufferSize)
+	go h.runFeed(ctx, client, roomID, documentID, events)
+	return events, nil
+}
+
+// documentFeed 单个订阅的事件合并状态
+type documentFeed struct {
+	documentID int64
+	content    *domain.DocumentEvent // 待发送的内容变更，只保留最新一条
+	presence   int                   // 上次发送的在线人数
+	dirty      bool                  // 成员有进出，需要重新统计在线人数
+}
+
+// runFeed 将房间广播转换为文档事件，订阅结束时离开房间并关闭事件通道
+func (h *Hub) runFeed(ctx context.Context, client *Client, roomID string, documentID int64, events chan<- *domain.DocumentEvent) {
+	defer func() {
+		h.leaveFeed(client, roomID)
+		h.connections.Add(-1)
+		close(events)
+	}()
+
+	emit := func(event *domain.DocumentEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	feed := &documentFeed{documentID: documentID, presence: -1, dirty: true}
+	if !h.flushFeed(feed, roomID, emit) {
+		return
+	}
+
+	ticker := time.NewTicker(feedFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-client.done:
+			return
+		case <-h.stopCh:
+			return
+
+		case message := <-client.send:
+			event := feed.apply(message)
+			if event == nil {
+				continue
+			}
+			// 文档删除后不会再有其他事件，先发出待发送的内容变更
+			if event.Type == domain.DocumentEventDeleted {
+				if feed.content != nil {
+					emit(feed.content)
+				}
+				emit(event)
+				return
+			}
+			if !emit(event) {
+				return
+			}
+
+		case message := <-client.sendBinary:
+			// Yjs 同步消息携带内容更新，感知消息忽略
+			if msg, err := yjs.DecodeMessage(message); err == nil && msg.Type == yjs.MessageSync {
+				feed.content = domain.NewDocumentEvent(domain.DocumentEventContentChanged, documentID, nil)
+			}
+
+		case <-client.wake:
+			// 合并的消息只有感知状态，事件流不需要
+			client.takeCoalesced()
+
+		case <-ticker.C:
+			if !h.flushFeed(feed, roomID, emit) {
+				return
+			}
+		}
+	}
+}
+
+// apply 处理一条房间广播，需要立即发送的事件直接返回，内容变更和成员进出只记录
+func (f *documentFeed) apply(message []byte) *domain.DocumentEvent {
+	var msg struct {
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil
+	}
+
+	switch msg.Event {
+	case "collaboration_operation":
+		var operation struct {
+			UserID   int64 `json:"user_id"`
+			Revision int64 `json:"revision"`
+			Seq      int64 `json:"seq"`
+		}
+		if err := json.Unmarshal(msg.Data, &operation); err != nil {
+			return nil
+		}
+		f.content = domain.NewDocumentEvent(domain.DocumentEventContentChanged, f.documentID, map[string]interface{}{
+			"user_id":  operation.UserID,
+			"revision": operation.Revision,
+			"seq":      operation.Seq,
+		})
+
+	case "user_joined", "user_left":
+		f.dirty = true
+
+	case string(domain.DocumentEventContentChanged), string(domain.DocumentEventContentReset), string(domain.DocumentEventTitleChanged),
+		string(domain.DocumentEventDeleted), string(domain.DocumentEventArchived), string(domain.DocumentEventUnarchived):
+		var event domain.DocumentEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			return nil
+		}
+		switch event.Type {
+		case domain.DocumentEventContentChanged:
+			f.content = &event
+			return nil
+		case domain.DocumentEventContentReset:
+			// 整体替换之前的内容变更已无意义
+			f.content = nil
+		}
+		return &event
+	}
+
+	return nil
+}
+
+// flushFeed 发送合并后的内容变更，在线人数变化时发送新的人数
+func (h *Hub) flushFeed(feed *documentFeed, roomID string, emit func(*domain.DocumentEvent) bool) bool {
+	if feed.content != nil {
+		if !emit(feed.content) {
+			return false
+		}
+		feed.content = nil
+	}
+
+	if !feed.dirty {
+		return true
+	}
+	feed.dirty = false
+
+	// 同一用户的多个连接只计一次
+	users := make(map[int64]bool)
+	for _, userID := range h.GetRoomUsers(roomID) {
+		users[userID] = true
+	}
+	if len(users) == feed.presence {
+		return true
+	}
+	feed.presence = len(users)
+	return emit(domain.NewDocumentEvent(domain.DocumentEventPresenceCount, feed.documentID, map[string]interface{}{
+		"count": feed.presence,
+	}))
+}
+
+// leaveFeed 将事件流订阅移出房间，不通知其他成员
+func (h *Hub) leaveFeed(client *Client, roomID string) {
+	client.shutdown(0, "")
+
+	r, release := h.releaseRoom(client, roomID)
+	if r == nil {
+		return
+	}
+	r.enqueue(func() {
+		delete(r.clients, client)
+	})
+	release()
+
+	log.Printf("文档事件流订阅结束: room=%s, SubscriptionID=%s", roomID, client.ID)
+}
+
+// closeFeeds 结束本实例上的所有事件流订阅
+func (h *Hub) closeFeeds() {
+	for _, r := range h.allRooms() {
+		r.enqueue(func() {
+			for client := range r.clients {
+				if client.feed {
+					client.shutdown(0, "")
+				}
+			}
+		})
+	}
+}
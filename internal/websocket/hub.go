@@ -1,65 +1,152 @@
 package websocket
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"DOC/domain"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// 消息总线调用超时时间
+	backplaneTimeout = 3 * time.Second
+
+	// 消息总线订阅断开后的重试间隔
+	backplaneRetryInterval = 2 * time.Second
 )
 
+// HubConfig Hub 配置
+type HubConfig struct {
+	ReadBufferSize        int           `json:"read_buffer_size"`         // 读缓冲区大小，默认1024
+	WriteBufferSize       int           `json:"write_buffer_size"`        // 写缓冲区大小，默认1024
+	MaxMessageSize        int64         `json:"max_message_size"`         // 单条消息最大字节数，默认1MB
+	PingPeriod            time.Duration `json:"ping_period"`              // ping 发送间隔，默认为 PongWait 的 9/10
+	PongWait              time.Duration `json:"pong_wait"`                // 读取等待时间，默认60秒
+	WriteWait             time.Duration `json:"write_wait"`               // 写入等待时间，默认10秒
+	MaxConnections        int           `json:"max_connections"`          // 本实例最大连接数，0 表示不限制
+	MaxConnectionsPerUser int           `json:"max_connections_per_user"` // 单用户在本实例的最大连接数，0 表示不限制
+	MaxConnectionsPerRoom int           `json:"max_connections_per_room"` // 单房间在本实例的最大非旁观连接数，0 表示不限制
+	DrainReconnectDelay   time.Duration `json:"drain_reconnect_delay"`    // 排空时建议客户端重连前等待的最长时间，默认5秒
+}
+
+// withDefaults 补全未设置的配置项
+func (c HubConfig) withDefaults() HubConfig {
+	if c.ReadBufferSize <= 0 {
+		c.ReadBufferSize = 1024
+	}
+	if c.WriteBufferSize <= 0 {
+		c.WriteBufferSize = 1024
+	}
+	if c.MaxMessageSize <= 0 {
+		c.MaxMessageSize = 1 << 20
+	}
+	if c.PongWait <= 0 {
+		c.PongWait = 60 * time.Second
+	}
+	// ping 间隔必须小于 PongWait，否则连接会因读取超时被断开
+	if c.PingPeriod <= 0 || c.PingPeriod >= c.PongWait {
+		c.PingPeriod = c.PongWait * 9 / 10
+	}
+	if c.WriteWait <= 0 {
+		c.WriteWait = 10 * time.Second
+	}
+	if c.DrainReconnectDelay <= 0 {
+		c.DrainReconnectDelay = 5 * time.Second
+	}
+	return c
+}
+
+// errHubNotRunning Hub 未运行
+var errHubNotRunning = errors.New("websocket hub is not running")
+
 // Hub WebSocket 连接管理中心
 // 负责管理所有 WebSocket 连接，实现房间管理和消息广播
+// 房间和连接按分片登记，每个房间由独立协程投递消息，不同房间之间没有共享锁
+// 配置消息总线后，房间广播、成员进出通知和按用户发送会转发到其他实例
 type Hub struct {
-	// 客户端连接管理
-	clients   map[*Client]bool            // 所有活跃的客户端连接
-	rooms     map[string]map[*Client]bool // 房间 -> 客户端映射
-	userRooms map[int64]map[string]bool   // 用户 -> 房间映射
+	config HubConfig
 
-	// 消息通道
-	register   chan *Client           // 客户端注册通道
-	unregister chan *Client           // 客户端注销通道
-	broadcast  chan *BroadcastMessage // 广播消息通道
+	// 连接和房间注册表
+	clientShards [hubShardCount]clientShard
+	roomShards   [hubShardCount]roomShard
+
+	// 本实例的连接总数
+	connections atomic.Int64
 
 	// 协作相关
 	collaborationRepo domain.CollaborationRepository
 
-	// 控制
+	// 跨实例消息总线，为空时只在本实例内投递
+	backplane     domain.HubBackplane
+	stopBackplane context.CancelFunc
+
+	// 协作操作按房间串行提交和投递，保证各客户端收到的修订号有序
+	// 锁按引用计数登记，最后一个持有者释放时删除
+	operationMu    sync.Mutex
+	operationLocks map[string]*operationLock
+
+	// 房间内各连接的感知状态，按房间分片
+	awarenessShards        [hubShardCount]awarenessShard
+	lastAwarenessHeartbeat atomic.Int64 // 上次发送感知心跳的时间（纳秒）
+
+	// 排空状态，重启和滚动发布时使用
+	drainMu sync.Mutex
+	drain   drainState
+
+	// 控制，只保护启动和停止
 	mu      sync.RWMutex
 	running bool
-	stopCh  chan struct{}
-}
-
-// BroadcastMessage 广播消息结构
-type BroadcastMessage struct {
-	RoomID  string      `json:"room_id"`
-	Event   string      `json:"event"`
-	Data    interface{} `json:"data"`
-	Exclude *Client     `json:"-"` // 排除的客户端（通常是发送者）
-}
-
-// NewHub 创建新的 Hub 实例
-func NewHub(collaborationRepo domain.CollaborationRepository) *Hub {
-	return &Hub{
-		clients:           make(map[*Client]bool),
-		rooms:             make(map[string]map[*Client]bool),
-		userRooms:         make(map[int64]map[string]bool),
-		register:          make(chan *Client),
-		unregister:        make(chan *Client),
-		broadcast:         make(chan *BroadcastMessage),
+	stopped bool          // 已停止，Hub 只能启动一次
+	stopCh  chan struct{} // 停止时关闭，不会重新创建
+}
+
+// NewHub 创建新的 Hub 实例，backplane 为空时以单实例模式运行
+func NewHub(collaborationRepo domain.CollaborationRepository, backplane domain.HubBackplane, config HubConfig) *Hub {
+	h := &Hub{
+		config:            config.withDefaults(),
 		collaborationRepo: collaborationRepo,
+		backplane:         backplane,
+		operationLocks:    make(map[string]*operationLock),
 		stopCh:            make(chan struct{}),
 	}
+	for i := range h.clientShards {
+		h.clientShards[i].clients = make(map[int64]map[*Client]bool)
+	}
+	for i := range h.roomShards {
+		h.roomShards[i].rooms = make(map[string]*room)
+	}
+	for i := range h.awarenessShards {
+		h.awarenessShards[i].rooms = make(map[string]map[string]*awarenessEntry)
+	}
+	return h
 }
 
 // Start 启动 Hub
+// Hub 只能使用一次：停止后房间、连接和排空状态都不再恢复，再次调用 Start 不会生效，需要重新创建
 func (h *Hub) Start() {
 	h.mu.Lock()
 	if h.running {
 		h.mu.Unlock()
 		return
 	}
+	if h.stopped {
+		h.mu.Unlock()
+		log.Println("WebSocket Hub 已停止，不能再次启动")
+		return
+	}
 	h.running = true
+	if h.backplane != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		h.stopBackplane = cancel
+		go h.runBackplane(ctx)
+	}
 	h.mu.Unlock()
 
 	log.Println("WebSocket Hub 启动")
@@ -67,7 +154,7 @@ func (h *Hub) Start() {
 	go h.run()
 }
 
-// Stop 停止 Hub
+// Stop 停止 Hub，停止后不能再次启动
 func (h *Hub) Stop() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -79,10 +166,17 @@ func (h *Hub) Stop() {
 	log.Println("正在停止 WebSocket Hub...")
 	close(h.stopCh)
 	h.running = false
+	h.stopped = true
+	if h.stopBackplane != nil {
+		h.stopBackplane()
+	}
 
-	// 关闭所有客户端连接
-	for client := range h.clients {
-		close(client.send)
+	// 停止所有房间并关闭所有客户端连接
+	for _, r := range h.allRooms() {
+		r.stop()
+	}
+	for _, client := range h.allClients() {
+		client.shutdown(websocket.CloseGoingAway, "server shutting down")
 	}
 
 	log.Println("WebSocket Hub 已停止")
@@ -90,35 +184,67 @@ func (h *Hub) Stop() {
 
 // run Hub 主循环
 func (h *Hub) run() {
-	ticker := time.NewTicker(30 * time.Second) // 定期清理
-	defer ticker.Stop()
+	awarenessTicker := time.NewTicker(awarenessTickPeriod)
+	defer awarenessTicker.Stop()
 
 	for {
 		select {
 		case <-h.stopCh:
 			return
 
-		case client := <-h.register:
-			h.registerClient(client)
-
-		case client := <-h.unregister:
-			h.unregisterClient(client)
+		case now := <-awarenessTicker.C:
+			h.tickAwareness(now)
+		}
+	}
+}
 
-		case message := <-h.broadcast:
-			h.broadcastMessage(message)
+// runBackplane 订阅其他实例转发的消息，订阅断开后自动重连
+func (h *Hub) runBackplane(ctx context.Context) {
+	for {
+		err := h.backplane.Subscribe(ctx, h.deliverRemote)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("消息总线订阅断开，稍后重试: %v", err)
 
-		case <-ticker.C:
-			h.cleanup()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backplaneRetryInterval):
 		}
 	}
 }
 
-// registerClient 注册客户端
-func (h *Hub) registerClient(client *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+// isRunning 检查 Hub 是否在运行
+func (h *Hub) isRunning() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.running
+}
+
+// registerClient 注册客户端，Hub 正在排空或超过本实例、单用户的连接上限时返回错误
+func (h *Hub) registerClient(client *Client) error {
+	if h.IsDraining() {
+		return errDraining
+	}
+	if count := h.connections.Add(1); h.config.MaxConnections > 0 && count > int64(h.config.MaxConnections) {
+		h.connections.Add(-1)
+		return errServerFull
+	}
+
+	shard := &h.clientShards[uint64(client.UserID)%hubShardCount]
+	shard.mu.Lock()
+	if h.config.MaxConnectionsPerUser > 0 && len(shard.clients[client.UserID]) >= h.config.MaxConnectionsPerUser {
+		shard.mu.Unlock()
+		h.connections.Add(-1)
+		return errUserConnectionLimit
+	}
+	if shard.clients[client.UserID] == nil {
+		shard.clients[client.UserID] = make(map[*Client]bool)
+	}
+	shard.clients[client.UserID][client] = true
+	shard.mu.Unlock()
 
-	h.clients[client] = true
 	log.Printf("客户端已连接: UserID=%d, SocketID=%s", client.UserID, client.ID)
 
 	// 发送连接成功消息
@@ -127,65 +253,115 @@ func (h *Hub) registerClient(client *Client) {
 		"user_id":   client.UserID,
 		"timestamp": time.Now(),
 	})
+	return nil
 }
 
 // unregisterClient 注销客户端
 func (h *Hub) unregisterClient(client *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	if _, ok := h.clients[client]; ok {
-		// 从所有房间中移除
-		for roomID := range h.userRooms[client.UserID] {
-			h.leaveRoomUnsafe(client, roomID)
+	shard := &h.clientShards[uint64(client.UserID)%hubShardCount]
+	shard.mu.Lock()
+	_, registered := shard.clients[client.UserID][client]
+	if registered {
+		delete(shard.clients[client.UserID], client)
+		if len(shard.clients[client.UserID]) == 0 {
+			delete(shard.clients, client.UserID)
 		}
+	}
+	shard.mu.Unlock()
 
-		// 清理用户房间映射
-		delete(h.userRooms, client.UserID)
+	if !registered {
+		return
+	}
+	h.connections.Add(-1)
 
-		// 移除客户端
-		delete(h.clients, client)
-		close(client.send)
+	// 从所有房间中移除，集群成员在后台注销，避免阻塞读协程退出
+	var leftRooms []string
+	for _, roomID := range client.joinedRooms() {
+		if h.leaveRoom(client, roomID) {
+			leftRooms = append(leftRooms, roomID)
+		}
+	}
+	if len(leftRooms) > 0 && h.backplane != nil {
+		go func() {
+			for _, roomID := range leftRooms {
+				h.removeMember(client, roomID)
+			}
+		}()
+	}
 
-		log.Printf("客户端已断开: UserID=%d, SocketID=%s", client.UserID, client.ID)
+	client.shutdown(websocket.CloseNormalClosure, "")
+	log.Printf("客户端已断开: UserID=%d, SocketID=%s", client.UserID, client.ID)
+}
+
+// allClients 获取本实例上的所有客户端
+func (h *Hub) allClients() []*Client {
+	var clients []*Client
+	for i := range h.clientShards {
+		shard := &h.clientShards[i]
+		shard.mu.RLock()
+		for _, userClients := range shard.clients {
+			for client := range userClients {
+				clients = append(clients, client)
+			}
+		}
+		shard.mu.RUnlock()
 	}
+	return clients
 }
 
-// JoinRoom 加入房间
+// JoinRoom 加入房间，client.spectator 为 true 时以旁观者身份加入
+// 成员变更经房间队列串行处理，加入前已入队的消息不会发给新成员
 func (h *Hub) JoinRoom(client *Client, roomID string) error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	// 初始化房间
-	if h.rooms[roomID] == nil {
-		h.rooms[roomID] = make(map[*Client]bool)
+	if !h.isRunning() {
+		return errHubNotRunning
 	}
-
-	// 初始化用户房间映射
-	if h.userRooms[client.UserID] == nil {
-		h.userRooms[client.UserID] = make(map[string]bool)
+	if h.IsDraining() {
+		return errDraining
 	}
 
-	// 添加到房间
-	h.rooms[roomID][client] = true
-	h.userRooms[client.UserID][roomID] = true
+	r, err := h.acquireRoom(client, roomID, client.spectator.Load())
+	if err != nil {
+		return err
+	}
 	client.CurrentRoom = roomID
 
+	// 通知房间内其他用户
+	joined := presenceData(client, roomID)
+	message := encodeMessage("user_joined", joined)
+	r.enqueue(func() {
+		r.clients[client] = true
+		r.broadcast(message, client.ID, "")
+	})
+
 	log.Printf("用户 %d 加入房间 %s", client.UserID, roomID)
 
-	// 通知房间内其他用户
-	h.broadcastToRoomUnsafe(roomID, "user_joined", map[string]interface{}{
-		"user_id":   client.UserID,
-		"socket_id": client.ID,
-		"room_id":   roomID,
-		"timestamp": time.Now(),
-	}, client)
+	// 登记集群成员并通知其他实例
+	if h.backplane != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), backplaneTimeout)
+		defer cancel()
+
+		if err := h.backplane.AddMember(ctx, &domain.HubMember{
+			RoomID:    roomID,
+			UserID:    client.UserID,
+			SocketID:  client.ID,
+			Spectator: client.spectator.Load(),
+		}); err != nil {
+			log.Printf("登记房间成员失败: room=%s, socket=%s, err=%v", roomID, client.ID, err)
+		}
+		h.publish(&domain.HubMessage{RoomID: roomID, Event: "user_joined", Exclude: client.ID}, joined)
+
+		// 请求其他实例立即发送感知状态，新用户无需等待下一次心跳
+		h.publish(&domain.HubMessage{RoomID: roomID, Event: eventAwarenessQuery}, nil)
+	}
+
+	// 登记新用户的感知状态，颜色等信息随快照一起下发
+	h.UpdateAwareness(client, roomID, awarenessInput{Status: AwarenessStatusActive})
 
 	// 发送房间信息给新用户
-	roomUsers := h.getRoomUsersUnsafe(roomID)
 	client.Send("room_joined", map[string]interface{}{
 		"room_id":   roomID,
-		"users":     roomUsers,
+		"users":     h.roomMembers(roomID),
+		"awareness": h.awarenessSnapshot(roomID),
 		"timestamp": time.Now(),
 	})
 
@@ -194,137 +370,345 @@ func (h *Hub) JoinRoom(client *Client, roomID string) error {
 
 // LeaveRoom 离开房间
 func (h *Hub) LeaveRoom(client *Client, roomID string) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	if h.leaveRoom(client, roomID) && h.backplane != nil {
+		h.removeMember(client, roomID)
+	}
+}
 
-	h.leaveRoomUnsafe(client, roomID)
-}
-
-// leaveRoomUnsafe 离开房间（不加锁版本）
-func (h *Hub) leaveRoomUnsafe(client *Client, roomID string) {
-	if room, exists := h.rooms[roomID]; exists {
-		if _, inRoom := room[client]; inRoom {
-			delete(room, client)
-			delete(h.userRooms[client.UserID], roomID)
-
-			// 如果房间为空，删除房间
-			if len(room) == 0 {
-				delete(h.rooms, roomID)
-			} else {
-				// 通知房间内其他用户
-				h.broadcastToRoomUnsafe(roomID, "user_left", map[string]interface{}{
-					"user_id":   client.UserID,
-					"socket_id": client.ID,
-					"room_id":   roomID,
-					"timestamp": time.Now(),
-				}, nil)
-			}
+// removeMember 注销集群成员并通知其他实例
+func (h *Hub) removeMember(client *Client, roomID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), backplaneTimeout)
+	defer cancel()
 
-			log.Printf("用户 %d 离开房间 %s", client.UserID, roomID)
-		}
+	if err := h.backplane.RemoveMember(ctx, roomID, client.ID); err != nil {
+		log.Printf("注销房间成员失败: room=%s, socket=%s, err=%v", roomID, client.ID, err)
+	}
+	h.publish(&domain.HubMessage{RoomID: roomID, Event: "user_left"}, presenceData(client, roomID))
+	h.publish(&domain.HubMessage{RoomID: roomID, Event: eventAwarenessRemoved}, awarenessRemovedData(client.UserID, client.ID))
+}
+
+// presenceData 成员进出房间的通知数据
+func presenceData(client *Client, roomID string) map[string]interface{} {
+	return map[string]interface{}{
+		"user_id":   client.UserID,
+		"socket_id": client.ID,
+		"room_id":   roomID,
+		"spectator": client.spectator.Load(),
+		"timestamp": time.Now(),
 	}
+}
 
+// leaveRoom 将客户端移出本实例上的房间，返回客户端是否在房间中
+func (h *Hub) leaveRoom(client *Client, roomID string) bool {
 	if client.CurrentRoom == roomID {
 		client.CurrentRoom = ""
 	}
+
+	r, release := h.releaseRoom(client, roomID)
+	if r == nil {
+		return false
+	}
+
+	// 通知房间内其他用户
+	message := encodeMessage("user_left", presenceData(client, roomID))
+	r.enqueue(func() {
+		delete(r.clients, client)
+		r.broadcast(message, "", "")
+	})
+	h.dropAwareness(roomID, client)
+	release()
+
+	log.Printf("用户 %d 离开房间 %s", client.UserID, roomID)
+	return true
 }
 
 // BroadcastToRoom 向房间广播消息
 func (h *Hub) BroadcastToRoom(roomID, event string, data interface{}) {
-	message := &BroadcastMessage{
-		RoomID: roomID,
-		Event:  event,
-		Data:   data,
+	h.broadcastLocal(roomID, event, data, "", "")
+	h.publish(&domain.HubMessage{RoomID: roomID, Event: event}, data)
+}
+
+// SendToUser 向用户在集群内的所有连接发送消息
+func (h *Hub) SendToUser(userID int64, event string, data interface{}) {
+	h.sendToLocalUser(userID, encodeMessage(event, data))
+	h.publish(&domain.HubMessage{UserID: userID, Event: event}, data)
+}
+
+// sendToLocalUser 向用户在本实例上的连接发送已编码的消息
+func (h *Hub) sendToLocalUser(userID int64, message []byte) {
+	if message == nil {
+		return
 	}
 
-	select {
-	case h.broadcast <- message:
-	default:
-		log.Printf("广播队列已满，丢弃消息: room=%s, event=%s", roomID, event)
+	shard := &h.clientShards[uint64(userID)%hubShardCount]
+	shard.mu.RLock()
+	for client := range shard.clients[userID] {
+		client.sendRaw(message)
 	}
+	shard.mu.RUnlock()
 }
 
-// broadcastMessage 处理广播消息
-func (h *Hub) broadcastMessage(message *BroadcastMessage) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// broadcastLocal 经房间队列向本实例的房间成员广播消息
+// excludeID 为不接收消息的连接ID，coalesceKey 不为空时慢连接上只保留同键的最新消息
+func (h *Hub) broadcastLocal(roomID, event string, data interface{}, excludeID string, coalesceKey string) {
+	r := h.lookupRoom(roomID)
+	if r == nil {
+		return
+	}
+
+	message := encodeMessage(event, data)
+	if message == nil {
+		return
+	}
+	r.enqueue(func() {
+		r.broadcast(message, excludeID, coalesceKey)
+	})
+}
 
-	h.broadcastToRoomUnsafe(message.RoomID, message.Event, message.Data, message.Exclude)
+// operationLock 房间协作操作锁
+// refs 为持有和等待该锁的调用方数量，由 Hub.operationMu 保护
+type operationLock struct {
+	mu   sync.Mutex
+	refs int
 }
 
-// broadcastToRoomUnsafe 向房间广播消息（不加锁版本）
-func (h *Hub) broadcastToRoomUnsafe(roomID, event string, data interface{}, exclude *Client) {
-	room, exists := h.rooms[roomID]
+// LockRoomOperations 获取房间的协作操作锁，返回解锁函数
+// 提交操作和投递结果需在同一把锁内完成，加入房间时也需持有以免漏收操作
+// 等待中的调用方也计入引用，锁在没有任何引用时才从注册表删除，同一房间不会同时存在两把锁
+func (h *Hub) LockRoomOperations(roomID string) func() {
+	h.operationMu.Lock()
+	lock, exists := h.operationLocks[roomID]
 	if !exists {
+		lock = &operationLock{}
+		h.operationLocks[roomID] = lock
+	}
+	lock.refs++
+	h.operationMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+
+		h.operationMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(h.operationLocks, roomID)
+		}
+		h.operationMu.Unlock()
+	}
+}
+
+// DeliverOperation 投递已提交的协作操作：先发给房间内其他客户端，再向发送者确认
+// 调用方需持有 LockRoomOperations 返回的锁，操作和确认在房间队列中按提交顺序投递
+// 其他实例上的客户端经消息总线收到操作，可能与本实例提交的操作交错到达，客户端需按修订号排序
+func (h *Hub) DeliverOperation(roomID string, sender *Client, operation interface{}, ack interface{}) {
+	var message []byte
+	if operation != nil {
+		message = encodeMessage("collaboration_operation", operation)
+	}
+	ackMessage := encodeMessage("operation_ack", ack)
+
+	r := h.lookupRoom(roomID)
+	if r == nil || !r.enqueue(func() {
+		if message != nil {
+			r.broadcast(message, sender.ID, "")
+		}
+		sender.sendRaw(ackMessage)
+	}) {
+		sender.sendRaw(ackMessage)
+	}
+
+	if operation != nil {
+		h.publish(&domain.HubMessage{RoomID: roomID, Event: "collaboration_operation", Exclude: sender.ID}, operation)
+	}
+}
+
+// BroadcastBinaryToRoom 向房间内除 exclude 外的客户端发送二进制消息
+func (h *Hub) BroadcastBinaryToRoom(roomID string, data []byte, exclude *Client) {
+	message := &domain.HubMessage{RoomID: roomID, Binary: data}
+	if exclude != nil {
+		message.Exclude = exclude.ID
+	}
+
+	if r := h.lookupRoom(roomID); r != nil {
+		r.enqueue(func() {
+			r.broadcastBinary(data, message.Exclude)
+		})
+	}
+
+	h.publish(message, nil)
+}
+
+// publish 将消息发布到消息总线，data 不为空时作为事件数据
+func (h *Hub) publish(message *domain.HubMessage, data interface{}) {
+	if h.backplane == nil {
 		return
 	}
 
-	for client := range room {
-		if exclude != nil && client == exclude {
-			continue
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("消息总线数据序列化失败: %v", err)
+			return
 		}
+		message.Data = raw
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), backplaneTimeout)
+	defer cancel()
 
-		client.Send(event, data)
+	if err := h.backplane.Publish(ctx, message); err != nil {
+		log.Printf("消息总线发布失败: room=%s, event=%s, err=%v", message.RoomID, message.Event, err)
 	}
 }
 
-// getRoomUsersUnsafe 获取房间用户列表（不加锁版本）
-func (h *Hub) getRoomUsersUnsafe(roomID string) []map[string]interface{} {
-	room, exists := h.rooms[roomID]
-	if !exists {
-		return []map[string]interface{}{}
+// deliverRemote 将其他实例转发的消息投递给本实例的客户端
+func (h *Hub) deliverRemote(message *domain.HubMessage) {
+	if !h.isRunning() {
+		return
+	}
+
+	switch message.Event {
+	case eventAwarenessUpdate, eventAwarenessRemoved, eventAwarenessHeartbeat, eventAwarenessQuery:
+		for _, event := range h.handleRemoteAwareness(message) {
+			h.broadcastAwareness(event.roomID, event.event, event.data, event.socketID, "")
+		}
+		return
 	}
 
-	users := make([]map[string]interface{}, 0, len(room))
-	for client := range room {
+	if message.RoomID == "" {
+		h.sendToLocalUser(message.UserID, encodeMessage(message.Event, message.Data))
+		return
+	}
+
+	r := h.lookupRoom(message.RoomID)
+	if r == nil {
+		return
+	}
+	if message.Binary != nil {
+		r.enqueue(func() {
+			r.broadcastBinary(message.Binary, message.Exclude)
+		})
+		return
+	}
+
+	encoded := encodeMessage(message.Event, message.Data)
+	if encoded == nil {
+		return
+	}
+	r.enqueue(func() {
+		r.broadcast(encoded, message.Exclude, "")
+	})
+	if message.Event == string(domain.DocumentEventContentReset) {
+		h.resyncRoom(message.RoomID)
+	}
+}
+
+// encodeMessage 编码客户端消息，房间广播只编码一次
+func encodeMessage(event string, data interface{}) []byte {
+	encoded, err := json.Marshal(Message{Event: event, Data: data})
+	if err != nil {
+		log.Printf("消息序列化失败: event=%s, err=%v", event, err)
+		return nil
+	}
+	return encoded
+}
+
+// localMembers 获取本实例上的房间成员
+func (h *Hub) localMembers(roomID string) []*Client {
+	r := h.lookupRoom(roomID)
+	if r == nil {
+		return nil
+	}
+	return r.members()
+}
+
+// roomMembers 获取房间成员列表，配置消息总线时返回集群范围的成员
+func (h *Hub) roomMembers(roomID string) []map[string]interface{} {
+	if members, ok := h.clusterMembers(roomID); ok {
+		users := make([]map[string]interface{}, 0, len(members))
+		for _, member := range members {
+			users = append(users, map[string]interface{}{
+				"user_id":   member.UserID,
+				"socket_id": member.SocketID,
+				"spectator": member.Spectator,
+			})
+		}
+		return users
+	}
+
+	clients := h.localMembers(roomID)
+	users := make([]map[string]interface{}, 0, len(clients))
+	for _, client := range clients {
 		users = append(users, map[string]interface{}{
 			"user_id":   client.UserID,
 			"socket_id": client.ID,
+			"spectator": client.spectator.Load(),
 		})
 	}
-
 	return users
 }
 
-// GetRoomUsers 获取房间用户列表
-func (h *Hub) GetRoomUsers(roomID string) []int64 {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// clusterMembers 从消息总线获取集群范围的房间成员，未配置或查询失败时返回 false
+func (h *Hub) clusterMembers(roomID string) ([]*domain.HubMember, bool) {
+	if h.backplane == nil {
+		return nil, false
+	}
 
-	room, exists := h.rooms[roomID]
-	if !exists {
-		return []int64{}
+	ctx, cancel := context.WithTimeout(context.Background(), backplaneTimeout)
+	defer cancel()
+
+	members, err := h.backplane.GetMembers(ctx, roomID)
+	if err != nil {
+		log.Printf("获取集群房间成员失败，使用本实例成员: room=%s, err=%v", roomID, err)
+		return nil, false
+	}
+	return members, true
+}
+
+// GetRoomUsers 获取房间用户列表，配置消息总线时返回集群范围的结果
+func (h *Hub) GetRoomUsers(roomID string) []int64 {
+	if members, ok := h.clusterMembers(roomID); ok {
+		userIDs := make([]int64, 0, len(members))
+		for _, member := range members {
+			userIDs = append(userIDs, member.UserID)
+		}
+		return userIDs
 	}
 
-	userIDs := make([]int64, 0, len(room))
-	for client := range room {
+	clients := h.localMembers(roomID)
+	userIDs := make([]int64, 0, len(clients))
+	for _, client := range clients {
 		userIDs = append(userIDs, client.UserID)
 	}
 
 	return userIDs
 }
 
-// cleanup 定期清理
-func (h *Hub) cleanup() {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	// 清理空房间
-	for roomID, room := range h.rooms {
-		if len(room) == 0 {
-			delete(h.rooms, roomID)
+// GetStats 获取统计信息
+func (h *Hub) GetStats() map[string]interface{} {
+	totalClients := 0
+	for i := range h.clientShards {
+		shard := &h.clientShards[i]
+		shard.mu.RLock()
+		for _, userClients := range shard.clients {
+			totalClients += len(userClients)
 		}
+		shard.mu.RUnlock()
 	}
-}
 
-// GetStats 获取统计信息
-func (h *Hub) GetStats() map[string]interface{} {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+	totalRooms := 0
+	for i := range h.roomShards {
+		shard := &h.roomShards[i]
+		shard.mu.Lock()
+		totalRooms += len(shard.rooms)
+		shard.mu.Unlock()
+	}
 
 	return map[string]interface{}{
-		"total_clients": len(h.clients),
-		"total_rooms":   len(h.rooms),
+		"total_clients": totalClients,
+		"total_rooms":   totalRooms,
+		"draining":      h.IsDraining(),
 		"timestamp":     time.Now(),
 	}
 }
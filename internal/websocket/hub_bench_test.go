@@ -0,0 +1,111 @@
+package websocket
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkStats 基准测试的投递统计
+type benchmarkStats struct {
+	delivered    atomic.Int64 // 投递到连接的消息数
+	disconnected atomic.Int64 // 因发送队列满被断开的连接数
+}
+
+// report 报告每秒投递的消息数和被断开的慢连接数
+func (s *benchmarkStats) report(b *testing.B) {
+	b.ReportMetric(float64(s.delivered.Load())/b.Elapsed().Seconds(), "deliveries/s")
+	b.ReportMetric(float64(s.disconnected.Load()), "disconnects")
+}
+
+// benchmarkRooms 创建 rooms 个房间，每个房间 clientsPerRoom 个连接，并持续消费发送队列
+func benchmarkRooms(b *testing.B, hub *Hub, rooms, clientsPerRoom int, stats *benchmarkStats) []string {
+	b.Helper()
+
+	roomIDs := make([]string, rooms)
+	for i := range roomIDs {
+		roomIDs[i] = fmt.Sprintf("bench:%d", i)
+		for j := 0; j < clientsPerRoom; j++ {
+			client := &Client{
+				ID:         fmt.Sprintf("socket-%d-%d", i, j),
+				UserID:     int64(i*clientsPerRoom + j + 1),
+				hub:        hub,
+				send:       make(chan []byte, sendQueueSize),
+				sendBinary: make(chan []byte, sendQueueSize),
+				done:       make(chan struct{}),
+				wake:       make(chan struct{}, 1),
+			}
+			hub.registerClient(client)
+			hub.JoinRoom(client, roomIDs[i])
+			go func() {
+				for {
+					select {
+					case <-client.send:
+						stats.delivered.Add(1)
+					case <-client.done:
+						stats.disconnected.Add(1)
+						return
+					}
+				}
+			}()
+		}
+	}
+	hub.flush()
+	stats.delivered.Store(0)
+	return roomIDs
+}
+
+// benchmarkBroadcast 并发向各房间轮流广播
+func benchmarkBroadcast(b *testing.B, rooms, clientsPerRoom int) {
+	hub := NewHub(nil, nil, HubConfig{})
+	hub.Start()
+	defer hub.Stop()
+
+	var stats benchmarkStats
+	roomIDs := benchmarkRooms(b, hub, rooms, clientsPerRoom, &stats)
+	payload := map[string]interface{}{"revision": 1, "operations": []string{"insert"}}
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			roomID := roomIDs[int(next.Add(1))%len(roomIDs)]
+			hub.BroadcastToRoom(roomID, "collaboration_operation", payload)
+		}
+	})
+	hub.flush()
+	b.StopTimer()
+
+	stats.report(b)
+}
+
+func BenchmarkBroadcast100Rooms(b *testing.B)  { benchmarkBroadcast(b, 100, 4) }
+func BenchmarkBroadcast1000Rooms(b *testing.B) { benchmarkBroadcast(b, 1000, 4) }
+func BenchmarkBroadcast5000Rooms(b *testing.B) { benchmarkBroadcast(b, 5000, 4) }
+
+// BenchmarkJoinLeave 并发加入和离开大量不同的房间
+func BenchmarkJoinLeave(b *testing.B) {
+	hub := NewHub(nil, nil, HubConfig{})
+	hub.Start()
+	defer hub.Stop()
+
+	var stats benchmarkStats
+	roomIDs := benchmarkRooms(b, hub, 5000, 1, &stats)
+
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id := next.Add(1)
+		client := newTestClient(hub, fmt.Sprintf("joiner-%d", id), 1_000_000+id)
+		i := int(id)
+		for pb.Next() {
+			roomID := roomIDs[i%len(roomIDs)]
+			hub.JoinRoom(client, roomID)
+			hub.LeaveRoom(client, roomID)
+			i += 7
+			for len(client.send) > 0 {
+				<-client.send
+			}
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"errors"
+
+	"github.com/gorilla/websocket"
+)
+
+// 拒绝连接时使用的关闭码，4000-4999 为应用自定义关闭码
+// 本实例连接数已满使用标准的 1013 (Try Again Later)，客户端可换一个实例或稍后重连
+// 本实例正在排空使用标准的 1012 (Service Restart)，客户端应稍后重连
+// 消息超过 MaxMessageSize 时由 gorilla/websocket 以 1009 (Message Too Big) 关闭
+const (
+	// CloseUserConnectionLimit 用户连接数超过上限，客户端应关闭其他标签页或连接后再重试
+	CloseUserConnectionLimit = 4008
+)
+
+// 关闭已建立的连接时使用的关闭码
+const (
+	// CloseResyncRequired 文档内容在协作会话之外被整体替换，客户端应丢弃本地状态，重新连接并从文档内容开始同步
+	CloseResyncRequired = 4009
+)
+
+var (
+	// errServerFull 本实例连接数已达上限
+	errServerFull = errors.New("server connection limit reached")
+
+	// errUserConnectionLimit 用户连接数已达上限
+	errUserConnectionLimit = errors.New("user connection limit reached")
+
+	// errRoomFull 房间非旁观连接数已达上限，可以旁观者身份加入
+	errRoomFull = errors.New("room connection limit reached")
+)
+
+// rejectCloseCode 获取拒绝连接的错误对应的关闭码和原因
+// 关闭原因写入关闭帧，长度不能超过 123 字节
+func rejectCloseCode(err error) (int, string) {
+	switch {
+	case errors.Is(err, errUserConnectionLimit):
+		return CloseUserConnectionLimit, "too many connections for user"
+	case errors.Is(err, errServerFull):
+		return websocket.CloseTryAgainLater, "server connection limit reached"
+	case errors.Is(err, errDraining):
+		return websocket.CloseServiceRestart, "server restarting"
+	default:
+		return websocket.CloseInternalServerErr, "connection rejected"
+	}
+}
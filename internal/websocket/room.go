@@ -0,0 +1,255 @@
+package websocket
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	// 房间任务队列长度，队列满时投递方阻塞等待，不丢弃消息
+	roomQueueSize = 256
+
+	// 房间和连接注册表的分片数
+	hubShardCount = 64
+)
+
+// room 房间
+// 每个房间由独立协程串行处理成员变更和消息投递，不同房间之间互不阻塞
+// 房间协程只做非阻塞的发送，投递方因队列满而阻塞的时间有上限
+type room struct {
+	id string
+
+	// 房间成员，仅由房间协程访问
+	clients map[*Client]bool
+
+	// 成员数和其中非旁观成员数，由所在分片的锁保护，成员数归零时房间从注册表移除并停止
+	size    int
+	editors int
+
+	tasks    chan func()
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// roomShard 房间注册表分片
+type roomShard struct {
+	mu    sync.Mutex
+	rooms map[string]*room
+}
+
+// roomMembership 客户端在房间中的成员身份
+type roomMembership struct {
+	room      *room
+	spectator bool
+}
+
+// clientShard 连接注册表分片：用户ID -> 连接
+type clientShard struct {
+	mu      sync.RWMutex
+	clients map[int64]map[*Client]bool
+}
+
+// newRoom 创建房间并启动房间协程
+func newRoom(id string) *room {
+	r := &room{
+		id:      id,
+		clients: make(map[*Client]bool),
+		tasks:   make(chan func(), roomQueueSize),
+		done:    make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// run 房间主循环
+func (r *room) run() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case task := <-r.tasks:
+			task()
+		}
+	}
+}
+
+// enqueue 将任务加入房间队列，房间已停止时返回 false
+// 房间停止前已入队但未执行的任务会被丢弃，此时房间已没有成员
+func (r *room) enqueue(task func()) bool {
+	select {
+	case r.tasks <- task:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// stop 停止房间协程
+func (r *room) stop() {
+	r.stopOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// broadcast 向房间成员发送已编码的消息，需在房间协程中调用
+// coalesceKey 不为空时慢连接上的同键消息只保留最新一条
+func (r *room) broadcast(message []byte, excludeID string, coalesceKey string) {
+	for client := range r.clients {
+		if client.ID == excludeID {
+			continue
+		}
+		if coalesceKey != "" {
+			client.sendCoalesced(coalesceKey, message)
+		} else {
+			client.sendRaw(message)
+		}
+	}
+}
+
+// broadcastBinary 向房间成员发送二进制消息，需在房间协程中调用
+func (r *room) broadcastBinary(data []byte, excludeID string) {
+	for client := range r.clients {
+		if client.ID != excludeID {
+			client.SendBinary(data)
+		}
+	}
+}
+
+// members 获取房间成员快照，不含事件流订阅，房间已停止时返回空列表
+func (r *room) members() []*Client {
+	reply := make(chan []*Client, 1)
+	if !r.enqueue(func() {
+		clients := make([]*Client, 0, len(r.clients))
+		for client := range r.clients {
+			if !client.feed {
+				clients = append(clients, client)
+			}
+		}
+		reply <- clients
+	}) {
+		return nil
+	}
+
+	select {
+	case clients := <-reply:
+		return clients
+	case <-r.done:
+		return nil
+	}
+}
+
+// flush 等待房间处理完此前入队的任务
+func (r *room) flush() {
+	barrier := make(chan struct{})
+	if !r.enqueue(func() { close(barrier) }) {
+		return
+	}
+
+	select {
+	case <-barrier:
+	case <-r.done:
+	}
+}
+
+// shardIndex 计算房间所在的分片
+func shardIndex(roomID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(roomID))
+	return int(h.Sum32() % hubShardCount)
+}
+
+// acquireRoom 将客户端登记为房间成员，房间不存在时创建
+// 非旁观成员数达到单房间连接上限时返回 errRoomFull，旁观者不占用名额
+// 客户端已在房间中时直接返回原房间，不重复计数
+func (h *Hub) acquireRoom(client *Client, roomID string, spectator bool) (*room, error) {
+	client.roomsMu.Lock()
+	defer client.roomsMu.Unlock()
+
+	if membership, exists := client.rooms[roomID]; exists {
+		return membership.room, nil
+	}
+
+	shard := &h.roomShards[shardIndex(roomID)]
+	shard.mu.Lock()
+	r := shard.rooms[roomID]
+	if !spectator && r != nil && h.config.MaxConnectionsPerRoom > 0 && r.editors >= h.config.MaxConnectionsPerRoom {
+		shard.mu.Unlock()
+		return nil, errRoomFull
+	}
+	if r == nil {
+		r = newRoom(roomID)
+		shard.rooms[roomID] = r
+	}
+	r.size++
+	if !spectator {
+		r.editors++
+	}
+	shard.mu.Unlock()
+
+	if client.rooms == nil {
+		client.rooms = make(map[string]roomMembership)
+	}
+	client.rooms[roomID] = roomMembership{room: r, spectator: spectator}
+	return r, nil
+}
+
+// releaseRoom 注销客户端的房间成员身份，返回客户端原先所在的房间
+// 调用方在房间任务入队后需调用返回的函数减少成员数，成员数归零时停止房间
+func (h *Hub) releaseRoom(client *Client, roomID string) (*room, func()) {
+	client.roomsMu.Lock()
+	membership, exists := client.rooms[roomID]
+	delete(client.rooms, roomID)
+	client.roomsMu.Unlock()
+
+	if !exists {
+		return nil, nil
+	}
+
+	r := membership.room
+	return r, func() {
+		shard := &h.roomShards[shardIndex(roomID)]
+		shard.mu.Lock()
+		r.size--
+		if !membership.spectator {
+			r.editors--
+		}
+		empty := r.size == 0
+		if empty && shard.rooms[roomID] == r {
+			delete(shard.rooms, roomID)
+		}
+		shard.mu.Unlock()
+
+		if empty {
+			r.stop()
+		}
+	}
+}
+
+// lookupRoom 获取本实例上的房间，不存在时返回 nil
+func (h *Hub) lookupRoom(roomID string) *room {
+	shard := &h.roomShards[shardIndex(roomID)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return shard.rooms[roomID]
+}
+
+// allRooms 获取本实例上的所有房间
+func (h *Hub) allRooms() []*room {
+	var rooms []*room
+	for i := range h.roomShards {
+		shard := &h.roomShards[i]
+		shard.mu.Lock()
+		for _, r := range shard.rooms {
+			rooms = append(rooms, r)
+		}
+		shard.mu.Unlock()
+	}
+	return rooms
+}
+
+// flush 等待所有房间处理完此前入队的任务
+func (h *Hub) flush() {
+	for _, r := range h.allRooms() {
+		r.flush()
+	}
+}
@@ -9,15 +9,19 @@ import (
 
 	"DOC/domain"
 	"DOC/pkg/jwt"
+	"DOC/pkg/ot"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
 // Server WebSocket 服务器
 type Server struct {
 	hub                  *Hub
+	upgrader             websocket.Upgrader
 	jwtManager           *jwt.JWTManager
 	collaborationUsecase domain.CollaborationUsecase
+	userRepo             domain.UserRepository
 }
 
 // NewServer 创建新的 WebSocket 服务器
@@ -25,11 +29,21 @@ func NewServer(
 	hub *Hub,
 	jwtManager *jwt.JWTManager,
 	collaborationUsecase domain.CollaborationUsecase,
+	userRepo domain.UserRepository,
 ) *Server {
 	return &Server{
-		hub:                  hub,
+		hub: hub,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  hub.config.ReadBufferSize,
+			WriteBufferSize: hub.config.WriteBufferSize,
+			CheckOrigin: func(r *http.Request) bool {
+				// 在生产环境中应该检查 Origin
+				return true
+			},
+		},
 		jwtManager:           jwtManager,
 		collaborationUsecase: collaborationUsecase,
+		userRepo:             userRepo,
 	}
 }
 
@@ -45,6 +59,11 @@ func (s *Server) Stop() {
 	log.Println("WebSocket 服务器已停止")
 }
 
+// Drain 排空连接：通知客户端重连、等待正在处理的操作确认后关闭连接，之后仍需调用 Stop
+func (s *Server) Drain(ctx context.Context) error {
+	return s.hub.Drain(ctx)
+}
+
 // HandleWebSocket 处理 WebSocket 连接
 func (s *Server) HandleWebSocket(c *gin.Context) {
 	// 1. 验证用户身份
@@ -57,20 +76,40 @@ func (s *Server) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	// 2. 升级 HTTP 连接为 WebSocket
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	// 2. 升级 HTTP 连接为 WebSocket，超过连接数上限时由客户端发送关闭帧拒绝
+	conn, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket 升级失败: %v", err)
 		return
 	}
 
 	// 3. 创建客户端并启动
-	client := NewClient(s.hub, conn, userID)
+	client := NewClient(s.hub, conn, userID, s.collaborationUsecase)
+	s.loadUserProfile(c.Request.Context(), client)
 	client.Start()
 
 	log.Printf("WebSocket 连接已建立: UserID=%d, SocketID=%s", userID, client.ID)
 }
 
+// loadUserProfile 加载用户展示信息，失败时感知状态中不带名称和头像
+func (s *Server) loadUserProfile(ctx context.Context, client *Client) {
+	if s.userRepo == nil {
+		return
+	}
+
+	user, err := s.userRepo.GetByID(ctx, client.UserID)
+	if err != nil {
+		log.Printf("加载用户信息失败: UserID=%d, err=%v", client.UserID, err)
+		return
+	}
+
+	client.name = user.Name
+	if client.name == "" {
+		client.name = user.Username
+	}
+	client.avatarURL = user.AvatarURL
+}
+
 // authenticateUser 验证用户身份
 func (s *Server) authenticateUser(c *gin.Context) (int64, error) {
 	// 方式1: 从查询参数获取 token
@@ -198,9 +237,8 @@ func (s *Server) BroadcastToRoomService(ctx context.Context, roomID string, even
 }
 
 func (s *Server) SendToUser(ctx context.Context, userID int64, event string, data interface{}) error {
-	// 查找用户的所有连接并发送消息
-	// 这里需要扩展 Hub 来支持按用户ID发送消息
-	// 暂时通过广播到用户所在的所有房间来实现
+	// 发送到用户在集群内的所有连接
+	s.hub.SendToUser(userID, event, data)
 	return nil
 }
 
@@ -223,10 +261,9 @@ func (s *Server) GetRoomUsersService(ctx context.Context, roomID string) ([]int6
 	return s.hub.GetRoomUsers(roomID), nil
 }
 
-func (s *Server) TransformOperation(ctx context.Context, operation *domain.CollaborationOperation, concurrentOps []*domain.CollaborationOperation) (*domain.CollaborationOperation, error) {
-	// 操作转换逻辑，这里需要实现 OT (Operational Transformation) 算法
-	// 暂时返回原操作
-	return operation, nil
+func (s *Server) TransformOperation(ctx context.Context, operation *domain.CollaborationOperation, concurrentOps []*domain.CollaborationOperation) ([]*domain.CollaborationOperation, error) {
+	// 并发操作已先于 operation 应用，冲突时优先
+	return ot.Transform([]*domain.CollaborationOperation{operation}, concurrentOps)
 }
 
 func (s *Server) HealthCheck(ctx context.Context) error {
@@ -234,5 +271,8 @@ func (s *Server) HealthCheck(ctx context.Context) error {
 	if !s.hub.running {
 		return fmt.Errorf("WebSocket Hub 未运行")
 	}
+	if s.hub.IsDraining() {
+		return fmt.Errorf("WebSocket Hub 正在排空")
+	}
 	return nil
 }
@@ -0,0 +1,106 @@
+package access
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"DOC/domain"
+)
+
+// AccessWorker 文档访问持久化工作者
+// 定期将 Redis 中等待持久化的文档访问写入数据库
+type AccessWorker struct {
+	accessUsecase domain.DocumentAccessUsecase
+
+	// 基本配置
+	flushInterval time.Duration // 持久化间隔
+
+	// 控制
+	stopCh  chan struct{}
+	running bool
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+// WorkerConfig 工作者配置
+type WorkerConfig struct {
+	FlushInterval time.Duration `json:"flush_interval"` // 持久化间隔，默认1分钟
+}
+
+// NewAccessWorker 创建新的文档访问持久化工作者
+func NewAccessWorker(accessUsecase domain.DocumentAccessUsecase, config WorkerConfig) *AccessWorker {
+	// 设置默认值
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Minute
+	}
+
+	return &AccessWorker{
+		accessUsecase: accessUsecase,
+		flushInterval: config.FlushInterval,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// Start 启动文档访问持久化工作者
+func (w *AccessWorker) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.running {
+		return
+	}
+
+	w.running = true
+	log.Printf("启动文档访问持久化工作者，持久化间隔: %v", w.flushInterval)
+
+	w.wg.Add(1)
+	go w.run()
+}
+
+// Stop 停止文档访问持久化工作者，停止前再持久化一次
+func (w *AccessWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.running {
+		return
+	}
+
+	close(w.stopCh)
+	w.wg.Wait()
+	w.running = false
+
+	log.Println("文档访问持久化工作者已停止")
+}
+
+// run 工作协程
+func (w *AccessWorker) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			w.flush()
+			return
+		case <-ticker.C:
+			w.flush()
+		}
+	}
+}
+
+// flush 持久化等待中的文档访问，失败时访问留在 Redis 中等待下次持久化
+func (w *AccessWorker) flush() {
+	flushed, err := w.accessUsecase.Flush(context.Background())
+	if err != nil {
+		log.Printf("持久化文档访问失败: %v", err)
+		return
+	}
+	if flushed > 0 {
+		log.Printf("已持久化 %d 条文档访问", flushed)
+	}
+}
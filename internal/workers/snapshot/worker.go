@@ -0,0 +1,141 @@
+package snapshot
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"DOC/domain"
+)
+
+// SnapshotWorker 协作快照工作者
+// 定期将协作操作应用到文档内容生成检查点，并清理超过保留期的操作
+type SnapshotWorker struct {
+	collaborationUsecase domain.CollaborationUsecase
+
+	// 基本配置
+	pollInterval    time.Duration // 检查点扫描间隔
+	minOperations   int64         // 累计多少个操作后生成检查点
+	idleTimeout     time.Duration // 会话空闲多久后生成检查点
+	retention       time.Duration // 检查点之前的操作保留时长
+	cleanupInterval time.Duration // 操作清理间隔
+
+	// 控制
+	stopCh  chan struct{}
+	running bool
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+// WorkerConfig 工作者配置
+type WorkerConfig struct {
+	PollInterval    time.Duration `json:"poll_interval"`    // 检查点扫描间隔，默认10秒
+	MinOperations   int64         `json:"min_operations"`   // 累计操作数阈值，默认200
+	IdleTimeout     time.Duration `json:"idle_timeout"`     // 空闲阈值，默认30秒
+	Retention       time.Duration `json:"retention"`        // 操作保留时长，默认7天
+	CleanupInterval time.Duration `json:"cleanup_interval"` // 操作清理间隔，默认1小时
+}
+
+// NewSnapshotWorker 创建新的协作快照工作者
+func NewSnapshotWorker(collaborationUsecase domain.CollaborationUsecase, config WorkerConfig) *SnapshotWorker {
+	// 设置默认值
+	if config.PollInterval <= 0 {
+		config.PollInterval = 10 * time.Second
+	}
+	if config.MinOperations <= 0 {
+		config.MinOperations = 200
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 30 * time.Second
+	}
+	if config.Retention <= 0 {
+		config.Retention = 7 * 24 * time.Hour
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = time.Hour
+	}
+
+	return &SnapshotWorker{
+		collaborationUsecase: collaborationUsecase,
+		pollInterval:         config.PollInterval,
+		minOperations:        config.MinOperations,
+		idleTimeout:          config.IdleTimeout,
+		retention:            config.Retention,
+		cleanupInterval:      config.CleanupInterval,
+		stopCh:               make(chan struct{}),
+	}
+}
+
+// Start 启动快照工作者
+func (w *SnapshotWorker) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.running {
+		return
+	}
+
+	w.running = true
+	log.Printf("启动协作快照工作者，扫描间隔: %v", w.pollInterval)
+
+	w.wg.Add(1)
+	go w.run()
+}
+
+// Stop 停止快照工作者，停止前为所有有未写入操作的会话生成检查点
+func (w *SnapshotWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.running {
+		return
+	}
+
+	close(w.stopCh)
+	w.wg.Wait()
+	w.running = false
+
+	w.checkpoint(0)
+	log.Println("协作快照工作者已停止")
+}
+
+// run 工作协程
+func (w *SnapshotWorker) run() {
+	defer w.wg.Done()
+
+	checkpointTicker := time.NewTicker(w.pollInterval)
+	defer checkpointTicker.Stop()
+	cleanupTicker := time.NewTicker(w.cleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-checkpointTicker.C:
+			w.checkpoint(w.idleTimeout)
+		case <-cleanupTicker.C:
+			w.cleanup()
+		}
+	}
+}
+
+// checkpoint 为达到操作数阈值或空闲超过 idle 的会话生成检查点
+func (w *SnapshotWorker) checkpoint(idle time.Duration) {
+	created, err := w.collaborationUsecase.CheckpointSessions(context.Background(), w.minOperations, idle)
+	if err != nil {
+		log.Printf("生成协作检查点失败: %v", err)
+		return
+	}
+	if created > 0 {
+		log.Printf("已为 %d 个协作会话生成检查点", created)
+	}
+}
+
+// cleanup 清理超过保留期的操作
+func (w *SnapshotWorker) cleanup() {
+	if err := w.collaborationUsecase.CleanupOldOperations(context.Background(), w.retention); err != nil {
+		log.Printf("清理协作操作失败: %v", err)
+	}
+}
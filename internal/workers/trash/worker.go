@@ -0,0 +1,106 @@
+package trash
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"DOC/domain"
+)
+
+// TrashWorker 回收站清理工作者
+// 定期永久删除超过保留期的回收站条目
+type TrashWorker struct {
+	trashUsecase domain.DocumentTrashUsecase
+
+	// 基本配置
+	purgeInterval time.Duration // 清理间隔
+
+	// 控制
+	stopCh  chan struct{}
+	running bool
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+// WorkerConfig 工作者配置
+type WorkerConfig struct {
+	PurgeInterval time.Duration `json:"purge_interval"` // 清理间隔，默认1小时
+}
+
+// NewTrashWorker 创建新的回收站清理工作者
+func NewTrashWorker(trashUsecase domain.DocumentTrashUsecase, config WorkerConfig) *TrashWorker {
+	// 设置默认值
+	if config.PurgeInterval <= 0 {
+		config.PurgeInterval = time.Hour
+	}
+
+	return &TrashWorker{
+		trashUsecase:  trashUsecase,
+		purgeInterval: config.PurgeInterval,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// Start 启动回收站清理工作者
+func (w *TrashWorker) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.running {
+		return
+	}
+
+	w.running = true
+	log.Printf("启动回收站清理工作者，清理间隔: %v", w.purgeInterval)
+
+	w.wg.Add(1)
+	go w.run()
+}
+
+// Stop 停止回收站清理工作者，等待正在进行的清理完成
+func (w *TrashWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.running {
+		return
+	}
+
+	close(w.stopCh)
+	w.wg.Wait()
+	w.running = false
+
+	log.Println("回收站清理工作者已停止")
+}
+
+// run 工作协程，启动时先清理一次，避免频繁重启时一直不清理
+func (w *TrashWorker) run() {
+	defer w.wg.Done()
+
+	w.purge()
+
+	ticker := time.NewTicker(w.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+			w.purge()
+		}
+	}
+}
+
+// purge 永久删除超过保留期的回收站条目
+func (w *TrashWorker) purge() {
+	purged, err := w.trashUsecase.PurgeExpired(context.Background())
+	if err != nil {
+		log.Printf("清理回收站失败: %v", err)
+	}
+	if purged > 0 {
+		log.Printf("已永久删除 %d 个过期的回收站条目", purged)
+	}
+}
@@ -3,9 +3,18 @@ package main
 import (
 	"DOC/app"
 	"log"
+	"os"
 )
 
 func main() {
+	// go run . reindex 重建文档搜索索引后退出
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		if err := app.Reindex("./"); err != nil {
+			log.Fatalf("重建搜索索引失败 err : %v", err)
+		}
+		return
+	}
+
 	newApp, err := app.NewApp("./")
 	if err != nil {
 		log.Fatalf("启动app失败 err : %v", err)
@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"DOC/domain"
+)
+
+// rebuild 由差异片段还原旧文本和新文本
+func rebuild(chunks []domain.DiffChunk) (string, string) {
+	var oldText, newText strings.Builder
+	for _, chunk := range chunks {
+		if chunk.Op != domain.DiffInsert {
+			oldText.WriteString(chunk.Text)
+		}
+		if chunk.Op != domain.DiffDelete {
+			newText.WriteString(chunk.Text)
+		}
+	}
+	return oldText.String(), newText.String()
+}
+
+func TestTextLines(t *testing.T) {
+	chunks := Text("a\nb\nc\n", "a\nB\nc\nd\n", domain.DiffGranularityLine)
+
+	assert.Equal(t, []domain.DiffChunk{
+		{Op: domain.DiffEqual, Text: "a\n"},
+		{Op: domain.DiffDelete, Text: "b\n"},
+		{Op: domain.DiffInsert, Text: "B\n"},
+		{Op: domain.DiffEqual, Text: "c\n"},
+		{Op: domain.DiffInsert, Text: "d\n"},
+	}, chunks)
+}
+
+func TestTextWords(t *testing.T) {
+	chunks := Text("第一段\nthe quick fox\n", "第一段\nthe slow fox\n", domain.DiffGranularityWord)
+
+	assert.Equal(t, []domain.DiffChunk{
+		{Op: domain.DiffEqual, Text: "第一段\nthe "},
+		{Op: domain.DiffDelete, Text: "quick"},
+		{Op: domain.DiffInsert, Text: "slow"},
+		{Op: domain.DiffEqual, Text: " fox\n"},
+	}, chunks)
+
+	// 汉字逐字对齐
+	assert.Equal(t, []domain.DiffChunk{
+		{Op: domain.DiffEqual, Text: "今天"},
+		{Op: domain.DiffDelete, Text: "下雨"},
+		{Op: domain.DiffInsert, Text: "晴朗"},
+	}, Words("今天下雨", "今天晴朗"))
+}
+
+func TestTextRebuildsBothSides(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"alpha", "beta", "文档", "版本", " ", "\n", ",", "x"}
+	random := func() string {
+		var builder strings.Builder
+		for i := rng.Intn(40); i > 0; i-- {
+			builder.WriteString(words[rng.Intn(len(words))])
+		}
+		return builder.String()
+	}
+
+	for i := 0; i < 300; i++ {
+		oldText, newText := random(), random()
+		for _, granularity := range []domain.DiffGranularity{domain.DiffGranularityLine, domain.DiffGranularityWord} {
+			gotOld, gotNew := rebuild(Text(oldText, newText, granularity))
+			require.Equal(t, oldText, gotOld)
+			require.Equal(t, newText, gotNew)
+		}
+	}
+}
+
+func TestNodes(t *testing.T) {
+	oldContent := `{"blocks":[{"type":"paragraph","data":{"text":"一"}},{"type":"paragraph","data":{"text":"二"}}],"entityMap":{}}`
+	newContent := `{"blocks":[{"type":"header","data":{"text":"标题","level":1}},{"type":"paragraph","data":{"text":"一"}},{"type":"paragraph","data":{"text":"贰"}}],"version":2}`
+
+	changes, ok := Nodes(oldContent, newContent)
+	require.True(t, ok)
+
+	assert.Equal(t, []domain.DiffNodeChange{
+		{Op: domain.DiffInsert, Path: "/blocks/0", New: []byte(`{"data":{"level":1,"text":"标题"},"type":"header"}`)},
+		{Op: domain.DiffReplace, Path: "/blocks/2/data/text", Old: []byte(`"二"`), New: []byte(`"贰"`)},
+		{Op: domain.DiffDelete, Path: "/entityMap", Old: []byte(`{}`)},
+		{Op: domain.DiffInsert, Path: "/version", New: []byte(`2`)},
+	}, changes)
+
+	_, ok = Nodes("plain text", newContent)
+	assert.False(t, ok)
+}
+
+func TestPlainText(t *testing.T) {
+	content := `{"blocks":[{"type":"paragraph","data":{"text":"第一段"}},{"data":{"text":"第二段"},"type":"paragraph"}]}`
+	assert.Equal(t, "第一段\n第二段\n", PlainText(content))
+	assert.Equal(t, "not json", PlainText("not json"))
+}
+
+func TestHTML(t *testing.T) {
+	rendered := HTML(
+		[]domain.DiffChunk{{Op: domain.DiffEqual, Text: "标题"}},
+		[]domain.DiffChunk{
+			{Op: domain.DiffEqual, Text: "a\n"},
+			{Op: domain.DiffDelete, Text: "<b>"},
+			{Op: domain.DiffInsert, Text: "c"},
+		},
+	)
+
+	assert.Equal(t, `<div class="document-diff"><h1 class="document-diff-title">标题</h1>`+
+		`<div class="document-diff-body">a<br><del class="diff-delete">&lt;b&gt;</del><ins class="diff-insert">c</ins></div></div>`, rendered)
+}
@@ -0,0 +1,37 @@
+package diff
+
+import (
+	"html"
+	"strings"
+
+	"DOC/domain"
+)
+
+// HTML 将标题和内容的差异渲染为 HTML
+// 新增片段使用 <ins>，删除片段使用 <del>，换行渲染为 <br>，文本均已转义
+func HTML(title, body []domain.DiffChunk) string {
+	var builder strings.Builder
+	builder.WriteString(`<div class="document-diff">`)
+	builder.WriteString(`<h1 class="document-diff-title">`)
+	writeChunks(&builder, title)
+	builder.WriteString(`</h1>`)
+	builder.WriteString(`<div class="document-diff-body">`)
+	writeChunks(&builder, body)
+	builder.WriteString(`</div></div>`)
+	return builder.String()
+}
+
+// writeChunks 渲染差异片段
+func writeChunks(builder *strings.Builder, chunks []domain.DiffChunk) {
+	for _, chunk := range chunks {
+		text := strings.ReplaceAll(html.EscapeString(chunk.Text), "\n", "<br>")
+		switch chunk.Op {
+		case domain.DiffInsert:
+			builder.WriteString(`<ins class="diff-insert">` + text + `</ins>`)
+		case domain.DiffDelete:
+			builder.WriteString(`<del class="diff-delete">` + text + `</del>`)
+		default:
+			builder.WriteString(text)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"DOC/domain"
+)
+
+// Nodes 计算两份 JSON 内容的节点差异，任一内容不是 JSON 时返回 false
+// 对象按键比较；数组按元素对齐，一段连续变化中删除和新增的元素按位置配对，
+// 配对的元素都是对象或都是数组时递归比较，否则整体替换
+func Nodes(oldContent, newContent string) ([]domain.DiffNodeChange, bool) {
+	oldValue, ok := decode(oldContent)
+	if !ok {
+		return nil, false
+	}
+	newValue, ok := decode(newContent)
+	if !ok {
+		return nil, false
+	}
+
+	var changes []domain.DiffNodeChange
+	compare("", oldValue, newValue, &changes)
+	return changes, true
+}
+
+// PlainText 提取内容中的文本，JSON 内容按文档顺序提取所有 text 字段，每个一行
+// 内容不是 JSON 时原样返回
+func PlainText(content string) string {
+	if _, ok := decode(content); !ok {
+		return content
+	}
+
+	// 使用流式解析以保持字段在文档中的顺序
+	decoder := json.NewDecoder(strings.NewReader(content))
+	var builder strings.Builder
+
+	// 每层容器是否为对象，以及对象当前是否在等待键
+	type frame struct {
+		object     bool
+		expectsKey bool
+	}
+	var stack []frame
+	lastKey := ""
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return content
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = &stack[len(stack)-1]
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				if top != nil && top.object {
+					top.expectsKey = true
+				}
+				stack = append(stack, frame{object: delim == '{', expectsKey: delim == '{'})
+			default:
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		if top == nil {
+			// 整个内容是一个 JSON 字符串
+			if text, ok := token.(string); ok {
+				builder.WriteString(text)
+			}
+			continue
+		}
+		if top.object && top.expectsKey {
+			lastKey, _ = token.(string)
+			top.expectsKey = false
+			continue
+		}
+		if top.object {
+			top.expectsKey = true
+			if text, ok := token.(string); ok && lastKey == "text" && text != "" {
+				builder.WriteString(text)
+				builder.WriteByte('\n')
+			}
+		}
+	}
+
+	return builder.String()
+}
+
+// decode 解析 JSON 内容，数字保留原始表示
+func decode(content string) (interface{}, bool) {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	if decoder.More() {
+		return nil, false
+	}
+	return value, true
+}
+
+// compare 递归比较两个 JSON 值
+func compare(path string, oldValue, newValue interface{}, changes *[]domain.DiffNodeChange) {
+	if reflect.DeepEqual(oldValue, newValue) {
+		return
+	}
+
+	switch oldTyped := oldValue.(type) {
+	case map[string]interface{}:
+		if newTyped, ok := newValue.(map[string]interface{}); ok {
+			compareObjects(path, oldTyped, newTyped, changes)
+			return
+		}
+	case []interface{}:
+		if newTyped, ok := newValue.([]interface{}); ok {
+			compareArrays(path, oldTyped, newTyped, changes)
+			return
+		}
+	}
+
+	*changes = append(*changes, domain.DiffNodeChange{
+		Op:   domain.DiffReplace,
+		Path: path,
+		Old:  encode(oldValue),
+		New:  encode(newValue),
+	})
+}
+
+// compareObjects 按键比较两个对象，键按字典序输出以保证结果稳定
+func compareObjects(path string, oldObject, newObject map[string]interface{}, changes *[]domain.DiffNodeChange) {
+	keys := make([]string, 0, len(oldObject)+len(newObject))
+	for key := range oldObject {
+		keys = append(keys, key)
+	}
+	for key := range newObject {
+		if _, exists := oldObject[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "/" + escapePointer(key)
+		oldChild, inOld := oldObject[key]
+		newChild, inNew := newObject[key]
+		switch {
+		case !inNew:
+			*changes = append(*changes, domain.DiffNodeChange{Op: domain.DiffDelete, Path: childPath, Old: encode(oldChild)})
+		case !inOld:
+			*changes = append(*changes, domain.DiffNodeChange{Op: domain.DiffInsert, Path: childPath, New: encode(newChild)})
+		default:
+			compare(childPath, oldChild, newChild, changes)
+		}
+	}
+}
+
+// compareArrays 对齐两个数组的元素后比较
+func compareArrays(path string, oldArray, newArray []interface{}, changes *[]domain.DiffNodeChange) {
+	oldKeys := make([]string, len(oldArray))
+	for i, element := range oldArray {
+		oldKeys[i] = string(encode(element))
+	}
+	newKeys := make([]string, len(newArray))
+	for i, element := range newArray {
+		newKeys[i] = string(encode(element))
+	}
+
+	var deleted, inserted []int
+	flush := func() {
+		paired := len(deleted)
+		if len(inserted) < paired {
+			paired = len(inserted)
+		}
+		for i := 0; i < paired; i++ {
+			oldElement, newElement := oldArray[deleted[i]], newArray[inserted[i]]
+			if sameContainer(oldElement, newElement) {
+				compare(path+"/"+strconv.Itoa(inserted[i]), oldElement, newElement, changes)
+			} else {
+				*changes = append(*changes, domain.DiffNodeChange{
+					Op:   domain.DiffReplace,
+					Path: path + "/" + strconv.Itoa(inserted[i]),
+					Old:  encode(oldElement),
+					New:  encode(newElement),
+				})
+			}
+		}
+		for _, index := range deleted[paired:] {
+			*changes = append(*changes, domain.DiffNodeChange{Op: domain.DiffDelete, Path: path + "/" + strconv.Itoa(index), Old: encode(oldArray[index])})
+		}
+		for _, index := range inserted[paired:] {
+			*changes = append(*changes, domain.DiffNodeChange{Op: domain.DiffInsert, Path: path + "/" + strconv.Itoa(index), New: encode(newArray[index])})
+		}
+		deleted, inserted = deleted[:0], inserted[:0]
+	}
+
+	for _, e := range align(oldKeys, newKeys) {
+		switch e.op {
+		case editEqual:
+			flush()
+		case editDelete:
+			deleted = append(deleted, e.index)
+		case editInsert:
+			inserted = append(inserted, e.index)
+		}
+	}
+	flush()
+}
+
+// sameContainer 检查两个值是否都是对象或都是数组
+func sameContainer(a, b interface{}) bool {
+	switch a.(type) {
+	case map[string]interface{}:
+		_, ok := b.(map[string]interface{})
+		return ok
+	case []interface{}:
+		_, ok := b.([]interface{})
+		return ok
+	}
+	return false
+}
+
+// encode 将 JSON 值编码为紧凑格式，对象的键按字典序排列，可用于比较相等
+func encode(value interface{}) json.RawMessage {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil
+	}
+	return bytes.TrimRight(buffer.Bytes(), "\n")
+}
+
+// escapePointer 按 JSON Pointer 规则转义路径中的键
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
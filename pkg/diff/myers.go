@@ -0,0 +1,147 @@
+// Package diff 计算文档版本之间的差异
+//
+// 文本差异按行或按词计算，按词计算时先按行对齐，再在变化的行内按词细化，
+// 中文等没有空格分隔的文字每个字作为一个词。
+// JSON 内容模型按节点计算差异：对象按键比较，数组按元素对齐，
+// 因此在数组中插入或删除一个块只产生一处变化，而不是其后所有块都变化。
+package diff
+
+// maxEditDistance 单次对齐允许的最大编辑距离，超过时将两段内容整体视为删除和插入
+// 对齐的内存开销与编辑距离的平方成正比
+const maxEditDistance = 2000
+
+// editOp 对齐结果中单个元素的操作
+type editOp int
+
+const (
+	editEqual editOp = iota
+	editDelete
+	editInsert
+)
+
+// edit 对齐结果中的单个元素，index 为元素在旧序列（相等和删除）或新序列（插入）中的下标
+type edit struct {
+	op    editOp
+	index int
+}
+
+// align 使用 Myers 算法计算两个序列的最短编辑脚本
+// 先去掉相同的前缀和后缀，编辑距离超过 maxEditDistance 时中间部分整体视为删除和插入
+func align(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b)-prefix-suffix)
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, edit{op: editEqual, index: i})
+	}
+
+	middleA := a[prefix : len(a)-suffix]
+	middleB := b[prefix : len(b)-suffix]
+	if middle, ok := myers(middleA, middleB); ok {
+		for _, e := range middle {
+			edits = append(edits, edit{op: e.op, index: e.index + prefix})
+		}
+	} else {
+		for i := range middleA {
+			edits = append(edits, edit{op: editDelete, index: prefix + i})
+		}
+		for j := range middleB {
+			edits = append(edits, edit{op: editInsert, index: prefix + j})
+		}
+	}
+
+	for i := len(a) - suffix; i < len(a); i++ {
+		edits = append(edits, edit{op: editEqual, index: i})
+	}
+	return edits
+}
+
+// myers 计算最短编辑脚本，编辑距离超过 maxEditDistance 时返回 false
+func myers(a, b []string) ([]edit, bool) {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil, true
+	}
+
+	limit := n + m
+	if limit > maxEditDistance {
+		limit = maxEditDistance
+	}
+
+	// v[k] 为对角线 k 上能到达的最远 x，trace[d] 保存第 d 步开始时 [-d-1, d+1] 范围内的 v
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, n, m), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack 从终点沿保存的搜索状态回溯出编辑脚本
+func backtrack(trace [][]int, n, m int) []edit {
+	var reversed []edit
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		snapshot := trace[d]
+		at := func(k int) int { return snapshot[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, edit{op: editEqual, index: x})
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, edit{op: editInsert, index: prevY})
+			} else {
+				reversed = append(reversed, edit{op: editDelete, index: prevX})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	edits := make([]edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+	return edits
+}
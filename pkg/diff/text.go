@@ -0,0 +1,145 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+
+	"DOC/domain"
+)
+
+// Text 计算两段文本的差异
+// 按词计算时先按行对齐，再将每段连续变化的行按词细化，避免长文本逐词对齐的开销
+func Text(oldText, newText string, granularity domain.DiffGranularity) []domain.DiffChunk {
+	oldLines, newLines := splitLines(oldText), splitLines(newText)
+	edits := align(oldLines, newLines)
+
+	var chunks []domain.DiffChunk
+	var deleted, inserted strings.Builder
+	flush := func() {
+		if deleted.Len() == 0 && inserted.Len() == 0 {
+			return
+		}
+		if granularity == domain.DiffGranularityWord {
+			chunks = appendChunks(chunks, tokens(splitWords(deleted.String()), splitWords(inserted.String()))...)
+		} else {
+			chunks = appendChunk(chunks, domain.DiffDelete, deleted.String())
+			chunks = appendChunk(chunks, domain.DiffInsert, inserted.String())
+		}
+		deleted.Reset()
+		inserted.Reset()
+	}
+
+	for _, e := range edits {
+		switch e.op {
+		case editEqual:
+			flush()
+			chunks = appendChunk(chunks, domain.DiffEqual, oldLines[e.index])
+		case editDelete:
+			deleted.WriteString(oldLines[e.index])
+		case editInsert:
+			inserted.WriteString(newLines[e.index])
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// Words 按词计算两段短文本的差异，用于标题等单行文本
+func Words(oldText, newText string) []domain.DiffChunk {
+	return tokens(splitWords(oldText), splitWords(newText))
+}
+
+// tokens 对齐两个词序列并合并为差异片段
+func tokens(a, b []string) []domain.DiffChunk {
+	var chunks []domain.DiffChunk
+	for _, e := range align(a, b) {
+		switch e.op {
+		case editEqual:
+			chunks = appendChunk(chunks, domain.DiffEqual, a[e.index])
+		case editDelete:
+			chunks = appendChunk(chunks, domain.DiffDelete, a[e.index])
+		case editInsert:
+			chunks = appendChunk(chunks, domain.DiffInsert, b[e.index])
+		}
+	}
+	return chunks
+}
+
+// appendChunks 依次追加差异片段
+func appendChunks(chunks []domain.DiffChunk, more ...domain.DiffChunk) []domain.DiffChunk {
+	for _, chunk := range more {
+		chunks = appendChunk(chunks, chunk.Op, chunk.Text)
+	}
+	return chunks
+}
+
+// appendChunk 追加差异片段，与最后一个片段操作相同时合并
+func appendChunk(chunks []domain.DiffChunk, op domain.DiffOperation, text string) []domain.DiffChunk {
+	if text == "" {
+		return chunks
+	}
+	if n := len(chunks); n > 0 && chunks[n-1].Op == op {
+		chunks[n-1].Text += text
+		return chunks
+	}
+	return append(chunks, domain.DiffChunk{Op: op, Text: text})
+}
+
+// splitLines 将文本拆分为行，每行保留结尾的换行符
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitWords 将文本拆分为词：连续的字母数字、连续的空白各为一个词，汉字和标点每个字符为一个词
+func splitWords(text string) []string {
+	var words []string
+	start := -1
+	var startClass runeClass
+
+	for i, r := range text {
+		class := classify(r)
+		if start >= 0 && (class != startClass || class == classSingle) {
+			words = append(words, text[start:i])
+			start = -1
+		}
+		if start < 0 {
+			start = i
+			startClass = class
+		}
+	}
+	if start >= 0 {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+// runeClass 字符的分词类别
+type runeClass int
+
+const (
+	classWord   runeClass = iota // 字母和数字
+	classSpace                   // 空白
+	classSingle                  // 汉字、标点等单独成词的字符
+)
+
+// classify 获取字符的分词类别
+func classify(r rune) runeClass {
+	switch {
+	case unicode.Is(unicode.Han, r) || unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		return classSingle
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+		return classWord
+	case unicode.IsSpace(r):
+		return classSpace
+	default:
+		return classSingle
+	}
+}
@@ -0,0 +1,287 @@
+// Package ot 实现协作编辑的操作转换（Operational Transformation）算法
+//
+// 文档视为 Unicode 字符（rune）序列，支持插入、删除和格式化三类操作。
+// 单个操作在转换后可能被拆分为多个按顺序执行的操作，因此所有转换函数都以操作序列为单位。
+// 并发冲突的处理规则：
+//   - 同一位置的并发插入，优先方排在前面
+//   - 插入点落在并发删除范围内时，插入内容保留在删除位置
+//   - 重叠范围内的格式化冲突属性以优先方为准
+package ot
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"DOC/domain"
+)
+
+// Validate 验证操作是否可以参与转换
+func Validate(op *domain.CollaborationOperation) error {
+	if op == nil || op.Position < 0 {
+		return domain.ErrInvalidCollaborationOperation
+	}
+
+	switch op.Type {
+	case domain.CollaborationOperationTypeInsert:
+		if op.Content == "" {
+			return domain.ErrInvalidCollaborationOperation
+		}
+	case domain.CollaborationOperationTypeDelete:
+		if op.Length <= 0 {
+			return domain.ErrInvalidCollaborationOperation
+		}
+	case domain.CollaborationOperationTypeFormat:
+		if op.Length <= 0 {
+			return domain.ErrInvalidCollaborationOperation
+		}
+		if _, err := attributes(op); err != nil {
+			return domain.ErrInvalidCollaborationOperation
+		}
+	default:
+		return domain.ErrInvalidCollaborationOperation
+	}
+	return nil
+}
+
+// Transform 将操作序列转换到并发操作序列之后执行
+// concurrent 为服务端已经应用的操作，冲突时优先于 ops
+func Transform(ops, concurrent []*domain.CollaborationOperation) ([]*domain.CollaborationOperation, error) {
+	for _, op := range ops {
+		if err := Validate(op); err != nil {
+			return nil, err
+		}
+	}
+	for _, op := range concurrent {
+		if err := Validate(op); err != nil {
+			return nil, err
+		}
+	}
+
+	transformed, _ := TransformPair(ops, concurrent, false)
+	return transformed, nil
+}
+
+// TransformPair 对两个基于同一文档状态的并发操作序列做双向转换
+// 返回 a' 和 b'，满足 apply(apply(S, a), b') == apply(apply(S, b), a')
+// aWins 表示冲突时 a 是否优先
+func TransformPair(a, b []*domain.CollaborationOperation, aWins bool) ([]*domain.CollaborationOperation, []*domain.CollaborationOperation) {
+	if len(a) == 0 || len(b) == 0 {
+		return a, b
+	}
+
+	if len(a) == 1 && len(b) == 1 {
+		return transformOne(a[0], b[0], aWins), transformOne(b[0], a[0], !aWins)
+	}
+
+	if len(a) > 1 {
+		head, rest := TransformPair(a[:1], b, aWins)
+		tail, rest := TransformPair(a[1:], rest, aWins)
+		return concat(head, tail), rest
+	}
+
+	first, bHead := TransformPair(a, b[:1], aWins)
+	result, bTail := TransformPair(first, b[1:], aWins)
+	return result, concat(bHead, bTail)
+}
+
+// Apply 将操作序列依次应用到文本内容上
+// 格式化操作只校验范围，不改变文本
+func Apply(content string, ops ...*domain.CollaborationOperation) (string, error) {
+	runes := []rune(content)
+
+	for _, op := range ops {
+		if err := Validate(op); err != nil {
+			return "", err
+		}
+
+		switch op.Type {
+		case domain.CollaborationOperationTypeInsert:
+			if op.Position > len(runes) {
+				return "", domain.ErrInvalidCollaborationOperation
+			}
+			inserted := []rune(op.Content)
+			next := make([]rune, 0, len(runes)+len(inserted))
+			next = append(next, runes[:op.Position]...)
+			next = append(next, inserted...)
+			runes = append(next, runes[op.Position:]...)
+		case domain.CollaborationOperationTypeDelete:
+			if op.Position+op.Length > len(runes) {
+				return "", domain.ErrInvalidCollaborationOperation
+			}
+			runes = append(runes[:op.Position:op.Position], runes[op.Position+op.Length:]...)
+		case domain.CollaborationOperationTypeFormat:
+			if op.Position+op.Length > len(runes) {
+				return "", domain.ErrInvalidCollaborationOperation
+			}
+		}
+	}
+
+	return string(runes), nil
+}
+
+// transformOne 将单个操作 a 转换到 b 之后执行
+func transformOne(a, b *domain.CollaborationOperation, aWins bool) []*domain.CollaborationOperation {
+	switch a.Type {
+	case domain.CollaborationOperationTypeInsert:
+		return []*domain.CollaborationOperation{transformInsert(a, b, aWins)}
+	case domain.CollaborationOperationTypeDelete, domain.CollaborationOperationTypeFormat:
+		return transformRange(a, b, aWins)
+	}
+	return []*domain.CollaborationOperation{clone(a)}
+}
+
+// transformInsert 转换插入操作
+func transformInsert(a, b *domain.CollaborationOperation, aWins bool) *domain.CollaborationOperation {
+	result := clone(a)
+
+	switch b.Type {
+	case domain.CollaborationOperationTypeInsert:
+		if b.Position < a.Position || (b.Position == a.Position && !aWins) {
+			result.Position += runeLen(b)
+		}
+	case domain.CollaborationOperationTypeDelete:
+		end := b.Position + b.Length
+		switch {
+		case a.Position <= b.Position:
+		case a.Position >= end:
+			result.Position -= b.Length
+		default:
+			// 插入点被删除，保留到删除起点
+			result.Position = b.Position
+		}
+	}
+
+	return result
+}
+
+// transformRange 转换删除或格式化等作用于范围的操作
+func transformRange(a, b *domain.CollaborationOperation, aWins bool) []*domain.CollaborationOperation {
+	start, end := a.Position, a.Position+a.Length
+
+	switch b.Type {
+	case domain.CollaborationOperationTypeInsert:
+		inserted := runeLen(b)
+		switch {
+		case b.Position <= start:
+			result := clone(a)
+			result.Position += inserted
+			return []*domain.CollaborationOperation{result}
+		case b.Position >= end:
+			return []*domain.CollaborationOperation{clone(a)}
+		default:
+			// 插入点位于范围内部，拆分为两段以跳过新插入的内容
+			left := withRange(a, start, b.Position-start)
+			right := withRange(a, b.Position+inserted, end-b.Position)
+			if a.Type == domain.CollaborationOperationTypeDelete {
+				// 左段删除后右段整体左移
+				right.Position -= left.Length
+			}
+			return []*domain.CollaborationOperation{left, right}
+		}
+
+	case domain.CollaborationOperationTypeDelete:
+		position, length := shrinkRange(start, end, b.Position, b.Position+b.Length)
+		if length == 0 {
+			return nil
+		}
+		return []*domain.CollaborationOperation{withRange(a, position, length)}
+
+	case domain.CollaborationOperationTypeFormat:
+		if a.Type == domain.CollaborationOperationTypeFormat && !aWins {
+			return transformFormatConflict(a, b)
+		}
+	}
+
+	return []*domain.CollaborationOperation{clone(a)}
+}
+
+// transformFormatConflict 处理并发格式化冲突，重叠部分去掉对方已设置的属性
+func transformFormatConflict(a, b *domain.CollaborationOperation) []*domain.CollaborationOperation {
+	start, end := a.Position, a.Position+a.Length
+	overlapStart := max(start, b.Position)
+	overlapEnd := min(end, b.Position+b.Length)
+	if overlapStart >= overlapEnd {
+		return []*domain.CollaborationOperation{clone(a)}
+	}
+
+	attrs, _ := attributes(a)
+	otherAttrs, _ := attributes(b)
+	conflict := false
+	for key := range otherAttrs {
+		if _, ok := attrs[key]; ok {
+			delete(attrs, key)
+			conflict = true
+		}
+	}
+	if !conflict {
+		return []*domain.CollaborationOperation{clone(a)}
+	}
+
+	var result []*domain.CollaborationOperation
+	if overlapStart > start {
+		result = append(result, withRange(a, start, overlapStart-start))
+	}
+	if len(attrs) > 0 {
+		overlap := withRange(a, overlapStart, overlapEnd-overlapStart)
+		metadata, _ := json.Marshal(attrs)
+		overlap.Metadata = string(metadata)
+		result = append(result, overlap)
+	}
+	if end > overlapEnd {
+		result = append(result, withRange(a, overlapEnd, end-overlapEnd))
+	}
+	return result
+}
+
+// shrinkRange 计算范围 [start, end) 在删除 [delStart, delEnd) 之后的位置和长度
+func shrinkRange(start, end, delStart, delEnd int) (int, int) {
+	overlap := max(0, min(end, delEnd)-max(start, delStart))
+	length := end - start - overlap
+
+	switch {
+	case start <= delStart:
+		return start, length
+	case start >= delEnd:
+		return start - (delEnd - delStart), length
+	default:
+		return delStart, length
+	}
+}
+
+// attributes 解析格式化操作的属性
+func attributes(op *domain.CollaborationOperation) (map[string]json.RawMessage, error) {
+	attrs := map[string]json.RawMessage{}
+	if op.Metadata == "" {
+		return attrs, nil
+	}
+	if err := json.Unmarshal([]byte(op.Metadata), &attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// withRange 复制操作并设置新的范围
+func withRange(op *domain.CollaborationOperation, position, length int) *domain.CollaborationOperation {
+	result := clone(op)
+	result.Position = position
+	result.Length = length
+	return result
+}
+
+// clone 复制操作，转换过程中不修改原操作
+func clone(op *domain.CollaborationOperation) *domain.CollaborationOperation {
+	result := *op
+	return &result
+}
+
+// runeLen 插入内容的字符数
+func runeLen(op *domain.CollaborationOperation) int {
+	return utf8.RuneCountInString(op.Content)
+}
+
+// concat 拼接两个操作序列
+func concat(a, b []*domain.CollaborationOperation) []*domain.CollaborationOperation {
+	result := make([]*domain.CollaborationOperation, 0, len(a)+len(b))
+	result = append(result, a...)
+	return append(result, b...)
+}
@@ -0,0 +1,327 @@
+package ot
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"DOC/domain"
+)
+
+// richDoc 测试用文档模型：文本加逐字符格式属性
+type richDoc struct {
+	text  []rune
+	attrs []map[string]string
+}
+
+func newRichDoc(content string) *richDoc {
+	doc := &richDoc{text: []rune(content)}
+	doc.attrs = make([]map[string]string, len(doc.text))
+	for i := range doc.attrs {
+		doc.attrs[i] = map[string]string{}
+	}
+	return doc
+}
+
+func (d *richDoc) clone() *richDoc {
+	result := &richDoc{text: append([]rune(nil), d.text...)}
+	for _, attrs := range d.attrs {
+		copied := make(map[string]string, len(attrs))
+		for k, v := range attrs {
+			copied[k] = v
+		}
+		result.attrs = append(result.attrs, copied)
+	}
+	return result
+}
+
+func (d *richDoc) apply(t *testing.T, ops []*domain.CollaborationOperation) {
+	t.Helper()
+
+	// 文本部分与 Apply 的结果必须一致
+	expected, err := Apply(string(d.text), ops...)
+	require.NoError(t, err)
+
+	for _, op := range ops {
+		switch op.Type {
+		case domain.CollaborationOperationTypeInsert:
+			inserted := []rune(op.Content)
+			attrs := make([]map[string]string, len(inserted))
+			for i := range attrs {
+				attrs[i] = map[string]string{}
+			}
+			d.text = append(d.text[:op.Position], append(inserted, d.text[op.Position:]...)...)
+			d.attrs = append(d.attrs[:op.Position], append(attrs, d.attrs[op.Position:]...)...)
+		case domain.CollaborationOperationTypeDelete:
+			d.text = append(d.text[:op.Position], d.text[op.Position+op.Length:]...)
+			d.attrs = append(d.attrs[:op.Position], d.attrs[op.Position+op.Length:]...)
+		case domain.CollaborationOperationTypeFormat:
+			var values map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal([]byte(op.Metadata), &values))
+			for i := op.Position; i < op.Position+op.Length; i++ {
+				for k, v := range values {
+					d.attrs[i][k] = string(v)
+				}
+			}
+		}
+	}
+
+	require.Equal(t, expected, string(d.text))
+}
+
+func (d *richDoc) assertEqual(t *testing.T, other *richDoc) {
+	t.Helper()
+	require.Equal(t, string(d.text), string(other.text))
+	require.Equal(t, d.attrs, other.attrs)
+}
+
+// randomOp 基于当前文档生成一个合法的随机操作
+func randomOp(rng *rand.Rand, doc *richDoc) *domain.CollaborationOperation {
+	length := len(doc.text)
+	alphabet := []rune("abcxyz中文")
+
+	kind := rng.Intn(3)
+	if length == 0 {
+		kind = 0
+	}
+
+	switch kind {
+	case 0:
+		content := make([]rune, 1+rng.Intn(3))
+		for i := range content {
+			content[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return &domain.CollaborationOperation{
+			Type:     domain.CollaborationOperationTypeInsert,
+			Position: rng.Intn(length + 1),
+			Content:  string(content),
+		}
+	case 1:
+		position := rng.Intn(length)
+		return &domain.CollaborationOperation{
+			Type:     domain.CollaborationOperationTypeDelete,
+			Position: position,
+			Length:   1 + rng.Intn(min(4, length-position)),
+		}
+	default:
+		position := rng.Intn(length)
+		formats := []string{`{"bold":true}`, `{"bold":false}`, `{"color":"red"}`, `{"color":"blue","bold":true}`}
+		return &domain.CollaborationOperation{
+			Type:     domain.CollaborationOperationTypeFormat,
+			Position: position,
+			Length:   1 + rng.Intn(min(4, length-position)),
+			Metadata: formats[rng.Intn(len(formats))],
+		}
+	}
+}
+
+// randomOps 基于文档生成一串依次执行的随机操作，并应用到 doc 上
+func randomOps(t *testing.T, rng *rand.Rand, doc *richDoc, n int) []*domain.CollaborationOperation {
+	var ops []*domain.CollaborationOperation
+	for i := 0; i < n; i++ {
+		op := randomOp(rng, doc)
+		doc.apply(t, []*domain.CollaborationOperation{op})
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+func TestTransformInsertTieBreak(t *testing.T) {
+	a := []*domain.CollaborationOperation{{Type: domain.CollaborationOperationTypeInsert, Position: 1, Content: "A"}}
+	b := []*domain.CollaborationOperation{{Type: domain.CollaborationOperationTypeInsert, Position: 1, Content: "B"}}
+
+	aPrime, bPrime := TransformPair(a, b, true)
+	left, err := Apply("xy", append(a, bPrime...)...)
+	require.NoError(t, err)
+	right, err := Apply("xy", append(b, aPrime...)...)
+	require.NoError(t, err)
+
+	assert.Equal(t, "xABy", left)
+	assert.Equal(t, left, right)
+}
+
+func TestTransformDeleteKeepsConcurrentInsert(t *testing.T) {
+	del := []*domain.CollaborationOperation{{Type: domain.CollaborationOperationTypeDelete, Position: 1, Length: 3}}
+	ins := []*domain.CollaborationOperation{{Type: domain.CollaborationOperationTypeInsert, Position: 2, Content: "ZZ"}}
+
+	delPrime, insPrime := TransformPair(del, ins, false)
+	assert.Len(t, delPrime, 2)
+
+	left, err := Apply("abcde", append(del, insPrime...)...)
+	require.NoError(t, err)
+	right, err := Apply("abcde", append(ins, delPrime...)...)
+	require.NoError(t, err)
+
+	assert.Equal(t, "aZZe", left)
+	assert.Equal(t, left, right)
+}
+
+func TestTransformRejectsInvalidOperation(t *testing.T) {
+	_, err := Transform([]*domain.CollaborationOperation{{Type: domain.CollaborationOperationTypeCursor}}, nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidCollaborationOperation)
+
+	_, err = Transform([]*domain.CollaborationOperation{{Type: domain.CollaborationOperationTypeDelete, Position: 0}}, nil)
+	assert.ErrorIs(t, err, domain.ErrInvalidCollaborationOperation)
+}
+
+// TestTransformPairConvergence 随机并发操作序列双向转换后两端结果一致
+func TestTransformPairConvergence(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 3000; i++ {
+		base := newRichDoc("hello world")
+		randomOps(t, rng, base, rng.Intn(4))
+
+		siteA, siteB := base.clone(), base.clone()
+		a := randomOps(t, rng, siteA, 1+rng.Intn(4))
+		b := randomOps(t, rng, siteB, 1+rng.Intn(4))
+
+		aPrime, bPrime := TransformPair(a, b, rng.Intn(2) == 0)
+		siteA.apply(t, bPrime)
+		siteB.apply(t, aPrime)
+
+		siteA.assertEqual(t, siteB)
+	}
+}
+
+// simClient 模拟客户端：已发送未确认的操作和本地缓冲操作
+type simClient struct {
+	doc      *richDoc
+	revision int
+	awaiting bool
+	inflight []*domain.CollaborationOperation
+	buffer   []*domain.CollaborationOperation
+	inbox    []simMessage
+}
+
+// simMessage 模拟服务端下发的消息
+type simMessage struct {
+	ack      bool
+	revision int
+	ops      []*domain.CollaborationOperation
+}
+
+// simSubmit 模拟客户端提交到服务端的消息
+type simSubmit struct {
+	client   int
+	revision int
+	ops      []*domain.CollaborationOperation
+}
+
+// TestServerConvergence 随机交错多个客户端的提交、服务端处理和消息投递，最终所有副本一致
+func TestServerConvergence(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+
+		server := newRichDoc("collaborative")
+		var history [][]*domain.CollaborationOperation
+		var queue []simSubmit
+
+		clients := make([]*simClient, 2+rng.Intn(3))
+		for i := range clients {
+			clients[i] = &simClient{doc: server.clone()}
+		}
+
+		send := func(id int, c *simClient, ops []*domain.CollaborationOperation) {
+			c.inflight = ops
+			c.awaiting = true
+			queue = append(queue, simSubmit{client: id, revision: c.revision, ops: ops})
+		}
+
+		serverStep := func() {
+			submit := queue[0]
+			queue = queue[1:]
+
+			var concurrent []*domain.CollaborationOperation
+			for _, ops := range history[submit.revision:] {
+				concurrent = append(concurrent, ops...)
+			}
+			transformed, err := Transform(submit.ops, concurrent)
+			require.NoError(t, err)
+
+			if len(transformed) > 0 {
+				server.apply(t, transformed)
+				history = append(history, transformed)
+			}
+			for id, c := range clients {
+				if id == submit.client {
+					c.inbox = append(c.inbox, simMessage{ack: true, revision: len(history)})
+				} else if len(transformed) > 0 {
+					c.inbox = append(c.inbox, simMessage{revision: len(history), ops: transformed})
+				}
+			}
+		}
+
+		deliver := func(id int, c *simClient) {
+			msg := c.inbox[0]
+			c.inbox = c.inbox[1:]
+			c.revision = msg.revision
+
+			if msg.ack {
+				c.awaiting = false
+				c.inflight = nil
+				if len(c.buffer) > 0 {
+					buffered := c.buffer
+					c.buffer = nil
+					send(id, c, buffered)
+				}
+				return
+			}
+
+			ops := msg.ops
+			c.inflight, ops = TransformPair(c.inflight, ops, false)
+			c.buffer, ops = TransformPair(c.buffer, ops, false)
+			c.doc.apply(t, ops)
+		}
+
+		for step := 0; step < 300; step++ {
+			id := rng.Intn(len(clients))
+			c := clients[id]
+
+			switch rng.Intn(3) {
+			case 0:
+				op := randomOp(rng, c.doc)
+				c.doc.apply(t, []*domain.CollaborationOperation{op})
+				if c.awaiting {
+					c.buffer = append(c.buffer, op)
+				} else {
+					send(id, c, []*domain.CollaborationOperation{op})
+				}
+			case 1:
+				if len(queue) > 0 {
+					serverStep()
+				}
+			case 2:
+				if len(c.inbox) > 0 {
+					deliver(id, c)
+				}
+			}
+		}
+
+		// 排空所有消息
+		for {
+			progressed := false
+			if len(queue) > 0 {
+				serverStep()
+				progressed = true
+			}
+			for id, c := range clients {
+				if len(c.inbox) > 0 {
+					deliver(id, c)
+					progressed = true
+				}
+			}
+			if !progressed {
+				break
+			}
+		}
+
+		for _, c := range clients {
+			assert.Equal(t, len(history), c.revision)
+			c.doc.assertEqual(t, server)
+		}
+	}
+}
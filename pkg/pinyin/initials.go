@@ -0,0 +1,87 @@
+// Package pinyin 提供汉字拼音首字母的转换，用于标题的拼音首字母匹配
+package pinyin
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// gb2312Boundaries GB2312 一级汉字按拼音排序，每个声母对应的第一个汉字的区位码
+// 二级汉字按部首排序，无法由区位码得到拼音
+var gb2312Boundaries = []struct {
+	code    int
+	initial byte
+}{
+	{0xB0A1, 'a'}, {0xB0C5, 'b'}, {0xB2C1, 'c'}, {0xB4EE, 'd'}, {0xB6EA, 'e'},
+	{0xB7A2, 'f'}, {0xB8C1, 'g'}, {0xB9FE, 'h'}, {0xBBF7, 'j'}, {0xBFA6, 'k'},
+	{0xC0AC, 'l'}, {0xC2E8, 'm'}, {0xC4C3, 'n'}, {0xC5B6, 'o'}, {0xC5BE, 'p'},
+	{0xC6DA, 'q'}, {0xC8BB, 'r'}, {0xC8F6, 's'}, {0xCBFA, 't'}, {0xCDDA, 'w'},
+	{0xCEF4, 'x'}, {0xD1B9, 'y'}, {0xD4D1, 'z'},
+}
+
+// gb2312Level1End GB2312 一级汉字的最后一个区位码
+const gb2312Level1End = 0xD7F9
+
+// Initial 返回汉字拼音的首字母，非 GB2312 一级汉字返回 0
+func Initial(r rune) byte {
+	if !unicode.Is(unicode.Han, r) {
+		return 0
+	}
+	encoded, err := simplifiedchinese.GB18030.NewEncoder().Bytes([]byte(string(r)))
+	if err != nil || len(encoded) != 2 {
+		return 0
+	}
+	code := int(encoded[0])<<8 | int(encoded[1])
+	if code < gb2312Boundaries[0].code || code > gb2312Level1End {
+		return 0
+	}
+
+	initial := byte(0)
+	for _, boundary := range gb2312Boundaries {
+		if code < boundary.code {
+			break
+		}
+		initial = boundary.initial
+	}
+	return initial
+}
+
+// Initials 将文本转换为拼音首字母，字母和数字转为小写保留，其他字符被忽略
+// 例如 "项目计划 v2" 转换为 "xmjhv2"，没有可转换的字符时返回空字符串
+func Initials(text string) string {
+	initials, _ := InitialsWithPositions(text)
+	return initials
+}
+
+// InitialsWithPositions 将文本转换为拼音首字母，并返回每个首字母对应的字符在文本中的位置
+// 位置按 Unicode 字符计，用于将首字母的匹配映射回原文高亮
+func InitialsWithPositions(text string) (string, []int) {
+	var b strings.Builder
+	var positions []int
+	for i, r := range []rune(text) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			initial := Initial(r)
+			if initial == 0 {
+				continue
+			}
+			b.WriteByte(initial)
+		}
+		positions = append(positions, i)
+	}
+	return b.String(), positions
+}
+
+// HasHan 文本中是否包含汉字
+func HasHan(text string) bool {
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package pinyin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitial(t *testing.T) {
+	assert.Equal(t, byte('a'), Initial('啊'))
+	assert.Equal(t, byte('x'), Initial('项'))
+	assert.Equal(t, byte('m'), Initial('目'))
+	assert.Equal(t, byte('z'), Initial('座'))
+	assert.Equal(t, byte(0), Initial('a'))
+	assert.Equal(t, byte(0), Initial('亍')) // 二级汉字
+}
+
+func TestInitials(t *testing.T) {
+	assert.Equal(t, "xmjhv2", Initials("项目计划 V2"))
+	assert.Equal(t, "hyjy", Initials("会议纪要"))
+	assert.Equal(t, "", Initials("，。"))
+	assert.True(t, HasHan("Go 协作"))
+	assert.False(t, HasHan("Go editor"))
+}
@@ -0,0 +1,132 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"DOC/domain"
+)
+
+// Terms 将搜索关键词按空白拆分为检索词，转为小写并去重，保持原有顺序
+func Terms(keyword string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Fields(keyword) {
+		term := strings.ToLower(field)
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// Highlights 查找文本中所有检索词出现的位置，忽略大小写
+// 偏移和长度按 Unicode 字符计，重叠或相邻的匹配合并为一个
+func Highlights(text string, terms []string) []domain.SearchHighlight {
+	return highlights(lowerRunes(text), terms)
+}
+
+// Snippets 从文本中截取包含检索词的摘要，最多 maxSnippets 段，每段不超过 width 个字符
+// 匹配前保留约四分之一宽度的上下文，换行替换为空格；没有匹配时返回 nil
+func Snippets(text string, terms []string, width, maxSnippets int) []domain.SearchSnippet {
+	if width <= 0 || maxSnippets <= 0 {
+		return nil
+	}
+
+	runes := []rune(text)
+	matches := highlights(lowerRunes(text), terms)
+
+	var snippets []domain.SearchSnippet
+	for i := 0; i < len(matches) && len(snippets) < maxSnippets; {
+		start := matches[i].Offset - width/4
+		if start < 0 {
+			start = 0
+		}
+		end := start + width
+		if end > len(runes) {
+			end = len(runes)
+		}
+
+		snippet := domain.SearchSnippet{
+			Text:       strings.Map(replaceNewline, string(runes[start:end])),
+			Start:      start,
+			Highlights: []domain.SearchHighlight{},
+		}
+		for ; i < len(matches) && matches[i].Offset < end; i++ {
+			length := matches[i].Length
+			if matches[i].Offset+length > end {
+				length = end - matches[i].Offset
+			}
+			snippet.Highlights = append(snippet.Highlights, domain.SearchHighlight{
+				Offset: matches[i].Offset - start,
+				Length: length,
+			})
+		}
+		snippets = append(snippets, snippet)
+	}
+	return snippets
+}
+
+// highlights 在已转为小写的文本中查找检索词出现的位置
+func highlights(text []rune, terms []string) []domain.SearchHighlight {
+	// 标记每个字符是否被任一检索词覆盖
+	covered := make([]bool, len(text))
+	found := false
+	for _, term := range terms {
+		pattern := lowerRunes(term)
+		if len(pattern) == 0 {
+			continue
+		}
+		for i := 0; i+len(pattern) <= len(text); i++ {
+			if runesEqual(text[i:i+len(pattern)], pattern) {
+				for j := i; j < i+len(pattern); j++ {
+					covered[j] = true
+				}
+				found = true
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	var result []domain.SearchHighlight
+	for i := 0; i < len(covered); i++ {
+		if !covered[i] {
+			continue
+		}
+		start := i
+		for i < len(covered) && covered[i] {
+			i++
+		}
+		result = append(result, domain.SearchHighlight{Offset: start, Length: i - start})
+	}
+	return result
+}
+
+// lowerRunes 逐字符转为小写，保持字符数不变以便偏移与原文一致
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func replaceNewline(r rune) rune {
+	if r == '\n' || r == '\r' {
+		return ' '
+	}
+	return r
+}
@@ -0,0 +1,56 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"DOC/domain"
+)
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"协作", "go"}, Terms("  协作 Go\tgo "))
+	assert.Nil(t, Terms("   "))
+}
+
+func TestHighlightsMergesOverlaps(t *testing.T) {
+	highlights := Highlights("实时协作编辑器 Collaboration", []string{"协作", "作编", "collab"})
+
+	assert.Equal(t, []domain.SearchHighlight{
+		{Offset: 2, Length: 3},
+		{Offset: 8, Length: 6},
+	}, highlights)
+}
+
+func TestHighlightsNoMatch(t *testing.T) {
+	assert.Nil(t, Highlights("文档标题", []string{"协作"}))
+}
+
+func TestSnippets(t *testing.T) {
+	text := "第一段没有关键词\n第二段提到了协作编辑\n第三段再次提到协作"
+
+	snippets := Snippets(text, []string{"协作"}, 8, 2)
+
+	if assert.Len(t, snippets, 2) {
+		assert.Equal(t, domain.SearchSnippet{
+			Text:       "到了协作编辑 第",
+			Start:      13,
+			Highlights: []domain.SearchHighlight{{Offset: 2, Length: 2}},
+		}, snippets[0])
+		assert.Equal(t, domain.SearchSnippet{
+			Text:       "提到协作",
+			Start:      25,
+			Highlights: []domain.SearchHighlight{{Offset: 2, Length: 2}},
+		}, snippets[1])
+	}
+}
+
+func TestSnippetsTruncatesMatchAtEnd(t *testing.T) {
+	snippets := Snippets("协作协作协作", []string{"协作"}, 3, 1)
+
+	assert.Equal(t, []domain.SearchSnippet{{
+		Text:       "协作协",
+		Start:      0,
+		Highlights: []domain.SearchHighlight{{Offset: 0, Length: 3}},
+	}}, snippets)
+}
@@ -0,0 +1,442 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"DOC/domain"
+)
+
+const (
+	titleBoost       = 3.0   // 标题匹配相关度的权重
+	bm25K1           = 1.2   // BM25 词频饱和参数
+	bm25B            = 0.75  // BM25 长度归一化参数
+	compactThreshold = 10000 // 日志条目数超过该值时压缩为新的快照
+)
+
+// index 嵌入式倒排索引，实现 domain.SearchIndex
+// 索引常驻内存，变更先追加写入磁盘上的日志再生效，打开时从快照和日志恢复，日志过长时压缩为新的快照
+type index struct {
+	mu       sync.RWMutex
+	store    *store
+	docs     map[int64]*indexedDocument
+	postings map[string]map[int64]*posting // 索引词 -> 文档ID -> 出现次数
+
+	titleLength int64 // 所有文档标题的索引词总数，用于计算平均长度
+	bodyLength  int64 // 所有文档正文的索引词总数
+}
+
+// indexedDocument 索引中的文档及其索引词统计
+type indexedDocument struct {
+	document    *domain.SearchDocument
+	titleLength int
+	bodyLength  int
+	terms       []string // 文档包含的索引词，删除时用于清理倒排表
+}
+
+// posting 索引词在文档标题和正文中的出现次数
+type posting struct {
+	title int
+	body  int
+}
+
+// NewIndex 打开 dir 目录下的索引，目录不存在时创建空索引
+func NewIndex(dir string) (domain.SearchIndex, error) {
+	s, err := openStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &index{
+		store:    s,
+		docs:     make(map[int64]*indexedDocument),
+		postings: make(map[string]map[int64]*posting),
+	}
+	if err := s.load(idx.apply); err != nil {
+		return nil, err
+	}
+
+	// 打开时压缩一次，之后的日志由新的编码器写入
+	if err := s.compact(idx.documents()); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Index 写入文档，已存在时整体替换
+func (idx *index) Index(ctx context.Context, document *domain.SearchDocument) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	entry := &logEntry{Document: document}
+	if err := idx.store.append(entry); err != nil {
+		return err
+	}
+	idx.apply(entry)
+	return idx.compactIfNeeded()
+}
+
+// Delete 从索引中删除文档，不存在的文档被忽略
+func (idx *index) Delete(ctx context.Context, documentIDs ...int64) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var ids []int64
+	for _, id := range documentIDs {
+		if _, ok := idx.docs[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	entry := &logEntry{DeleteIDs: ids}
+	if err := idx.store.append(entry); err != nil {
+		return err
+	}
+	idx.apply(entry)
+	return idx.compactIfNeeded()
+}
+
+// Search 按相关度查询用户可以查看的文档
+// 关键词中的所有索引词都需要出现在标题或正文中，相关度按 BM25 计算，标题匹配加权；
+// 关键词为空时返回所有满足过滤条件的文档，相关度为 0
+func (idx *index) Search(ctx context.Context, query *domain.SearchQuery) (*domain.SearchIndexResult, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	result := &domain.SearchIndexResult{Hits: []*domain.SearchIndexHit{}, Facets: domain.NewSearchFacets()}
+	tokens := QueryTokens(query.Keyword)
+	if len(tokens) == 0 && strings.TrimSpace(query.Keyword) != "" {
+		return result, nil
+	}
+
+	// 1. 查出每个索引词的倒排表，任一索引词没有命中时直接返回
+	postingLists := make([][]map[int64]*posting, len(tokens))
+	for i, token := range tokens {
+		postingLists[i] = idx.lookup(token)
+		if len(postingLists[i]) == 0 {
+			return result, nil
+		}
+	}
+
+	// 2. 以第一个索引词的命中文档为候选，没有关键词时以所有文档为候选，过滤后计算相关度
+	candidates := idx.candidates(postingLists)
+	var hits []*domain.SearchIndexHit
+	for id := range candidates {
+		indexed := idx.docs[id]
+		if !idx.matchesQuery(indexed.document, query) {
+			continue
+		}
+
+		score, matched := 0.0, true
+		for _, lists := range postingLists {
+			termScore, ok := idx.score(indexed, lists)
+			if !ok {
+				matched = false
+				break
+			}
+			score += termScore
+		}
+		if !matched {
+			continue
+		}
+
+		hits = append(hits, &domain.SearchIndexHit{
+			ID:        id,
+			Score:     score,
+			PlainText: indexed.document.PlainText,
+		})
+		if !query.SkipFacets {
+			result.Facets.Add(indexed.document)
+		}
+	}
+
+	// 3. 按相关度排序，相关度相同时最近更新的在前
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		a, b := idx.docs[hits[i].ID].document, idx.docs[hits[j].ID].document
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+		return a.ID > b.ID
+	})
+
+	// 4. 分页
+	result.Total = int64(len(hits))
+	if query.Offset < len(hits) {
+		end := len(hits)
+		if query.Limit > 0 && query.Offset+query.Limit < end {
+			end = query.Offset + query.Limit
+		}
+		result.Hits = hits[query.Offset:end]
+	}
+	return result, nil
+}
+
+// Reset 清空索引
+func (idx *index) Reset(ctx context.Context) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.docs = make(map[int64]*indexedDocument)
+	idx.postings = make(map[string]map[int64]*posting)
+	idx.titleLength = 0
+	idx.bodyLength = 0
+	return idx.store.compact(nil)
+}
+
+// Close 将当前所有文档写入快照并关闭日志
+func (idx *index) Close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if err := idx.store.compact(idx.documents()); err != nil {
+		idx.store.close()
+		return err
+	}
+	return idx.store.close()
+}
+
+// apply 将日志条目应用到内存中的索引
+func (idx *index) apply(entry *logEntry) {
+	for _, id := range entry.DeleteIDs {
+		idx.remove(id)
+	}
+	if entry.Document == nil {
+		return
+	}
+
+	document := entry.Document
+	idx.remove(document.ID)
+
+	titleTokens := Tokenize(document.Title)
+	bodyTokens := Tokenize(document.PlainText)
+	indexed := &indexedDocument{
+		document:    document,
+		titleLength: len(titleTokens),
+		bodyLength:  len(bodyTokens),
+	}
+
+	counts := make(map[string]*posting)
+	for _, token := range titleTokens {
+		if counts[token] == nil {
+			counts[token] = &posting{}
+		}
+		counts[token].title++
+	}
+	for _, token := range bodyTokens {
+		if counts[token] == nil {
+			counts[token] = &posting{}
+		}
+		counts[token].body++
+	}
+	for token, p := range counts {
+		if idx.postings[token] == nil {
+			idx.postings[token] = make(map[int64]*posting)
+		}
+		idx.postings[token][document.ID] = p
+		indexed.terms = append(indexed.terms, token)
+	}
+
+	idx.docs[document.ID] = indexed
+	idx.titleLength += int64(indexed.titleLength)
+	idx.bodyLength += int64(indexed.bodyLength)
+}
+
+// remove 从内存中的索引删除文档
+func (idx *index) remove(id int64) {
+	indexed, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range indexed.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.docs, id)
+	idx.titleLength -= int64(indexed.titleLength)
+	idx.bodyLength -= int64(indexed.bodyLength)
+}
+
+// lookup 查找索引词的倒排表
+// 单个中日韩文字在文本中按两字切分，不会单独成词，因此匹配所有包含该字的索引词
+func (idx *index) lookup(token string) []map[int64]*posting {
+	if utf8.RuneCountInString(token) == 1 {
+		if r, _ := utf8.DecodeRuneInString(token); isCJK(r) {
+			var lists []map[int64]*posting
+			for term, list := range idx.postings {
+				if strings.Contains(term, token) {
+					lists = append(lists, list)
+				}
+			}
+			return lists
+		}
+	}
+
+	if list, ok := idx.postings[token]; ok {
+		return []map[int64]*posting{list}
+	}
+	return nil
+}
+
+// score 计算文档对一个查询索引词的 BM25 相关度，文档不包含该索引词时返回 false
+func (idx *index) score(indexed *indexedDocument, lists []map[int64]*posting) (float64, bool) {
+	total := float64(len(idx.docs))
+	avgTitle := float64(idx.titleLength) / total
+	avgBody := float64(idx.bodyLength) / total
+
+	score, matched := 0.0, false
+	for _, list := range lists {
+		p, ok := list[indexed.document.ID]
+		if !ok {
+			continue
+		}
+		matched = true
+
+		idf := math.Log(1 + (total-float64(len(list))+0.5)/(float64(len(list))+0.5))
+		score += titleBoost * idf * bm25(p.title, indexed.titleLength, avgTitle)
+		score += idf * bm25(p.body, indexed.bodyLength, avgBody)
+	}
+	return score, matched
+}
+
+// documents 返回索引中的所有文档，按ID排序
+func (idx *index) documents() []*domain.SearchDocument {
+	documents := make([]*domain.SearchDocument, 0, len(idx.docs))
+	for _, indexed := range idx.docs {
+		documents = append(documents, indexed.document)
+	}
+	sort.Slice(documents, func(i, j int) bool { return documents[i].ID < documents[j].ID })
+	return documents
+}
+
+// compactIfNeeded 日志条目过多时压缩为新的快照
+func (idx *index) compactIfNeeded() error {
+	if idx.store.entries < compactThreshold {
+		return nil
+	}
+	return idx.store.compact(idx.documents())
+}
+
+// bm25 计算一个字段的 BM25 词频分量
+func bm25(frequency, length int, avgLength float64) float64 {
+	if frequency == 0 {
+		return 0
+	}
+	if avgLength == 0 {
+		avgLength = 1
+	}
+	tf := float64(frequency)
+	return tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(length)/avgLength))
+}
+
+// candidates 搜索的候选文档，有关键词时为第一个索引词的命中文档，否则为所有文档
+func (idx *index) candidates(postingLists [][]map[int64]*posting) map[int64]bool {
+	if len(postingLists) > 0 {
+		return union(postingLists[0])
+	}
+	ids := make(map[int64]bool, len(idx.docs))
+	for id := range idx.docs {
+		ids[id] = true
+	}
+	return ids
+}
+
+// matchesQuery 文档是否可见并满足查询中的过滤条件
+func (idx *index) matchesQuery(document *domain.SearchDocument, query *domain.SearchQuery) bool {
+	switch document.Status {
+	case domain.DocumentStatusActive:
+	case domain.DocumentStatusArchived:
+		if !query.IncludeArchived {
+			return false
+		}
+	default:
+		return false
+	}
+	if query.Type != nil && document.Type != *query.Type {
+		return false
+	}
+	if query.OwnerID != nil && document.OwnerID != *query.OwnerID {
+		return false
+	}
+	if query.SpaceID != nil && !document.InSpace(*query.SpaceID) {
+		return false
+	}
+	if query.StarredOnly && !document.IsStarred {
+		return false
+	}
+	if !inTimeRange(document.UpdatedAt, query.UpdatedAfter, query.UpdatedBefore) ||
+		!inTimeRange(document.CreatedAt, query.CreatedAfter, query.CreatedBefore) {
+		return false
+	}
+	if query.DocumentIDs != nil && !containsID(query.DocumentIDs, document.ID) {
+		return false
+	}
+	if query.AncestorID != nil && !idx.isDescendant(document, *query.AncestorID) {
+		return false
+	}
+	if query.SharedWithMe {
+		return document.IsSharedWith(query.UserID, query.Now)
+	}
+	return document.CanRead(query.UserID, query.SpaceIDs, query.Now)
+}
+
+// isDescendant 文档是否是 ancestorID 的子孙文档，沿索引中的父文档向上查找
+// 父文档不在索引中时停止，查找步数不超过索引中的文档数，避免父子关系成环时死循环
+func (idx *index) isDescendant(document *domain.SearchDocument, ancestorID int64) bool {
+	parentID := document.ParentID
+	for steps := 0; parentID != nil && steps < len(idx.docs); steps++ {
+		if *parentID == ancestorID {
+			return true
+		}
+		parent, ok := idx.docs[*parentID]
+		if !ok {
+			return false
+		}
+		parentID = parent.document.ParentID
+	}
+	return false
+}
+
+// inTimeRange 时间是否在范围内，包含边界，为空的边界不限
+func inTimeRange(t time.Time, after, before *time.Time) bool {
+	if after != nil && t.Before(*after) {
+		return false
+	}
+	if before != nil && t.After(*before) {
+		return false
+	}
+	return true
+}
+
+// containsID ID 是否在列表中
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// union 合并多个倒排表中的文档ID
+func union(lists []map[int64]*posting) map[int64]bool {
+	ids := make(map[int64]bool)
+	for _, list := range lists {
+		for id := range list {
+			ids[id] = true
+		}
+	}
+	return ids
+}
@@ -0,0 +1,166 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"DOC/domain"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"实时", "时协", "协作", "go", "editor", "v2"}, Tokenize("实时协作 Go-Editor v2"))
+	assert.Equal(t, []string{"文", "doc"}, Tokenize("文，DOC"))
+	assert.Nil(t, Tokenize("  ，。 "))
+}
+
+func TestIndexSearchRanksTitleMatchesFirst(t *testing.T) {
+	idx, err := NewIndex(t.TempDir())
+	require.NoError(t, err)
+	defer idx.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 1, Title: "周报", PlainText: "本周讨论了协作编辑的实现", OwnerID: 1, UpdatedAt: now}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 2, Title: "协作编辑设计", PlainText: "设计文档", OwnerID: 1, UpdatedAt: now.Add(-time.Hour)}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 3, Title: "会议纪要", PlainText: "没有相关内容", OwnerID: 1, UpdatedAt: now}))
+
+	result, err := idx.Search(ctx, &domain.SearchQuery{UserID: 1, Keyword: "协作编辑", Limit: 10})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), result.Total)
+	if assert.Len(t, result.Hits, 2) {
+		assert.Equal(t, int64(2), result.Hits[0].ID)
+		assert.Equal(t, int64(1), result.Hits[1].ID)
+		assert.Greater(t, result.Hits[0].Score, result.Hits[1].Score)
+		assert.Equal(t, "本周讨论了协作编辑的实现", result.Hits[1].PlainText)
+	}
+}
+
+func TestIndexSearchRequiresAllTerms(t *testing.T) {
+	idx, err := NewIndex(t.TempDir())
+	require.NoError(t, err)
+	defer idx.Close()
+
+	ctx := context.Background()
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 1, Title: "Go 协作", OwnerID: 1}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 2, Title: "Go 服务", OwnerID: 1}))
+
+	result, err := idx.Search(ctx, &domain.SearchQuery{UserID: 1, Keyword: "go 协作"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, hitIDs(result))
+
+	// 单个汉字匹配所有包含该字的词
+	result, err = idx.Search(ctx, &domain.SearchQuery{UserID: 1, Keyword: "服"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, hitIDs(result))
+}
+
+func TestIndexSearchFilters(t *testing.T) {
+	idx, err := NewIndex(t.TempDir())
+	require.NoError(t, err)
+	defer idx.Close()
+
+	ctx := context.Background()
+	folder := domain.DocumentTypeFolder
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 1, Title: "项目计划", Type: domain.DocumentTypeFile, OwnerID: 1, ReaderIDs: []int64{2}}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 2, Title: "项目资料", Type: folder, OwnerID: 1}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 3, Title: "项目归档", Type: domain.DocumentTypeFile, Status: domain.DocumentStatusArchived, OwnerID: 1}))
+
+	result, err := idx.Search(ctx, &domain.SearchQuery{UserID: 2, Keyword: "项目"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, hitIDs(result))
+
+	result, err = idx.Search(ctx, &domain.SearchQuery{UserID: 1, Keyword: "项目", Type: &folder})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, hitIDs(result))
+
+	result, err = idx.Search(ctx, &domain.SearchQuery{UserID: 1, Keyword: "项目", IncludeArchived: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2, 3}, hitIDs(result))
+}
+
+func TestIndexSearchVisibilityAndFacets(t *testing.T) {
+	idx, err := NewIndex(t.TempDir())
+	require.NoError(t, err)
+	defer idx.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 1, Title: "项目", Type: domain.DocumentTypeFile, OwnerID: 2, ReaderIDs: []int64{1}}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 2, Title: "项目", Type: domain.DocumentTypeFile, OwnerID: 2, ShareReaders: []domain.SearchReader{{UserID: 1}}}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 3, Title: "项目", Type: domain.DocumentTypeFile, OwnerID: 2, ShareReaders: []domain.SearchReader{{UserID: 1, ExpiresAt: &expired}}}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 4, Title: "项目", Type: domain.DocumentTypeFolder, OwnerID: 3, SpaceIDs: []int64{10, 20}}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 5, Title: "项目", Type: domain.DocumentTypeFile, OwnerID: 1, IsStarred: true, ParentID: int64Ptr(4), SpaceIDs: []int64{10}}))
+
+	query := &domain.SearchQuery{UserID: 1, SpaceIDs: []int64{10}, Keyword: "项目", Now: now}
+	result, err := idx.Search(ctx, query)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2, 4, 5}, hitIDs(result))
+	assert.Equal(t, map[int64]int64{10: 2, 20: 1}, result.Facets.Spaces)
+	assert.Equal(t, map[int64]int64{1: 1, 2: 2, 3: 1}, result.Facets.Owners)
+	assert.Equal(t, map[domain.DocumentType]int64{domain.DocumentTypeFile: 3, domain.DocumentTypeFolder: 1}, result.Facets.Types)
+
+	shared := *query
+	shared.SharedWithMe = true
+	result, err = idx.Search(ctx, &shared)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int64{1, 2}, hitIDs(result))
+
+	// 没有关键词时按过滤条件列出
+	filtered := domain.SearchQuery{UserID: 1, SpaceIDs: []int64{10}, AncestorID: int64Ptr(4), StarredOnly: true, Now: now}
+	result, err = idx.Search(ctx, &filtered)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{5}, hitIDs(result))
+
+	filtered = domain.SearchQuery{UserID: 1, SpaceIDs: []int64{10}, OwnerID: int64Ptr(2), DocumentIDs: []int64{2, 4}, Now: now}
+	result, err = idx.Search(ctx, &filtered)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, hitIDs(result))
+}
+
+func TestIndexPersistsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	idx, err := NewIndex(dir)
+	require.NoError(t, err)
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 1, Title: "旧标题", OwnerID: 1}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 1, Title: "新标题", OwnerID: 1}))
+	require.NoError(t, idx.Index(ctx, &domain.SearchDocument{ID: 2, Title: "新标题", OwnerID: 1}))
+	require.NoError(t, idx.Delete(ctx, 2))
+
+	// 未关闭时重新打开，从日志恢复
+	reopened, err := NewIndex(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	result, err := reopened.Search(ctx, &domain.SearchQuery{UserID: 1, Keyword: "标题"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1}, hitIDs(result))
+
+	result, err = reopened.Search(ctx, &domain.SearchQuery{UserID: 1, Keyword: "旧"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+
+	require.NoError(t, reopened.Reset(ctx))
+	result, err = reopened.Search(ctx, &domain.SearchQuery{UserID: 1, Keyword: "标题"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Hits)
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func hitIDs(result *domain.SearchIndexResult) []int64 {
+	var ids []int64
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
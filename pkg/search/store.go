@@ -0,0 +1,156 @@
+package search
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"DOC/domain"
+)
+
+const (
+	snapshotFileName = "index.snapshot" // 快照文件，依次保存所有文档
+	logFileName      = "index.log"      // 日志文件，保存快照之后的变更
+)
+
+// logEntry 日志条目，写入文档或删除文档
+type logEntry struct {
+	Document  *domain.SearchDocument // 写入的文档，删除时为空
+	DeleteIDs []int64                // 删除的文档ID
+}
+
+// store 索引的磁盘存储
+// 由快照和追加写入的日志组成，压缩时将当前所有文档写入新的快照并清空日志
+type store struct {
+	dir     string
+	log     *os.File
+	encoder *gob.Encoder
+	entries int // 日志中的条目数
+}
+
+// openStore 打开索引目录，目录不存在时创建
+func openStore(dir string) (*store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create index dir: %w", err)
+	}
+	return &store{dir: dir}, nil
+}
+
+// load 依次读取快照中的文档和日志中的条目
+// 日志末尾不完整的条目来自写入中途退出，读到时停止并忽略其后的内容
+func (s *store) load(apply func(entry *logEntry)) error {
+	snapshot, err := os.Open(filepath.Join(s.dir, snapshotFileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		defer snapshot.Close()
+		decoder := gob.NewDecoder(snapshot)
+		for {
+			var document domain.SearchDocument
+			if err := decoder.Decode(&document); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return fmt.Errorf("read index snapshot: %w", err)
+			}
+			apply(&logEntry{Document: &document})
+		}
+	}
+
+	logFile, err := os.Open(filepath.Join(s.dir, logFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	decoder := gob.NewDecoder(logFile)
+	for {
+		var entry logEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("索引日志末尾不完整，忽略其后的内容: %v", err)
+			}
+			return nil
+		}
+		apply(&entry)
+	}
+}
+
+// append 追加一条日志
+func (s *store) append(entry *logEntry) error {
+	if s.log == nil {
+		return errors.New("index store is closed")
+	}
+	if err := s.encoder.Encode(entry); err != nil {
+		return err
+	}
+	s.entries++
+	return nil
+}
+
+// compact 将所有文档写入新的快照并清空日志
+// 快照先写入临时文件再替换，中途失败时原有的快照和日志保持不变
+func (s *store) compact(documents []*domain.SearchDocument) error {
+	tmpPath := filepath.Join(s.dir, snapshotFileName+".tmp")
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	encoder := gob.NewEncoder(tmp)
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, snapshotFileName)); err != nil {
+		return err
+	}
+
+	return s.truncateLog()
+}
+
+// truncateLog 清空日志并重新开始追加，每个日志文件只有一个编码器，类型信息只写一次
+func (s *store) truncateLog() error {
+	if s.log != nil {
+		s.log.Close()
+	}
+	logFile, err := os.Create(filepath.Join(s.dir, logFileName))
+	if err != nil {
+		s.log = nil
+		return err
+	}
+	s.log = logFile
+	s.encoder = gob.NewEncoder(logFile)
+	s.entries = 0
+	return nil
+}
+
+// close 关闭日志文件
+func (s *store) close() error {
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
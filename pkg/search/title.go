@@ -0,0 +1,152 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"DOC/domain"
+	"DOC/pkg/pinyin"
+)
+
+// 标题匹配方式，按优先级从高到低
+const (
+	MatchPrefix   = "prefix"   // 标题以查询开头
+	MatchContains = "contains" // 标题包含查询
+	MatchPinyin   = "pinyin"   // 标题的拼音首字母以查询开头或包含查询
+	MatchFuzzy    = "fuzzy"    // 标题按顺序包含查询的所有字符
+)
+
+// 各匹配方式的基础分，同一方式内按匹配部分占标题的比例再加至多 10 分
+const (
+	prefixScore       = 100.0
+	wordContainsScore = 80.0 // 在单词开头包含
+	containsScore     = 70.0
+	pinyinPrefixScore = 60.0
+	pinyinScore       = 50.0
+	fuzzyScore        = 40.0 // 模糊匹配的最高分，按匹配字符的连续程度折算
+)
+
+// TitleMatch 标题与快速切换查询的匹配结果
+type TitleMatch struct {
+	Kind       string
+	Score      float64
+	Highlights []domain.SearchHighlight // 标题中的匹配位置
+}
+
+// MatchTitle 按前缀、包含、拼音首字母和模糊匹配的顺序匹配标题，忽略大小写和查询中的空白
+// initials 为标题的拼音首字母，标题不含汉字时为空；查询为空或不匹配时返回 false
+func MatchTitle(title, initials, query string) (*TitleMatch, bool) {
+	pattern := lowerRunes(strings.Join(strings.Fields(query), ""))
+	if len(pattern) == 0 {
+		return nil, false
+	}
+	text := lowerRunes(title)
+	ratio := float64(len(pattern)) / float64(len(text)+1)
+
+	// 1. 前缀和包含，查询保留原有空白以匹配多个单词的标题
+	spaced := lowerRunes(strings.TrimSpace(query))
+	if offset := indexRunes(text, spaced); offset >= 0 {
+		match := &TitleMatch{Kind: MatchContains, Score: containsScore + 10*ratio}
+		switch {
+		case offset == 0:
+			match.Kind, match.Score = MatchPrefix, prefixScore+10*ratio
+		case isWordStart(text, offset):
+			match.Score = wordContainsScore + 10*ratio
+		}
+		match.Highlights = []domain.SearchHighlight{{Offset: offset, Length: len(spaced)}}
+		return match, true
+	}
+
+	// 2. 拼音首字母，只匹配由字母和数字组成的查询
+	if initials != "" && isAlphanumeric(pattern) {
+		if offset := strings.Index(initials, string(pattern)); offset >= 0 {
+			match := &TitleMatch{Kind: MatchPinyin, Score: pinyinScore + 10*ratio}
+			if offset == 0 {
+				match.Score = pinyinPrefixScore + 10*ratio
+			}
+			_, positions := pinyin.InitialsWithPositions(title)
+			if offset+len(pattern) <= len(positions) {
+				match.Highlights = mergePositions(positions[offset : offset+len(pattern)])
+			}
+			return match, true
+		}
+	}
+
+	// 3. 模糊匹配
+	positions, ok := fuzzyPositions(text, pattern)
+	if !ok {
+		return nil, false
+	}
+	span := positions[len(positions)-1] - positions[0] + 1
+	return &TitleMatch{
+		Kind:       MatchFuzzy,
+		Score:      fuzzyScore * float64(len(pattern)) / float64(span),
+		Highlights: mergePositions(positions),
+	}, true
+}
+
+// fuzzyPositions 按顺序在文本中查找模式的每个字符，返回匹配位置
+// 从每个可能的起点贪心匹配，选择跨度最小的一组位置
+func fuzzyPositions(text, pattern []rune) ([]int, bool) {
+	var best []int
+	for start := 0; start < len(text); start++ {
+		if text[start] != pattern[0] {
+			continue
+		}
+		positions := []int{start}
+		for i, j := start+1, 1; i < len(text) && j < len(pattern); i++ {
+			if text[i] == pattern[j] {
+				positions = append(positions, i)
+				j++
+			}
+		}
+		if len(positions) < len(pattern) {
+			// 更靠后的起点剩余字符更少，不可能匹配
+			break
+		}
+		if best == nil || positions[len(positions)-1]-positions[0] < best[len(best)-1]-best[0] {
+			best = positions
+		}
+	}
+	return best, best != nil
+}
+
+// mergePositions 将匹配的字符位置合并为连续的高亮区间
+func mergePositions(positions []int) []domain.SearchHighlight {
+	var result []domain.SearchHighlight
+	for _, position := range positions {
+		if n := len(result); n > 0 && result[n-1].Offset+result[n-1].Length == position {
+			result[n-1].Length++
+			continue
+		}
+		result = append(result, domain.SearchHighlight{Offset: position, Length: 1})
+	}
+	return result
+}
+
+// indexRunes 返回模式在文本中第一次出现的字符位置，不存在时返回 -1
+func indexRunes(text, pattern []rune) int {
+	for i := 0; i+len(pattern) <= len(text); i++ {
+		if runesEqual(text[i:i+len(pattern)], pattern) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isWordStart 位置是否为单词的开头，即前一个字符不是字母或数字，或者是中日韩文字
+func isWordStart(text []rune, offset int) bool {
+	prev := text[offset-1]
+	return !(unicode.IsLetter(prev) || unicode.IsDigit(prev)) || isCJK(prev) || isCJK(text[offset])
+}
+
+// isAlphanumeric 是否只包含 ASCII 字母和数字
+func isAlphanumeric(runes []rune) bool {
+	for _, r := range runes {
+		if r >= utf8.RuneSelf || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
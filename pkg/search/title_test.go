@@ -0,0 +1,45 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"DOC/domain"
+	"DOC/pkg/pinyin"
+)
+
+func TestMatchTitle(t *testing.T) {
+	match, ok := MatchTitle("Design Review", "", "des")
+	require.True(t, ok)
+	assert.Equal(t, MatchPrefix, match.Kind)
+	assert.Equal(t, []domain.SearchHighlight{{Offset: 0, Length: 3}}, match.Highlights)
+
+	match, ok = MatchTitle("Design Review", "", "review")
+	require.True(t, ok)
+	assert.Equal(t, MatchContains, match.Kind)
+	assert.Equal(t, []domain.SearchHighlight{{Offset: 7, Length: 6}}, match.Highlights)
+
+	match, ok = MatchTitle("Design Review", "", "dsrv")
+	require.True(t, ok)
+	assert.Equal(t, MatchFuzzy, match.Kind)
+	assert.Equal(t, []domain.SearchHighlight{{Offset: 0, Length: 1}, {Offset: 2, Length: 1}, {Offset: 7, Length: 1}, {Offset: 9, Length: 1}}, match.Highlights)
+
+	_, ok = MatchTitle("Design Review", "", "xyz")
+	assert.False(t, ok)
+}
+
+func TestMatchTitlePinyin(t *testing.T) {
+	title := "项目 计划"
+	match, ok := MatchTitle(title, pinyin.Initials(title), "xmj")
+	require.True(t, ok)
+	assert.Equal(t, MatchPinyin, match.Kind)
+	assert.Equal(t, []domain.SearchHighlight{{Offset: 0, Length: 2}, {Offset: 3, Length: 1}}, match.Highlights)
+
+	// 前缀匹配优先于拼音匹配
+	prefix, ok := MatchTitle(title, pinyin.Initials(title), "项目")
+	require.True(t, ok)
+	assert.Equal(t, MatchPrefix, prefix.Kind)
+	assert.Greater(t, prefix.Score, match.Score)
+}
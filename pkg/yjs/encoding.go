@@ -0,0 +1,221 @@
+package yjs
+
+import "errors"
+
+// ErrUnexpectedEOF 二进制数据提前结束
+var ErrUnexpectedEOF = errors.New("yjs: unexpected end of data")
+
+// ErrMalformed 二进制数据格式错误
+var ErrMalformed = errors.New("yjs: malformed data")
+
+// lib0 any 编码的类型标记
+const (
+	anyUndefined  = 127
+	anyNull       = 126
+	anyInteger    = 125
+	anyFloat32    = 124
+	anyFloat64    = 123
+	anyBigInt     = 122
+	anyFalse      = 121
+	anyTrue       = 120
+	anyString     = 119
+	anyObject     = 118
+	anyArray      = 117
+	anyUint8Array = 116
+)
+
+// Decoder lib0 二进制解码器
+type Decoder struct {
+	buf []byte
+	pos int
+}
+
+// NewDecoder 创建解码器
+func NewDecoder(buf []byte) *Decoder {
+	return &Decoder{buf: buf}
+}
+
+// HasContent 是否还有未读取的数据
+func (d *Decoder) HasContent() bool {
+	return d.pos < len(d.buf)
+}
+
+// ReadUint8 读取单字节
+func (d *Decoder) ReadUint8() (byte, error) {
+	if d.pos >= len(d.buf) {
+		return 0, ErrUnexpectedEOF
+	}
+	b := d.buf[d.pos]
+	d.pos++
+	return b, nil
+}
+
+// ReadVarUint 读取变长无符号整数
+func (d *Decoder) ReadVarUint() (uint64, error) {
+	var num uint64
+	var shift uint
+	for {
+		b, err := d.ReadUint8()
+		if err != nil {
+			return 0, err
+		}
+		if shift > 63 {
+			return 0, ErrMalformed
+		}
+		num |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return num, nil
+		}
+		shift += 7
+	}
+}
+
+// ReadBytes 读取固定长度的字节
+func (d *Decoder) ReadBytes(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return nil, ErrUnexpectedEOF
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// ReadVarUint8Array 读取带长度前缀的字节数组
+func (d *Decoder) ReadVarUint8Array() ([]byte, error) {
+	n, err := d.ReadVarUint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.buf)) {
+		return nil, ErrUnexpectedEOF
+	}
+	return d.ReadBytes(int(n))
+}
+
+// ReadVarString 读取带长度前缀的 UTF-8 字符串
+func (d *Decoder) ReadVarString() (string, error) {
+	b, err := d.ReadVarUint8Array()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// skipVarInt 跳过变长有符号整数
+func (d *Decoder) skipVarInt() error {
+	for {
+		b, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		if b < 0x80 {
+			return nil
+		}
+	}
+}
+
+// ReadAnyRaw 读取一个 lib0 any 编码的值，返回其原始字节
+func (d *Decoder) ReadAnyRaw() ([]byte, error) {
+	start := d.pos
+	if err := d.skipAny(0); err != nil {
+		return nil, err
+	}
+	return d.buf[start:d.pos], nil
+}
+
+// skipAny 跳过一个 lib0 any 编码的值
+func (d *Decoder) skipAny(depth int) error {
+	if depth > 256 {
+		return ErrMalformed
+	}
+
+	tag, err := d.ReadUint8()
+	if err != nil {
+		return err
+	}
+
+	switch tag {
+	case anyUndefined, anyNull, anyFalse, anyTrue:
+		return nil
+	case anyInteger:
+		return d.skipVarInt()
+	case anyFloat32:
+		_, err = d.ReadBytes(4)
+	case anyFloat64, anyBigInt:
+		_, err = d.ReadBytes(8)
+	case anyString, anyUint8Array:
+		_, err = d.ReadVarUint8Array()
+	case anyObject:
+		n, err := d.ReadVarUint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if _, err := d.ReadVarUint8Array(); err != nil {
+				return err
+			}
+			if err := d.skipAny(depth + 1); err != nil {
+				return err
+			}
+		}
+	case anyArray:
+		n, err := d.ReadVarUint()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := d.skipAny(depth + 1); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrMalformed
+	}
+	return err
+}
+
+// Encoder lib0 二进制编码器
+type Encoder struct {
+	buf []byte
+}
+
+// NewEncoder 创建编码器
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Bytes 返回已编码的数据
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// WriteUint8 写入单字节
+func (e *Encoder) WriteUint8(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+// WriteVarUint 写入变长无符号整数
+func (e *Encoder) WriteVarUint(num uint64) {
+	for num > 0x7f {
+		e.buf = append(e.buf, byte(num&0x7f)|0x80)
+		num >>= 7
+	}
+	e.buf = append(e.buf, byte(num))
+}
+
+// WriteBytes 直接写入字节
+func (e *Encoder) WriteBytes(b []byte) {
+	e.buf = append(e.buf, b...)
+}
+
+// WriteVarUint8Array 写入带长度前缀的字节数组
+func (e *Encoder) WriteVarUint8Array(b []byte) {
+	e.WriteVarUint(uint64(len(b)))
+	e.WriteBytes(b)
+}
+
+// WriteVarString 写入带长度前缀的 UTF-8 字符串
+func (e *Encoder) WriteVarString(s string) {
+	e.WriteVarUint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
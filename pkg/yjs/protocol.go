@@ -0,0 +1,92 @@
+// Package yjs 实现与 Yjs 兼容的 v1 更新编码和 y-protocols 同步消息
+//
+// 服务端不构建完整的 CRDT 文档，而是直接在更新的二进制结构上完成合并、差异计算和状态向量计算，
+// 与 Yjs 的 mergeUpdates / diffUpdate / encodeStateVectorFromUpdate 语义一致。
+package yjs
+
+// y-websocket 消息类型
+const (
+	MessageSync           = 0
+	MessageAwareness      = 1
+	MessageAuth           = 2
+	MessageQueryAwareness = 3
+)
+
+// 同步子消息类型
+const (
+	SyncStep1  = 0
+	SyncStep2  = 1
+	SyncUpdate = 2
+)
+
+// Message 解析后的 y-websocket 消息
+type Message struct {
+	Type     uint64
+	SyncType uint64 // 仅同步消息有效
+	Payload  []byte // 同步消息为状态向量或更新，感知消息为感知更新
+}
+
+// DecodeMessage 解析二进制消息
+func DecodeMessage(data []byte) (*Message, error) {
+	d := NewDecoder(data)
+
+	messageType, err := d.ReadVarUint()
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Type: messageType}
+
+	switch messageType {
+	case MessageSync:
+		if msg.SyncType, err = d.ReadVarUint(); err != nil {
+			return nil, err
+		}
+		if msg.SyncType > SyncUpdate {
+			return nil, ErrMalformed
+		}
+		if msg.Payload, err = d.ReadVarUint8Array(); err != nil {
+			return nil, err
+		}
+	case MessageAwareness:
+		if msg.Payload, err = d.ReadVarUint8Array(); err != nil {
+			return nil, err
+		}
+	case MessageQueryAwareness:
+	default:
+		return nil, ErrMalformed
+	}
+
+	return msg, nil
+}
+
+// EncodeSyncStep1 编码同步第一步：发送本端状态向量
+func EncodeSyncStep1(stateVector []byte) []byte {
+	return encodeSyncMessage(SyncStep1, stateVector)
+}
+
+// EncodeSyncStep2 编码同步第二步：发送对方缺少的更新
+func EncodeSyncStep2(update []byte) []byte {
+	return encodeSyncMessage(SyncStep2, update)
+}
+
+// EncodeSyncUpdate 编码增量更新
+func EncodeSyncUpdate(update []byte) []byte {
+	return encodeSyncMessage(SyncUpdate, update)
+}
+
+// EncodeAwareness 编码感知消息
+func EncodeAwareness(update []byte) []byte {
+	e := NewEncoder()
+	e.WriteVarUint(MessageAwareness)
+	e.WriteVarUint8Array(update)
+	return e.Bytes()
+}
+
+// encodeSyncMessage 编码同步消息
+func encodeSyncMessage(syncType uint64, payload []byte) []byte {
+	e := NewEncoder()
+	e.WriteVarUint(MessageSync)
+	e.WriteVarUint(syncType)
+	e.WriteVarUint8Array(payload)
+	return e.Bytes()
+}
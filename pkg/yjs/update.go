@@ -0,0 +1,707 @@
+package yjs
+
+import (
+	"sort"
+	"unicode/utf16"
+)
+
+// 结构体类型标记
+const (
+	structGC   = 0
+	structSkip = 10
+)
+
+// Item 内容类型引用号
+const (
+	contentDeleted = 1
+	contentJSON    = 2
+	contentBinary  = 3
+	contentString  = 4
+	contentEmbed   = 5
+	contentFormat  = 6
+	contentType    = 7
+	contentAny     = 8
+	contentDoc     = 9
+)
+
+// Item info 字节中的标志位
+const (
+	bitOrigin      = 0x80
+	bitRightOrigin = 0x40
+	bitParentSub   = 0x20
+	bitsContent    = 0x1f
+)
+
+// 需要额外读取名称的类型引用号（YXmlElement / YXmlHook）
+const (
+	typeRefXMLElement = 3
+	typeRefXMLHook    = 5
+)
+
+// ID 结构体标识：客户端ID + 逻辑时钟
+type ID struct {
+	Client uint64
+	Clock  uint64
+}
+
+// blockKind 结构体种类
+type blockKind int
+
+const (
+	kindItem blockKind = iota
+	kindGC
+	kindSkip
+)
+
+// block 更新中的一个结构体（Item / GC / Skip）
+type block struct {
+	kind   blockKind
+	id     ID
+	length uint64
+
+	// Item 元信息
+	contentRef   byte
+	origin       *ID
+	rightOrigin  *ID
+	parentKey    *string
+	parentID     *ID
+	parentSub    *string
+	parentSubBit bool
+
+	// Item 内容：可拆分的内容按单元保存，其余保存原始编码
+	units [][]byte // JSON 为字符串，Any 为原始 any 编码
+	str   []uint16 // String 内容的 UTF-16 编码
+	raw   []byte   // Binary / Embed / Format / Type / Doc 的原始编码
+}
+
+// deleteRange 删除集合中的一段时钟范围
+type deleteRange struct {
+	clock  uint64
+	length uint64
+}
+
+// Update 解析后的 Yjs 更新（v1 编码）
+type Update struct {
+	blocks    map[uint64][]*block
+	deleteSet map[uint64][]deleteRange
+}
+
+// EmptyUpdate 不包含任何内容的更新
+func EmptyUpdate() []byte {
+	return []byte{0, 0}
+}
+
+// DecodeUpdate 解析 v1 编码的更新
+func DecodeUpdate(data []byte) (*Update, error) {
+	d := NewDecoder(data)
+	u := &Update{
+		blocks:    map[uint64][]*block{},
+		deleteSet: map[uint64][]deleteRange{},
+	}
+
+	numClients, err := d.ReadVarUint()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < numClients; i++ {
+		numBlocks, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		if numBlocks > uint64(len(data)) {
+			return nil, ErrMalformed
+		}
+		client, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		clock, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+
+		for j := uint64(0); j < numBlocks; j++ {
+			b, err := readBlock(d, ID{Client: client, Clock: clock})
+			if err != nil {
+				return nil, err
+			}
+			u.blocks[client] = append(u.blocks[client], b)
+			clock += b.length
+		}
+	}
+
+	if err := readDeleteSet(d, u.deleteSet); err != nil {
+		return nil, err
+	}
+	if d.HasContent() {
+		return nil, ErrMalformed
+	}
+
+	for client, blocks := range u.blocks {
+		u.blocks[client] = mergeBlocks(blocks)
+	}
+	return u, nil
+}
+
+// Encode 将更新编码为 v1 格式
+func (u *Update) Encode() []byte {
+	e := NewEncoder()
+
+	// 与 Yjs 一致，客户端按ID降序写入
+	clients := make([]uint64, 0, len(u.blocks))
+	for client, blocks := range u.blocks {
+		if len(blocks) > 0 {
+			clients = append(clients, client)
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] > clients[j] })
+
+	e.WriteVarUint(uint64(len(clients)))
+	for _, client := range clients {
+		blocks := u.blocks[client]
+		e.WriteVarUint(uint64(len(blocks)))
+		e.WriteVarUint(client)
+		e.WriteVarUint(blocks[0].id.Clock)
+		for _, b := range blocks {
+			b.write(e)
+		}
+	}
+
+	writeDeleteSet(e, u.deleteSet)
+	return e.Bytes()
+}
+
+// IsEmpty 更新是否既不包含结构体也不包含删除
+func (u *Update) IsEmpty() bool {
+	return len(u.blocks) == 0 && len(u.deleteSet) == 0
+}
+
+// StateVector 计算更新中每个客户端从 0 开始连续覆盖到的时钟
+func (u *Update) StateVector() map[uint64]uint64 {
+	sv := map[uint64]uint64{}
+	for client, blocks := range u.blocks {
+		var clock uint64
+		for _, b := range blocks {
+			if b.kind == kindSkip || b.id.Clock != clock {
+				break
+			}
+			clock = b.id.Clock + b.length
+		}
+		if clock > 0 {
+			sv[client] = clock
+		}
+	}
+	return sv
+}
+
+// MergeUpdates 合并多个更新为一个更新，重复的结构体只保留一份
+func MergeUpdates(updates ...[]byte) ([]byte, error) {
+	merged := &Update{
+		blocks:    map[uint64][]*block{},
+		deleteSet: map[uint64][]deleteRange{},
+	}
+
+	for _, data := range updates {
+		u, err := DecodeUpdate(data)
+		if err != nil {
+			return nil, err
+		}
+		for client, blocks := range u.blocks {
+			merged.blocks[client] = append(merged.blocks[client], blocks...)
+		}
+		for client, ranges := range u.deleteSet {
+			merged.deleteSet[client] = append(merged.deleteSet[client], ranges...)
+		}
+	}
+
+	for client, blocks := range merged.blocks {
+		merged.blocks[client] = mergeBlocks(blocks)
+	}
+	for client, ranges := range merged.deleteSet {
+		merged.deleteSet[client] = mergeDeleteRanges(ranges)
+	}
+
+	return merged.Encode(), nil
+}
+
+// DiffUpdate 计算更新中对方状态向量尚未包含的部分
+// 删除集合总是完整返回
+func DiffUpdate(update, stateVector []byte) ([]byte, error) {
+	u, err := DecodeUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	sv, err := DecodeStateVector(stateVector)
+	if err != nil {
+		return nil, err
+	}
+
+	for client, blocks := range u.blocks {
+		from := sv[client]
+		var kept []*block
+		for _, b := range blocks {
+			if b.id.Clock+b.length <= from {
+				continue
+			}
+			// 第一个写入的结构体不能是 Skip
+			if len(kept) == 0 && b.kind == kindSkip {
+				continue
+			}
+			if b.id.Clock < from {
+				b = b.sliceFrom(from - b.id.Clock)
+			}
+			kept = append(kept, b)
+		}
+		u.blocks[client] = kept
+	}
+
+	return u.Encode(), nil
+}
+
+// EncodeStateVectorFromUpdate 从更新计算编码后的状态向量
+func EncodeStateVectorFromUpdate(update []byte) ([]byte, error) {
+	u, err := DecodeUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeStateVector(u.StateVector()), nil
+}
+
+// EncodeStateVector 编码状态向量
+func EncodeStateVector(sv map[uint64]uint64) []byte {
+	clients := make([]uint64, 0, len(sv))
+	for client := range sv {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] > clients[j] })
+
+	e := NewEncoder()
+	e.WriteVarUint(uint64(len(clients)))
+	for _, client := range clients {
+		e.WriteVarUint(client)
+		e.WriteVarUint(sv[client])
+	}
+	return e.Bytes()
+}
+
+// DecodeStateVector 解码状态向量
+func DecodeStateVector(data []byte) (map[uint64]uint64, error) {
+	d := NewDecoder(data)
+	sv := map[uint64]uint64{}
+
+	n, err := d.ReadVarUint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(data)) {
+		return nil, ErrMalformed
+	}
+	for i := uint64(0); i < n; i++ {
+		client, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		clock, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		sv[client] = clock
+	}
+	return sv, nil
+}
+
+// readBlock 读取一个结构体
+func readBlock(d *Decoder, id ID) (*block, error) {
+	info, err := d.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case info&bitsContent == structGC:
+		length, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		return newBlock(kindGC, id, length)
+	case info == structSkip:
+		length, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		return newBlock(kindSkip, id, length)
+	}
+
+	b := &block{
+		kind:         kindItem,
+		id:           id,
+		contentRef:   info & bitsContent,
+		parentSubBit: info&bitParentSub != 0,
+	}
+
+	if info&bitOrigin != 0 {
+		origin, err := readID(d)
+		if err != nil {
+			return nil, err
+		}
+		b.origin = origin
+	}
+	if info&bitRightOrigin != 0 {
+		rightOrigin, err := readID(d)
+		if err != nil {
+			return nil, err
+		}
+		b.rightOrigin = rightOrigin
+	}
+
+	// 没有左右原点时需要显式记录父节点
+	if b.origin == nil && b.rightOrigin == nil {
+		isKey, err := d.ReadVarUint()
+		if err != nil {
+			return nil, err
+		}
+		if isKey == 1 {
+			key, err := d.ReadVarString()
+			if err != nil {
+				return nil, err
+			}
+			b.parentKey = &key
+		} else {
+			if b.parentID, err = readID(d); err != nil {
+				return nil, err
+			}
+		}
+		if b.parentSubBit {
+			sub, err := d.ReadVarString()
+			if err != nil {
+				return nil, err
+			}
+			b.parentSub = &sub
+		}
+	}
+
+	if err := b.readContent(d); err != nil {
+		return nil, err
+	}
+	if b.length == 0 {
+		return nil, ErrMalformed
+	}
+	return b, nil
+}
+
+// newBlock 创建 GC 或 Skip 结构体
+func newBlock(kind blockKind, id ID, length uint64) (*block, error) {
+	if length == 0 {
+		return nil, ErrMalformed
+	}
+	return &block{kind: kind, id: id, length: length}, nil
+}
+
+// readContent 读取 Item 内容并计算长度
+func (b *block) readContent(d *Decoder) error {
+	start := d.pos
+
+	switch b.contentRef {
+	case contentDeleted:
+		length, err := d.ReadVarUint()
+		if err != nil {
+			return err
+		}
+		b.length = length
+		return nil
+
+	case contentJSON, contentAny:
+		n, err := d.ReadVarUint()
+		if err != nil {
+			return err
+		}
+		if n > uint64(len(d.buf)) {
+			return ErrMalformed
+		}
+		for i := uint64(0); i < n; i++ {
+			var unit []byte
+			if b.contentRef == contentJSON {
+				unit, err = d.ReadVarUint8Array()
+			} else {
+				unit, err = d.ReadAnyRaw()
+			}
+			if err != nil {
+				return err
+			}
+			b.units = append(b.units, unit)
+		}
+		b.length = n
+		return nil
+
+	case contentString:
+		s, err := d.ReadVarString()
+		if err != nil {
+			return err
+		}
+		b.str = utf16.Encode([]rune(s))
+		b.length = uint64(len(b.str))
+		return nil
+
+	case contentBinary, contentEmbed:
+		if _, err := d.ReadVarUint8Array(); err != nil {
+			return err
+		}
+	case contentFormat:
+		if _, err := d.ReadVarUint8Array(); err != nil {
+			return err
+		}
+		if _, err := d.ReadVarUint8Array(); err != nil {
+			return err
+		}
+	case contentType:
+		typeRef, err := d.ReadVarUint()
+		if err != nil {
+			return err
+		}
+		if typeRef == typeRefXMLElement || typeRef == typeRefXMLHook {
+			if _, err := d.ReadVarUint8Array(); err != nil {
+				return err
+			}
+		}
+	case contentDoc:
+		if _, err := d.ReadVarUint8Array(); err != nil {
+			return err
+		}
+		if _, err := d.ReadAnyRaw(); err != nil {
+			return err
+		}
+	default:
+		return ErrMalformed
+	}
+
+	// 不可拆分的内容长度固定为 1
+	b.raw = d.buf[start:d.pos]
+	b.length = 1
+	return nil
+}
+
+// write 写入结构体
+func (b *block) write(e *Encoder) {
+	switch b.kind {
+	case kindGC:
+		e.WriteUint8(structGC)
+		e.WriteVarUint(b.length)
+		return
+	case kindSkip:
+		e.WriteUint8(structSkip)
+		e.WriteVarUint(b.length)
+		return
+	}
+
+	info := b.contentRef & bitsContent
+	if b.origin != nil {
+		info |= bitOrigin
+	}
+	if b.rightOrigin != nil {
+		info |= bitRightOrigin
+	}
+	if b.parentSubBit {
+		info |= bitParentSub
+	}
+	e.WriteUint8(info)
+
+	if b.origin != nil {
+		writeID(e, *b.origin)
+	}
+	if b.rightOrigin != nil {
+		writeID(e, *b.rightOrigin)
+	}
+	if b.origin == nil && b.rightOrigin == nil {
+		if b.parentKey != nil {
+			e.WriteVarUint(1)
+			e.WriteVarString(*b.parentKey)
+		} else {
+			e.WriteVarUint(0)
+			writeID(e, *b.parentID)
+		}
+		if b.parentSub != nil {
+			e.WriteVarString(*b.parentSub)
+		}
+	}
+
+	switch b.contentRef {
+	case contentDeleted:
+		e.WriteVarUint(b.length)
+	case contentJSON:
+		e.WriteVarUint(uint64(len(b.units)))
+		for _, unit := range b.units {
+			e.WriteVarUint8Array(unit)
+		}
+	case contentAny:
+		e.WriteVarUint(uint64(len(b.units)))
+		for _, unit := range b.units {
+			e.WriteBytes(unit)
+		}
+	case contentString:
+		e.WriteVarString(string(utf16.Decode(b.str)))
+	default:
+		e.WriteBytes(b.raw)
+	}
+}
+
+// sliceFrom 返回从 offset 开始的后半部分结构体
+func (b *block) sliceFrom(offset uint64) *block {
+	if offset == 0 {
+		return b
+	}
+
+	result := *b
+	result.id = ID{Client: b.id.Client, Clock: b.id.Clock + offset}
+	result.length = b.length - offset
+
+	if b.kind != kindItem {
+		return &result
+	}
+
+	// 拆分后的 Item 以前半部分最后一个字符为左原点
+	result.origin = &ID{Client: b.id.Client, Clock: b.id.Clock + offset - 1}
+
+	switch b.contentRef {
+	case contentJSON, contentAny:
+		result.units = b.units[offset:]
+	case contentString:
+		str := append([]uint16(nil), b.str[offset:]...)
+		// 拆开代理对时与 Yjs 一样用替换字符代替
+		if utf16.IsSurrogate(rune(b.str[offset-1])) && b.str[offset-1] < 0xdc00 {
+			str[0] = 0xfffd
+		}
+		result.str = str
+	}
+	return &result
+}
+
+// mergeBlocks 合并同一客户端的结构体：按时钟排序、去除重复部分、用 Skip 填补空缺
+func mergeBlocks(blocks []*block) []*block {
+	items := make([]*block, 0, len(blocks))
+	for _, b := range blocks {
+		if b.kind != kindSkip {
+			items = append(items, b)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].id.Clock != items[j].id.Clock {
+			return items[i].id.Clock < items[j].id.Clock
+		}
+		return items[i].length > items[j].length
+	})
+
+	var result []*block
+	var next uint64
+	for _, b := range items {
+		if len(result) > 0 {
+			if b.id.Clock+b.length <= next {
+				continue
+			}
+			if b.id.Clock < next {
+				b = b.sliceFrom(next - b.id.Clock)
+			} else if b.id.Clock > next {
+				result = append(result, &block{
+					kind:   kindSkip,
+					id:     ID{Client: b.id.Client, Clock: next},
+					length: b.id.Clock - next,
+				})
+			}
+		}
+		result = append(result, b)
+		next = b.id.Clock + b.length
+	}
+	return result
+}
+
+// readDeleteSet 读取删除集合
+func readDeleteSet(d *Decoder, ds map[uint64][]deleteRange) error {
+	numClients, err := d.ReadVarUint()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < numClients; i++ {
+		client, err := d.ReadVarUint()
+		if err != nil {
+			return err
+		}
+		numRanges, err := d.ReadVarUint()
+		if err != nil {
+			return err
+		}
+		if numRanges > uint64(len(d.buf)) {
+			return ErrMalformed
+		}
+		for j := uint64(0); j < numRanges; j++ {
+			clock, err := d.ReadVarUint()
+			if err != nil {
+				return err
+			}
+			length, err := d.ReadVarUint()
+			if err != nil {
+				return err
+			}
+			ds[client] = append(ds[client], deleteRange{clock: clock, length: length})
+		}
+	}
+	return nil
+}
+
+// writeDeleteSet 写入删除集合
+func writeDeleteSet(e *Encoder, ds map[uint64][]deleteRange) {
+	clients := make([]uint64, 0, len(ds))
+	for client, ranges := range ds {
+		if len(ranges) > 0 {
+			clients = append(clients, client)
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] < clients[j] })
+
+	e.WriteVarUint(uint64(len(clients)))
+	for _, client := range clients {
+		ranges := ds[client]
+		e.WriteVarUint(client)
+		e.WriteVarUint(uint64(len(ranges)))
+		for _, r := range ranges {
+			e.WriteVarUint(r.clock)
+			e.WriteVarUint(r.length)
+		}
+	}
+}
+
+// mergeDeleteRanges 合并重叠或相邻的删除范围
+func mergeDeleteRanges(ranges []deleteRange) []deleteRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].clock < ranges[j].clock })
+
+	var result []deleteRange
+	for _, r := range ranges {
+		if r.length == 0 {
+			continue
+		}
+		if n := len(result); n > 0 && result[n-1].clock+result[n-1].length >= r.clock {
+			end := max(result[n-1].clock+result[n-1].length, r.clock+r.length)
+			result[n-1].length = end - result[n-1].clock
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// readID 读取结构体标识
+func readID(d *Decoder) (*ID, error) {
+	client, err := d.ReadVarUint()
+	if err != nil {
+		return nil, err
+	}
+	clock, err := d.ReadVarUint()
+	if err != nil {
+		return nil, err
+	}
+	return &ID{Client: client, Clock: clock}, nil
+}
+
+// writeID 写入结构体标识
+func writeID(e *Encoder, id ID) {
+	e.WriteVarUint(id.Client)
+	e.WriteVarUint(id.Clock)
+}
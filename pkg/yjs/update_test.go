@@ -0,0 +1,118 @@
+package yjs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 以下更新按 Yjs v1 编码手工构造：
+// insertHello: 客户端 1 在根类型 "t" 中插入 "hello"
+// insertWorld: 客户端 1 在 "hello" 之后插入 " world"
+// deleteH:     删除客户端 1 的第一个字符
+// insertBang:  客户端 1 在时钟 11 插入 "!"，依赖尚未收到的 " world"
+var (
+	insertHello = []byte{1, 1, 1, 0, 0x04, 1, 1, 't', 5, 'h', 'e', 'l', 'l', 'o', 0}
+	insertWorld = []byte{1, 1, 1, 5, 0x84, 1, 4, 6, ' ', 'w', 'o', 'r', 'l', 'd', 0}
+	deleteH     = []byte{0, 1, 1, 1, 0, 1}
+	insertBang  = []byte{1, 1, 1, 11, 0x84, 1, 10, 1, '!', 0}
+)
+
+func TestMergeUpdates(t *testing.T) {
+	merged, err := MergeUpdates(insertHello, insertWorld, deleteH)
+	require.NoError(t, err)
+
+	expected := []byte{
+		1, 2, 1, 0,
+		0x04, 1, 1, 't', 5, 'h', 'e', 'l', 'l', 'o',
+		0x84, 1, 4, 6, ' ', 'w', 'o', 'r', 'l', 'd',
+		1, 1, 1, 0, 1,
+	}
+	assert.Equal(t, expected, merged)
+
+	// 合并顺序和重复合并不影响结果
+	again, err := MergeUpdates(deleteH, insertWorld, merged, insertHello)
+	require.NoError(t, err)
+	assert.Equal(t, merged, again)
+
+	sv, err := EncodeStateVectorFromUpdate(merged)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1, 11}, sv)
+}
+
+func TestDiffUpdateSlicesItems(t *testing.T) {
+	merged, err := MergeUpdates(insertHello, insertWorld, deleteH)
+	require.NoError(t, err)
+
+	diff, err := DiffUpdate(merged, EncodeStateVector(map[uint64]uint64{1: 3}))
+	require.NoError(t, err)
+
+	expected := []byte{
+		1, 2, 1, 3,
+		0x84, 1, 2, 2, 'l', 'o',
+		0x84, 1, 4, 6, ' ', 'w', 'o', 'r', 'l', 'd',
+		1, 1, 1, 0, 1,
+	}
+	assert.Equal(t, expected, diff)
+
+	// 已全部包含时只返回删除集合
+	diff, err = DiffUpdate(merged, EncodeStateVector(map[uint64]uint64{1: 11}))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 1, 1, 0, 1}, diff)
+}
+
+func TestMergeUpdatesWithMissingDependency(t *testing.T) {
+	merged, err := MergeUpdates(insertHello, insertBang)
+	require.NoError(t, err)
+
+	// 缺失的时钟范围用 Skip 占位，状态向量只统计连续部分
+	expected := []byte{
+		1, 3, 1, 0,
+		0x04, 1, 1, 't', 5, 'h', 'e', 'l', 'l', 'o',
+		10, 6,
+		0x84, 1, 10, 1, '!',
+		0,
+	}
+	assert.Equal(t, expected, merged)
+
+	sv, err := EncodeStateVectorFromUpdate(merged)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1, 5}, sv)
+
+	// 补齐后 Skip 被实际内容替换
+	complete, err := MergeUpdates(merged, insertWorld)
+	require.NoError(t, err)
+	sv, err = EncodeStateVectorFromUpdate(complete)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1, 12}, sv)
+
+	// 差异的第一个结构体不能是 Skip
+	diff, err := DiffUpdate(merged, EncodeStateVector(map[uint64]uint64{1: 5}))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1, 1, 11, 0x84, 1, 10, 1, '!', 0}, diff)
+}
+
+func TestDecodeUpdateRejectsMalformed(t *testing.T) {
+	_, err := DecodeUpdate(insertHello[:len(insertHello)-3])
+	assert.Error(t, err)
+
+	_, err = DecodeUpdate(append(append([]byte{}, insertHello...), 0))
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestSyncMessages(t *testing.T) {
+	msg, err := DecodeMessage(EncodeSyncStep1([]byte{1, 1, 5}))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(MessageSync), msg.Type)
+	assert.Equal(t, uint64(SyncStep1), msg.SyncType)
+	assert.Equal(t, []byte{1, 1, 5}, msg.Payload)
+
+	msg, err = DecodeMessage(EncodeSyncUpdate(insertHello))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(SyncUpdate), msg.SyncType)
+	assert.Equal(t, insertHello, msg.Payload)
+
+	_, err = DecodeMessage([]byte{9})
+	assert.ErrorIs(t, err, ErrMalformed)
+}
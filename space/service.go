@@ -13,6 +13,8 @@ type spaceService struct {
 	userRepo         domain.UserRepository
 	organizationRepo domain.OrganizationRepository
 	documentRepo     domain.DocumentRepository
+	search           domain.DocumentSearchSyncer // 搜索索引同步（可为空）
+	titles           domain.DocumentTitleSyncer  // 快速切换标题索引失效（可为空）
 	contextTimeout   time.Duration
 }
 
@@ -22,6 +24,8 @@ func NewSpaceService(
 	userRepo domain.UserRepository,
 	organizationRepo domain.OrganizationRepository,
 	documentRepo domain.DocumentRepository,
+	search domain.DocumentSearchSyncer,
+	titles domain.DocumentTitleSyncer,
 	timeout time.Duration,
 ) domain.SpaceUsecase {
 	return &spaceService{
@@ -29,6 +33,8 @@ func NewSpaceService(
 		userRepo:         userRepo,
 		organizationRepo: organizationRepo,
 		documentRepo:     documentRepo,
+		search:           search,
+		titles:           titles,
 		contextTimeout:   timeout,
 	}
 }
@@ -167,6 +173,12 @@ func (s *spaceService) UpdateSpace(ctx context.Context, para domain.UpdateSpaceP
 	if para.IsPublic != nil {
 		space.IsPublic = *para.IsPublic
 	}
+	if para.VersionRetentionDays != nil {
+		space.VersionRetentionDays = *para.VersionRetentionDays
+	}
+	if para.VersionRetentionCount != nil {
+		space.VersionRetentionCount = *para.VersionRetentionCount
+	}
 
 	// 验证更新后的空间
 	if err := space.Validate(); err != nil {
@@ -203,7 +215,22 @@ func (s *spaceService) DeleteSpace(ctx context.Context, userID, spaceID int64) e
 
 	// 软删除空间
 	space.Status = domain.SpaceStatusDeleted
-	return s.spaceRepo.Update(ctx, space)
+	if err := s.spaceRepo.Update(ctx, space); err != nil {
+		return err
+	}
+
+	// 成员不再能通过空间看到其中的文档
+	if s.titles != nil {
+		members, err := s.spaceRepo.GetMembers(ctx, spaceID)
+		if err == nil {
+			userIDs := make([]int64, 0, len(members))
+			for _, member := range members {
+				userIDs = append(userIDs, member.UserID)
+			}
+			s.invalidateTitles(ctx, userIDs...)
+		}
+	}
+	return nil
 }
 
 // GetMySpaces 获取用户的空间列表
@@ -302,7 +329,11 @@ func (s *spaceService) AddSpaceMember(ctx context.Context, userID, spaceID, memb
 		return err
 	}
 
-	return s.spaceRepo.AddMember(ctx, member)
+	if err := s.spaceRepo.AddMember(ctx, member); err != nil {
+		return err
+	}
+	s.invalidateTitles(ctx, memberUserID)
+	return nil
 }
 
 // UpdateMemberRole 更新成员角色
@@ -368,7 +399,11 @@ func (s *spaceService) RemoveSpaceMember(ctx context.Context, userID, spaceID, m
 		return domain.ErrPermissionDenied
 	}
 
-	return s.spaceRepo.RemoveMember(ctx, spaceID, memberUserID)
+	if err := s.spaceRepo.RemoveMember(ctx, spaceID, memberUserID); err != nil {
+		return err
+	}
+	s.invalidateTitles(ctx, memberUserID)
+	return nil
 }
 
 // GetSpaceMembers 获取空间成员列表
@@ -427,7 +462,11 @@ func (s *spaceService) AddDocumentToSpace(ctx context.Context, userID, spaceID,
 		AddedBy:    userID,
 	}
 
-	return s.spaceRepo.AddDocument(ctx, spaceDocument)
+	if err := s.spaceRepo.AddDocument(ctx, spaceDocument); err != nil {
+		return err
+	}
+	s.syncSearch(ctx, documentID)
+	return nil
 }
 
 // RemoveDocumentFromSpace 从空间移除文档
@@ -453,7 +492,11 @@ func (s *spaceService) RemoveDocumentFromSpace(ctx context.Context, userID, spac
 		return domain.ErrDocumentNotFound
 	}
 
-	return s.spaceRepo.RemoveDocument(ctx, spaceID, documentID)
+	if err := s.spaceRepo.RemoveDocument(ctx, spaceID, documentID); err != nil {
+		return err
+	}
+	s.syncSearch(ctx, documentID)
+	return nil
 }
 
 // GetSpaceDocuments 获取空间文档列表
@@ -570,3 +613,19 @@ func (s *spaceService) checkSpaceManagePermission(ctx context.Context, userID, s
 
 	return member.CanManageMembers(), nil
 }
+
+// syncSearch 文档加入或移出空间后同步文档的搜索索引，未配置搜索索引时忽略
+func (s *spaceService) syncSearch(ctx context.Context, documentID int64) {
+	if s.search == nil {
+		return
+	}
+	s.search.SyncDocuments(ctx, documentID)
+}
+
+// invalidateTitles 空间成员变化后使用户的快速切换标题索引失效，未配置时忽略
+func (s *spaceService) invalidateTitles(ctx context.Context, userIDs ...int64) {
+	if s.titles == nil || len(userIDs) == 0 {
+		return
+	}
+	s.titles.InvalidateUsers(ctx, userIDs...)
+}